@@ -0,0 +1,408 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"compress/bzip2"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// архив вместо директории: zip (в том числе Zip64, больше 4GB), tar (GNU и PAX, с длинными именами и разреженными файлами)
+// и tar, сжатый gzip или bzip2; никуда не распаковывается, файлы читаются прямо из архива:
+// у zip и несжатого tar — с нужного места, у сжатого tar — проходом по потоку вперёд
+// (поэтому большой сжатый tar обрабатывается заметно медленнее, чем zip или просто tar)
+// символьные и жёсткие ссылки разрешаются внутри архива; ссылки наружу и на директории не читаются,
+// как и ссылки на директории на диске
+
+// archiveExts — расширения архивов, которые можно передать вместо директории
+var archiveExts = []string{".tar.gz", ".tgz", ".tar.bz2", ".tbz2", ".tar", ".zip"}
+
+// archiveRoot возвращает имя корня для дампа архива (имя файла без расширения) или "", если это не архив
+func archiveRoot(name string) string {
+	base := path.Base(strings.ReplaceAll(name, `\`, "/"))
+	for _, ext := range archiveExts {
+		if len(base) > len(ext) && strings.EqualFold(base[len(base)-len(ext):], ext) {
+			return base[:len(base)-len(ext)]
+		}
+	}
+	return ""
+}
+
+// archiveEntry — файл или ссылка в архиве
+type archiveEntry struct {
+	mode  fs.FileMode
+	size  int64
+	mtime time.Time
+	link  string // цель символьной ссылки (от директории ссылки) или жёсткой (от корня архива)
+	hard  bool
+	open  func() (io.ReadCloser, error)
+}
+
+// archiveFS — fs.FS с содержимым архива
+type archiveFS struct {
+	files  map[string]archiveEntry
+	dirs   map[string][]string // имена детей каждой директории (корень — ".")
+	mtimes map[string]time.Time
+	closer io.Closer
+	rooted bool // образ контейнера: абсолютные ссылки ведут от корня архива, как в chroot
+}
+
+// openArchive читает оглавление архива
+func openArchive(name string) (*archiveFS, error) {
+	a := &archiveFS{files: make(map[string]archiveEntry), dirs: map[string][]string{".": nil}, mtimes: make(map[string]time.Time)}
+	var err error
+	if strings.EqualFold(path.Ext(name), ".zip") {
+		err = a.indexZip(name)
+	} else {
+		err = a.indexTar(name)
+	}
+	if err != nil {
+		a.Close()
+		return nil, err
+	}
+	for _, names := range a.dirs {
+		sort.Strings(names)
+	}
+	return a, nil
+}
+
+// Close закрывает файл архива
+func (a *archiveFS) Close() error {
+	if a.closer == nil {
+		return nil
+	}
+	return a.closer.Close()
+}
+
+// cleanArchivePath приводит имя из архива к виду fs.FS ("./a/b/" → "a/b"); имена, выходящие за корень (../), отбрасываются
+func cleanArchivePath(name string) (string, bool) {
+	name = path.Clean(strings.TrimLeft(name, "/"))
+	return name, name != "." && fs.ValidPath(name)
+}
+
+// add добавляет путь и недостающие директории над ним
+func (a *archiveFS) add(name string, entry archiveEntry, isDir bool) {
+	if isDir {
+		a.mtimes[name] = entry.mtime
+		if _, ok := a.dirs[name]; ok {
+			return
+		}
+		a.dirs[name] = nil
+	} else {
+		// в tar один и тот же путь может встретиться дважды (дописанный архив), действует последняя запись
+		_, dup := a.files[name]
+		a.files[name] = entry
+		if dup {
+			return
+		}
+	}
+	for child, dir := name, path.Dir(name); ; child, dir = dir, path.Dir(dir) {
+		_, known := a.dirs[dir]
+		a.dirs[dir] = append(a.dirs[dir], path.Base(child))
+		if known {
+			break
+		}
+	}
+}
+
+// indexZip читает центральный каталог zip; archive/zip сам понимает Zip64
+func (a *archiveFS) indexZip(name string) error {
+	zr, err := zip.OpenReader(name)
+	if err != nil {
+		return err
+	}
+	a.closer = zr
+	for _, f := range zr.File {
+		clean, ok := cleanArchivePath(f.Name)
+		if !ok {
+			continue
+		}
+		entry := archiveEntry{mode: f.Mode(), size: int64(f.UncompressedSize64), mtime: f.Modified, open: f.Open}
+		if entry.mode&fs.ModeSymlink != 0 {
+			// цель ссылки в zip хранится как содержимое записи
+			if entry.link, err = readLink(f.Open); err != nil {
+				return fmt.Errorf("%s: %w", f.Name, err)
+			}
+		}
+		a.add(clean, entry, f.FileInfo().IsDir())
+	}
+	return nil
+}
+
+// readLink читает цель символьной ссылки из содержимого записи
+func readLink(open func() (io.ReadCloser, error)) (string, error) {
+	r, err := open()
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+	target, err := io.ReadAll(io.LimitReader(r, 4096))
+	return string(target), err
+}
+
+// indexTar проходит tar один раз и запоминает, где лежит каждая запись
+func (a *archiveFS) indexTar(name string) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	a.closer = f
+	compressed, err := isCompressed(f)
+	if err != nil {
+		return err
+	}
+	// у несжатого tar содержимое записи — непрерывный кусок файла, его читаем напрямую;
+	// остальное (сжатый поток, разреженные файлы) — через tarStream
+	stream := &tarStream{name: name}
+	var r io.Reader = f
+	if compressed {
+		if r, err = decompress(f); err != nil {
+			return err
+		}
+	}
+	tr := tar.NewReader(r)
+	for index := 0; ; index++ {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		clean, ok := cleanArchivePath(hdr.Name)
+		if !ok {
+			continue
+		}
+		entry := archiveEntry{mode: hdr.FileInfo().Mode(), size: hdr.Size, mtime: hdr.ModTime}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			a.add(clean, entry, true)
+			continue
+		case tar.TypeSymlink:
+			entry.link = hdr.Linkname
+		case tar.TypeLink:
+			if entry.link, ok = cleanArchivePath(hdr.Linkname); !ok {
+				continue
+			}
+			entry.hard = true
+			entry.mode = 0o644
+		case tar.TypeReg, tar.TypeGNUSparse:
+			index := index
+			entry.open = func() (io.ReadCloser, error) { return stream.open(index) }
+			if !compressed && !isSparse(hdr) {
+				offset, err := f.Seek(0, io.SeekCurrent)
+				if err != nil {
+					return err
+				}
+				entry.open = func() (io.ReadCloser, error) {
+					return io.NopCloser(io.NewSectionReader(f, offset, hdr.Size)), nil
+				}
+			}
+		default:
+			// устройства и каналы из архива показываем, но не читаем (как special на диске)
+		}
+		a.add(clean, entry, false)
+	}
+}
+
+// isSparse сообщает, разреженный ли файл в tar: его содержимое в архиве не лежит одним куском
+func isSparse(hdr *tar.Header) bool {
+	if hdr.Typeflag == tar.TypeGNUSparse {
+		return true
+	}
+	for key := range hdr.PAXRecords {
+		if strings.HasPrefix(key, "GNU.sparse.") {
+			return true
+		}
+	}
+	return false
+}
+
+// isCompressed смотрит на сигнатуру gzip или bzip2 в начале файла и возвращается в начало
+func isCompressed(f *os.File) (bool, error) {
+	magic := make([]byte, 3)
+	n, err := io.ReadFull(f, magic)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return false, err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return false, err
+	}
+	magic = magic[:n]
+	return strings.HasPrefix(string(magic), "\x1f\x8b") || strings.HasPrefix(string(magic), "BZh"), nil
+}
+
+// decompress распаковывает поток gzip или bzip2
+func decompress(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	magic, _ := br.Peek(2)
+	if string(magic) == "\x1f\x8b" {
+		return gzip.NewReader(br)
+	}
+	return bzip2.NewReader(br), nil
+}
+
+// tarStream — проход по tar от начала до нужной записи; пока открытая запись не закрыта, поток занят
+// обход открывает файлы ненадолго и по одному, так что соседние записи читаются одним проходом
+type tarStream struct {
+	name string
+	mu   sync.Mutex
+	f    *os.File
+	tr   *tar.Reader
+	next int // номер записи, которую вернёт следующий tr.Next
+}
+
+// open переходит к записи номер index и отдаёт её содержимое
+func (s *tarStream) open(index int) (io.ReadCloser, error) {
+	s.mu.Lock()
+	if s.tr == nil || s.next > index {
+		if err := s.reset(); err != nil {
+			s.mu.Unlock()
+			return nil, err
+		}
+	}
+	for s.next <= index {
+		if _, err := s.tr.Next(); err != nil {
+			s.tr = nil
+			s.mu.Unlock()
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF // архив поменялся с момента чтения оглавления
+			}
+			return nil, err
+		}
+		s.next++
+	}
+	return &streamEntry{Reader: s.tr, unlock: s.mu.Unlock}, nil
+}
+
+// reset открывает архив заново и встаёт в начало
+func (s *tarStream) reset() error {
+	if s.f != nil {
+		s.f.Close()
+	}
+	f, err := os.Open(s.name)
+	if err != nil {
+		return err
+	}
+	s.f, s.next = f, 0
+	var r io.Reader = f
+	if compressed, err := isCompressed(f); err != nil {
+		return err
+	} else if compressed {
+		if r, err = decompress(f); err != nil {
+			return err
+		}
+	}
+	s.tr = tar.NewReader(r)
+	return nil
+}
+
+// streamEntry — содержимое записи из tarStream; Close освобождает поток
+type streamEntry struct {
+	io.Reader
+	unlock func()
+	once   sync.Once
+}
+
+func (e *streamEntry) Close() error {
+	e.once.Do(e.unlock)
+	return nil
+}
+
+// resolve идёт по ссылкам до файла или директории; ссылки за пределы архива не разрешаются
+func (a *archiveFS) resolve(name string) (string, error) {
+	for range 40 {
+		entry, ok := a.files[name]
+		if !ok || entry.link == "" {
+			return name, nil
+		}
+		target := entry.link
+		if a.rooted && !entry.hard {
+			// выше корня подняться нельзя: /../etc — это /etc
+			if !path.IsAbs(target) {
+				target = path.Join("/", path.Dir(name), target)
+			}
+			if target = strings.TrimPrefix(path.Clean(target), "/"); target == "" {
+				target = "."
+			}
+		} else if !entry.hard {
+			if path.IsAbs(target) {
+				return "", fmt.Errorf("link to %s points outside the archive", target)
+			}
+			target = path.Join(path.Dir(name), target)
+		}
+		if !fs.ValidPath(target) {
+			return "", fmt.Errorf("link to %s points outside the archive", entry.link)
+		}
+		name = target
+	}
+	return "", errors.New("too many levels of links")
+}
+
+func (a *archiveFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	if names, ok := a.dirs[name]; ok {
+		entries := make([]fs.DirEntry, len(names))
+		for i, child := range names {
+			entries[i] = fs.FileInfoToDirEntry(a.stat(path.Join(name, child)))
+		}
+		return &gitDir{info: a.stat(name), entries: entries}, nil
+	}
+	target, err := a.resolve(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	entry, ok := a.files[target]
+	if !ok {
+		if _, ok := a.dirs[target]; ok {
+			err = errors.New("link to a directory is not followed")
+		} else {
+			err = fs.ErrNotExist
+		}
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	if entry.open == nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: errors.New("not a regular file")}
+	}
+	r, err := entry.open()
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	return &archiveFile{ReadCloser: r, info: a.stat(name)}, nil
+}
+
+// stat возвращает сведения о пути из оглавления; ссылка на файл выглядит как сам файл под именем ссылки
+// (типы для сведений и открытых директорий общие с gitFS, см. committed.go)
+func (a *archiveFS) stat(name string) gitStat {
+	if _, ok := a.dirs[name]; ok {
+		return gitStat{name: path.Base(name), mode: fs.ModeDir | 0o755, mtime: a.mtimes[name]}
+	}
+	entry := a.files[name]
+	if target, err := a.resolve(name); err == nil && target != name {
+		if resolved, ok := a.files[target]; ok {
+			entry.mode, entry.size = resolved.mode, resolved.size
+		}
+	}
+	return gitStat{name: path.Base(name), size: entry.size, mode: entry.mode, mtime: entry.mtime}
+}
+
+// archiveFile — открытая запись архива
+type archiveFile struct {
+	io.ReadCloser
+	info gitStat
+}
+
+func (f *archiveFile) Stat() (fs.FileInfo, error) { return f.info, nil }
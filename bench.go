@@ -0,0 +1,213 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/asquebay/directory-serialization/detector"
+	"github.com/asquebay/directory-serialization/internal/benchtree"
+	"github.com/asquebay/directory-serialization/serializer"
+)
+
+// подкоманда bench генерирует синтетические деревья и меряет на них скорость трёх этапов:
+//
+//	walk   — обход с определением типа файлов, без содержимого (--format tree-json)
+//	detect — определение текста и кодировки по началу файлов, в памяти, без диска
+//	dump   — полный текстовый дамп
+//
+// результат можно сохранить (--save) и сравнивать с ним следующие запуски (--baseline): если какой-то этап
+// стал медленнее больше чем на --tolerance процентов, код выхода 1 — так переделку обхода или вывода
+// можно проверить, не держа в голове цифры прошлого раза
+// на время в миллисекундах влияют машина и её загрузка, поэтому сравнивать стоит запуски на одной машине
+// деревья (см. internal/benchtree) те же, что у бенчмарков пакета serializer (go test -bench . ./serializer)
+
+// benchResult — замер одного этапа на одном дереве
+type benchResult struct {
+	Tree  string  `json:"tree"`
+	Stage string  `json:"stage"`
+	Files int     `json:"files"`
+	Bytes int64   `json:"bytes"`
+	Ms    float64 `json:"ms"` // лучшее время из --runs запусков
+}
+
+// benchReport — файл --save и --baseline
+type benchReport struct {
+	Scale   float64       `json:"scale"`
+	Results []benchResult `json:"results"`
+}
+
+// runBench реализует подкоманду bench
+// возвращает код выхода: 0 — замеры сделаны (и не хуже --baseline), 1 — есть регрессия, 2 — ошибка
+func runBench(args []string) int {
+	fs := flag.NewFlagSet("bench", flag.ExitOnError)
+	scale := fs.Float64("scale", 1, "grow or shrink the synthetic trees by this `factor`")
+	runs := fs.Int("runs", 3, "measure each stage this many `times` and keep the best")
+	dir := fs.String("dir", "", "generate the trees in `directory` (default: a temporary directory, removed afterwards)")
+	save := fs.String("save", "", "write the results to `file` (JSON) to compare later runs with --baseline")
+	baseline := fs.String("baseline", "", "compare with results saved by --save in `file`; exit 1 on a regression")
+	tolerance := fs.Float64("tolerance", 20, "with --baseline, how many `percent` slower a stage may get before it counts as a regression")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: directory-serialization bench [flags]\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 0 || *scale <= 0 || *runs < 1 || *tolerance < 0 {
+		fs.Usage()
+		return 2
+	}
+
+	var base *benchReport
+	if *baseline != "" {
+		data, err := os.ReadFile(*baseline)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading baseline %s: %v\n", *baseline, err)
+			return 2
+		}
+		base = &benchReport{}
+		if err := json.Unmarshal(data, base); err != nil {
+			fmt.Fprintf(os.Stderr, "Error parsing baseline %s: %v\n", *baseline, err)
+			return 2
+		}
+		if base.Scale != *scale {
+			fmt.Fprintf(os.Stderr, "Warning: baseline was measured with --scale %g, not %g\n", base.Scale, *scale)
+		}
+	}
+
+	root := *dir
+	if root == "" {
+		tmp, err := os.MkdirTemp("", "directory-serialization-bench-*")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error creating temporary directory: %v\n", err)
+			return 2
+		}
+		defer os.RemoveAll(tmp)
+		root = tmp
+	}
+
+	report := benchReport{Scale: *scale}
+	fmt.Printf("%-6s %-7s %8s %10s %10s %12s %10s\n", "TREE", "STAGE", "FILES", "MB", "MS", "FILES/S", "MB/S")
+	for _, tree := range benchtree.Trees {
+		treeRoot := filepath.Join(root, tree.Name)
+		fmt.Fprintf(os.Stderr, "Generating %s: %s\n", tree.Name, tree.Desc)
+		if err := os.RemoveAll(treeRoot); err != nil {
+			fmt.Fprintf(os.Stderr, "Error removing %s: %v\n", treeRoot, err)
+			return 2
+		}
+		if err := tree.Make(treeRoot, *scale); err != nil {
+			fmt.Fprintf(os.Stderr, "Error generating %s: %v\n", tree.Name, err)
+			return 2
+		}
+		for _, stage := range []string{"walk", "detect", "dump"} {
+			r, err := benchStage(treeRoot, stage, *runs)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Error measuring %s/%s: %v\n", tree.Name, stage, err)
+				return 2
+			}
+			r.Tree = tree.Name
+			report.Results = append(report.Results, r)
+			mb := float64(r.Bytes) / (1 << 20)
+			fmt.Printf("%-6s %-7s %8d %10.1f %10.1f %12.0f %10.1f\n", r.Tree, r.Stage, r.Files, mb, r.Ms,
+				float64(r.Files)/(r.Ms/1000), mb/(r.Ms/1000))
+		}
+	}
+
+	if *save != "" {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err == nil {
+			err = os.WriteFile(*save, append(data, '\n'), 0o644)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing %s: %v\n", *save, err)
+			return 2
+		}
+	}
+	if base != nil && benchRegressions(os.Stdout, *base, report, *tolerance) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// benchStage меряет этап stage на дереве root: лучшее время из runs запусков
+func benchStage(root, stage string, runs int) (benchResult, error) {
+	r := benchResult{Stage: stage}
+	// файлы и байты считаются по диску, а для detect начала файлов читаются заранее:
+	// меряется только определение, а не диск
+	var samples [][]byte
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		r.Files++
+		r.Bytes += int64(len(data))
+		if stage == "detect" {
+			samples = append(samples, data[:min(len(data), detector.SampleSize)])
+		}
+		return nil
+	})
+	if err != nil {
+		return r, err
+	}
+	best := time.Duration(-1)
+	for range runs {
+		start := time.Now()
+		switch stage {
+		case "detect":
+			for _, sample := range samples {
+				detector.IsText(sample)
+				detector.EncodingDetector(sample, detector.None)
+			}
+		default:
+			opts := serializer.Options{Log: io.Discard}
+			if stage == "walk" {
+				opts.Format = serializer.FormatTreeJSON
+			}
+			if _, err := serializer.Run(io.Discard, root, opts); err != nil {
+				return r, err
+			}
+		}
+		if d := time.Since(start); best < 0 || d < best {
+			best = d
+		}
+	}
+	r.Ms = float64(best.Microseconds()) / 1000
+	return r, nil
+}
+
+// benchRegressions печатает сравнение с base и возвращает число этапов, ставших медленнее
+// больше чем на tolerance процентов (и хотя бы на миллисекунду)
+func benchRegressions(out io.Writer, base, cur benchReport, tolerance float64) int {
+	prev := make(map[string]float64, len(base.Results))
+	for _, r := range base.Results {
+		prev[r.Tree+"/"+r.Stage] = r.Ms
+	}
+	regressions := 0
+	fmt.Fprintln(out)
+	for _, r := range cur.Results {
+		was, ok := prev[r.Tree+"/"+r.Stage]
+		if !ok || was <= 0 {
+			continue
+		}
+		change := (r.Ms - was) / was * 100
+		verdict := "ok"
+		// разница меньше миллисекунды — шум таймера и планировщика, а не регрессия
+		if change > tolerance && r.Ms-was >= 1 {
+			verdict = "REGRESSION"
+			regressions++
+		}
+		fmt.Fprintf(out, "%-6s %-7s %10.1f -> %10.1f ms %+7.1f%%  %s\n", r.Tree, r.Stage, was, r.Ms, change, verdict)
+	}
+	if regressions > 0 {
+		fmt.Fprintf(out, "%d stage(s) slower than the baseline by more than %g%%\n", regressions, tolerance)
+	}
+	return regressions
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"runtime/debug"
+	"slices"
+
+	"github.com/asquebay/directory-serialization/detector"
+	"github.com/asquebay/directory-serialization/format"
+	"github.com/asquebay/directory-serialization/serializer"
+)
+
+// --capabilities печатает JSON с тем, что умеет эта сборка: форматы, подкоманды, фильтры, допустимые значения
+// флагов, языковые группы детектора и версию раскладки дампа, — чтобы обёртки проверяли возможности,
+// а не разбирали текст --help, который меняется от версии к версии
+// поля только добавляются: обёртке достаточно не спотыкаться о незнакомые
+
+// subcommandNames — подкоманды из main; при добавлении подкоманды её нужно дописать и сюда
+var subcommandNames = []string{"verify", "diff", "view", "merge", "hook", "review", "restore-xattrs", "restore", "bench"}
+
+// filterFlags — флаги, которые отбирают пути и файлы
+var filterFlags = []string{
+	"exclude", "exclude-from", "include", "full-tree", "ignore-case", "gitignore", "no-gitignore", "no-default-excludes",
+	"newer-than", "changed-within", "owned-by-me", "min-perms", "only-class", "tests", "filter", "content-match", "keep-minified",
+}
+
+// capabilities — документ --capabilities
+type capabilities struct {
+	Version        string              `json:"version"`        // версия модуля из сведений о сборке ("(devel)" без неё)
+	FormatVersion  int                 `json:"format_version"` // format.Version: версия раскладки текстового дампа
+	ManifestSchema string              `json:"manifest_schema"`
+	Formats        []string            `json:"formats"` // значения --format
+	Outputs        []string            `json:"outputs"` // префиксы --output "формат:путь"
+	Subcommands    []string            `json:"subcommands"`
+	Filters        []string            `json:"filters"`
+	Values         map[string][]string `json:"values"` // допустимые значения флагов с фиксированным набором
+	Detector       capabilityDetector  `json:"detector"`
+	Flags          []capabilityFlag    `json:"flags"`
+}
+
+type capabilityDetector struct {
+	Scripts []string `json:"scripts"` // языковые группы эвристик кодировки
+}
+
+type capabilityFlag struct {
+	Name    string `json:"name"`
+	Arg     string `json:"arg,omitempty"` // имя значения из справки (у булевых флагов пусто)
+	Bool    bool   `json:"bool,omitempty"`
+	Default string `json:"default,omitempty"`
+	Usage   string `json:"usage"`
+}
+
+// printCapabilities печатает документ --capabilities для флагов fs
+func printCapabilities(fs *flag.FlagSet) error {
+	c := capabilities{
+		Version:       "(devel)",
+		FormatVersion: format.Version,
+		Formats:       slices.DeleteFunc(slices.Clone(outputFormats), func(f string) bool { return f == formatManifest }),
+		Outputs:       slices.Clone(outputFormats),
+		Subcommands:   subcommandNames,
+		Filters:       filterFlags,
+		Values: map[string][]string{
+			"content-encoding": {serializer.ContentRaw, serializer.ContentEscaped, serializer.ContentBase64},
+			"detect-sampling":  {detector.SampleHead, detector.SampleSpread},
+			"go-doc":           {serializer.GoDocAdd, serializer.GoDocOnly},
+			"group-by":         {serializer.GroupByDir, serializer.GroupByExt, serializer.GroupByLang},
+			"hash-algo":        serializer.HashAlgos,
+			"mtime-format":     {serializer.MtimeISO8601, serializer.MtimeUnix},
+			"normalize-names":  {serializer.NormalizeNFC, serializer.NormalizeNFD, serializer.NormalizeKeep},
+			"only-class":       serializer.Classes,
+			"tests":            {serializer.TestsInclude, serializer.TestsExclude, serializer.TestsOnly},
+		},
+	}
+	// без драйвера SQLite (платформа, под которую он не собирается) формата sqlite нет
+	if !sqliteAvailable() {
+		noSQLite := func(f string) bool { return f == serializer.FormatSQLite }
+		c.Formats, c.Outputs = slices.DeleteFunc(c.Formats, noSQLite), slices.DeleteFunc(c.Outputs, noSQLite)
+	}
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" {
+		c.Version = info.Main.Version
+	}
+	var schema struct {
+		ID string `json:"$id"`
+	}
+	if err := json.Unmarshal(serializer.ManifestSchema, &schema); err == nil {
+		c.ManifestSchema = schema.ID
+	}
+	for _, s := range detector.Scripts() {
+		c.Detector.Scripts = append(c.Detector.Scripts, s.String())
+	}
+	fs.VisitAll(func(f *flag.Flag) {
+		arg, usage := flag.UnquoteUsage(f)
+		isBool := false
+		if b, ok := f.Value.(interface{ IsBoolFlag() bool }); ok && b.IsBoolFlag() {
+			isBool, arg = true, ""
+		}
+		c.Flags = append(c.Flags, capabilityFlag{Name: f.Name, Arg: arg, Bool: isBool, Default: f.DefValue, Usage: usage})
+	})
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	return enc.Encode(c)
+}
@@ -0,0 +1,317 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// --as-committed: файлы читаются не из рабочего дерева, а из git — из коммита или из индекса
+// так дамп показывает канонические блобы (LF вместо CRLF от core.autocrlf, без smudge-фильтров)
+// и не цепляет незакоммиченный мусор: неотслеживаемых файлов в нём нет вовсе
+// блобы читаются одним процессом git cat-file --batch, а не процессом на файл
+// читает их установленный git, а не встроенная библиотека: его разбор ревизий (v1.2~3, @{upstream}), индекса
+// и его расширений, sparse checkout, worktree, фильтров и атрибутов совпадает с тем, что видит пользователь;
+// к тому же ownership, review и hook уже вызывают git, так что он и так нужен
+
+// indexRevision — значение --as-committed, означающее индекс (staged-содержимое) вместо коммита
+const indexRevision = "index"
+
+// revisionFlag — флаг, который можно указать и без значения (--as-committed — это HEAD), и со значением
+type revisionFlag struct{ rev *string }
+
+func (f revisionFlag) String() string {
+	if f.rev == nil {
+		return ""
+	}
+	return *f.rev
+}
+
+func (f revisionFlag) Set(s string) error {
+	switch s {
+	case "true":
+		s = "HEAD"
+	case "false":
+		s = ""
+	}
+	*f.rev = s
+	return nil
+}
+
+func (f revisionFlag) IsBoolFlag() bool { return true }
+
+// gitBlob — файл из git
+type gitBlob struct {
+	oid   string
+	size  int64
+	mode  fs.FileMode
+	mtime time.Time
+}
+
+// gitFS — fs.FS с содержимым директории из коммита или индекса
+type gitFS struct {
+	files map[string]gitBlob
+	dirs  map[string][]string // имена детей каждой директории (корень — "."); подмодули — пустые директории
+
+	mu    sync.Mutex // cat-file отвечает по одному запросу за раз
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+	out   *bufio.Reader
+}
+
+// openGitFS перечисляет файлы директории dir (она может быть и подкаталогом репозитория) в ревизии rev
+func openGitFS(dir, rev string) (*gitFS, error) {
+	if _, err := exec.LookPath("git"); err != nil {
+		return nil, fmt.Errorf("git is required: %w", err)
+	}
+	if _, err := git(dir, "rev-parse", "--show-toplevel"); err != nil {
+		return nil, fmt.Errorf("not inside a git repository: %w", err)
+	}
+	fsys := &gitFS{files: make(map[string]gitBlob), dirs: map[string][]string{".": nil}}
+
+	// ls-tree и ls-files, запущенные в подкаталоге, выдают только его содержимое и пути относительно него
+	var listing string
+	var commitTime time.Time
+	var err error
+	if rev == indexRevision {
+		listing, err = git(dir, "ls-files", "--stage", "-z")
+	} else {
+		var ct string
+		if ct, err = git(dir, "log", "-1", "--format=%ct", rev, "--"); err != nil {
+			return nil, err
+		}
+		sec, _ := strconv.ParseInt(strings.TrimSpace(ct), 10, 64)
+		commitTime = time.Unix(sec, 0)
+		listing, err = git(dir, "ls-tree", "-r", "-z", rev)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var oids []string
+	for _, record := range strings.Split(listing, "\x00") {
+		// ls-tree: "<mode> <type> <oid>\t<path>", ls-files --stage: "<mode> <oid> <stage>\t<path>"
+		meta, name, ok := strings.Cut(record, "\t")
+		fields := strings.Fields(meta)
+		if !ok || len(fields) != 3 || !fs.ValidPath(name) {
+			continue
+		}
+		mode, oid := fields[0], fields[2]
+		if rev == indexRevision {
+			oid = fields[1]
+			// при конфликте слияния нулевой стадии нет, берём «нашу» версию
+			if stage := fields[2]; stage != "0" && stage != "2" {
+				continue
+			}
+		}
+		if mode == "160000" {
+			// подмодуль: в коммите это ссылка на чужой коммит, показываем пустой директорией
+			fsys.add(name, true)
+			continue
+		}
+		if _, dup := fsys.files[name]; dup {
+			continue
+		}
+		blob := gitBlob{oid: oid, mode: 0o644, mtime: commitTime}
+		if mode == "100755" {
+			blob.mode = 0o755
+		}
+		if rev == indexRevision {
+			// у индекса нет времени коммита, берём время изменения файла в рабочем дереве
+			if info, err := os.Lstat(filepath.Join(dir, filepath.FromSlash(name))); err == nil {
+				blob.mtime = info.ModTime()
+			}
+		}
+		fsys.files[name] = blob
+		fsys.add(name, false)
+		oids = append(oids, oid)
+	}
+	for _, names := range fsys.dirs {
+		sort.Strings(names)
+	}
+
+	fsys.cmd = exec.Command("git", "-C", dir, "cat-file", "--batch")
+	if fsys.stdin, err = fsys.cmd.StdinPipe(); err != nil {
+		return nil, err
+	}
+	stdout, err := fsys.cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	fsys.out = bufio.NewReader(stdout)
+	if err := fsys.cmd.Start(); err != nil {
+		return nil, err
+	}
+	// размеры нужны обходу заранее (по ним, например, резервируется память), спрашиваем их одним запросом
+	if err := fsys.loadSizes(dir, oids); err != nil {
+		fsys.Close()
+		return nil, err
+	}
+	return fsys, nil
+}
+
+// add добавляет путь и недостающие директории над ним
+func (fsys *gitFS) add(name string, isDir bool) {
+	if isDir {
+		if _, ok := fsys.dirs[name]; ok {
+			return
+		}
+		fsys.dirs[name] = nil
+	}
+	for child, dir := name, path.Dir(name); ; child, dir = dir, path.Dir(dir) {
+		_, known := fsys.dirs[dir]
+		fsys.dirs[dir] = append(fsys.dirs[dir], path.Base(child))
+		if known {
+			break
+		}
+	}
+}
+
+// loadSizes узнаёт размеры блобов через git cat-file --batch-check
+func (fsys *gitFS) loadSizes(dir string, oids []string) error {
+	cmd := exec.Command("git", "-C", dir, "cat-file", "--batch-check")
+	cmd.Stdin = strings.NewReader(strings.Join(oids, "\n") + "\n")
+	out, err := cmd.Output()
+	if err != nil {
+		return fmt.Errorf("git cat-file: %w", err)
+	}
+	sizes := make(map[string]int64, len(oids))
+	for _, line := range strings.Split(string(out), "\n") {
+		// "<oid> blob <size>"
+		if fields := strings.Fields(line); len(fields) == 3 {
+			sizes[fields[0]], _ = strconv.ParseInt(fields[2], 10, 64)
+		}
+	}
+	for name, blob := range fsys.files {
+		blob.size = sizes[blob.oid]
+		fsys.files[name] = blob
+	}
+	return nil
+}
+
+// Close завершает git cat-file
+func (fsys *gitFS) Close() error {
+	fsys.stdin.Close()
+	return fsys.cmd.Wait()
+}
+
+// readBlob читает содержимое блоба
+func (fsys *gitFS) readBlob(oid string) ([]byte, error) {
+	fsys.mu.Lock()
+	defer fsys.mu.Unlock()
+	if _, err := io.WriteString(fsys.stdin, oid+"\n"); err != nil {
+		return nil, err
+	}
+	// ответ: "<oid> <type> <size>\n<содержимое>\n" или "<oid> missing\n"
+	header, err := fsys.out.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	fields := strings.Fields(header)
+	if len(fields) != 3 {
+		return nil, fmt.Errorf("git cat-file: %s", strings.TrimSpace(header))
+	}
+	size, err := strconv.ParseInt(fields[2], 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	data := make([]byte, size+1)
+	if _, err := io.ReadFull(fsys.out, data); err != nil {
+		return nil, err
+	}
+	return data[:size], nil
+}
+
+func (fsys *gitFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	if names, ok := fsys.dirs[name]; ok {
+		entries := make([]fs.DirEntry, len(names))
+		for i, child := range names {
+			entries[i] = fs.FileInfoToDirEntry(fsys.stat(path.Join(name, child)))
+		}
+		return &gitDir{info: fsys.stat(name), entries: entries}, nil
+	}
+	blob, ok := fsys.files[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	data, err := fsys.readBlob(blob.oid)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	return &gitFile{Reader: bytes.NewReader(data), info: fsys.stat(name)}, nil
+}
+
+// stat возвращает сведения о пути, который точно есть в индексе gitFS
+func (fsys *gitFS) stat(name string) gitStat {
+	if _, ok := fsys.dirs[name]; ok {
+		return gitStat{name: path.Base(name), mode: fs.ModeDir | 0o755}
+	}
+	blob := fsys.files[name]
+	return gitStat{name: path.Base(name), size: blob.size, mode: blob.mode, mtime: blob.mtime}
+}
+
+// gitStat — fs.FileInfo для gitFS
+type gitStat struct {
+	name  string
+	size  int64
+	mode  fs.FileMode
+	mtime time.Time
+}
+
+func (s gitStat) Name() string       { return s.name }
+func (s gitStat) Size() int64        { return s.size }
+func (s gitStat) Mode() fs.FileMode  { return s.mode }
+func (s gitStat) ModTime() time.Time { return s.mtime }
+func (s gitStat) IsDir() bool        { return s.mode.IsDir() }
+func (s gitStat) Sys() any           { return nil }
+
+// gitFile — открытый файл (блоб уже прочитан)
+type gitFile struct {
+	*bytes.Reader
+	info gitStat
+}
+
+func (f *gitFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *gitFile) Close() error               { return nil }
+
+// gitDir — открытая директория
+type gitDir struct {
+	info    gitStat
+	entries []fs.DirEntry
+	offset  int
+}
+
+func (d *gitDir) Stat() (fs.FileInfo, error) { return d.info, nil }
+func (d *gitDir) Close() error               { return nil }
+
+func (d *gitDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.info.name, Err: fs.ErrInvalid}
+}
+
+func (d *gitDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	rest := d.entries[d.offset:]
+	if n <= 0 {
+		d.offset = len(d.entries)
+		return rest, nil
+	}
+	if len(rest) == 0 {
+		return nil, io.EOF
+	}
+	rest = rest[:min(n, len(rest))]
+	d.offset += len(rest)
+	return rest, nil
+}
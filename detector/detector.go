@@ -3,6 +3,7 @@ package detector
 import (
 	"bytes"
 	"regexp"
+	"strconv"
 	"strings"
 	"unicode/utf8"
 )
@@ -45,6 +46,31 @@ const (
 	Unicode
 )
 
+// scriptNames — имена языковых групп для String (и --capabilities)
+var scriptNames = [...]string{
+	None: "none", Arabic: "arabic", Baltic: "baltic", CentralEuropean: "central-european",
+	ChineseSimplified: "chinese-simplified", ChineseTraditional: "chinese-traditional", Cyrillic: "cyrillic",
+	Greek: "greek", Hebrew: "hebrew", Japanese: "japanese", Korean: "korean", Turkish: "turkish",
+	WesternEuropean: "western-european", Unicode: "unicode",
+}
+
+// Scripts — все языковые группы по порядку, от None до Unicode
+func Scripts() []AutoDetectScript {
+	scripts := make([]AutoDetectScript, len(scriptNames))
+	for i := range scripts {
+		scripts[i] = AutoDetectScript(i)
+	}
+	return scripts
+}
+
+// String возвращает имя языковой группы в нижнем регистре через дефис: "cyrillic", "western-european"
+func (s AutoDetectScript) String() string {
+	if s >= 0 && int(s) < len(scriptNames) {
+		return scriptNames[s]
+	}
+	return "AutoDetectScript(" + strconv.Itoa(int(s)) + ")"
+}
+
 // DetectorResult содержит результат анализа
 type DetectorResult struct {
 	Encoding string
@@ -55,6 +81,10 @@ type DetectorResult struct {
 
 const maxBuffer = 16 * 1024 // максимальный размер буфера для анализа
 
+// SampleSize — сколько байт от начала файла нужно детектору: больше он не смотрит (кроме проверки на UTF-8),
+// поэтому для определения типа файла достаточно прочитать только их
+const SampleSize = maxBuffer
+
 // isBinary проверяет, является ли файл бинарным, ища нулевые байты
 // Аналог KEncodingDetector::processNull.
 func isBinary(data []byte) bool {
@@ -0,0 +1,67 @@
+package detector
+
+import (
+	"bytes"
+	"math"
+)
+
+// минифицированные и сгенерированные файлы (бандлы JS, минифицированный CSS, JSON в одну строку, base64-блобы)
+// текстовые — нулевых байт в них нет, — но для чтения бесполезны и занимают в дампе больше всего места
+// распознаём по статистике строк: большая часть байт в очень длинных строках, а в них почти нет пробелов
+// (у прозы без переносов пробелов ~15%) или энтропия как у сжатых или закодированных данных
+
+const (
+	minifiedMinSize     = 1024 // файлы короче не трогаем: места они не занимают, а статистика по ним ненадёжна
+	minifiedLongLine    = 500  // строка длиннее — «длинная»
+	minifiedLongShare   = 0.5  // доля байт в длинных строках, начиная с которой файл похож на минифицированный
+	minifiedMaxSpace    = 0.1  // доля пробельных символов в длинных строках, ниже которой это не проза
+	minifiedHighEntropy = 5.5  // бит на байт: выше — base64, hex и прочие закодированные данные
+)
+
+// Minified сообщает, похож ли текст на минифицированный или сгенерированный файл в одну строку;
+// достаточно начала файла (SampleSize байт)
+func Minified(sample []byte) bool {
+	if len(sample) < minifiedMinSize {
+		return false
+	}
+	longBytes, spaces := 0, 0
+	var counts [256]int
+	for line := range bytes.Lines(sample) {
+		line = bytes.TrimRight(line, "\r\n")
+		if len(line) < minifiedLongLine {
+			continue
+		}
+		longBytes += len(line)
+		for _, b := range line {
+			counts[b]++
+			if b == ' ' || b == '\t' {
+				spaces++
+			}
+		}
+	}
+	if float64(longBytes) < minifiedLongShare*float64(len(sample)) {
+		return false
+	}
+	return float64(spaces) < minifiedMaxSpace*float64(longBytes) || entropy(counts[:], longBytes) > minifiedHighEntropy
+}
+
+// Entropy возвращает энтропию Шеннона байт data в битах на байт: у текста ~4, у base64 случайных данных ~6
+func Entropy(data []byte) float64 {
+	var counts [256]int
+	for _, b := range data {
+		counts[b]++
+	}
+	return entropy(counts[:], len(data))
+}
+
+// entropy — энтропия Шеннона распределения байт в битах на байт
+func entropy(counts []int, total int) float64 {
+	h := 0.0
+	for _, c := range counts {
+		if c > 0 {
+			p := float64(c) / float64(total)
+			h -= p * math.Log2(p)
+		}
+	}
+	return h
+}
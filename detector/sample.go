@@ -0,0 +1,114 @@
+package detector
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+// выборка для больших файлов: по первым SampleSize байтам многогигабайтного лога не видно, что дальше
+// он перешёл на другую кодировку (ротация после смены локали, склеенные логи разных машин); со SampleSpread
+// детектор смотрит ещё на середину и конец файла и выносит общий вердикт, прочитав всего три куска по SampleSize байт
+
+// стратегии выборки (Config.Sampling)
+const (
+	SampleHead   = "head"   // только начало файла (по умолчанию)
+	SampleSpread = "spread" // начало, середина и конец
+)
+
+// DefaultSpreadFrom — с какого размера файла SampleSpread смотрит не только начало
+const DefaultSpreadFrom = 1 << 20
+
+// Config — как детектор выбирает, какие части файла смотреть
+type Config struct {
+	Sampling string // SampleHead (и пусто) или SampleSpread
+	// SpreadFrom — с какого размера файла включается SampleSpread (0 — DefaultSpreadFrom); файлы меньше смотрятся только с начала
+	SpreadFrom int64
+}
+
+// Offsets возвращает, с каких смещений читать по SampleSize байт в файле размера size; первое всегда 0
+func (c Config) Offsets(size int64) []int64 {
+	from := c.SpreadFrom
+	if from <= 0 {
+		from = DefaultSpreadFrom
+	}
+	if c.Sampling != SampleSpread || size < from || size < 3*SampleSize {
+		return []int64{0}
+	}
+	// смещения чётные, чтобы не разрезать пару байт UTF-16
+	middle := (size - SampleSize) / 2 &^ 1
+	tail := (size - SampleSize) &^ 1
+	return []int64{0, middle, tail}
+}
+
+// DetectSamples определяет кодировку по нескольким кускам файла (первый — начало, см. Config.Offsets)
+// и возвращает общий вердикт и расхождение между кусками ("" — его нет), в духе EncodingCheck.Suspect:
+//   - файл бинарный, если бинарный хоть один кусок;
+//   - кодировка — по началу, а если там только ASCII — по первому куску, где есть что-то ещё;
+//   - расхождение — когда одни куски в UTF-8, а другие в 8-битной кодировке
+//
+// у файла с BOM кодировку определяет BOM, остальные куски не смотрятся
+func DetectSamples(samples [][]byte, offsets []int64, scriptHint AutoDetectScript) (*DetectorResult, string) {
+	result := EncodingDetector(samples[0], scriptHint)
+	if result.Source == BOM || result.IsBinary || len(samples) == 1 {
+		return result, ""
+	}
+	chosen := -1 // первый кусок не только из ASCII
+	if !isASCII(samples[0]) {
+		chosen = 0
+	}
+	for i, sample := range samples[1:] {
+		sample = trimRunes(sample)
+		if !IsText(sample) {
+			result.IsBinary = true
+			result.Encoding = "binary"
+			return result, ""
+		}
+		if isASCII(sample) {
+			continue
+		}
+		if chosen < 0 {
+			chosen = i + 1
+			result = EncodingDetector(sample, scriptHint)
+			continue
+		}
+		// UTF-8 или нет — надёжный признак; 8-битные кодировки между собой детектор различает по статистике,
+		// и на разных кусках одного файла он может ошибаться по-разному
+		if first, this := utf8.Valid(trimRunes(samples[chosen])), utf8.Valid(sample); first != this {
+			return result, fmt.Sprintf("%s at byte %d, %s at byte %d", utf8Name(first), offsets[chosen], utf8Name(this), offsets[i+1])
+		}
+	}
+	return result, ""
+}
+
+// isASCII сообщает, что в data только ASCII
+func isASCII(data []byte) bool {
+	for _, b := range data {
+		if b >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// trimRunes отрезает с краёв куска, вырезанного из середины файла, обрывки символов UTF-8
+func trimRunes(data []byte) []byte {
+	for i := 0; i < utf8.UTFMax-1 && len(data) > 0 && !utf8.RuneStart(data[0]); i++ {
+		data = data[1:]
+	}
+	for i := 1; i < utf8.UTFMax && i <= len(data); i++ {
+		if utf8.RuneStart(data[len(data)-i]) {
+			if !utf8.FullRune(data[len(data)-i:]) {
+				data = data[:len(data)-i]
+			}
+			break
+		}
+	}
+	return data
+}
+
+func utf8Name(valid bool) string {
+	if valid {
+		return "UTF-8"
+	}
+	return "8-bit text"
+}
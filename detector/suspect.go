@@ -0,0 +1,88 @@
+package detector
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// проверка всего файла, а не первых SampleSize байт: кодировку угадываем по началу,
+// а дальше может оказаться кусок в другой кодировке (склеенные файлы, правка не тем редактором)
+// или оборванный посреди символа хвост (недокачанный или обрезанный файл)
+
+// EncodingCheck проверяет файл целиком на согласие с кодировкой, угаданной по началу
+// данные подаются кусками через Write, так что файл не нужно держать в памяти целиком
+type EncodingCheck struct {
+	encoding  string
+	offset    int64  // сколько байт уже разобрано
+	carry     []byte // начало символа UTF-8, разрезанного между кусками
+	firstWide int64  // где встретился первый многобайтовый символ UTF-8 (-1 — нигде)
+	firstBad  int64  // где встретился первый байт, невалидный в UTF-8 (-1 — нигде)
+	total     int64
+}
+
+// NewEncodingCheck начинает проверку файла, кодировка которого определена как encoding (см. EncodingDetector)
+func NewEncodingCheck(encoding string) *EncodingCheck {
+	return &EncodingCheck{encoding: encoding, firstWide: -1, firstBad: -1}
+}
+
+// Write разбирает очередной кусок файла; ошибок не возвращает
+func (c *EncodingCheck) Write(p []byte) (int, error) {
+	c.total += int64(len(p))
+	if c.utf16() {
+		return len(p), nil
+	}
+	data := p
+	if len(c.carry) > 0 {
+		data = append(c.carry, p...)
+	}
+	i := 0
+	for i < len(data) {
+		if data[i] < utf8.RuneSelf {
+			i++
+			continue
+		}
+		if !utf8.FullRune(data[i:]) {
+			break // конец символа в следующем куске
+		}
+		r, size := utf8.DecodeRune(data[i:])
+		if r == utf8.RuneError && size == 1 {
+			if c.firstBad < 0 {
+				c.firstBad = c.offset + int64(i)
+			}
+		} else if c.firstWide < 0 && !(c.offset+int64(i) == 0 && r == '\uFEFF') {
+			c.firstWide = c.offset + int64(i)
+		}
+		i += size
+	}
+	c.carry = append(c.carry[:0], data[i:]...)
+	c.offset += int64(i)
+	return len(p), nil
+}
+
+func (c *EncodingCheck) utf16() bool {
+	return strings.HasPrefix(c.encoding, "UTF-16")
+}
+
+// Suspect возвращает, что не так с кодировкой файла, или "", если противоречий не нашлось
+func (c *EncodingCheck) Suspect() string {
+	if c.utf16() {
+		if c.total%2 != 0 {
+			return "odd length for UTF-16"
+		}
+		return ""
+	}
+	if c.firstWide >= 0 && c.firstBad >= 0 {
+		return fmt.Sprintf("mixed UTF-8 and 8-bit text from byte %d", max(c.firstWide, c.firstBad))
+	}
+	utf8Guess := c.encoding == "UTF-8" || c.encoding == "UTF-8-BOM" || c.encoding == "us-ascii"
+	switch {
+	case len(c.carry) > 0 && utf8Guess:
+		return "truncated multibyte sequence at the end"
+	case c.firstBad >= 0 && utf8Guess:
+		return fmt.Sprintf("not %s from byte %d", c.encoding, c.firstBad)
+	case c.firstWide >= 0 && !utf8Guess:
+		return fmt.Sprintf("UTF-8 instead of %s from byte %d", c.encoding, c.firstWide)
+	}
+	return ""
+}
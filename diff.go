@@ -0,0 +1,338 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/asquebay/directory-serialization/detector"
+	"github.com/asquebay/directory-serialization/format"
+	"github.com/asquebay/directory-serialization/serializer"
+)
+
+// runDiff реализует подкоманду diff: сравнивает две директории, два дампа или дамп с директорией
+// сначала печатается список изменений (добавленные, удалённые, переименованные, изменённые файлы, смена типа),
+// затем, без --tree-only, — diff содержимого изменённых файлов
+// переименование находится по содержимому (см. diffrename.go): файл, который пропал в одном месте
+// и с тем же или похожим содержимым появился в другом, — это одна строка RENAMED, а не REMOVED и ADDED
+// возвращает код выхода: 0 — различий нет, 1 — есть различия, 2 — ошибка
+func runDiff(args []string) int {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	treeOnly := fs.Bool("tree-only", false, "print only the list of changes, without content diffs")
+	context := fs.Int("context", 3, "lines of context around each change in content diffs")
+	renames := fs.Int("find-renames", 50, "treat a removed and an added file as renamed when at least `percent` of their content matches (100: only identical files, 0: no rename detection)")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: directory-serialization diff [flags] <old> <new>\n\nEach side is a directory or a text dump.\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 2 || *context < 0 || *renames < 0 || *renames > 100 {
+		fs.Usage()
+		return 2
+	}
+
+	var sides [2]*diffSide
+	for i, name := range fs.Args() {
+		side, err := openDiffSide(name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 2
+		}
+		sides[i] = side
+	}
+	a, b := sides[0], sides[1]
+	changes := compareSides(a, b, *renames)
+
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+	list := collapseDirs(changes)
+	for _, c := range list {
+		fmt.Fprintln(out, c)
+	}
+	if !*treeOnly && len(changes) > 0 {
+		fmt.Fprintln(out)
+		sanitize := isTerminal(os.Stdout)
+		for _, c := range changes {
+			writeFileDiff(out, a, b, c, *context, sanitize)
+		}
+	}
+	out.Flush()
+
+	if len(changes) > 0 {
+		fmt.Fprintf(os.Stderr, "%d difference(s) between %s and %s\n", len(list), a.name, b.name)
+		return 1
+	}
+	fmt.Fprintf(os.Stderr, "OK: %s and %s match\n", a.name, b.name)
+	return 0
+}
+
+// diffSide — одна сторона сравнения: директория на диске или разобранный дамп
+type diffSide struct {
+	name    string
+	entries map[string]bool // путь от корня через "/" → директория ли
+	dir     string          // корень директории; пусто — сторона из дампа
+	files   map[string]format.File
+}
+
+// diffContent — содержимое файла одной стороны
+type diffContent struct {
+	data    []byte
+	known   bool // содержимое есть: в дампе нет бинарных и пропущенных файлов
+	partial bool // в дампе только начало файла
+}
+
+// openDiffSide читает директорию (без фильтров дампа: пропускаются только .git, temp и мусор из
+// serializer.DefaultExcludes) или разбирает дамп
+func openDiffSide(name string) (*diffSide, error) {
+	info, err := os.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+	side := &diffSide{name: name, entries: make(map[string]bool)}
+	if !info.IsDir() {
+		f, err := os.Open(name)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		dump, err := format.Parse(f)
+		if err != nil {
+			return nil, fmt.Errorf("parsing dump %s: %v", name, err)
+		}
+		side.files = make(map[string]format.File)
+		for _, e := range dump.Entries {
+			side.entries[e.Path] = e.IsDir
+		}
+		for _, file := range dump.Files {
+			side.files[file.Path] = file
+		}
+		return side, nil
+	}
+
+	side.dir = name
+	err = filepath.WalkDir(name, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if p == name {
+			return nil
+		}
+		if d.Name() == ".git" || d.Name() == "temp" || slices.ContainsFunc(serializer.DefaultExcludes, func(junk string) bool {
+			return strings.EqualFold(d.Name(), junk)
+		}) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(name, p)
+		if err != nil {
+			return err
+		}
+		side.entries[filepath.ToSlash(rel)] = d.IsDir()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return side, nil
+}
+
+// content возвращает содержимое файла
+func (s *diffSide) content(p string) (diffContent, error) {
+	if s.dir == "" {
+		file, ok := s.files[p]
+		return diffContent{data: file.Content, known: ok, partial: file.Truncated}, nil
+	}
+	data, err := os.ReadFile(filepath.Join(s.dir, filepath.FromSlash(p)))
+	if err != nil {
+		return diffContent{}, err
+	}
+	return diffContent{data: data, known: true}, nil
+}
+
+// gitMode — режим файла в заголовке git diff; у файлов дампа режима нет, они считаются обычными
+func (s *diffSide) gitMode(p string) string {
+	if s.dir != "" {
+		if info, err := os.Stat(filepath.Join(s.dir, filepath.FromSlash(p))); err == nil && info.Mode()&0o111 != 0 {
+			return "100755"
+		}
+	}
+	return "100644"
+}
+
+// sameContent сравнивает содержимое; если хотя бы одной стороны нет, различие не доказано
+func sameContent(a, b diffContent) bool {
+	switch {
+	case !a.known || !b.known:
+		return true
+	case a.partial && b.partial:
+		n := min(len(a.data), len(b.data))
+		return bytes.Equal(a.data[:n], b.data[:n])
+	case a.partial:
+		return bytes.HasPrefix(b.data, a.data)
+	case b.partial:
+		return bytes.HasPrefix(a.data, b.data)
+	}
+	return bytes.Equal(a.data, b.data)
+}
+
+// diffChange — одно различие; у RENAMED from — старый путь, similarity — процент похожести
+type diffChange struct {
+	kind       string // ADDED, REMOVED, RENAMED, CHANGED, TYPE, ERROR
+	path       string
+	from       string
+	isDir      bool
+	similarity int
+}
+
+func (c diffChange) String() string {
+	name := format.QuoteName(c.path)
+	if c.isDir {
+		name += "/"
+	}
+	if c.kind == "RENAMED" {
+		name = format.QuoteName(c.from) + " -> " + name
+		if c.similarity < 100 {
+			name += fmt.Sprintf(" (%d%%)", c.similarity)
+		}
+	}
+	return fmt.Sprintf("%-8s %s", c.kind, name)
+}
+
+// compareSides возвращает различия между сторонами в порядке путей
+func compareSides(a, b *diffSide, renameThreshold int) []diffChange {
+	var changes, removed, added []diffChange
+	for p, isDir := range a.entries {
+		otherDir, ok := b.entries[p]
+		switch {
+		case !ok:
+			removed = append(removed, diffChange{kind: "REMOVED", path: p, isDir: isDir})
+		case isDir != otherDir:
+			changes = append(changes, diffChange{kind: "TYPE", path: p})
+		case !isDir:
+			if c, ok := compareFile(a, b, p); !ok {
+				changes = append(changes, c)
+			}
+		}
+	}
+	for p, isDir := range b.entries {
+		if _, ok := a.entries[p]; !ok {
+			added = append(added, diffChange{kind: "ADDED", path: p, isDir: isDir})
+		}
+	}
+	sortChanges(removed)
+	sortChanges(added)
+	changes = append(changes, findRenames(a, b, removed, added, renameThreshold)...)
+	sortChanges(changes)
+	return changes
+}
+
+// compareFile сравнивает файл, который есть в обеих сторонах; false — различается (или не прочитался)
+func compareFile(a, b *diffSide, p string) (diffChange, bool) {
+	ca, err := a.content(p)
+	if err == nil {
+		var cb diffContent
+		if cb, err = b.content(p); err == nil {
+			return diffChange{kind: "CHANGED", path: p}, sameContent(ca, cb)
+		}
+	}
+	fmt.Fprintf(os.Stderr, "Error reading %s: %v\n", p, err)
+	return diffChange{kind: "ERROR", path: p}, false
+}
+
+func sortChanges(changes []diffChange) {
+	sort.Slice(changes, func(i, j int) bool { return changes[i].path < changes[j].path })
+}
+
+// collapseDirs оставляет от добавленной или удалённой директории одну строку,
+// если ни один файл внутри неё не участвует в переименовании
+func collapseDirs(changes []diffChange) []diffChange {
+	var moved []string
+	for _, c := range changes {
+		if c.kind == "RENAMED" {
+			moved = append(moved, c.path, c.from)
+		}
+	}
+	var list []diffChange
+	var collapsed []string // свёрнутые директории с "/" на конце
+	for _, c := range changes {
+		if slices.ContainsFunc(collapsed, func(dir string) bool { return strings.HasPrefix(c.path, dir) }) {
+			continue
+		}
+		list = append(list, c)
+		if !c.isDir {
+			continue
+		}
+		dir := c.path + "/"
+		if !slices.ContainsFunc(moved, func(p string) bool { return strings.HasPrefix(p, dir) }) {
+			collapsed = append(collapsed, dir)
+		}
+	}
+	return list
+}
+
+// writeFileDiff пишет diff содержимого файла в формате git diff; директории и смена типа есть в списке изменений
+func writeFileDiff(out io.Writer, a, b *diffSide, c diffChange, context int, sanitize bool) {
+	if c.isDir || c.kind == "TYPE" || c.kind == "ERROR" {
+		return
+	}
+	oldPath, newPath := c.path, c.path
+	if c.kind == "RENAMED" {
+		oldPath = c.from
+	}
+	fmt.Fprintf(out, "diff --git %s %s\n", format.QuoteName("a/"+oldPath), format.QuoteName("b/"+newPath))
+	if c.kind == "RENAMED" {
+		fmt.Fprintf(out, "similarity index %d%%\nrename from %s\nrename to %s\n", c.similarity, format.QuoteName(oldPath), format.QuoteName(newPath))
+		// у одинаковых файлов показывать нечего, у похожих — только правки, а не всё содержимое дважды
+		if c.similarity == 100 {
+			return
+		}
+	}
+
+	var before, after diffContent
+	var err error
+	if c.kind != "ADDED" {
+		before, err = a.content(oldPath)
+	}
+	if err == nil && c.kind != "REMOVED" {
+		after, err = b.content(newPath)
+	}
+	oldName, newName := format.QuoteName("a/"+oldPath), format.QuoteName("b/"+newPath)
+	// без строк режима git apply не поймёт, что файл создаётся или удаляется
+	switch c.kind {
+	case "ADDED":
+		fmt.Fprintf(out, "new file mode %s\n", b.gitMode(newPath))
+		oldName, before.known = "/dev/null", true
+	case "REMOVED":
+		fmt.Fprintf(out, "deleted file mode %s\n", a.gitMode(oldPath))
+		newName, after.known = "/dev/null", true
+	}
+	switch {
+	case err != nil:
+		fmt.Fprintf(out, "Cannot read the file: %v\n", err)
+		return
+	case !before.known || !after.known:
+		fmt.Fprintln(out, "Content is not in the dump")
+		return
+	case before.partial || after.partial:
+		fmt.Fprintf(out, "Files %s and %s differ (the dump has only the beginning of the file)\n", oldName, newName)
+		return
+	case !detector.IsText(before.data) || !detector.IsText(after.data):
+		fmt.Fprintf(out, "Binary files %s and %s differ\n", oldName, newName)
+		return
+	}
+	if sanitize {
+		before.data, after.data = serializer.SanitizeControls(before.data), serializer.SanitizeControls(after.data)
+	}
+	fmt.Fprintf(out, "--- %s\n+++ %s\n", oldName, newName)
+	writeHunks(out, editScript(splitLines(before.data), splitLines(after.data)), context)
+}
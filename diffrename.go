@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"path"
+	"sort"
+
+	"github.com/asquebay/directory-serialization/detector"
+)
+
+// поиск переименований для подкоманды diff, как git diff -M: сначала файлы с одинаковым содержимым
+// (по SHA-256), затем похожие — перенесённые и слегка поправленные
+// похожесть считается, как у git: содержимое режется на строки, строки хешируются, и доля байт общих строк
+// от большего из файлов — процент похожести; пары сводятся жадно, начиная с самых похожих
+
+// diffRenameLimit — при большем числе удалённых или добавленных файлов похожие не ищутся: пар слишком много
+// (у git такой же предел, diff.renameLimit)
+const diffRenameLimit = 1000
+
+// renameFile — удалённый или добавленный файл, который может оказаться переименованным
+type renameFile struct {
+	change diffChange
+	sum    [sha256.Size]byte
+	size   int
+	lines  map[uint64]int // хеш строки → сколько байт занимают такие строки; nil — бинарный файл
+	used   bool
+}
+
+// loadRenameFile читает файл стороны; false — директория, пустой файл или содержимое неизвестно целиком
+func loadRenameFile(s *diffSide, c diffChange, withLines bool) (*renameFile, bool) {
+	if c.isDir {
+		return nil, false
+	}
+	content, err := s.content(c.path)
+	// ошибку чтения покажет вывод diff; пустые файлы одинаковы у всех, переименованием их не считаем
+	if err != nil || !content.known || content.partial || len(content.data) == 0 {
+		return nil, false
+	}
+	f := &renameFile{change: c, sum: sha256.Sum256(content.data), size: len(content.data)}
+	if withLines && detector.IsText(content.data) {
+		f.lines = make(map[uint64]int)
+		for line := range bytes.Lines(content.data) {
+			h := fnv.New64a()
+			h.Write(line)
+			f.lines[h.Sum64()] += len(line)
+		}
+	}
+	return f, true
+}
+
+// similarity — процент похожести двух текстовых файлов: доля байт общих строк от большего файла
+func similarity(a, b *renameFile) int {
+	common := 0
+	for h, n := range a.lines {
+		common += min(n, b.lines[h])
+	}
+	return common * 100 / max(a.size, b.size)
+}
+
+// findRenames сводит удалённые и добавленные файлы в RENAMED: сначала одинаковые, затем похожие не меньше
+// чем на threshold процентов (100 — только одинаковые, 0 — переименования не ищутся); остальные возвращает как есть
+// при нескольких одинаковых кандидатах предпочитается файл с тем же именем
+func findRenames(a, b *diffSide, removed, added []diffChange, threshold int) []diffChange {
+	if threshold == 0 {
+		return append(removed, added...)
+	}
+	withLines := threshold < 100 && len(removed) <= diffRenameLimit && len(added) <= diffRenameLimit
+	if threshold < 100 && !withLines {
+		fmt.Fprintf(os.Stderr, "Warning: too many added or removed files (over %d), only identical files are detected as renamed\n", diffRenameLimit)
+	}
+
+	var sources, targets []*renameFile
+	bySum := make(map[[sha256.Size]byte][]*renameFile)
+	var changes []diffChange
+	for _, c := range removed {
+		if f, ok := loadRenameFile(a, c, withLines); ok {
+			sources = append(sources, f)
+			bySum[f.sum] = append(bySum[f.sum], f)
+		} else {
+			changes = append(changes, c)
+		}
+	}
+	for _, c := range added {
+		if f, ok := loadRenameFile(b, c, withLines); ok {
+			targets = append(targets, f)
+		} else {
+			changes = append(changes, c)
+		}
+	}
+	rename := func(from, to *renameFile, score int) {
+		from.used, to.used = true, true
+		changes = append(changes, diffChange{kind: "RENAMED", path: to.change.path, from: from.change.path, similarity: score})
+	}
+
+	for _, to := range targets {
+		var match *renameFile
+		for _, from := range bySum[to.sum] {
+			if from.used {
+				continue
+			}
+			if match == nil || path.Base(from.change.path) == path.Base(to.change.path) && path.Base(match.change.path) != path.Base(to.change.path) {
+				match = from
+			}
+		}
+		if match != nil {
+			rename(match, to, 100)
+		}
+	}
+
+	if withLines {
+		type pair struct {
+			from, to *renameFile
+			score    int
+		}
+		var pairs []pair
+		for _, from := range sources {
+			for _, to := range targets {
+				if from.used || to.used || from.lines == nil || to.lines == nil {
+					continue
+				}
+				// по размерам видно, что похожести не хватит, даже если меньший файл целиком внутри большего
+				if min(from.size, to.size)*100 < threshold*max(from.size, to.size) {
+					continue
+				}
+				if score := similarity(from, to); score >= threshold {
+					pairs = append(pairs, pair{from, to, score})
+				}
+			}
+		}
+		// sources и targets отсортированы по путям, так что при равной похожести порядок тоже определён
+		sort.SliceStable(pairs, func(i, j int) bool { return pairs[i].score > pairs[j].score })
+		for _, p := range pairs {
+			if !p.from.used && !p.to.used {
+				rename(p.from, p.to, p.score)
+			}
+		}
+	}
+
+	for _, f := range append(sources, targets...) {
+		if !f.used {
+			changes = append(changes, f.change)
+		}
+	}
+	return changes
+}
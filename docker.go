@@ -0,0 +1,324 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// --docker-image nginx:latest[:/etc]: файловая система образа, какой она будет в контейнере
+// образ берётся у локального демона (docker pull, если его там нет) и выгружается docker save во временный файл;
+// слои накладываются по порядку с учётом whiteout (.wh.имя удаляет путь из нижних слоёв, .wh..wh..opq — всё
+// содержимое директории), а файлы читаются прямо из выгрузки, как из несжатого tar (см. archive.go)
+// абсолютные символьные ссылки ведут от корня образа, а не от корня хоста
+
+// whiteout-пометки слоёв (спецификация OCI image, layer.md)
+const (
+	whiteoutPrefix = ".wh."
+	whiteoutOpaque = ".wh..wh..opq"
+)
+
+// parseImageRef разбирает "образ[:/путь]"; у образа может быть свой ":" (тег, порт реестра), путь начинается с ":/"
+func parseImageRef(s string) (string, string) {
+	if i := strings.LastIndex(s, ":/"); i > 0 {
+		return s[:i], s[i+1:]
+	}
+	return s, "/"
+}
+
+// imageRoot возвращает имя корня дампа: имя выбранной директории или, для всего образа, имя репозитория
+func imageRoot(ref, dir string) string {
+	if dir = path.Clean(dir); dir != "/" {
+		return path.Base(dir)
+	}
+	ref, _, _ = strings.Cut(ref, "@")
+	if i := strings.LastIndex(ref, ":"); i > strings.LastIndex(ref, "/") {
+		ref = ref[:i]
+	}
+	return path.Base(ref)
+}
+
+// docker запускает docker и возвращает stdout
+func docker(args ...string) (string, error) {
+	cmd := exec.Command("docker", args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("docker %s: %v: %s", args[0], err, msg)
+		}
+		return "", fmt.Errorf("docker %s: %w", args[0], err)
+	}
+	return string(out), nil
+}
+
+// imageLayer — путь в наложенной файловой системе
+type imageLayer struct {
+	entry archiveEntry
+	dir   bool
+}
+
+// openImage выгружает образ и накладывает его слои; возвращает файловую систему с корнем в dir
+func openImage(ref, dir string) (fs.FS, io.Closer, error) {
+	if _, err := docker("image", "inspect", "--format", "{{.Id}}", ref); err != nil {
+		// локально образа нет; прогресс docker pull идёт в stderr, stdout занят дампом
+		pull := exec.Command("docker", "pull", ref)
+		pull.Stdout, pull.Stderr = os.Stderr, os.Stderr
+		if err := pull.Run(); err != nil {
+			return nil, nil, fmt.Errorf("docker pull: %w", err)
+		}
+	}
+	temp := &tempFiles{}
+	fail := func(err error) (fs.FS, io.Closer, error) {
+		temp.Close()
+		return nil, nil, err
+	}
+	save, err := temp.create()
+	if err != nil {
+		return fail(err)
+	}
+	if _, err := docker("save", "-o", save.Name(), ref); err != nil {
+		return fail(err)
+	}
+
+	// выгрузка — несжатый tar: запоминаем, где лежит каждый файл, и читаем manifest.json
+	blobs := make(map[string]*io.SectionReader)
+	tr := tar.NewReader(save)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fail(fmt.Errorf("docker save: %w", err))
+		}
+		offset, err := save.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return fail(err)
+		}
+		blobs[path.Clean(hdr.Name)] = io.NewSectionReader(save, offset, hdr.Size)
+	}
+	var manifest []struct{ Layers []string }
+	if m, ok := blobs["manifest.json"]; !ok {
+		return fail(errors.New("docker save: no manifest.json"))
+	} else if err := json.NewDecoder(m).Decode(&manifest); err != nil || len(manifest) == 0 {
+		return fail(fmt.Errorf("docker save: bad manifest.json: %v", err))
+	}
+
+	state := make(map[string]imageLayer)
+	for _, name := range manifest[0].Layers {
+		layer, ok := blobs[path.Clean(name)]
+		if !ok {
+			return fail(fmt.Errorf("docker save: missing layer %s", name))
+		}
+		if layer, err = temp.uncompressed(layer); err != nil {
+			return fail(fmt.Errorf("layer %s: %w", name, err))
+		}
+		if err := applyLayer(state, layer); err != nil {
+			return fail(fmt.Errorf("layer %s: %w", name, err))
+		}
+	}
+
+	a := &archiveFS{files: make(map[string]archiveEntry), dirs: map[string][]string{".": nil}, mtimes: make(map[string]time.Time), closer: temp, rooted: true}
+	names := make([]string, 0, len(state))
+	for name := range state {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		a.add(name, state[name].entry, state[name].dir)
+	}
+	for _, names := range a.dirs {
+		sort.Strings(names)
+	}
+
+	// выбранный путь может проходить через ссылки (/lib → usr/lib), их разрешаем покомпонентно
+	sub := "."
+	for _, part := range strings.Split(strings.Trim(path.Clean(dir), "/"), "/") {
+		if part == "" {
+			continue
+		}
+		if sub, err = a.resolve(path.Join(sub, part)); err != nil {
+			return fail(fmt.Errorf("%s: %w", dir, err))
+		}
+	}
+	if _, ok := a.dirs[sub]; !ok {
+		if _, ok := a.files[sub]; ok {
+			return fail(fmt.Errorf("%s is not a directory in the image", dir))
+		}
+		return fail(fmt.Errorf("%s does not exist in the image", dir))
+	}
+	if sub == "." {
+		return a, a, nil
+	}
+	fsys, err := fs.Sub(a, sub)
+	if err != nil {
+		return fail(err)
+	}
+	return fsys, a, nil
+}
+
+// applyLayer накладывает слой на state: сначала whiteout слоя убирают пути нижних слоёв, потом добавляются его записи
+func applyLayer(state map[string]imageLayer, layer *io.SectionReader) error {
+	type record struct {
+		name string
+		node imageLayer
+		hard string // жёсткая ссылка: путь цели от корня
+	}
+	var records []record
+	var removed, opaque []string
+	tr := tar.NewReader(layer)
+	for index := 0; ; index++ {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		name, ok := cleanArchivePath(hdr.Name)
+		if !ok {
+			continue
+		}
+		dir, base := path.Dir(name), path.Base(name)
+		if base == whiteoutOpaque {
+			opaque = append(opaque, dir)
+			continue
+		}
+		if strings.HasPrefix(base, whiteoutPrefix) {
+			removed = append(removed, path.Join(dir, strings.TrimPrefix(base, whiteoutPrefix)))
+			continue
+		}
+		r := record{name: name, node: imageLayer{entry: archiveEntry{mode: hdr.FileInfo().Mode(), size: hdr.Size, mtime: hdr.ModTime}}}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			r.node.dir = true
+		case tar.TypeSymlink:
+			r.node.entry.link = hdr.Linkname
+		case tar.TypeLink:
+			if r.hard, ok = cleanArchivePath(hdr.Linkname); !ok {
+				continue
+			}
+		case tar.TypeReg, tar.TypeGNUSparse:
+			if isSparse(hdr) {
+				// разреженный файл не лежит одним куском, его отдаёт tar.Reader по второму проходу слоя
+				r.node.entry.open = func() (io.ReadCloser, error) { return layerEntry(layer, index) }
+				break
+			}
+			offset, err := layer.Seek(0, io.SeekCurrent)
+			if err != nil {
+				return err
+			}
+			section := io.NewSectionReader(layer, offset, hdr.Size)
+			r.node.entry.open = func() (io.ReadCloser, error) {
+				return io.NopCloser(io.NewSectionReader(section, 0, section.Size())), nil
+			}
+		}
+		records = append(records, r)
+	}
+
+	for _, name := range removed {
+		removeTree(state, name, true)
+	}
+	for _, dir := range opaque {
+		removeTree(state, dir, false)
+	}
+	for _, r := range records {
+		if r.hard != "" {
+			// жёсткая ссылка — тот же файл; цель может быть удалена следующими слоями, поэтому копируем запись
+			target, ok := state[r.hard]
+			if !ok || target.dir {
+				continue
+			}
+			r.node.entry = target.entry
+		}
+		if prev, ok := state[r.name]; ok && prev.dir && !r.node.dir {
+			removeTree(state, r.name, true)
+		}
+		state[r.name] = r.node
+	}
+	return nil
+}
+
+// removeTree удаляет из state содержимое директории name и, если self, её саму
+func removeTree(state map[string]imageLayer, name string, self bool) {
+	if self {
+		delete(state, name)
+	}
+	prefix := name + "/"
+	if name == "." {
+		prefix = ""
+	}
+	for p := range state {
+		if strings.HasPrefix(p, prefix) {
+			delete(state, p)
+		}
+	}
+}
+
+// layerEntry проходит слой заново до записи номер index
+func layerEntry(layer *io.SectionReader, index int) (io.ReadCloser, error) {
+	tr := tar.NewReader(io.NewSectionReader(layer, 0, layer.Size()))
+	for range index + 1 {
+		if _, err := tr.Next(); err != nil {
+			return nil, err
+		}
+	}
+	return io.NopCloser(tr), nil
+}
+
+// tempFiles — временные файлы выгрузки; Close удаляет их
+type tempFiles []*os.File
+
+func (t *tempFiles) create() (*os.File, error) {
+	f, err := scratch.createTemp("image-*.tar")
+	if err == nil {
+		*t = append(*t, f)
+	}
+	return f, err
+}
+
+// uncompressed возвращает слой как несжатый tar: сжатый gzip распаковывается во временный файл
+func (t *tempFiles) uncompressed(layer *io.SectionReader) (*io.SectionReader, error) {
+	magic := make([]byte, 4)
+	n, _ := layer.ReadAt(magic, 0)
+	switch {
+	case bytes.HasPrefix(magic[:n], []byte("\x1f\x8b")):
+	case bytes.HasPrefix(magic[:n], []byte("\x28\xb5\x2f\xfd")):
+		return nil, errors.New("zstd-compressed layers are not supported")
+	default:
+		return layer, nil
+	}
+	r, err := decompress(io.NewSectionReader(layer, 0, layer.Size()))
+	if err != nil {
+		return nil, err
+	}
+	f, err := t.create()
+	if err != nil {
+		return nil, err
+	}
+	size, err := io.Copy(f, r)
+	if err != nil {
+		return nil, err
+	}
+	return io.NewSectionReader(f, 0, size), nil
+}
+
+func (t *tempFiles) Close() error {
+	for _, f := range *t {
+		f.Close()
+		os.Remove(f.Name())
+	}
+	return nil
+}
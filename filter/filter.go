@@ -0,0 +1,190 @@
+package filter
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// здесь лежит маленький язык условий для --filter: одно выражение вместо россыпи отдельных флагов
+//
+//	size < 100KB && ext in ["go", "md"] && !path.matches("testdata/")
+//	age < 7d || name == "Makefile"
+//
+// поля файла: path (от корня через "/"), name, ext (без точки, в нижнем регистре), dir, size, age, depth, hidden
+// литералы: строки в двойных или одинарных кавычках, числа, размеры (512B, 100KB, 1.5MB, 2GB),
+// длительности (30s, 15m, 36h, 7d, 2w), списки [..], true и false
+// операции: || && ! == != < <= > >= in, скобки; методы строк: matches(regexp), glob(шаблон),
+// contains, startsWith, endsWith
+// типы проверяются при разборе, так что опечатка в выражении — ошибка сразу, а не молчаливый пустой дамп
+
+// Expr — разобранное выражение
+type Expr struct {
+	eval func(e *entry) any
+	now  time.Time // от какого момента считается age
+}
+
+// entry — поля файла, доступные выражению
+type entry struct {
+	path string
+	info fs.FileInfo
+	now  time.Time
+}
+
+// Parse разбирает выражение; age считается от момента разбора
+func Parse(src string) (*Expr, error) {
+	p := &parser{src: src}
+	if err := p.lex(); err != nil {
+		return nil, err
+	}
+	n, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.toks) {
+		return nil, p.errorf("unexpected %q", p.toks[p.pos].text)
+	}
+	if n.typ != tBool {
+		return nil, fmt.Errorf("filter must be a condition, got %s", n.typ)
+	}
+	return &Expr{eval: n.eval, now: time.Now()}, nil
+}
+
+// Match сообщает, подходит ли файл relPath (от корня через "/") под выражение
+func (x *Expr) Match(relPath string, info fs.FileInfo) bool {
+	return x.eval(&entry{path: relPath, info: info, now: x.now}).(bool)
+}
+
+// типы значений
+type typ int
+
+const (
+	tBool typ = iota
+	tNum      // число без единиц
+	tSize     // байты
+	tDur      // длительность
+	tStr
+	tList
+)
+
+func (t typ) String() string {
+	return [...]string{"bool", "number", "size", "duration", "string", "list"}[t]
+}
+
+// node — скомпилированный узел: тип и функция вычисления
+// числа, размеры и длительности вычисляются в int64 (длительность — в наносекундах), списки — в []any
+type node struct {
+	typ  typ
+	elem typ // тип элементов списка
+	eval func(e *entry) any
+}
+
+// fields — поля файла
+var fields = map[string]node{
+	"path": {typ: tStr, eval: func(e *entry) any { return e.path }},
+	"name": {typ: tStr, eval: func(e *entry) any { return path.Base(e.path) }},
+	"ext": {typ: tStr, eval: func(e *entry) any {
+		return strings.ToLower(strings.TrimPrefix(path.Ext(e.path), "."))
+	}},
+	"dir": {typ: tStr, eval: func(e *entry) any {
+		if dir := path.Dir(e.path); dir != "." {
+			return dir
+		}
+		return ""
+	}},
+	"size":   {typ: tSize, eval: func(e *entry) any { return e.info.Size() }},
+	"age":    {typ: tDur, eval: func(e *entry) any { return int64(e.now.Sub(e.info.ModTime())) }},
+	"depth":  {typ: tNum, eval: func(e *entry) any { return int64(strings.Count(e.path, "/")) }},
+	"hidden": {typ: tBool, eval: func(e *entry) any { return strings.HasPrefix(path.Base(e.path), ".") }},
+}
+
+// methods — методы строк: аргумент — строка-литерал, чтобы регулярку и шаблон проверить при разборе
+var methods = map[string]func(arg string) (func(s string) bool, error){
+	"matches": func(arg string) (func(string) bool, error) {
+		re, err := regexp.Compile(arg)
+		if err != nil {
+			return nil, err
+		}
+		return re.MatchString, nil
+	},
+	"glob": func(arg string) (func(string) bool, error) {
+		if _, err := path.Match(arg, ""); err != nil {
+			return nil, err
+		}
+		return func(s string) bool {
+			ok, _ := path.Match(arg, s)
+			return ok
+		}, nil
+	},
+	"contains": func(arg string) (func(string) bool, error) {
+		return func(s string) bool { return strings.Contains(s, arg) }, nil
+	},
+	"startsWith": func(arg string) (func(string) bool, error) {
+		return func(s string) bool { return strings.HasPrefix(s, arg) }, nil
+	},
+	"endsWith": func(arg string) (func(string) bool, error) {
+		return func(s string) bool { return strings.HasSuffix(s, arg) }, nil
+	},
+}
+
+// comparable сообщает, можно ли сравнивать значения типов a и b;
+// число без единиц годится и как размер в байтах, а длительность нужна с единицами (7d), иначе легко ошибиться
+func comparable(a, b typ) bool {
+	if a == b {
+		return a != tList
+	}
+	return a == tNum && b == tSize || a == tSize && b == tNum
+}
+
+// compare сравнивает два вычисленных значения одного вида
+func compare(op string, a, b any) bool {
+	switch a := a.(type) {
+	case int64:
+		b := b.(int64)
+		switch op {
+		case "==":
+			return a == b
+		case "!=":
+			return a != b
+		case "<":
+			return a < b
+		case "<=":
+			return a <= b
+		case ">":
+			return a > b
+		default:
+			return a >= b
+		}
+	case string:
+		b := b.(string)
+		switch op {
+		case "==":
+			return a == b
+		case "!=":
+			return a != b
+		case "<":
+			return a < b
+		case "<=":
+			return a <= b
+		case ">":
+			return a > b
+		default:
+			return a >= b
+		}
+	default:
+		// bool: при разборе пропускаются только == и !=
+		if op == "==" {
+			return a == b
+		}
+		return a != b
+	}
+}
+
+// in проверяет, есть ли значение в списке
+func in(v any, list []any) bool {
+	return slices.Contains(list, v)
+}
@@ -0,0 +1,344 @@
+package filter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// разбор выражения: лексер и рекурсивный спуск
+//
+//	or    := and ("||" and)*
+//	and   := unary ("&&" unary)*
+//	unary := "!" unary | cmp
+//	cmp   := value (("==" | "!=" | "<" | "<=" | ">" | ">=" | "in") value)?
+//	value := primary ("." method "(" string ")")*
+//	primary := field | literal | "[" literals "]" | "(" or ")"
+
+type token struct {
+	kind byte // 'i' — имя, 'n' — число, 's' — строка, 'p' — знак
+	text string
+	pos  int
+}
+
+type parser struct {
+	src  string
+	toks []token
+	pos  int
+}
+
+func (p *parser) errorf(format string, args ...any) error {
+	at := len(p.src)
+	if p.pos < len(p.toks) {
+		at = p.toks[p.pos].pos
+	}
+	return fmt.Errorf("filter: column %d: %s", at+1, fmt.Sprintf(format, args...))
+}
+
+// lex разбивает выражение на лексемы
+func (p *parser) lex() error {
+	s := p.src
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			i++
+		case c == '"' || c == '\'':
+			j := i + 1
+			for j < len(s) && s[j] != c {
+				if s[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j >= len(s) {
+				return fmt.Errorf("filter: column %d: unterminated string", i+1)
+			}
+			text := s[i+1 : j]
+			if c == '"' {
+				unq, err := strconv.Unquote(s[i : j+1])
+				if err != nil {
+					return fmt.Errorf("filter: column %d: bad string: %v", i+1, err)
+				}
+				text = unq
+			} else {
+				text = strings.ReplaceAll(strings.ReplaceAll(text, `\'`, `'`), `\\`, `\`)
+			}
+			p.toks = append(p.toks, token{'s', text, i})
+			i = j + 1
+		case c >= '0' && c <= '9':
+			j := i
+			for j < len(s) && (s[j] >= '0' && s[j] <= '9' || s[j] == '.' || unicode.IsLetter(rune(s[j]))) {
+				j++
+			}
+			p.toks = append(p.toks, token{'n', s[i:j], i})
+			i = j
+		case c == '_' || unicode.IsLetter(rune(c)):
+			j := i
+			for j < len(s) && (s[j] == '_' || unicode.IsLetter(rune(s[j])) || s[j] >= '0' && s[j] <= '9') {
+				j++
+			}
+			p.toks = append(p.toks, token{'i', s[i:j], i})
+			i = j
+		default:
+			op := ""
+			for _, candidate := range []string{"&&", "||", "==", "!=", "<=", ">=", "<", ">", "!", "(", ")", "[", "]", ",", "."} {
+				if strings.HasPrefix(s[i:], candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				return fmt.Errorf("filter: column %d: unexpected %q", i+1, c)
+			}
+			p.toks = append(p.toks, token{'p', op, i})
+			i += len(op)
+		}
+	}
+	return nil
+}
+
+// accept съедает знак op, если он следующий
+func (p *parser) accept(op string) bool {
+	if p.pos < len(p.toks) && p.toks[p.pos].kind == 'p' && p.toks[p.pos].text == op {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(op string) error {
+	if !p.accept(op) {
+		if p.pos < len(p.toks) {
+			return p.errorf("expected %q, got %q", op, p.toks[p.pos].text)
+		}
+		return p.errorf("expected %q at the end", op)
+	}
+	return nil
+}
+
+func (p *parser) or() (node, error) {
+	left, err := p.and()
+	if err != nil {
+		return node{}, err
+	}
+	for p.accept("||") {
+		right, err := p.and()
+		if err != nil {
+			return node{}, err
+		}
+		if left.typ != tBool || right.typ != tBool {
+			return node{}, p.errorf("|| needs conditions on both sides")
+		}
+		l, r := left.eval, right.eval
+		left = node{typ: tBool, eval: func(e *entry) any { return l(e).(bool) || r(e).(bool) }}
+	}
+	return left, nil
+}
+
+func (p *parser) and() (node, error) {
+	left, err := p.unary()
+	if err != nil {
+		return node{}, err
+	}
+	for p.accept("&&") {
+		right, err := p.unary()
+		if err != nil {
+			return node{}, err
+		}
+		if left.typ != tBool || right.typ != tBool {
+			return node{}, p.errorf("&& needs conditions on both sides")
+		}
+		l, r := left.eval, right.eval
+		left = node{typ: tBool, eval: func(e *entry) any { return l(e).(bool) && r(e).(bool) }}
+	}
+	return left, nil
+}
+
+func (p *parser) unary() (node, error) {
+	if p.accept("!") {
+		n, err := p.unary()
+		if err != nil {
+			return node{}, err
+		}
+		if n.typ != tBool {
+			return node{}, p.errorf("! needs a condition, got %s", n.typ)
+		}
+		return node{typ: tBool, eval: func(e *entry) any { return !n.eval(e).(bool) }}, nil
+	}
+	return p.cmp()
+}
+
+func (p *parser) cmp() (node, error) {
+	left, err := p.value()
+	if err != nil {
+		return node{}, err
+	}
+	if p.pos < len(p.toks) && p.toks[p.pos].kind == 'i' && p.toks[p.pos].text == "in" {
+		p.pos++
+		right, err := p.value()
+		if err != nil {
+			return node{}, err
+		}
+		if right.typ != tList || !comparable(left.typ, right.elem) {
+			return node{}, p.errorf("in needs a list of %s values", left.typ)
+		}
+		l, r := left.eval, right.eval
+		return node{typ: tBool, eval: func(e *entry) any { return in(l(e), r(e).([]any)) }}, nil
+	}
+	for _, op := range []string{"==", "!=", "<=", ">=", "<", ">"} {
+		if !p.accept(op) {
+			continue
+		}
+		right, err := p.value()
+		if err != nil {
+			return node{}, err
+		}
+		if !comparable(left.typ, right.typ) {
+			return node{}, p.errorf("cannot compare %s %s %s", left.typ, op, right.typ)
+		}
+		if left.typ == tBool && op != "==" && op != "!=" {
+			return node{}, p.errorf("conditions can only be compared with == and !=")
+		}
+		l, r := left.eval, right.eval
+		return node{typ: tBool, eval: func(e *entry) any { return compare(op, l(e), r(e)) }}, nil
+	}
+	return left, nil
+}
+
+func (p *parser) value() (node, error) {
+	n, err := p.primary()
+	if err != nil {
+		return node{}, err
+	}
+	for p.accept(".") {
+		if p.pos >= len(p.toks) || p.toks[p.pos].kind != 'i' {
+			return node{}, p.errorf("expected a method name after \".\"")
+		}
+		name := p.toks[p.pos].text
+		method, ok := methods[name]
+		if !ok {
+			return node{}, p.errorf("unknown method %q (known: matches, glob, contains, startsWith, endsWith)", name)
+		}
+		if n.typ != tStr {
+			return node{}, p.errorf("%s is a string method, got %s", name, n.typ)
+		}
+		p.pos++
+		if err := p.expect("("); err != nil {
+			return node{}, err
+		}
+		if p.pos >= len(p.toks) || p.toks[p.pos].kind != 's' {
+			return node{}, p.errorf("%s needs a string argument", name)
+		}
+		test, err := method(p.toks[p.pos].text)
+		if err != nil {
+			return node{}, p.errorf("%s: %v", name, err)
+		}
+		p.pos++
+		if err := p.expect(")"); err != nil {
+			return node{}, err
+		}
+		s := n.eval
+		n = node{typ: tBool, eval: func(e *entry) any { return test(s(e).(string)) }}
+	}
+	return n, nil
+}
+
+func (p *parser) primary() (node, error) {
+	if p.pos >= len(p.toks) {
+		return node{}, p.errorf("unexpected end of expression")
+	}
+	tok := p.toks[p.pos]
+	switch {
+	case tok.kind == 'p' && tok.text == "(":
+		p.pos++
+		n, err := p.or()
+		if err != nil {
+			return node{}, err
+		}
+		return n, p.expect(")")
+	case tok.kind == 'p' && tok.text == "[":
+		p.pos++
+		var items []any
+		elem := typ(-1)
+		for !p.accept("]") {
+			if len(items) > 0 {
+				if err := p.expect(","); err != nil {
+					return node{}, err
+				}
+			}
+			item, err := p.primary()
+			if err != nil {
+				return node{}, err
+			}
+			if item.typ == tList {
+				return node{}, p.errorf("lists cannot be nested")
+			}
+			if elem == -1 {
+				elem = item.typ
+			} else if !comparable(elem, item.typ) {
+				return node{}, p.errorf("list mixes %s and %s", elem, item.typ)
+			}
+			items = append(items, item.eval(nil))
+		}
+		return node{typ: tList, elem: elem, eval: func(*entry) any { return items }}, nil
+	case tok.kind == 's':
+		p.pos++
+		return constant(tStr, tok.text), nil
+	case tok.kind == 'n':
+		p.pos++
+		return number(tok.text, p)
+	case tok.kind == 'i':
+		p.pos++
+		switch tok.text {
+		case "true", "false":
+			return constant(tBool, tok.text == "true"), nil
+		}
+		if f, ok := fields[tok.text]; ok {
+			return f, nil
+		}
+		p.pos--
+		return node{}, p.errorf("unknown field %q (known: path, name, ext, dir, size, age, depth, hidden)", tok.text)
+	}
+	return node{}, p.errorf("unexpected %q", tok.text)
+}
+
+func constant(t typ, v any) node {
+	return node{typ: t, eval: func(*entry) any { return v }}
+}
+
+// units — множители единиц в литералах: размеры (двоичные) и длительности
+var units = map[string]struct {
+	typ  typ
+	mult float64
+}{
+	"b": {tSize, 1}, "kb": {tSize, 1 << 10}, "mb": {tSize, 1 << 20}, "gb": {tSize, 1 << 30}, "tb": {tSize, 1 << 40},
+	"k": {tSize, 1 << 10}, "g": {tSize, 1 << 30},
+	"s": {tDur, float64(time.Second)}, "m": {tDur, float64(time.Minute)}, "h": {tDur, float64(time.Hour)},
+	"d": {tDur, float64(24 * time.Hour)}, "w": {tDur, float64(7 * 24 * time.Hour)},
+}
+
+// number разбирает числовой литерал с необязательной единицей: 42, 100KB, 1.5MB, 7d
+func number(text string, p *parser) (node, error) {
+	i := strings.IndexFunc(text, unicode.IsLetter)
+	digits, unit := text, ""
+	if i >= 0 {
+		digits, unit = text[:i], strings.ToLower(text[i:])
+	}
+	v, err := strconv.ParseFloat(digits, 64)
+	if err != nil {
+		p.pos--
+		return node{}, p.errorf("bad number %q", text)
+	}
+	if unit == "" {
+		return constant(tNum, int64(v)), nil
+	}
+	u, ok := units[unit]
+	if !ok {
+		p.pos--
+		return node{}, p.errorf("unknown unit in %q (sizes: B, KB, MB, GB; durations: s, m, h, d, w)", text)
+	}
+	return constant(u.typ, int64(v*u.mult)), nil
+}
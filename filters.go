@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// parsePerms разбирает права в стиле ls (r--, rw-, r-x) в битовую маску r=4, w=2, x=1
+func parsePerms(s string) (uint32, error) {
+	if len(s) != 3 {
+		return 0, fmt.Errorf("invalid permissions %q, expected something like r-- or rw-", s)
+	}
+	var mask uint32
+	for i, want := range "rwx" {
+		switch rune(s[i]) {
+		case want:
+			mask |= 4 >> i
+		case '-':
+		default:
+			return 0, fmt.Errorf("invalid permissions %q, expected something like r-- or rw-", s)
+		}
+	}
+	return mask, nil
+}
+
+// parseDate разбирает дату для --newer-than: либо 2024-01-01, либо полный RFC 3339
+func parseDate(s string) (time.Time, error) {
+	if t, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+// parseAge разбирает длительность для --changed-within
+// помимо стандартных для Go единиц (h, m, s) понимает дни (d) и недели (w)
+func parseAge(s string) (time.Duration, error) {
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if num, ok := strings.CutSuffix(s, suffix); ok {
+			n, err := strconv.ParseFloat(num, 64)
+			if err != nil || n < 0 {
+				return 0, fmt.Errorf("invalid duration %q", s)
+			}
+			return time.Duration(n * float64(unit)), nil
+		}
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	return d, nil
+}
+
+// parseSize разбирает размер вида 512, 100KB, 1.5MB, 2G (единицы двоичные, регистр не важен)
+func parseSize(s string) (int64, error) {
+	upper := strings.ToUpper(strings.TrimSpace(s))
+	mult := int64(1)
+	for _, u := range []struct {
+		suffix string
+		mult   int64
+	}{
+		{"KB", 1 << 10}, {"MB", 1 << 20}, {"GB", 1 << 30},
+		{"K", 1 << 10}, {"M", 1 << 20}, {"G", 1 << 30}, {"B", 1},
+	} {
+		if num, ok := strings.CutSuffix(upper, u.suffix); ok {
+			upper, mult = num, u.mult
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(strings.TrimSpace(upper), 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return int64(n * float64(mult)), nil
+}
@@ -0,0 +1,24 @@
+package format
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+)
+
+// ElisionLine — строка, которой заканчивается сокращённое содержимое длинного документа (--summarize-docs)
+func ElisionLine(omitted int) string {
+	return fmt.Sprintf("[... %d more lines omitted ...]", omitted)
+}
+
+var elisionLine = regexp.MustCompile(`^\[\.\.\. \d+ more lines omitted \.\.\.\]$`)
+
+// stripElision убирает строку ElisionLine из конца содержимого, чтобы осталось только начало файла
+func stripElision(content []byte) []byte {
+	body := bytes.TrimSuffix(content, []byte("\n"))
+	start := bytes.LastIndexByte(body, '\n') + 1
+	if !elisionLine.Match(body[start:]) {
+		return content
+	}
+	return content[:start]
+}
@@ -0,0 +1,214 @@
+package format
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+)
+
+// Encoder пишет дамп в том же формате, что и directory-serialization, из любого источника:
+// генератора кода, базы, архива — того, чего нет на диске; Parse и verify разбирают такой дамп как обычный
+//
+//	enc := format.NewEncoder(w, format.EncoderOptions{Root: "project"})
+//	enc.WriteTreeNode(format.TreeNode{Path: "cmd", IsDir: true})
+//	enc.WriteTreeNode(format.TreeNode{Path: "cmd/main.go"})
+//	enc.WriteFile("cmd/main.go", format.FileMeta{Lang: "go"}, strings.NewReader(src))
+//	enc.Close()
+//
+// древо печатается целиком перед первой секцией содержимого, поэтому все элементы древа
+// передаются до первого WriteFile; порядок элементов внутри директории — порядок вызовов
+
+// EncoderOptions — настройки Encoder
+type EncoderOptions struct {
+	Root      string // имя корня дампа (пусто — ".")
+	Preamble  string // текст перед древом
+	Postamble string // текст после содержимого
+	FenceLang bool   // ставить язык файла у открывающего fence (```go)
+	Canonical bool   // раскладка для git diff, как --canonical: одинаковые знаки древа и пустая строка между секциями
+	ByteExact bool   // как --byte-exact: содержимое, которое Parse вернул бы не байт в байт, писать в base64 (Base64Note)
+}
+
+// TreeNode — элемент древа
+type TreeNode struct {
+	Path  string // путь от корня через "/"
+	IsDir bool
+	// Tags — пометки в квадратных скобках после имени; Parse отделяет от имени те, что выводит сама программа:
+	// "binary", "unreadable" и другие решения, EmptyFileTag, EncodingSuspectTag, ID файла, ">64KB"
+	// пустой файл помечайте EmptyFileTag и не передавайте в WriteFile — так его выводит программа
+	Tags []string
+}
+
+// FileMeta — сведения для заголовка секции содержимого
+type FileMeta struct {
+	Lang  string   // метка языка у fence (только с EncoderOptions.FenceLang)
+	Notes []string // пометки в скобках после пути: "part 1/3", "lines 1-200", "truncated: ..."
+}
+
+// Encoder пишет дамп; методы не потокобезопасны
+type Encoder struct {
+	w     io.Writer
+	opts  EncoderOptions
+	root  *encodeNode
+	nodes map[string]*encodeNode // все элементы древа по пути
+	tree  bool                   // древо уже напечатано
+	files int                    // сколько секций содержимого напечатано
+	err   error                  // первая ошибка записи: дальше Encoder ничего не пишет
+}
+
+// encodeNode — элемент древа до печати
+type encodeNode struct {
+	name     string
+	isDir    bool
+	tags     []string
+	children []*encodeNode
+}
+
+// NewEncoder возвращает Encoder, который пишет дамп в w
+func NewEncoder(w io.Writer, opts EncoderOptions) *Encoder {
+	if opts.Root == "" {
+		opts.Root = "."
+	}
+	root := &encodeNode{name: opts.Root, isDir: true}
+	return &Encoder{w: w, opts: opts, root: root, nodes: map[string]*encodeNode{".": root}}
+}
+
+// WriteTreeNode добавляет элемент в древо; недостающие директории над ним добавляются сами
+func (e *Encoder) WriteTreeNode(n TreeNode) error {
+	if e.tree {
+		return fmt.Errorf("format: %s: the tree is already written, add tree nodes before the first file", n.Path)
+	}
+	if !fs.ValidPath(n.Path) || n.Path == "." {
+		return fmt.Errorf("format: invalid tree path %q", n.Path)
+	}
+	if node, ok := e.nodes[n.Path]; ok {
+		// директория могла появиться раньше как родитель другого элемента
+		if !node.isDir || !n.IsDir || node.tags != nil {
+			return fmt.Errorf("format: %s is already in the tree", n.Path)
+		}
+		node.tags = n.Tags
+		return nil
+	}
+	parent := e.dir(path.Dir(n.Path))
+	if parent == nil {
+		return fmt.Errorf("format: %s: %s is a file in the tree", n.Path, path.Dir(n.Path))
+	}
+	node := &encodeNode{name: path.Base(n.Path), isDir: n.IsDir, tags: n.Tags}
+	parent.children = append(parent.children, node)
+	e.nodes[n.Path] = node
+	return nil
+}
+
+// dir возвращает директорию древа, добавляя её и недостающих родителей (nil, если на пути файл)
+func (e *Encoder) dir(p string) *encodeNode {
+	if node, ok := e.nodes[p]; ok {
+		if !node.isDir {
+			return nil
+		}
+		return node
+	}
+	parent := e.dir(path.Dir(p))
+	if parent == nil {
+		return nil
+	}
+	node := &encodeNode{name: path.Base(p), isDir: true}
+	parent.children = append(parent.children, node)
+	e.nodes[p] = node
+	return node
+}
+
+// WriteFile печатает секцию с содержимым файла из r; файл должен быть в древе
+// древо печатается при первом вызове, после него WriteTreeNode уже нельзя
+func (e *Encoder) WriteFile(p string, meta FileMeta, r io.Reader) error {
+	if node, ok := e.nodes[p]; !ok || node.isDir {
+		return fmt.Errorf("format: %s is not a file in the tree", p)
+	}
+	e.writeTree()
+	if e.opts.Canonical && e.files > 0 {
+		e.printf("\n")
+	}
+	e.files++
+	if e.opts.ByteExact {
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		if NeedsBase64(data) {
+			meta.Notes = append(slices.Clip(meta.Notes), Base64Note)
+			data = EncodeBase64(data)
+		}
+		r = bytes.NewReader(data)
+	}
+	header := QuoteName(path.Join(e.opts.Root, p))
+	if len(meta.Notes) > 0 {
+		header += " (" + strings.Join(meta.Notes, ", ") + ")"
+	}
+	open := fence
+	if e.opts.FenceLang {
+		open += meta.Lang
+	}
+	e.printf("%s:\n%s\n", header, open)
+	if e.err == nil {
+		_, e.err = io.Copy(e.w, r)
+	}
+	// после содержимого — перевод строки, как при выводе программой; Parse его отбрасывает
+	e.printf("\n%s\n", fence)
+	return e.err
+}
+
+// Close печатает древо, если файлов не было, и постамбулу; w не закрывается
+func (e *Encoder) Close() error {
+	e.writeTree()
+	if e.opts.Postamble != "" {
+		e.printf("\n%s\n", e.opts.Postamble)
+	}
+	return e.err
+}
+
+// writeTree печатает преамбулу и древо, если они ещё не напечатаны
+func (e *Encoder) writeTree() {
+	if e.tree {
+		return
+	}
+	e.tree = true
+	if e.opts.Preamble != "" {
+		e.printf("%s\n\n", e.opts.Preamble)
+	}
+	e.printf("%s/\n", QuoteName(e.opts.Root))
+	e.writeChildren(e.root, "")
+	// пустая строка отделяет древо от содержимого
+	e.printf("\n")
+}
+
+func (e *Encoder) writeChildren(n *encodeNode, prefix string) {
+	for i, child := range n.children {
+		connector, next := "├── ", "│   "
+		if i == len(n.children)-1 && !e.opts.Canonical {
+			connector, next = "└── ", "    "
+		}
+		line := QuoteName(child.name)
+		if child.isDir {
+			line += "/"
+		}
+		for _, tag := range child.tags {
+			line += " [" + tag + "]"
+		}
+		e.printf("%s%s%s\n", prefix, connector, line)
+		if child.isDir {
+			e.writeChildren(child, prefix+next)
+		}
+	}
+}
+
+// printf пишет в w, пока не случилась ошибка
+func (e *Encoder) printf(format string, args ...any) {
+	if e.err != nil {
+		return
+	}
+	if _, err := fmt.Fprintf(e.w, format, args...); err != nil {
+		e.err = err
+	}
+}
@@ -0,0 +1,42 @@
+package format
+
+import (
+	"bytes"
+	"encoding/base64"
+)
+
+// Base64Note — пометка в заголовке секции, содержимое которой записано в base64 (--byte-exact):
+// так выводятся файлы, которые в обычном блоке Parse вернул бы не байт в байт
+const Base64Note = "base64"
+
+// base64Width — длина строки base64, как у MIME
+const base64Width = 76
+
+// NeedsBase64 сообщает, что содержимое нельзя вывести обычным блоком без риска его исказить:
+// строка из одного fence внутри файла выглядела бы как конец блока
+func NeedsBase64(data []byte) bool {
+	for line := range bytes.Lines(data) {
+		if string(bytes.TrimSuffix(line, []byte("\n"))) == fence {
+			return true
+		}
+	}
+	return false
+}
+
+// EncodeBase64 записывает содержимое в base64 строками по 76 символов
+func EncodeBase64(data []byte) []byte {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	var out bytes.Buffer
+	for len(encoded) > base64Width {
+		out.WriteString(encoded[:base64Width])
+		out.WriteByte('\n')
+		encoded = encoded[base64Width:]
+	}
+	out.WriteString(encoded)
+	return out.Bytes()
+}
+
+// decodeBase64 — обратное к EncodeBase64
+func decodeBase64(content []byte) ([]byte, error) {
+	return base64.StdEncoding.DecodeString(string(bytes.ReplaceAll(content, []byte("\n"), nil)))
+}
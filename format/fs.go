@@ -0,0 +1,141 @@
+package format
+
+import (
+	"io"
+	"io/fs"
+	"slices"
+	"strings"
+	"testing/fstest"
+)
+
+// дамп как файловая система только для чтения: можно натравить на него go/packages, линтер или fs.WalkDir,
+// не распаковывая на диск
+// в ней есть директории из древа и файлы, содержимое которых есть в дампе;
+// файлы, показанные только в древе (бинарные, слишком большие и т.д.), в неё не попадают,
+// а обрезанные (--head, --max-file-size) лежат в том виде, в каком они в дампе
+
+// ParseFS разбирает дамп из r и возвращает его содержимое как fs.FS; корень дампа — "."
+func ParseFS(r io.Reader) (fs.FS, error) {
+	d, err := Parse(r)
+	if err != nil {
+		return nil, err
+	}
+	return d.FS(), nil
+}
+
+// FS возвращает содержимое дампа как файловую систему только для чтения
+func (d *Dump) FS() fs.FS {
+	m := fstest.MapFS{}
+	for _, e := range d.Entries {
+		if e.IsDir && fs.ValidPath(e.Path) {
+			m[e.Path] = &fstest.MapFile{Mode: fs.ModeDir | 0o555}
+		}
+	}
+	for _, f := range d.Files {
+		if fs.ValidPath(f.Path) {
+			m[f.Path] = &fstest.MapFile{Data: f.Content, Mode: 0o444}
+		}
+	}
+	// MapFS можно менять через приведение типа, поэтому наружу отдаём только интерфейсы fs
+	return readOnlyFS{m}
+}
+
+// Restore разбирает дамп из r и возвращает то, что по нему можно воссоздать, как файловую систему в памяти
+// (см. Dump.RestoreFS): из неё можно собрать tar или контекст docker build, не трогая диск
+func Restore(r io.Reader) (fs.FS, error) {
+	d, err := Parse(r)
+	if err != nil {
+		return nil, err
+	}
+	fsys, _ := d.RestoreFS()
+	return fsys, nil
+}
+
+// Skipped — файл из древа, который по дампу воссоздать нельзя
+type Skipped struct {
+	Path   string
+	Reason string // "no contents in the dump", "truncated in the dump", "unsafe path" или "invalid path"
+}
+
+// RestoreEntry — директория или файл, которые по дампу можно воссоздать
+type RestoreEntry struct {
+	Path  string // от корня через "/", с исходными байтами имён (не обязательно UTF-8, см. QuoteName)
+	IsDir bool
+	Data  []byte // содержимое файла (у пустых файлов — пустое)
+}
+
+// RestoreEntries возвращает в порядке древа то, что по дампу можно воссоздать, и файлы, которые воссоздать нельзя
+// пустые файлы воссоздаются (их содержимое известно по пометке в древе), обрезанные — нет: начало файла вместо
+// файла при развёртывании хуже, чем его отсутствие
+// пути проверяются только на то, что не выводят за корень (абсолютные, "..", пустые части — "unsafe path"):
+// имена не в UTF-8 и с управляющими символами на диске бывают, и на диск их можно вернуть как были
+func (d *Dump) RestoreEntries() ([]RestoreEntry, []Skipped) {
+	contents := make(map[string]File, len(d.Files))
+	for _, f := range d.Files {
+		contents[f.Path] = f
+	}
+	var entries []RestoreEntry
+	var skipped []Skipped
+	for _, e := range d.Entries {
+		if !safeRestorePath(e.Path) {
+			skipped = append(skipped, Skipped{e.Path, "unsafe path"})
+			continue
+		}
+		if e.IsDir {
+			entries = append(entries, RestoreEntry{Path: e.Path, IsDir: true})
+			continue
+		}
+		file, ok := contents[e.Path]
+		switch {
+		case slices.Contains(e.Tags, EmptyFileTag):
+			entries = append(entries, RestoreEntry{Path: e.Path, Data: []byte{}})
+		case !ok:
+			skipped = append(skipped, Skipped{e.Path, "no contents in the dump"})
+		case file.Truncated:
+			skipped = append(skipped, Skipped{e.Path, "truncated in the dump"})
+		default:
+			entries = append(entries, RestoreEntry{Path: e.Path, Data: file.Content})
+		}
+	}
+	return entries, skipped
+}
+
+// safeRestorePath сообщает, что путь из дампа остаётся внутри корня: он относительный, без "." и ".."
+// и без пустых частей; байты имён не проверяются
+func safeRestorePath(p string) bool {
+	if p == "" || strings.HasPrefix(p, "/") || strings.ContainsRune(p, 0) {
+		return false
+	}
+	for _, part := range strings.Split(p, "/") {
+		if part == "" || part == "." || part == ".." {
+			return false
+		}
+	}
+	return true
+}
+
+// RestoreFS возвращает то же, что RestoreEntries, файловой системой в памяти; права — 0755 у директорий и 0644
+// у файлов; fs.FS допускает только пути в UTF-8 (fs.ValidPath), остальные попадают в пропущенные как "invalid path"
+func (d *Dump) RestoreFS() (fs.FS, []Skipped) {
+	entries, skipped := d.RestoreEntries()
+	m := fstest.MapFS{}
+	for _, e := range entries {
+		switch {
+		case !fs.ValidPath(e.Path):
+			skipped = append(skipped, Skipped{e.Path, "invalid path"})
+		case e.IsDir:
+			m[e.Path] = &fstest.MapFile{Mode: fs.ModeDir | 0o755}
+		default:
+			m[e.Path] = &fstest.MapFile{Data: e.Data, Mode: 0o644}
+		}
+	}
+	return readOnlyFS{m}, skipped
+}
+
+// readOnlyFS прячет MapFS за интерфейсами fs
+type readOnlyFS struct{ m fstest.MapFS }
+
+func (f readOnlyFS) Open(name string) (fs.File, error)          { return f.m.Open(name) }
+func (f readOnlyFS) ReadFile(name string) ([]byte, error)       { return f.m.ReadFile(name) }
+func (f readOnlyFS) ReadDir(name string) ([]fs.DirEntry, error) { return f.m.ReadDir(name) }
+func (f readOnlyFS) Stat(name string) (fs.FileInfo, error)      { return f.m.Stat(name) }
@@ -0,0 +1,47 @@
+package format
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// в именах файлов бывает что угодно: переводы строк, управляющие символы, байты не в UTF-8
+// выведенные как есть, они ломают древо и заголовки (а перевод строки ещё и подделывает их),
+// поэтому такие имена выводятся в кавычках по правилам Go: "bad\nname", "caf\xe9.txt"
+
+// NeedsQuoting сообщает, нужно ли заключать имя в кавычки при выводе
+func NeedsQuoting(name string) bool {
+	// имя, похожее на пометку древа (" [F3a9c01]" в конце), тоже экранируем, иначе пометка «съест» часть имени
+	if !utf8.ValidString(name) || strings.HasPrefix(name, `"`) || treeAnnotation.MatchString(name) {
+		return true
+	}
+	for _, r := range name {
+		if !unicode.IsPrint(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// QuoteName возвращает имя (или путь) в виде для дампа: обычные имена как есть,
+// проблемные — в кавычках с экранированием, так что в выводе остаётся одна печатная строка
+// имя, начинающееся с кавычки, тоже экранируется, чтобы его нельзя было спутать с экранированным
+func QuoteName(name string) string {
+	if !NeedsQuoting(name) {
+		return name
+	}
+	return strconv.Quote(name)
+}
+
+// UnquoteName обращает QuoteName: возвращает исходные байты имени
+func UnquoteName(name string) string {
+	if !strings.HasPrefix(name, `"`) {
+		return name
+	}
+	if raw, err := strconv.Unquote(name); err == nil {
+		return raw
+	}
+	return name
+}
@@ -0,0 +1,344 @@
+package format
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// здесь лежит разбор дампа, который печатает directory-serialization:
+// сначала древо директории, затем пустая строка и содержимое текстовых файлов в виде
+//
+//	root/path/to/file:
+//	```
+//	...содержимое...
+//	```
+//
+// перед древом и после содержимого может стоять произвольный текст (преамбула и постамбула),
+// отделённый пустой строкой
+
+// Entry — элемент древа (файл или директория)
+type Entry struct {
+	Path  string // путь относительно корня, через "/"
+	IsDir bool
+	// Tags — пометки в квадратных скобках после имени в порядке вывода: "binary", EmptyFileTag, ID файла и т.п.
+	Tags []string
+}
+
+// File — файл, содержимое которого есть в дампе
+type File struct {
+	Path      string // путь относительно корня, через "/"
+	Content   []byte
+	Truncated bool   // в дампе только начало файла
+	Section   string // подзаголовок раздела с --group-by (без "## "), пусто — без разделов
+}
+
+// Dump — разобранный дамп
+type Dump struct {
+	Root      string  // имя корневой директории (без завершающего "/")
+	Preamble  string  // текст перед древом
+	Postamble string  // текст после содержимого
+	Entries   []Entry // элементы древа в порядке вывода
+	Files     []File  // файлы с содержимым в порядке вывода
+}
+
+const fence = "```"
+
+// Version — версия раскладки текстового дампа; растёт, когда дамп меняется так, что старый Parse
+// разобрал бы его неверно (новые пометки и секции, которые Parse пропускает, версию не меняют)
+const Version = 1
+
+// Parse разбирает дамп из r
+func Parse(r io.Reader) (*Dump, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	// делим только по "\n": bufio.Scanner съел бы "\r" и испортил бы файлы с CRLF
+	lines := strings.Split(string(data), "\n")
+	if len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	// корень древа — первая строка вида "name/", за которой идёт строка древа (или пустая строка для пустой директории)
+	// всё, что выше, — преамбула
+	rootLine := -1
+	for j, line := range lines {
+		if strings.HasSuffix(line, "/") && (j+1 == len(lines) || lines[j+1] == "" || isTreeLine(lines[j+1])) {
+			rootLine = j
+			break
+		}
+	}
+	if rootLine == -1 {
+		return nil, fmt.Errorf("not a dump: no root directory line ending with \"/\" found")
+	}
+
+	d := &Dump{Root: UnquoteName(strings.TrimSuffix(lines[rootLine], "/"))}
+	if rootLine > 0 {
+		d.Preamble = strings.TrimRight(strings.Join(lines[:rootLine], "\n"), "\n")
+	}
+
+	// этап 1: древо идёт до первой пустой строки
+	i := rootLine + 1
+	var stack []string // имена директорий на пути к текущему уровню
+	var empty []string // пустые файлы: их содержимое известно по одному древу
+	for ; i < len(lines) && lines[i] != ""; i++ {
+		depth, name, tags, ok := parseTreeLine(lines[i])
+		if !ok {
+			return nil, fmt.Errorf("line %d: malformed tree line %q", i+1, lines[i])
+		}
+		if depth > len(stack) {
+			return nil, fmt.Errorf("line %d: tree line is nested too deep", i+1)
+		}
+		stack = stack[:depth]
+		isDir := strings.HasSuffix(name, "/")
+		name = UnquoteName(strings.TrimSuffix(name, "/"))
+		path := strings.Join(append(stack[:depth:depth], name), "/")
+		slices.Reverse(tags) // stripTreeAnnotations отдаёт их с конца
+		d.Entries = append(d.Entries, Entry{Path: path, IsDir: isDir, Tags: tags})
+		if !isDir && slices.Contains(tags, EmptyFileTag) {
+			empty = append(empty, path)
+		}
+		if isDir {
+			stack = append(stack, name)
+		}
+	}
+
+	// заголовком считаем только "root/путь:" (или "root/путь (part k/n, lines a-b):") для файла, известного по древу,
+	// это защищает от ложных срабатываний на строки внутри содержимого
+	// known сопоставляет путь в том виде, как он выведен в заголовке (возможно, в кавычках), с настоящим путём
+	known := make(map[string]string)
+	for _, e := range d.Entries {
+		if !e.IsDir {
+			// как при выводе: path.Join, так что у корня "." заголовок — просто путь
+			known[QuoteName(path.Join(d.Root, e.Path))] = e.Path
+		}
+	}
+	header := func(j int) (header, bool) {
+		if j+1 >= len(lines) || !isOpeningFence(lines[j+1]) {
+			return header{}, false
+		}
+		return parseHeader(lines[j], known)
+	}
+	isHeader := func(j int) bool {
+		_, ok := header(j)
+		return ok
+	}
+	// подзаголовок раздела (--group-by): "## метка", пустая строка и заголовок файла
+	isSection := func(j int) bool {
+		return strings.HasPrefix(lines[j], "## ") && j+2 < len(lines) && lines[j+1] == "" && isHeader(j+2)
+	}
+
+	// этап 2: содержимое файлов
+	last := i              // последняя строка, относящаяся к древу или содержимому
+	prevLast := 0          // номер последней строки файла в предыдущей части
+	wraps := map[int]int{} // индекс файла в d.Files → ширина переноса строк, если они переносились
+	section := ""
+	for i++; i < len(lines); i++ {
+		h, ok := header(i)
+		if !ok {
+			if isSection(i) {
+				section = UnquoteName(strings.TrimPrefix(lines[i], "## "))
+			}
+			continue
+		}
+		path := h.path
+		start := i + 2
+		end := -1
+		// закрывающий fence — тот, после которого конец дампа, следующий заголовок (в канонической раскладке —
+		// через пустую строку) или новый раздел
+		for j := start; j < len(lines); j++ {
+			if lines[j] == fence && (j+1 == len(lines) || isHeader(j+1) || lines[j+1] == "" && j+2 < len(lines) && (isHeader(j+2) || isSection(j+2))) {
+				end = j
+				break
+			}
+		}
+		// у последнего блока за закрывающим fence может идти постамбула (после пустой строки),
+		// тогда берём последний подходящий fence
+		if end == -1 {
+			for j := len(lines) - 2; j >= start; j-- {
+				if lines[j] == fence && lines[j+1] == "" {
+					end = j
+					break
+				}
+			}
+		}
+		if end == -1 {
+			return nil, fmt.Errorf("line %d: unterminated content block for %s", i+1, path)
+		}
+		// при выводе после содержимого печатается перевод строки, поэтому склеиваем строки через "\n" без хвоста
+		content := []byte(strings.Join(lines[start:end], "\n"))
+		if h.base64 {
+			if content, err = decodeBase64(content); err != nil {
+				return nil, fmt.Errorf("line %d: invalid base64 content of %s: %v", i+1, path, err)
+			}
+		}
+		if h.preview {
+			// hexdump бинарного файла — не содержимое, в Files его не кладём
+		} else if h.part > 1 && len(d.Files) > 0 && d.Files[len(d.Files)-1].Path == path {
+			// продолжение длинного файла: отбрасываем строки, перекрывающиеся с предыдущей частью
+			prev := &d.Files[len(d.Files)-1]
+			partLines := bytes.SplitAfter(content, []byte("\n"))
+			if skip := prevLast - h.firstLine + 1; skip > 0 && skip <= len(partLines) {
+				partLines = partLines[skip:]
+			}
+			prev.Content = append(prev.Content, bytes.Join(partLines, nil)...)
+		} else {
+			if h.wrap > 0 {
+				wraps[len(d.Files)] = h.wrap
+			}
+			d.Files = append(d.Files, File{Path: path, Content: content, Truncated: h.truncated, Section: section})
+		}
+		prevLast = h.lastLine
+		i = end
+		last = end
+	}
+
+	// переносы длинных строк (--wrap) склеиваем обратно, а у сокращённых документов (--summarize-docs)
+	// убираем строку о пропуске в конце: в файле их нет
+	for i := range d.Files {
+		if width := wraps[i]; width > 0 {
+			d.Files[i].Content = Unwrap(d.Files[i].Content, width)
+		}
+		if d.Files[i].Truncated {
+			d.Files[i].Content = stripElision(d.Files[i].Content)
+		}
+	}
+
+	if len(d.Files) > 0 && last+1 < len(lines) {
+		d.Postamble = strings.TrimLeft(strings.Join(lines[last+1:], "\n"), "\n")
+	}
+	for _, path := range empty {
+		d.Files = append(d.Files, File{Path: path, Content: []byte{}})
+	}
+
+	return d, nil
+}
+
+// header — разобранный заголовок секции содержимого
+type header struct {
+	path                string
+	part                int // номер части (0, если файл выведен целиком)
+	firstLine, lastLine int // диапазон строк части
+	truncated           bool
+	wrap                int  // ширина переноса строк (--wrap), 0 — строки не переносились
+	preview             bool // hexdump начала бинарного файла (--binary-preview), а не его содержимое
+	base64              bool // содержимое в base64 (--byte-exact)
+}
+
+var (
+	partNote  = regexp.MustCompile(`^part (\d+)/(\d+)$`)
+	linesNote = regexp.MustCompile(`^lines (\d+)-(\d+)$`)
+	wrapNote  = regexp.MustCompile(`^wrapped at (\d+) columns$`)
+)
+
+// parseHeader разбирает строку "root/path:" или "root/path (пометки через запятую):"
+// пометки бывают такими: "part k/n", "lines a-b", "wrapped at n columns", "truncated: ...", "summarized: ...",
+// "hexdump of first n bytes", Base64Note;
+// незнакомые пропускаются
+// known — выведенные пути файлов из древа: по ним отличаем скобки в имени файла от пометок
+func parseHeader(line string, known map[string]string) (header, bool) {
+	rest, ok := strings.CutSuffix(line, ":")
+	if !ok {
+		return header{}, false
+	}
+	if path, ok := known[rest]; ok {
+		return header{path: path}, true
+	}
+	if !strings.HasSuffix(rest, ")") {
+		return header{}, false
+	}
+	for i := 0; i < len(rest); i++ {
+		if !strings.HasPrefix(rest[i:], " (") {
+			continue
+		}
+		path, ok := known[rest[:i]]
+		if !ok {
+			continue
+		}
+		h := header{path: path}
+		for _, note := range strings.Split(rest[i+2:len(rest)-1], ", ") {
+			if m := partNote.FindStringSubmatch(note); m != nil {
+				h.part, _ = strconv.Atoi(m[1])
+			} else if m := linesNote.FindStringSubmatch(note); m != nil {
+				h.firstLine, _ = strconv.Atoi(m[1])
+				h.lastLine, _ = strconv.Atoi(m[2])
+			} else if m := wrapNote.FindStringSubmatch(note); m != nil {
+				h.wrap, _ = strconv.Atoi(m[1])
+			} else if note == Base64Note {
+				h.base64 = true
+			} else if note == "hexdump" || strings.HasPrefix(note, "hexdump of ") {
+				h.preview = true
+			} else if strings.HasPrefix(note, "truncated") || strings.HasPrefix(note, "summarized") {
+				h.truncated = true
+			}
+		}
+		return h, true
+	}
+	return header{}, false
+}
+
+// fenceLang — открывающий fence с языком (--fence-lang): "```go", "```go-template", "```c++"
+var fenceLang = regexp.MustCompile("^```[A-Za-z0-9_+#.-]+$")
+
+// isOpeningFence сообщает, открывает ли строка блок содержимого
+func isOpeningFence(line string) bool {
+	return line == fence || fenceLang.MatchString(line)
+}
+
+// isTreeLine сообщает, похожа ли строка на строку древа
+func isTreeLine(line string) bool {
+	_, _, _, ok := parseTreeLine(line)
+	return ok
+}
+
+// treeAnnotation — пометка в конце строки древа: ID файла " [F3a9c01]", цель ярлыка " [-> "https://example.com"]"
+// или причина, по которой содержимого нет или оно обрезано: " [binary]", " [no-match]", " [>64KB]", " [excluded: *.lock]"
+// и число элементов огромной директории " [120000 entries]"
+var treeAnnotation = regexp.MustCompile(` \[(?:F[0-9a-f]{6}(?:\.\d+)?|-> "(?:[^"\\]|\\.)*"|[a-z]+(?:-[a-z]+)*|empty file|encoding suspect|>[0-9.]+[KMG]?B|[0-9]+ entries|excluded: [^\]]*)\]$`)
+
+// EncodingSuspectTag — пометка файла, кодировка которого дальше начала не та, что угадана (--check-encoding)
+const EncodingSuspectTag = "encoding suspect"
+
+// EmptyFileTag — пометка пустого файла в древе: секции содержимого у него нет, пустой блок ``` путал бы парсеры
+const EmptyFileTag = "empty file"
+
+// parseTreeLine разбирает строку древа вида "│   ├── name" и возвращает глубину, имя и пометки (без скобок)
+func parseTreeLine(line string) (int, string, []string, bool) {
+	depth := 0
+	for {
+		switch {
+		case strings.HasPrefix(line, "│   "):
+			line = strings.TrimPrefix(line, "│   ")
+		case strings.HasPrefix(line, "    "):
+			line = strings.TrimPrefix(line, "    ")
+		case strings.HasPrefix(line, "├── "):
+			name, tags := stripTreeAnnotations(strings.TrimPrefix(line, "├── "))
+			return depth, name, tags, true
+		case strings.HasPrefix(line, "└── "):
+			name, tags := stripTreeAnnotations(strings.TrimPrefix(line, "└── "))
+			return depth, name, tags, true
+		default:
+			return 0, "", nil, false
+		}
+		depth++
+	}
+}
+
+// stripTreeAnnotations убирает пометки из конца имени в строке древа и возвращает их (от последней к первой)
+func stripTreeAnnotations(name string) (string, []string) {
+	var tags []string
+	for {
+		loc := treeAnnotation.FindStringIndex(name)
+		if loc == nil {
+			return name, tags
+		}
+		tags = append(tags, name[loc[0]+2:loc[1]-1])
+		name = name[:loc[0]]
+	}
+}
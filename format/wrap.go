@@ -0,0 +1,28 @@
+package format
+
+import (
+	"bytes"
+	"unicode/utf8"
+)
+
+// WrapMarker ставится в конце каждого куска длинной строки, перенесённой по --wrap:
+// строка из width символов и маркера продолжается на следующей
+const WrapMarker = "↩"
+
+// Unwrap склеивает строки, перенесённые по ширине width, обратно
+// перенесённый кусок — ровно width символов и маркер, поэтому исходные строки,
+// которые сами кончаются на "↩", не путаются с переносами
+func Unwrap(data []byte, width int) []byte {
+	lines := bytes.SplitAfter(data, []byte("\n"))
+	var out bytes.Buffer
+	out.Grow(len(data))
+	for _, line := range lines {
+		body, cut := bytes.CutSuffix(line, []byte(WrapMarker+"\n"))
+		if cut && utf8.RuneCount(body) == width {
+			out.Write(body)
+			continue
+		}
+		out.Write(line)
+	}
+	return out.Bytes()
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// hookMarker отличает наши git-хуки от чужих, чтобы случайно не затереть последние
+const hookMarker = "# installed by directory-serialization hook install"
+
+// runHook реализует подкоманду hook: установку и удаление git-хука,
+// который после коммита (или перед push) пересоздаёт дамп репозитория
+func runHook(args []string) int {
+	if len(args) == 0 || (args[0] != "install" && args[0] != "uninstall") {
+		fmt.Fprintln(os.Stderr, "Usage: directory-serialization hook install|uninstall [flags] [-- dump flags...]")
+		return 2
+	}
+	action := args[0]
+
+	fs := flag.NewFlagSet("hook "+action, flag.ExitOnError)
+	hookType := fs.String("type", "post-commit", "hook to install: post-commit or pre-push")
+	output := fs.String("output", ".git/directory-serialization.md", "where the hook writes the dump, relative to the repository root")
+	binary := fs.String("binary", defaultBinary(), "command the hook runs")
+	force := fs.Bool("force", false, "overwrite an existing hook that was not installed by this tool")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: directory-serialization hook %s [flags] [-- dump flags...]\n\nFlags:\n", action)
+		fs.PrintDefaults()
+	}
+	fs.Parse(args[1:])
+	dumpFlags := fs.Args() // всё после "--" передаётся сериализатору как есть
+
+	if *hookType != "post-commit" && *hookType != "pre-push" {
+		fmt.Fprintf(os.Stderr, "Error: unsupported hook type %q (expected post-commit or pre-push)\n", *hookType)
+		return 2
+	}
+
+	// учитываем core.hooksPath и worktree, поэтому спрашиваем путь у самого git
+	out, err := exec.Command("git", "rev-parse", "--git-path", "hooks").Output()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: not inside a git repository: %v\n", err)
+		return 1
+	}
+	hookPath := filepath.Join(strings.TrimSpace(string(out)), *hookType)
+
+	existing, err := os.ReadFile(hookPath)
+	ours := err == nil && bytes.Contains(existing, []byte(hookMarker))
+	if err == nil && !ours && !*force {
+		fmt.Fprintf(os.Stderr, "Error: %s already exists and was not installed by this tool (use --force to overwrite)\n", hookPath)
+		return 1
+	}
+
+	if action == "uninstall" {
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Nothing to uninstall: %s does not exist\n", hookPath)
+			return 0
+		}
+		if err := os.Remove(hookPath); err != nil {
+			fmt.Fprintf(os.Stderr, "Error removing %s: %v\n", hookPath, err)
+			return 1
+		}
+		fmt.Fprintf(os.Stderr, "Removed %s\n", hookPath)
+		return 0
+	}
+
+	if err := os.MkdirAll(filepath.Dir(hookPath), 0o755); err != nil {
+		fmt.Fprintf(os.Stderr, "Error creating %s: %v\n", filepath.Dir(hookPath), err)
+		return 1
+	}
+	script := hookScript(*binary, *output, dumpFlags)
+	if err := os.WriteFile(hookPath, []byte(script), 0o755); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing %s: %v\n", hookPath, err)
+		return 1
+	}
+	fmt.Fprintf(os.Stderr, "Installed %s hook: %s (dump goes to %s)\n", *hookType, hookPath, *output)
+	return 0
+}
+
+// hookScript собирает текст хука
+// хук никогда не завершается с ошибкой: неудавшийся дамп не должен мешать коммиту или push
+func hookScript(binary, output string, dumpFlags []string) string {
+	cmd := []string{shellQuote(binary)}
+	for _, f := range dumpFlags {
+		cmd = append(cmd, shellQuote(f))
+	}
+
+	var b strings.Builder
+	b.WriteString("#!/bin/sh\n")
+	b.WriteString(hookMarker + "\n")
+	b.WriteString("root=\"$(git rev-parse --show-toplevel)\" || exit 0\n")
+	b.WriteString("out=\"$root\"/" + shellQuote(output) + "\n")
+	// пишем во временный файл вне рабочего дерева (иначе он попадёт в сам дамп) и переносим,
+	// чтобы не оставить полудамп
+	b.WriteString("tmp=\"$(mktemp)\" || exit 0\n")
+	b.WriteString(strings.Join(cmd, " ") + " \"$root\" > \"$tmp\" && mv \"$tmp\" \"$out\" ||\n")
+	b.WriteString("\t{ rm -f \"$tmp\"; echo \"directory-serialization: failed to update $out\" >&2; }\n")
+	b.WriteString("exit 0\n")
+	return b.String()
+}
+
+// defaultBinary возвращает путь к текущему исполняемому файлу,
+// а при запуске через go run (бинарник во временной директории) — просто имя программы из PATH
+func defaultBinary() string {
+	exe, err := os.Executable()
+	if err != nil || strings.Contains(exe, string(filepath.Separator)+"go-build") {
+		return "directory-serialization"
+	}
+	return exe
+}
+
+// shellQuote заключает строку в одинарные кавычки для sh
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
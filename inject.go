@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/asquebay/directory-serialization/serializer"
+)
+
+// --inject path=command добавляет в дамп вывод команды как файл path (см. serializer.VirtualFile):
+//
+//	--inject context/go-env.txt="go env" --inject context/git-log.txt="git log -5 --stat"
+//
+// команда выполняется оболочкой (sh -c, на Windows — cmd /C) в сериализуемой директории, если она на диске,
+// иначе — в текущей; её stderr идёт в наш stderr, а если она завершилась с ошибкой, дамп не пишется
+
+// injection — одно --inject
+type injection struct {
+	path    string // путь файла от корня через "/"
+	command string // команда оболочки
+}
+
+// parseInjection разбирает "path=command"
+func parseInjection(s string) (injection, error) {
+	p, command, ok := strings.Cut(s, "=")
+	p = strings.TrimPrefix(strings.ReplaceAll(p, "\\", "/"), "./")
+	if !ok || strings.TrimSpace(command) == "" || !fs.ValidPath(p) || p == "." {
+		return injection{}, fmt.Errorf("expected path=command with a relative path, got %q", s)
+	}
+	return injection{path: p, command: command}, nil
+}
+
+// runInjections выполняет команды --inject в директории dir ("" — текущая) и возвращает их вывод как файлы
+func runInjections(injections []injection, dir string) ([]serializer.VirtualFile, error) {
+	var files []serializer.VirtualFile
+	for _, inj := range injections {
+		var cmd *exec.Cmd
+		if runtime.GOOS == "windows" {
+			cmd = exec.Command("cmd", "/C", inj.command)
+		} else {
+			cmd = exec.Command("sh", "-c", inj.command)
+		}
+		var out bytes.Buffer
+		cmd.Dir, cmd.Stdout, cmd.Stderr = dir, &out, os.Stderr
+		if err := cmd.Run(); err != nil {
+			return nil, fmt.Errorf("%s: %q: %v", inj.path, inj.command, err)
+		}
+		files = append(files, serializer.VirtualFile{Path: inj.path, Data: out.Bytes(), ModTime: time.Now()})
+	}
+	return files, nil
+}
@@ -0,0 +1,76 @@
+package benchtree
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// синтетические деревья для замеров скорости: на них меряют подкоманда bench и бенчмарки пакета serializer,
+// так что цифры одного и другого сравнимы; содержимое зависит только от имени дерева и масштаба
+
+// Tree — синтетическое дерево
+type Tree struct {
+	Name string
+	Desc string
+	// Make создаёт дерево в директории root; scale увеличивает или уменьшает его
+	Make func(root string, scale float64) error
+}
+
+// Trees — деревья, на которых меряется скорость
+var Trees = []Tree{
+	{"wide", "one directory with many small files", func(root string, scale float64) error {
+		return Files(root, scaled(5000, scale), 400)
+	}},
+	{"deep", "a long chain of nested directories", func(root string, scale float64) error {
+		dir := root
+		for i := range scaled(200, scale) {
+			dir = filepath.Join(dir, fmt.Sprintf("d%03d", i))
+			if err := Files(dir, 5, 400); err != nil {
+				return err
+			}
+		}
+		return nil
+	}},
+	{"big", "a few large files", func(root string, scale float64) error {
+		return Files(root, 8, scaled(4<<20, scale))
+	}},
+	{"small", "many directories of tiny files", func(root string, scale float64) error {
+		for i := range scaled(100, scale) {
+			if err := Files(filepath.Join(root, fmt.Sprintf("pkg%03d", i)), 100, 120); err != nil {
+				return err
+			}
+		}
+		return nil
+	}},
+}
+
+// scaled умножает n на scale, но не даёт опуститься ниже 1
+func scaled(n int, scale float64) int {
+	return max(1, int(float64(n)*scale))
+}
+
+// Files создаёт в dir count исходников на Go примерно по size байт
+func Files(dir string, count, size int) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	for i := range count {
+		if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("file%05d.go", i)), Source(i, size), 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Source генерирует исходник примерно из size байт; содержимое зависит только от seed
+func Source(seed, size int) []byte {
+	var b strings.Builder
+	b.Grow(size + 64)
+	fmt.Fprintf(&b, "package bench\n\n// File%d is generated by the bench subcommand\n", seed)
+	for line := 0; b.Len() < size; line++ {
+		fmt.Fprintf(&b, "func f%d_%d(x int) int { return x*%d + %d } // синтетика\n", seed, line, line%97, seed%13)
+	}
+	return []byte(b.String())
+}
@@ -0,0 +1,182 @@
+package lang
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+// здесь лежит определение языка файла по имени и расширению
+// одна и та же таблица используется для меток у блоков кода, группировки (--group-by lang) и статистики,
+// так что язык у файла везде один; таблицу можно дополнить или поправить своими правилами (".tpl=go-template")
+// ID языка — короткое имя в нижнем регистре, то самое, что ставится после ``` в Markdown ("go", "python")
+
+// Table — таблица соответствия имён и расширений файлов языкам
+// ключи: расширения с точкой в нижнем регистре (".go") и имена файлов в нижнем регистре ("makefile")
+type Table struct {
+	rules map[string]string
+}
+
+// builtin — встроенные правила
+var builtin = map[string]string{
+	".go": "go", ".mod": "go-mod", ".sum": "go-sum",
+	".c": "c", ".h": "c", ".cc": "cpp", ".cpp": "cpp", ".cxx": "cpp", ".hpp": "cpp",
+	".cs": "csharp", ".java": "java", ".kt": "kotlin", ".kts": "kotlin", ".scala": "scala",
+	".rs": "rust", ".swift": "swift", ".m": "objectivec", ".zig": "zig",
+	".py": "python", ".rb": "ruby", ".php": "php", ".pl": "perl", ".lua": "lua", ".r": "r",
+	".js": "javascript", ".mjs": "javascript", ".cjs": "javascript", ".jsx": "jsx",
+	".ts": "typescript", ".tsx": "tsx",
+	".html": "html", ".htm": "html", ".css": "css", ".scss": "scss", ".sass": "sass", ".less": "less",
+	".sh": "bash", ".bash": "bash", ".zsh": "zsh", ".fish": "fish", ".ps1": "powershell",
+	".bat": "batch", ".cmd": "batch",
+	".sql": "sql", ".proto": "protobuf", ".graphql": "graphql",
+	".json": "json", ".yaml": "yaml", ".yml": "yaml", ".toml": "toml", ".xml": "xml",
+	".ini": "ini", ".cfg": "ini", ".conf": "conf", ".env": "dotenv",
+	".md": "markdown", ".markdown": "markdown", ".rst": "rst", ".txt": "text", ".tex": "latex",
+	".csv": "csv", ".tsv": "tsv",
+	".nix": "nix", ".tf": "terraform", ".hcl": "hcl",
+	"makefile": "makefile", "gnumakefile": "makefile", "dockerfile": "dockerfile",
+	"go.mod": "go-mod", "go.sum": "go-sum",
+}
+
+// names — человекочитаемые названия языков для подзаголовков и статистики
+var names = map[string]string{
+	"go": "Go", "go-mod": "Go modules", "go-sum": "Go modules", "go-template": "Go templates",
+	"c": "C", "cpp": "C++", "csharp": "C#", "java": "Java", "kotlin": "Kotlin", "scala": "Scala",
+	"rust": "Rust", "swift": "Swift", "objectivec": "Objective-C", "zig": "Zig",
+	"python": "Python", "ruby": "Ruby", "php": "PHP", "perl": "Perl", "lua": "Lua", "r": "R",
+	"javascript": "JavaScript", "jsx": "JavaScript", "typescript": "TypeScript", "tsx": "TypeScript",
+	"html": "HTML", "css": "CSS", "scss": "CSS", "sass": "CSS", "less": "CSS",
+	"bash": "Shell", "sh": "Shell", "zsh": "Shell", "fish": "Shell", "powershell": "PowerShell", "batch": "Batch",
+	"sql": "SQL", "protobuf": "Protocol Buffers", "graphql": "GraphQL",
+	"json": "JSON", "yaml": "YAML", "toml": "TOML", "xml": "XML",
+	"ini": "INI", "conf": "Config", "dotenv": "Config",
+	"markdown": "Markdown", "rst": "reStructuredText", "text": "Text", "latex": "TeX",
+	"csv": "CSV", "tsv": "CSV",
+	"nix": "Nix", "terraform": "Terraform", "hcl": "HCL",
+	"makefile": "Makefile", "dockerfile": "Dockerfile",
+	"tcl": "Tcl", "awk": "AWK", "sed": "sed", "applescript": "AppleScript",
+}
+
+// Default возвращает новую таблицу со встроенными правилами
+func Default() *Table {
+	t := &Table{rules: make(map[string]string, len(builtin))}
+	for k, v := range builtin {
+		t.rules[k] = v
+	}
+	return t
+}
+
+// Set добавляет или заменяет правило: pattern — расширение (".tpl") или имя файла ("Jenkinsfile")
+// пустой id убирает правило
+func (t *Table) Set(pattern, id string) {
+	pattern = strings.ToLower(pattern)
+	if id == "" {
+		delete(t.rules, pattern)
+		return
+	}
+	t.rules[pattern] = id
+}
+
+// Lookup возвращает ID языка файла или "", если язык неизвестен
+// сначала ищется имя файла целиком, затем расширение
+func (t *Table) Lookup(filePath string) string {
+	base := strings.ToLower(path.Base(strings.ReplaceAll(filePath, "\\", "/")))
+	if id, ok := t.rules[base]; ok {
+		return id
+	}
+	return t.rules[path.Ext(base)]
+}
+
+// Name возвращает человекочитаемое название языка по ID (для неизвестных ID — сам ID)
+func Name(id string) string {
+	if name, ok := names[id]; ok {
+		return name
+	}
+	return id
+}
+
+// Load читает правила вида "pattern=id", по одному в строке или через запятую
+// пустые строки и строки, начинающиеся с "#", пропускаются
+func (t *Table) Load(r io.Reader) error {
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		for _, rule := range strings.Split(line, ",") {
+			pattern, id, ok := strings.Cut(strings.TrimSpace(rule), "=")
+			pattern, id = strings.TrimSpace(pattern), strings.TrimSpace(id)
+			if !ok || pattern == "" {
+				return fmt.Errorf("line %d: expected pattern=language, got %q", n, rule)
+			}
+			t.Set(pattern, id)
+		}
+	}
+	return sc.Err()
+}
+
+// interpreters сопоставляет интерпретаторы из shebang языкам
+var interpreters = map[string]string{
+	"sh": "bash", "bash": "bash", "dash": "bash", "ash": "bash", "ksh": "bash", "zsh": "zsh", "fish": "fish",
+	"python": "python", "pypy": "python", "ruby": "ruby", "perl": "perl", "php": "php", "lua": "lua",
+	"node": "javascript", "nodejs": "javascript", "deno": "typescript", "bun": "javascript", "ts-node": "typescript",
+	"tclsh": "tcl", "wish": "tcl", "awk": "awk", "gawk": "awk", "sed": "sed", "make": "makefile",
+	"Rscript": "r", "pwsh": "powershell", "osascript": "applescript", "nix-shell": "bash",
+}
+
+// Detect определяет язык по имени файла, а если по имени не понять (Makefile без правила, configure, скрипты
+// без расширения) — по первой строке содержимого: shebang ("#!/usr/bin/env python3"), "%YAML", "<?xml", "<?php"
+func (t *Table) Detect(filePath string, head []byte) string {
+	if id := t.Lookup(filePath); id != "" {
+		return id
+	}
+	return DetectContent(head)
+}
+
+// DetectContent определяет язык по первой строке содержимого; "" — не удалось
+func DetectContent(head []byte) string {
+	line, _, _ := strings.Cut(string(head), "\n")
+	line = strings.TrimSpace(strings.TrimPrefix(line, "\uFEFF"))
+	switch {
+	case strings.HasPrefix(line, "#!"):
+		return shebang(line[2:])
+	case strings.HasPrefix(line, "%YAML"):
+		return "yaml"
+	case strings.HasPrefix(line, "<?xml"):
+		return "xml"
+	case strings.HasPrefix(line, "<?php"):
+		return "php"
+	}
+	return ""
+}
+
+// shebang разбирает "/usr/bin/env -S python3 -u" и возвращает язык интерпретатора
+func shebang(s string) string {
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return ""
+	}
+	name := path.Base(fields[0])
+	if name == "env" {
+		// у env бывают свои флаги (-S, -i) и присваивания VAR=value перед командой
+		name = ""
+		for _, f := range fields[1:] {
+			if !strings.HasPrefix(f, "-") && !strings.Contains(f, "=") {
+				name = path.Base(f)
+				break
+			}
+		}
+	}
+	if id, ok := interpreters[name]; ok {
+		return id
+	}
+	// версии в имени: python3, python3.12, perl5, ruby3.2
+	if id, ok := interpreters[strings.TrimRight(name, "0123456789.")]; ok {
+		return id
+	}
+	return ""
+}
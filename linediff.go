@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// построчный diff для подкоманды diff: алгоритм Майерса (кратчайший скрипт правок) и вывод в unified-формате
+// общие начало и конец отрезаются заранее; если правок слишком много, файл показывается заменой целиком —
+// история шагов Майерса растёт квадратично от числа правок
+
+const diffMaxEdits = 2000
+
+// diffOp — строка скрипта правок: ' ' — общая, '-' — только в старом файле, '+' — только в новом
+type diffOp struct {
+	kind byte
+	line []byte // с "\n" на конце, кроме последней строки файла без перевода строки
+}
+
+func splitLines(data []byte) [][]byte {
+	var lines [][]byte
+	for line := range bytes.Lines(data) {
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// editScript возвращает скрипт правок, превращающий a в b
+func editScript(a, b [][]byte) []diffOp {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && bytes.Equal(a[prefix], b[prefix]) {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && bytes.Equal(a[len(a)-1-suffix], b[len(b)-1-suffix]) {
+		suffix++
+	}
+	var ops []diffOp
+	for _, line := range a[:prefix] {
+		ops = append(ops, diffOp{' ', line})
+	}
+	ops = append(ops, myers(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for _, line := range a[len(a)-suffix:] {
+		ops = append(ops, diffOp{' ', line})
+	}
+	return ops
+}
+
+// myers — жадный алгоритм Майерса: v[k] — самый дальний x на диагонали k = x - y после d правок;
+// trace[d] хранит v до шага d (только диагонали -d-1..d+1), по нему путь восстанавливается с конца
+func myers(a, b [][]byte) []diffOp {
+	n, m := len(a), len(b)
+	limit := min(n+m, diffMaxEdits)
+	off := limit + 1
+	v := make([]int, 2*limit+3)
+	var trace [][]int
+	for d := 0; d <= limit; d++ {
+		trace = append(trace, append([]int(nil), v[off-d-1:off+d+2]...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || k != d && v[off+k-1] < v[off+k+1] {
+				x = v[off+k+1]
+			} else {
+				x = v[off+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && bytes.Equal(a[x], b[y]) {
+				x, y = x+1, y+1
+			}
+			v[off+k] = x
+			if x >= n && y >= m {
+				return myersPath(a, b, trace)
+			}
+		}
+	}
+	// правок больше предела: старый файл целиком удалён, новый целиком добавлен
+	ops := make([]diffOp, 0, n+m)
+	for _, line := range a {
+		ops = append(ops, diffOp{'-', line})
+	}
+	for _, line := range b {
+		ops = append(ops, diffOp{'+', line})
+	}
+	return ops
+}
+
+// myersPath восстанавливает скрипт правок по истории шагов
+func myersPath(a, b [][]byte, trace [][]int) []diffOp {
+	var rev []diffOp
+	x, y := len(a), len(b)
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := func(k int) int { return trace[d][k+d+1] }
+		k := x - y
+		prevK := k - 1
+		if k == -d || k != d && v(k-1) < v(k+1) {
+			prevK = k + 1
+		}
+		prevX := v(prevK)
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x, y = x-1, y-1
+			rev = append(rev, diffOp{' ', a[x]})
+		}
+		if d == 0 {
+			break
+		}
+		if x == prevX {
+			rev = append(rev, diffOp{'+', b[prevY]})
+		} else {
+			rev = append(rev, diffOp{'-', a[prevX]})
+		}
+		x, y = prevX, prevY
+	}
+	ops := make([]diffOp, len(rev))
+	for i, op := range rev {
+		ops[len(rev)-1-i] = op
+	}
+	return ops
+}
+
+// writeHunks пишет скрипт правок блоками "@@ -a,n +b,m @@" с context общими строками вокруг изменений
+func writeHunks(out io.Writer, ops []diffOp, context int) {
+	for start := 0; start < len(ops); {
+		first := start
+		for first < len(ops) && ops[first].kind == ' ' {
+			first++
+		}
+		if first == len(ops) {
+			return
+		}
+		// блок продолжается, пока между изменениями не больше 2*context общих строк
+		last := first
+		for i := first + 1; i < len(ops); i++ {
+			if ops[i].kind != ' ' {
+				if i-last-1 > 2*context {
+					break
+				}
+				last = i
+			}
+		}
+		from, to := max(first-context, start), min(last+context+1, len(ops))
+		// номера строк, с которых начинается блок: считаем строки старого и нового файла до него
+		aLine, bLine := 0, 0
+		for _, op := range ops[:from] {
+			if op.kind != '+' {
+				aLine++
+			}
+			if op.kind != '-' {
+				bLine++
+			}
+		}
+		aLen, bLen := 0, 0
+		for _, op := range ops[from:to] {
+			if op.kind != '+' {
+				aLen++
+			}
+			if op.kind != '-' {
+				bLen++
+			}
+		}
+		fmt.Fprintf(out, "@@ -%s +%s @@\n", hunkRange(aLine, aLen), hunkRange(bLine, bLen))
+		for _, op := range ops[from:to] {
+			out.Write([]byte{op.kind})
+			out.Write(op.line)
+			if !bytes.HasSuffix(op.line, []byte("\n")) {
+				io.WriteString(out, "\n\\ No newline at end of file\n")
+			}
+		}
+		start = to
+	}
+}
+
+// hunkRange — диапазон строк в заголовке блока: пустой диапазон указывает на строку перед ним, длина 1 не пишется
+func hunkRange(before, n int) string {
+	switch n {
+	case 0:
+		return fmt.Sprintf("%d,0", before)
+	case 1:
+		return fmt.Sprintf("%d", before+1)
+	}
+	return fmt.Sprintf("%d,%d", before+1, n)
+}
@@ -1,165 +1,234 @@
 package main
 
 import (
+	"bufio"
 	"fmt"
+	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
-	"sort"
 
-	"github.com/asquebay/directory-serialization/detector"
+	"github.com/asquebay/directory-serialization/serializer"
 )
 
-// fileInfo содержит путь к файлу и флаг, является ли он текстовым
-type fileInfo struct {
-	relPath string
-	isText  bool
-}
-
-// walkDir возвращает слайс структур fileInfo
-func walkDir(currentDir, baseRelPath, prefix string) ([]fileInfo, error) {
-	f, err := os.Open(currentDir)
-	if err != nil {
-		return nil, err
-	}
-	defer f.Close()
+// сама сериализация живёт в пакете serializer; здесь только разбор флагов, подкоманды и stdout/stderr
 
-	items, err := f.Readdir(-1)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error reading directory %s: %v\n", currentDir, err)
-		// НЕ возвращаем ошибку, чтобы продолжить обход других директорий
+func main() {
+	// подкоманды; для директории с таким же именем пишите ./verify, ./hook и т.д.
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "verify":
+			os.Exit(runVerify(os.Args[2:]))
+		case "diff":
+			os.Exit(runDiff(os.Args[2:]))
+		case "view":
+			os.Exit(runView(os.Args[2:]))
+		case "merge":
+			os.Exit(runMerge(os.Args[2:]))
+		case "hook":
+			os.Exit(runHook(os.Args[2:]))
+		case "review":
+			os.Exit(runReview(os.Args[2:]))
+		case "restore-xattrs":
+			os.Exit(runRestoreXattrs(os.Args[2:]))
+		case "restore":
+			os.Exit(runRestore(os.Args[2:]))
+		case "bench":
+			os.Exit(runBench(os.Args[2:]))
+		}
 	}
 
-	// сортируем элементы для консистентного вывода
-	sort.Slice(items, func(i, j int) bool {
-		// директории всегда идут первыми
-		if items[i].IsDir() != items[j].IsDir() {
-			return items[i].IsDir()
-		}
-		return items[i].Name() < items[j].Name()
-	})
+	opts := parseOptions(os.Args[1:])
 
-	var files []fileInfo
-	for i, item := range items {
-		// пропускаем .git и temp (temp я использую для всякой всячины, которую НЕ кладу в проект)
-		if item.Name() == ".git" {
-			continue
+	root := opts.root
+	archive := false
+	// удалённую директорию и образ проверят openSFTP и openImage
+	if opts.sftp == "" && opts.dockerImage == "" {
+		info, err := os.Stat(root)
+		if os.IsNotExist(err) {
+			printError(os.Stderr, &serializer.Error{Code: serializer.CodeRootNotFound, Path: root, Msg: "The directory " + root + " does not exist"})
+			os.Exit(1)
 		}
-		if item.Name() == "temp" {
-			continue
+		if err != nil {
+			printError(os.Stderr, &serializer.Error{Code: serializer.CodeRootAccess, Path: root, Msg: "accessing " + root, Err: err})
+			os.Exit(1)
 		}
+		// вместо директории можно передать архив (см. archive.go)
+		archive = !info.IsDir() && archiveRoot(root) != ""
+		if !info.IsDir() && !archive {
+			printError(os.Stderr, &serializer.Error{Code: serializer.CodeRootNotDir, Path: root, Msg: root + " is not a directory"})
+			os.Exit(1)
+		}
+		if archive && (opts.asCommitted != "" || opts.Sandbox) {
+			fmt.Fprintln(os.Stderr, "Error: an archive cannot be combined with --as-committed or --sandbox")
+			os.Exit(1)
+		}
+	}
 
-		last := i == len(items)-1
-		name := item.Name()
-		childRelPath := filepath.Join(baseRelPath, name)
-
-		if item.IsDir() {
-			// вывод для директории (этап 1)
-			if last {
-				fmt.Println(prefix + "└── " + name + "/")
-			} else {
-				fmt.Println(prefix + "├── " + name + "/")
-			}
-
-			newPrefix := prefix
-			if last {
-				newPrefix += "    "
-			} else {
-				newPrefix += "│   "
-			}
+	// команды --inject выполняются в самой директории, если она на диске
+	if len(opts.injections) > 0 {
+		dir := ""
+		if opts.sftp == "" && opts.dockerImage == "" && !archive && opts.asCommitted == "" {
+			dir = root
+		}
+		virtual, err := runInjections(opts.injections, dir)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: --inject %v\n", err)
+			os.Exit(1)
+		}
+		opts.Virtual = virtual
+	}
 
-			fullPath := filepath.Join(currentDir, name)
-			subFiles, err := walkDir(fullPath, childRelPath, newPrefix)
-			if err != nil {
-				// ошибку логируем, но не прерываем весь процесс
-				fmt.Fprintf(os.Stderr, "Error accessing %s: %v\n", fullPath, err)
-			} else {
-				files = append(files, subFiles...)
-			}
-		} else {
-			// вывод для файла (этап 1)
-			if last {
-				fmt.Println(prefix + "└── " + name)
-			} else {
-				fmt.Println(prefix + "├── " + name)
-			}
+	// авторы файлов по истории git (см. ownership.go); с --as-committed — по истории до этой ревизии
+	if opts.ownership {
+		if archive {
+			fmt.Fprintln(os.Stderr, "Error: --ownership needs a git repository, not an archive")
+			os.Exit(1)
+		}
+		rev := "HEAD"
+		if opts.asCommitted != "" && opts.asCommitted != indexRevision {
+			rev = opts.asCommitted
+		}
+		owners, err := loadOwnership(root, rev, opts.ownershipCache)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: --ownership: %v\n", err)
+			os.Exit(1)
+		}
+		opts.FileNotes = owners.notes
+	}
 
-			// определяем, является ли файл текстовым
-			// (имеется в виду проверка, является ли файл "читабельным", а не бинарником или картинкой)
-			fullPath := filepath.Join(currentDir, name)
-			data, err := os.ReadFile(fullPath)
-			isTextFile := false
-			if err == nil {
-				// используем функцию-обёртку для ответа (текстовый ли файл, али бинарник кракозябрный)
-				isTextFile = detector.IsText(data)
-			} else {
-				fmt.Fprintf(os.Stderr, "Could not read file %s to determine type: %v\n", fullPath, err)
+	// текстовый дамп, repomix и gitingest пишутся потоком в stdout или --output, sqlite и cas — сами в --output
+	stream := serializer.Streams(opts.Format)
+	var out io.Writer = os.Stdout
+	if opts.discard {
+		out = io.Discard
+	}
+	// временные файлы (см. tempdir.go) удаляются и по Ctrl+C
+	scratch.base = opts.tempDir
+	removeOnSignal()
+	// код выхода отдаём последним, когда остальные defer (сброс буферов, временные файлы) уже отработали;
+	// панику не глушим: временные файлы удаляются, а паника идёт дальше со своим стеком и кодом выхода 2
+	exitCode := 0
+	defer func() {
+		if r := recover(); r != nil {
+			scratch.remove()
+			panic(r)
+		}
+		exit(exitCode)
+	}()
+	// сброс буфера и закрытие файла вывода — последняя запись: ошибка в них (диск полон) значит, что вывод неполон
+	var closers []func() error
+	defer func() {
+		for _, c := range closers {
+			if err := c(); err != nil {
+				fmt.Fprintf(os.Stderr, "Error writing output: %v\n", err)
+				exitCode = 1
 			}
-
-			files = append(files, fileInfo{relPath: childRelPath, isText: isTextFile})
 		}
+	}()
+	create := func(path string) io.Writer {
+		f, err := os.Create(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error creating %s: %v\n", path, err)
+			exit(1)
+		}
+		bw := bufio.NewWriter(f)
+		closers = append(closers, func() error {
+			err := bw.Flush()
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+			return err
+		})
+		return bw
 	}
-
-	return files, nil
-}
-
-func main() {
-	if len(os.Args) != 2 {
-		if len(os.Args) < 2 {
-			fmt.Fprintln(os.Stderr, "Error: Not enough arguments. Expected: 1 argument\nОшибка: Недостаточно аргументов. Ожидалось: 1 аргумент")
-		} else {
-			fmt.Fprintln(os.Stderr, "Error: Too Many Arguments. Expected: 1 argument\nОшибка: Слишком много аргументов. Ожидалось: 1 аргумент")
+	if stream && opts.Output != "" {
+		out = create(opts.Output)
+	}
+	for i, t := range opts.Targets {
+		if serializer.Streams(t.Format) {
+			opts.Targets[i].Writer = create(t.Output)
 		}
-		os.Exit(1)
+	}
+	// в терминал не выводим управляющие символы из файлов как есть, иначе файл может перехватить терминал
+	// (с --byte-exact — выводим: он обещает содержимое байт в байт)
+	opts.Sanitize = stream && opts.Output == "" && !opts.raw && !opts.ByteExact && isTerminal(os.Stdout)
+	opts.Log = os.Stderr
+	// спросить про --confirm-over можно только в терминале; без него Run откажется сам
+	if opts.ConfirmOver > 0 && isTerminal(os.Stdin) && isTerminal(os.Stderr) {
+		opts.Confirm = confirmRead
+	}
+	// для --model считаем, сколько байт ушло в основной вывод: древо и заголовки тоже займут контекст
+	var counter *byteCounter
+	if opts.model != "" && stream {
+		counter = &byteCounter{w: out}
+		out = counter
 	}
 
-	root := os.Args[1]
-	info, err := os.Stat(root)
-	if os.IsNotExist(err) {
-		fmt.Fprintf(os.Stderr, "Error: The directory %s does not exist\nОшибка: Директория %s не существует\n", root, root)
-		os.Exit(1)
+	var report serializer.Report
+	var err error
+	if opts.sftp != "" {
+		var fsys *sftpFS
+		var name string
+		if fsys, name, err = openSFTP(opts.sftp, opts.sshCommand, opts.sftpRequests); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: --sftp %s: %v\n", opts.sftp, err)
+			exit(1)
+		}
+		defer fsys.Close()
+		report, err = serializer.RunFS(out, fsys, name, opts.Options)
+	} else if opts.dockerImage != "" {
+		ref, dir := parseImageRef(opts.dockerImage)
+		var fsys fs.FS
+		var closer io.Closer
+		if fsys, closer, err = openImage(ref, dir); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: --docker-image %s: %v\n", opts.dockerImage, err)
+			exit(1)
+		}
+		defer closer.Close()
+		report, err = serializer.RunFS(out, fsys, imageRoot(ref, dir), opts.Options)
+	} else if archive {
+		var fsys *archiveFS
+		if fsys, err = openArchive(root); err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading archive %s: %v\n", root, err)
+			exit(1)
+		}
+		defer fsys.Close()
+		report, err = serializer.RunFS(out, fsys, archiveRoot(root), opts.Options)
+	} else if opts.asCommitted != "" {
+		var fsys *gitFS
+		if fsys, err = openGitFS(root, opts.asCommitted); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: --as-committed: %v\n", err)
+			exit(1)
+		}
+		defer fsys.Close()
+		report, err = serializer.RunFS(out, fsys, filepath.Base(root), opts.Options)
+	} else {
+		// процесс ограничивает себя только здесь, в CLI: библиотека с Sandbox лишь не выходит за корень
+		if opts.Sandbox {
+			if err := serializer.RestrictProcess(root, opts.Options); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: kernel-level sandbox unavailable (%v), relying on rooted read-only access\n", err)
+			}
+		}
+		report, err = serializer.Run(out, root, opts.Options)
 	}
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error accessing %s: %v\n", root, err)
-		os.Exit(1)
+		printError(os.Stderr, err)
+		exit(1)
 	}
-	if !info.IsDir() {
-		fmt.Fprintf(os.Stderr, "Error: %s is not a directory\n", root)
-		os.Exit(1)
+	if opts.stats {
+		printStats(os.Stderr, report)
 	}
-
-	// Этап 1: построение древа директории
-	rootName := filepath.Base(root)
-	fmt.Println(rootName + "/")
-
-	files, err := walkDir(root, "", "")
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error walking directory: %v\n", err)
-		os.Exit(1)
+	if opts.failOnSecrets && len(report.Secrets) > 0 {
+		fmt.Fprintf(os.Stderr, "Error: the dump contains %d line(s) that look like secrets (--fail-on-secrets)\n", len(report.Secrets))
+		exitCode = 1
 	}
-
-	// добавляем пустую строку для визуального разделения
-	fmt.Println()
-
-	// Этап 2: вывод содержимого только текстовых файлов
-	for _, file := range files {
-		// пропускаем нетекстовые файлы
-		if !file.isText {
-			continue
+	if opts.model != "" {
+		var written int64
+		if counter != nil {
+			written = counter.n
 		}
-
-		fullPath := filepath.Join(root, file.relPath)
-		displayPath := filepath.Join(rootName, file.relPath)
-		displayPath = filepath.ToSlash(displayPath) // для вывода на Windows
-
-		fmt.Printf("%s:\n", displayPath)
-		fmt.Println("```")
-		data, err := os.ReadFile(fullPath)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error reading %s: %v\n", fullPath, err)
-			fmt.Printf("Error reading file: %v\n", err)
-		} else {
-			fmt.Println(string(data))
+		if !checkContext(os.Stderr, report, written, opts.model, opts.contextLimit, opts.stats) && opts.failOverContext {
+			exitCode = 1
 		}
-		fmt.Println("```")
 	}
 }
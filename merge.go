@@ -0,0 +1,252 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/asquebay/directory-serialization/format"
+)
+
+// стратегии разрешения конфликтов merge: файл с разным содержимым в нескольких дампах
+const (
+	mergeLast   = "last"   // берётся из последнего дампа в командной строке
+	mergeFirst  = "first"  // из первого
+	mergeNewest = "newest" // из дампа, изменённого позже других (по времени изменения файла дампа)
+	mergeError  = "error"  // конфликт — ошибка, ничего не пишется
+)
+
+// runMerge реализует подкоманду merge: собирает несколько дампов (например, по поддеревьям разных команд) в один
+// древо — объединение древ, содержимое файла, который есть в нескольких дампах, берётся по --strategy;
+// одинаковое содержимое и обрезанное начало полного конфликтом не считаются
+// возвращает код выхода: 0 — дамп записан, 2 — ошибка или конфликт с --strategy error
+func runMerge(args []string) int {
+	fs := flag.NewFlagSet("merge", flag.ExitOnError)
+	var output string
+	fs.StringVar(&output, "output", "", "write the merged dump to `file` instead of stdout")
+	fs.StringVar(&output, "o", "", "shorthand for --output `file`")
+	strategy := fs.String("strategy", mergeLast, "which content wins when a file differs between dumps: last, first, newest (most recently modified dump) or error")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: directory-serialization merge [flags] <dump> <dump>...\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+	// флаги можно писать и после дампов: merge a.md b.md -o combined.md
+	var inputs []string
+	for fs.Parse(args); fs.NArg() > 0; fs.Parse(args) {
+		inputs = append(inputs, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+	if len(inputs) < 2 {
+		fs.Usage()
+		return 2
+	}
+	if !slices.Contains([]string{mergeLast, mergeFirst, mergeNewest, mergeError}, *strategy) {
+		fmt.Fprintf(os.Stderr, "Error: unknown --strategy %q (expected last, first, newest or error)\n", *strategy)
+		return 2
+	}
+
+	var dumps []mergeSource
+	for _, name := range inputs {
+		src, err := openMergeSource(name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 2
+		}
+		dumps = append(dumps, src)
+	}
+	merged, conflicts, err := mergeDumps(dumps, *strategy)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 2
+	}
+	if *strategy == mergeError && len(conflicts) > 0 {
+		for _, c := range conflicts {
+			fmt.Fprintf(os.Stderr, "CONFLICT %s\n", format.QuoteName(c))
+		}
+		fmt.Fprintf(os.Stderr, "Error: %d file(s) differ between dumps (choose a --strategy to resolve them)\n", len(conflicts))
+		return 2
+	}
+
+	var out io.Writer = os.Stdout
+	if output != "" {
+		f, err := os.Create(output)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error creating %s: %v\n", output, err)
+			return 2
+		}
+		defer f.Close()
+		out = f
+	}
+	bw := bufio.NewWriter(out)
+	err = merged.write(bw)
+	if err == nil {
+		err = bw.Flush()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing the merged dump: %v\n", err)
+		return 2
+	}
+	fmt.Fprintf(os.Stderr, "Merged %d dumps: %d entries, %d files with content, %d conflict(s) resolved (--strategy %s)\n",
+		len(dumps), len(merged.entries), len(merged.files), len(conflicts), *strategy)
+	return 0
+}
+
+// mergeSource — разобранный дамп и время изменения его файла (для --strategy newest)
+type mergeSource struct {
+	name  string
+	dump  *format.Dump
+	mtime time.Time
+}
+
+func openMergeSource(name string) (mergeSource, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return mergeSource{}, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return mergeSource{}, err
+	}
+	dump, err := format.Parse(f)
+	if err != nil {
+		return mergeSource{}, fmt.Errorf("parsing dump %s: %v", name, err)
+	}
+	return mergeSource{name: name, dump: dump, mtime: info.ModTime()}, nil
+}
+
+// mergedDump — результат слияния
+type mergedDump struct {
+	root                string
+	preamble, postamble string
+	entries             map[string]format.Entry
+	files               map[string]format.File
+}
+
+// mergeDumps сливает дампы; conflicts — пути файлов, содержимое которых пришлось выбирать по стратегии
+func mergeDumps(dumps []mergeSource, strategy string) (*mergedDump, []string, error) {
+	m := &mergedDump{root: dumps[0].dump.Root, entries: make(map[string]format.Entry), files: make(map[string]format.File)}
+	// порядок старшинства: выигрывает последний в order
+	order := slices.Clone(dumps)
+	switch strategy {
+	case mergeFirst:
+		slices.Reverse(order)
+	case mergeNewest:
+		slices.SortStableFunc(order, func(a, b mergeSource) int { return a.mtime.Compare(b.mtime) })
+	}
+
+	// преамбула и постамбула — из первых дампов, где они есть
+	for _, src := range dumps {
+		if m.preamble == "" {
+			m.preamble = src.dump.Preamble
+		}
+		if m.postamble == "" {
+			m.postamble = src.dump.Postamble
+		}
+	}
+
+	owner := make(map[string]string) // путь → дамп, из которого взят элемент (для сообщения о смене типа)
+	for _, src := range order {
+		for _, e := range src.dump.Entries {
+			if prev, ok := m.entries[e.Path]; ok && prev.IsDir != e.IsDir {
+				return nil, nil, fmt.Errorf("%s is a %s in %s and a %s in %s", e.Path, entryKind(prev.IsDir), owner[e.Path], entryKind(e.IsDir), src.name)
+			}
+			m.entries[e.Path] = e
+			owner[e.Path] = src.name
+		}
+	}
+
+	var conflicts []string
+	for _, src := range order {
+		for _, file := range src.dump.Files {
+			prev, ok := m.files[file.Path]
+			switch {
+			case !ok:
+			case bytes.Equal(prev.Content, file.Content) && prev.Truncated == file.Truncated:
+				continue
+			case prev.Truncated && bytes.HasPrefix(file.Content, prev.Content) && !file.Truncated:
+				// полное содержимое лучше обрезанного начала того же файла
+			case file.Truncated && bytes.HasPrefix(prev.Content, file.Content):
+				continue
+			default:
+				conflicts = append(conflicts, file.Path)
+			}
+			m.files[file.Path] = file
+			// пометки в древе (ID файла, empty file) — от того же дампа, что и содержимое
+			if e, ok := m.entries[file.Path]; ok && owner[file.Path] != src.name {
+				for _, other := range src.dump.Entries {
+					if other.Path == file.Path {
+						e.Tags = other.Tags
+					}
+				}
+				m.entries[file.Path] = e
+				owner[file.Path] = src.name
+			}
+		}
+	}
+	sort.Strings(conflicts)
+	return m, conflicts, nil
+}
+
+func entryKind(isDir bool) string {
+	if isDir {
+		return "directory"
+	}
+	return "file"
+}
+
+// write печатает слитый дамп: в директории сначала поддиректории, затем файлы, по имени — как у программы
+func (m *mergedDump) write(w io.Writer) error {
+	children := make(map[string][]format.Entry)
+	for _, e := range m.entries {
+		parent := path.Dir(e.Path)
+		children[parent] = append(children[parent], e)
+	}
+	var ordered []format.Entry
+	var walk func(dir string)
+	walk = func(dir string) {
+		list := children[dir]
+		sort.Slice(list, func(i, j int) bool {
+			if list[i].IsDir != list[j].IsDir {
+				return list[i].IsDir
+			}
+			return list[i].Path < list[j].Path
+		})
+		for _, e := range list {
+			ordered = append(ordered, e)
+			if e.IsDir {
+				walk(e.Path)
+			}
+		}
+	}
+	walk(".")
+
+	enc := format.NewEncoder(w, format.EncoderOptions{Root: m.root, Preamble: m.preamble, Postamble: m.postamble, ByteExact: true})
+	for _, e := range ordered {
+		if err := enc.WriteTreeNode(format.TreeNode{Path: e.Path, IsDir: e.IsDir, Tags: e.Tags}); err != nil {
+			return err
+		}
+	}
+	for _, e := range ordered {
+		file, ok := m.files[e.Path]
+		// пустой файл выводится одной пометкой в древе, без секции
+		if !ok || e.IsDir || slices.Contains(e.Tags, format.EmptyFileTag) {
+			continue
+		}
+		var meta format.FileMeta
+		if file.Truncated {
+			meta.Notes = []string{"truncated"}
+		}
+		if err := enc.WriteFile(e.Path, meta, bytes.NewReader(file.Content)); err != nil {
+			return err
+		}
+	}
+	return enc.Close()
+}
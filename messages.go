@@ -0,0 +1,34 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/asquebay/directory-serialization/serializer"
+)
+
+// сообщения об ошибках сериализации: библиотека отдаёт код (serializer.ErrorCode) и текст по-английски,
+// а CLI, как и в остальных своих сообщениях, добавляет строку по-русски; {path} заменяется путём из ошибки
+var russianErrors = map[serializer.ErrorCode]string{
+	serializer.CodeRootNotFound:   "Директория {path} не существует",
+	serializer.CodeRootNotDir:     "{path} — не директория",
+	serializer.CodeRootAccess:     "Нет доступа к {path}",
+	serializer.CodeInvalidOptions: "Несовместимые настройки",
+	serializer.CodeSandbox:        "Не удалось включить песочницу",
+	serializer.CodeWalk:           "Не удалось обойти директорию {path}",
+	serializer.CodeNotConfirmed:   "Чтение содержимого больше --confirm-over не подтверждено",
+	serializer.CodeOutput:         "Не удалось записать {path}",
+}
+
+// printError печатает ошибку по-английски и, если это ошибка сериализации с известным кодом, по-русски
+func printError(w io.Writer, err error) {
+	fmt.Fprintf(w, "Error: %v\n", err)
+	var e *serializer.Error
+	if errors.As(err, &e) {
+		if ru, ok := russianErrors[e.Code]; ok {
+			fmt.Fprintf(w, "Ошибка: %s\n", strings.ReplaceAll(ru, "{path}", e.Path))
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/asquebay/directory-serialization/serializer"
+)
+
+// --model: сравнить оценку размера дампа в токенах с окном контекста модели и предупредить, если дамп не влезет
+// оценка та же, что у --stats (см. serializer/tokens.go), плюс древо и заголовки по числу записанных байт;
+// настоящие токенизаторы могут разойтись с ней на десятки процентов, так что запас лучше оставлять
+
+// modelContexts — окна контекста известных моделей в токенах; имя сравнивается по самому длинному префиксу,
+// так что claude-sonnet-4-5 и gpt-4o-2024-08-06 тоже находятся
+var modelContexts = map[string]int{
+	"gpt-4o": 128000, "gpt-4o-mini": 128000, "gpt-4-turbo": 128000, "gpt-4.1": 1047576, "gpt-5": 400000,
+	"o1": 200000, "o3": 200000, "o4-mini": 200000,
+	"claude-sonnet": 200000, "claude-opus": 200000, "claude-haiku": 200000,
+	"gemini-1.5-pro": 2097152, "gemini-1.5-flash": 1048576, "gemini-2.0-flash": 1048576, "gemini-2.5": 1048576,
+	"llama-3.1": 131072, "llama-3.3": 131072, "mistral-large": 131072, "qwen2.5": 131072, "deepseek": 128000,
+}
+
+// modelContext возвращает окно контекста модели: по имени или числом токенов (--model 32000, --model 32k)
+func modelContext(model string) (int, error) {
+	if n, ok := parseTokenCount(model); ok {
+		return n, nil
+	}
+	best := ""
+	for name := range modelContexts {
+		if strings.HasPrefix(strings.ToLower(model), name) && len(name) > len(best) {
+			best = name
+		}
+	}
+	if best == "" {
+		names := make([]string, 0, len(modelContexts))
+		for name := range modelContexts {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return 0, fmt.Errorf("unknown --model %q (known: %s; or give the context size in tokens, e.g. 32k)", model, strings.Join(names, ", "))
+	}
+	return modelContexts[best], nil
+}
+
+// parseTokenCount разбирает число токенов: 32000 или 32k
+func parseTokenCount(s string) (int, bool) {
+	mult := 1
+	if rest, ok := strings.CutSuffix(strings.ToLower(s), "k"); ok {
+		s, mult = rest, 1000
+	}
+	n, err := strconv.Atoi(s)
+	return n * mult, err == nil && n > 0
+}
+
+// byteCounter считает байты, записанные в вывод
+type byteCounter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *byteCounter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// checkContext печатает предупреждение, если дамп (written байт потокового вывода, 0 — не потоковый) не влезает
+// в окно модели; с --stats печатает и долю окна, когда влезает; возвращает false при переполнении
+func checkContext(out io.Writer, r serializer.Report, written int64, model string, limit int, stats bool) bool {
+	tokens := r.Tokens
+	if overhead := written - r.ContentSize; overhead > 0 {
+		tokens += int(math.Ceil(float64(overhead) / serializer.DefaultCharsPerToken))
+	}
+	if tokens <= limit {
+		if stats {
+			fmt.Fprintf(out, "About %d tokens, %d%% of the %d-token context of %s\n", tokens, tokens*100/limit, limit, model)
+		}
+		return true
+	}
+	msg := fmt.Sprintf("Warning: the dump is about %d tokens and will not fit in the %d-token context of %s (over by %d%%)\n"+
+		"Tighten the selection (--filter, --max-file-size, --head, --newer-than) or let --fit %d keep what fits",
+		tokens, limit, model, (tokens-limit)*100/limit, limit*9/10)
+	if isTerminal(os.Stderr) {
+		msg = "\x1b[31m" + msg + "\x1b[0m"
+	}
+	fmt.Fprintln(out, msg)
+	return false
+}
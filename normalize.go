@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/asquebay/directory-serialization/serializer"
+)
+
+// --normalize-names у verify и restore-xattrs: пути из дампа или манифеста (например, снятого на Linux, в NFC)
+// ищутся на диске по нормализованному имени, так что находятся и файлы, имена которых записаны в NFD (macOS)
+
+// parseNormalizeForm проверяет значение --normalize-names
+func parseNormalizeForm(s string) error {
+	switch s {
+	case serializer.NormalizeKeep, serializer.NormalizeNFC, serializer.NormalizeNFD:
+		return nil
+	}
+	return fmt.Errorf("unknown form %q (expected nfc, nfd or keep)", s)
+}
+
+// diskPath возвращает путь к relPath внутри root; с формой nfc или nfd компонент, которого нет на диске
+// под таким именем, ищется среди элементов директории с тем же именем после нормализации
+func diskPath(root, relPath, form string) string {
+	dir := root
+	for _, part := range strings.Split(relPath, "/") {
+		next := filepath.Join(dir, part)
+		if form != serializer.NormalizeNFC && form != serializer.NormalizeNFD {
+			dir = next
+			continue
+		}
+		if _, err := os.Lstat(next); os.IsNotExist(err) {
+			want := serializer.NormalizeName(part, form)
+			entries, _ := os.ReadDir(dir)
+			for _, e := range entries {
+				if serializer.NormalizeName(e.Name(), form) == want {
+					next = filepath.Join(dir, e.Name())
+					break
+				}
+			}
+		}
+		dir = next
+	}
+	return dir
+}
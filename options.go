@@ -0,0 +1,547 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/asquebay/directory-serialization/detector"
+	"github.com/asquebay/directory-serialization/filter"
+	"github.com/asquebay/directory-serialization/format"
+	"github.com/asquebay/directory-serialization/lang"
+	"github.com/asquebay/directory-serialization/serializer"
+)
+
+// options — настройки сериализации плюс то, что нужно только CLI
+type options struct {
+	serializer.Options
+	root string // путь к директории, которую нужно обработать
+	raw  bool   // не экранировать управляющие символы при выводе в терминал
+
+	asCommitted    string // читать файлы из git: ревизия или "index" (пусто — рабочее дерево)
+	ownership      bool   // пометить файлы авторами и датой последнего изменения по истории git (см. ownership.go)
+	ownershipCache string // где кешировать историю для --ownership (пусто — пользовательский кеш, "off" — не кешировать)
+	discard        bool   // --output задан только для манифеста: дамп никуда не пишется
+	stats          bool   // напечатать в stderr оценку размера в токенах
+
+	model           string // с какой моделью сравнить размер дампа (см. models.go)
+	contextLimit    int    // её окно контекста в токенах
+	failOverContext bool   // завершиться с ошибкой, если дамп не влезает в окно
+
+	newerThan  time.Time // --newer-than и --changed-within: файлы, изменённые не позже, пропускаются
+	ignoreCase bool      // сверять --exclude, --include, --exclude-from и .gitignore без учёта регистра
+
+	failOnSecrets bool // завершиться с ошибкой, если в дампе нашлись строки, похожие на секреты
+
+	sftp         string // читать удалённую директорию по SFTP: user@host:/path (см. sftp.go)
+	sshCommand   string // чем подключаться, с аргументами: "ssh -p 2222"
+	sftpRequests int    // сколько запросов чтения одного файла держать в полёте
+	dockerImage  string // читать файловую систему образа: nginx:latest[:/etc] (см. docker.go)
+	tempDir      string // где создать личную временную директорию (см. tempdir.go)
+
+	injections []injection // --inject: вывод команд как файлы дампа (см. inject.go)
+}
+
+// parseOptions разбирает аргументы командной строки
+// при ошибке печатает сообщение и завершает программу
+func parseOptions(args []string) options {
+	opts := options{}
+	opts.Langs = lang.Default()
+
+	fs := flag.NewFlagSet("directory-serialization", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: directory-serialization [flags] <directory>\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+	fs.StringVar(&opts.Format, "format", serializer.FormatText, "output `format`: text, repomix, gitingest, json, yaml, tree-json, tree-xml, dot, mermaid, csv, k8s-configmap, k8s-secret, sqlite or cas")
+	fs.BoolVar(&opts.GraphFiles, "graph-files", false, "include files, not only directories, in --format dot and mermaid diagrams")
+	fs.StringVar(&opts.K8sName, "k8s-name", "", "`name` of the object written by --format k8s-configmap and k8s-secret (default: the directory name)")
+	var outputs []string
+	fs.Func("output", "write the output to `file` instead of stdout (required for sqlite and cas); repeat for several formats in one walk, choosing each by prefix (repomix:dump.xml) or extension (.md, .xml, .db, .json for the manifest)", func(s string) error {
+		outputs = append(outputs, s)
+		return nil
+	})
+	fs.StringVar(&opts.ContentEncoding, "content-encoding", serializer.ContentRaw, "how structured formats store file contents: raw, escaped or base64 (json and yaml: raw or base64) (`encoding`)")
+	fs.StringVar(&opts.ManifestPath, "manifest", "", "write a JSON manifest (sizes, hashes, encodings, decisions) to `file`")
+	fs.StringVar(&opts.MtimeFormat, "mtime-format", "", "record modification times in the manifest as `format`: unix or iso8601 (SOURCE_DATE_EPOCH, if set, caps them)")
+	fs.BoolVar(&opts.Sandbox, "sandbox", false, "confine reads to the directory (no symlink escapes) and drop filesystem access beyond it where the OS supports it")
+	fs.Var(revisionFlag{&opts.asCommitted}, "as-committed", "read files as committed in HEAD (--as-committed=`rev` for another revision, =index for staged content) instead of the working tree")
+	fs.StringVar(&opts.sftp, "sftp", "", "serialize a remote directory over SFTP (`user@host:/path`) instead of a local one")
+	fs.StringVar(&opts.sshCommand, "ssh-command", "ssh", "`command` that connects for --sftp, with extra arguments (\"ssh -p 2222 -i key\")")
+	fs.IntVar(&opts.sftpRequests, "sftp-requests", 64, "how many 32KB reads of one file to keep in flight over --sftp (`n`); raise it for high-latency links")
+	fs.StringVar(&opts.dockerImage, "docker-image", "", "serialize the filesystem of a container image (`image[:/path]`, e.g. nginx:latest:/etc), pulling it if the local daemon lacks it")
+	fs.StringVar(&opts.tempDir, "temp-dir", "", "keep temporary files (--docker-image exports) in a private, owner-only subdirectory of `dir` that is removed on exit (default $TMPDIR)")
+	fs.BoolVar(&opts.raw, "raw", false, "do not escape terminal control characters in file contents when writing to a terminal")
+	fs.BoolVar(&opts.FileIDs, "file-ids", false, "show short content-derived file IDs in the tree and content headers")
+	fs.BoolVar(&opts.CheckEncoding, "check-encoding", false, "scan whole text files, not just the first 16KB, and tag those with mixed encodings or a truncated multibyte tail as [encoding suspect]")
+	fs.BoolVar(&opts.ResolveShortcuts, "resolve-shortcuts", false, "show where .url, .lnk and .desktop shortcuts point in the tree (targets are not followed)")
+	fs.BoolVar(&opts.Xattrs, "xattrs", false, "record extended attributes and ACLs of files in the manifest (restore them with restore-xattrs)")
+	fs.StringVar(&opts.HashAlgo, "hash-algo", serializer.HashSHA256, "`algorithm` of content hashes in the manifest, sqlite, csv and --file-ids: sha256, sha1, xxhash (fast, not cryptographic) or blake3 (fast, uses all cores on large files)")
+	fs.BoolVar(&opts.FuzzyHash, "fuzzy-hash", false, "record an ssdeep-style fuzzy hash of binary files in the manifest")
+	fs.Func("preamble", "put `text` (or the contents of a file with that name) before the dump", func(s string) error {
+		text, err := fileOrString(s)
+		opts.Preamble = text
+		return err
+	})
+	fs.Func("postamble", "put `text` (or the contents of a file with that name) after the dump", func(s string) error {
+		text, err := fileOrString(s)
+		opts.Postamble = text
+		return err
+	})
+	fs.Func("detect-sampling", "which parts of a file to look at when detecting its encoding: head, or spread to also check the middle and the end of files over 1MB and tag a mismatch as [encoding suspect] (`strategy`)", func(s string) error {
+		if s != detector.SampleHead && s != detector.SampleSpread {
+			return fmt.Errorf("expected head or spread, got %q", s)
+		}
+		opts.Detector.Sampling = s
+		return nil
+	})
+	fs.Func("normalize-names", "write file names in Unicode `form` nfc (Linux, Windows), nfd (macOS) or keep (as on disk), so dumps of one project from different systems match", func(s string) error {
+		opts.NormalizeNames = s
+		return parseNormalizeForm(s)
+	})
+	fs.Func("inject", "add the output of a shell `path=command` to the dump as the file path, e.g. context/git-log.txt=\"git log -5\" (run in the directory); repeat for several", func(s string) error {
+		inj, err := parseInjection(s)
+		opts.injections = append(opts.injections, inj)
+		return err
+	})
+	fs.Func("newer-than", "include only files modified after `date` (2024-01-01 or RFC 3339)", func(s string) error {
+		t, err := parseDate(s)
+		if err != nil {
+			return err
+		}
+		opts.restrictMtime(t)
+		return nil
+	})
+	fs.Func("changed-within", "include only files modified within `duration` (e.g. 7d, 2w, 36h)", func(s string) error {
+		d, err := parseAge(s)
+		if err != nil {
+			return err
+		}
+		opts.restrictMtime(time.Now().Add(-d))
+		return nil
+	})
+	var excludes []string
+	fs.Func("exclude", "leave out files and directories matching the glob `pattern` (checked against the path and the name: *.log, build/*); repeat for several", func(s string) error {
+		if _, err := path.Match(s, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q: %v", s, err)
+		}
+		excludes = append(excludes, s)
+		return nil
+	})
+	var includes []string
+	fs.Func("include", "print the contents of only the files matching the glob `pattern` (checked against the path and the name: *.go, docs/*); the others are left out, directories stay; repeat for several", func(s string) error {
+		if _, err := path.Match(s, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q: %v", s, err)
+		}
+		includes = append(includes, s)
+		return nil
+	})
+	fs.BoolVar(&opts.IncludeTree, "full-tree", false, "with --include, still show the other files in the tree, tagged [not-included]")
+	// правила --exclude-from разбираются после всех флагов: от --ignore-case зависит, как их сверять
+	var ignores [][]byte
+	fs.Func("exclude-from", "leave out paths matching the rules in `file` (.gitignore syntax, paths relative to the root, ! to keep a path)", func(s string) error {
+		data, err := os.ReadFile(s)
+		ignores = append(ignores, data)
+		return err
+	})
+	// в файловых системах Windows и macOS регистр в именах обычно не важен, поэтому там и шаблоны его не учитывают
+	fs.BoolVar(&opts.ignoreCase, "ignore-case", runtime.GOOS == "windows" || runtime.GOOS == "darwin", "match --exclude, --include, --exclude-from and .gitignore patterns regardless of case (*.jpg also leaves out photo.JPG); on by default on Windows and macOS, whose file systems ignore case, off elsewhere (--ignore-case=false to turn off)")
+	fs.BoolVar(&opts.OwnedByMe, "owned-by-me", false, "include only files owned by the current user")
+	fs.Func("only-class", "include only files of these `classes`, comma-separated: source, config, docs, data, build-script, ci, other (guessed from path, language and first line; see class in the manifest)", func(s string) error {
+		for _, class := range strings.Split(s, ",") {
+			class = strings.TrimSpace(class)
+			if !slices.Contains(serializer.Classes, class) {
+				return fmt.Errorf("unknown class %q (expected %s)", class, strings.Join(serializer.Classes, ", "))
+			}
+			opts.OnlyClasses = append(opts.OnlyClasses, class)
+		}
+		return nil
+	})
+	fs.StringVar(&opts.Tests, "tests", serializer.TestsInclude, "`mode` for tests and their fixtures (foo_test.go, test_foo.py, foo.spec.ts, FooTest.java, tests/, __tests__/, testdata/): include, exclude or only")
+	fs.BoolVar(&opts.ScanSecrets, "scan-secrets", false, "warn about printed lines that look like keys or secrets (high-entropy strings, PEM private keys), with file:line")
+	fs.BoolVar(&opts.failOnSecrets, "fail-on-secrets", false, "like --scan-secrets, and exit with status 1 when anything is found (for CI)")
+	fs.BoolVar(&opts.KeepMinified, "keep-minified", false, "print the content of minified or generated one-line files (bundles, minified CSS, base64 blobs) instead of tagging them [minified] in the tree")
+	useGitignore := fs.Bool("gitignore", true, "leave out what .gitignore files exclude, in every directory and above the root up to the repository root, with ! to keep a path (on by default)")
+	fs.BoolVar(&opts.NoGitignore, "no-gitignore", false, "keep files and directories that .gitignore excludes (same as --gitignore=false)")
+	fs.BoolVar(&opts.NoEditorConfig, "no-editorconfig", false, "ignore charset declarations in .editorconfig and rely on encoding detection alone")
+	fs.BoolVar(&opts.FollowJunctions, "follow-junctions", false, "descend into Windows junctions (reparse points such as \"Application Data\" in user profiles) unless they lead back to a directory above them; by default they are only listed with their target")
+	fs.BoolVar(&opts.NoDefaultExcludes, "no-default-excludes", false, "keep OS and editor litter (.DS_Store, Thumbs.db, desktop.ini, __pycache__, .pytest_cache, .idea, .vscode) that is skipped by default")
+	fs.Func("min-perms", "include only files the current user can access with `perms` (e.g. r--, rw-)", func(s string) error {
+		mask, err := parsePerms(s)
+		if err != nil {
+			return err
+		}
+		opts.MinPerms = mask
+		return nil
+	})
+	fs.Func("filter", "include only files matching `expr`, e.g. 'size < 100KB && ext in [\"go\", \"md\"] && !path.matches(\"testdata/\")'; repeat to require all", func(s string) error {
+		expr, err := filter.Parse(s)
+		if err != nil {
+			return err
+		}
+		prev := opts.Filter
+		opts.Filter = func(relPath string, info os.FileInfo) bool {
+			return (prev == nil || prev(relPath, info)) && expr.Match(relPath, info)
+		}
+		return nil
+	})
+	fs.Func("content-match", "output contents only of text files matching `regexp` (the tree stays complete)", func(s string) error {
+		re, err := regexp.Compile(s)
+		opts.ContentMatch = re
+		return err
+	})
+	fs.IntVar(&opts.HeadLines, "head", 0, "output only the first `n` lines of each file")
+	fs.IntVar(&opts.BinaryPreview, "binary-preview", 0, "show a hexdump of the first `n` bytes of binary files (e.g. 64) instead of leaving them out")
+	fs.Func("transform", "apply content `rules` like \"*.go strip-comments\" or \"* redact REGEXP\" (or a file with one rule per line); transforms: notebook, strip-comments, mask-env, redact, replace, head", func(s string) error {
+		rules, err := fileOrString(s)
+		if err != nil {
+			return err
+		}
+		steps, err := parseTransforms(rules)
+		opts.Transformers = append(opts.Transformers, steps...)
+		return err
+	})
+	fs.BoolVar(&opts.MaskEnv, "mask-env", false, "include dotenv files (.env, .env.local, *.env) with values replaced by *** and variable names kept")
+	fs.IntVar(&opts.Wrap, "wrap", 0, "soft-wrap lines longer than `n` characters, ending each broken piece with "+format.WrapMarker)
+	fs.IntVar(&opts.SummarizeDocs, "summarize-docs", 0, "output only the first `n` lines of long docs (README, LICENSE, CHANGELOG, docs/*.md) and mark the rest as omitted")
+	fs.Func("max-file-size", "output at most `size` bytes of each file (e.g. 64KB), cut at a line or character boundary", func(s string) error {
+		n, err := parseSize(s)
+		opts.MaxFileSize = n
+		return err
+	})
+	fs.BoolVar(&opts.ByteExact, "byte-exact", false, "guarantee that file contents in the text dump are byte for byte as on disk: files that could not be parsed back exactly (a ``` line inside) are written in base64; rejects options that change contents")
+	fs.IntVar(&opts.ChunkLines, "chunk-lines", 0, "split files longer than `n` lines into numbered parts")
+	fs.IntVar(&opts.ChunkTokens, "chunk-tokens", 0, "split files larger than about `n` tokens into numbered parts")
+	fs.IntVar(&opts.ChunkOverlap, "chunk-overlap", 0, "repeat the last `n` lines of a part at the start of the next one")
+	fs.IntVar(&opts.Fit, "fit", 0, "output file contents only while they fit in about `n` tokens, skipping files that do not")
+	fs.IntVar(&opts.Skeleton, "skeleton", 0, "quick orientation dump of a huge codebase: the full tree, but contents of at most `n` files per directory (README, build and config files, then the smallest sources)")
+	fs.Func("per-dir-budget", "let no directory, with its subdirectories, contribute more than about `n` tokens (50k) or, with a B suffix, bytes (64KB) of file contents; files that do not fit are skipped", func(s string) error {
+		// с B на конце — байты, как у --max-file-size, иначе — токены, как у --fit
+		if strings.HasSuffix(strings.ToUpper(s), "B") {
+			n, err := parseSize(s)
+			opts.PerDirBytes = n
+			return err
+		}
+		n, ok := parseTokenCount(s)
+		if !ok {
+			return fmt.Errorf("invalid budget %q (expected tokens like 50k or bytes like 64KB)", s)
+		}
+		opts.PerDirTokens = n
+		return nil
+	})
+	fs.BoolVar(&opts.stats, "stats", false, "print the estimated size of the output in tokens, by language, to stderr")
+	fs.StringVar(&opts.model, "model", "", "warn when the dump will not fit in the context window of `model` (gpt-4o, claude-sonnet, gemini-2.5-pro... or a size in tokens like 32k)")
+	fs.BoolVar(&opts.failOverContext, "fail-over-context", false, "exit with status 1 when the dump will not fit in the --model context window")
+	fs.IntVar(&opts.MaxOpenFiles, "max-open-files", 0, "keep at most about `n` files open at once by walking fewer directories in parallel")
+	fs.Func("max-memory", "hold at most about `size` bytes of file contents in memory at once while walking (e.g. 256MB)", func(s string) error {
+		n, err := parseSize(s)
+		opts.MaxMemory = n
+		return err
+	})
+	fs.Func("confirm-over", "before reading file contents, ask for confirmation when they would add up to more than `size` (e.g. 50MB); without a terminal to ask on, fail instead", func(s string) error {
+		n, err := parseSize(s)
+		opts.ConfirmOver = n
+		return err
+	})
+	fs.DurationVar(&opts.Deadline, "deadline", 0, "stop reading files after `duration` (e.g. 30s) and list what was not processed")
+	fs.Func("lang", "add or override language `rules` like .tpl=go-template,Jenkinsfile=groovy (or a file with one rule per line)", func(s string) error {
+		rules, err := fileOrString(s)
+		if err != nil {
+			return err
+		}
+		return opts.Langs.Load(strings.NewReader(rules))
+	})
+	fs.BoolVar(&opts.FenceLang, "fence-lang", false, "tag opening code fences with the file's language (```go)")
+	fs.StringVar(&opts.GoDoc, "go-doc", "", "for Go code, write package documentation (what go doc -all shows) before file contents: add to keep the sources, only to replace them (`mode`)")
+	fs.BoolVar(&opts.ownership, "ownership", false, "in a git repository, add each file's top authors and last-changed date from git history to its header: (authors: Alice 12; Bob 3, last changed 2025-03-01)")
+	fs.StringVar(&opts.ownershipCache, "ownership-cache", "", "keep the --ownership history cache in `dir` (created owner-only); off disables the cache (default the user cache directory)")
+	fs.BoolVar(&opts.HeaderStats, "header-stats", false, "add line count, size, encoding and language to file headers: (342 lines, 12KB, UTF-8, go)")
+	fs.BoolVar(&opts.Canonical, "canonical", false, "diff-friendly text dump: the same tree glyph on every line, a blank line between file sections and \".\" for the root name, so git diff of two dumps shows whole-file changes")
+	fs.StringVar(&opts.GroupBy, "group-by", "", "organize file contents into sections by `key`: dir, ext or lang")
+	schema := fs.Bool("schema", false, "print the JSON Schema of the manifest and exit")
+	caps := fs.Bool("capabilities", false, "print a JSON description of supported formats, subcommands, filters, flag values, detector scripts and the dump format version, and exit")
+	fs.Parse(args)
+
+	if *schema {
+		os.Stdout.Write(serializer.ManifestSchema)
+		os.Exit(0)
+	}
+	if *caps {
+		if err := printCapabilities(fs); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	if opts.sftp != "" && opts.dockerImage != "" {
+		fmt.Fprintln(os.Stderr, "Error: choose one of --sftp and --docker-image")
+		os.Exit(1)
+	}
+	if source := cmp.Or(opts.sftp, opts.dockerImage); source != "" {
+		// удалённая директория или образ заменяют аргумент
+		if fs.NArg() > 0 {
+			fmt.Fprintln(os.Stderr, "Error: --sftp and --docker-image replace the directory argument\nОшибка: с --sftp и --docker-image директорию указывать не нужно")
+			os.Exit(1)
+		}
+		opts.root = source
+	} else if fs.NArg() != 1 {
+		if fs.NArg() < 1 {
+			fmt.Fprintln(os.Stderr, "Error: Not enough arguments. Expected: 1 argument\nОшибка: Недостаточно аргументов. Ожидалось: 1 аргумент")
+		} else {
+			fmt.Fprintln(os.Stderr, "Error: Too Many Arguments. Expected: 1 argument\nОшибка: Слишком много аргументов. Ожидалось: 1 аргумент")
+		}
+		os.Exit(1)
+	} else {
+		opts.root = fs.Arg(0)
+	}
+
+	// фильтры путей: сначала жёсткие (время изменения, --exclude), затем правила --exclude-from,
+	// чьё "!" не должно возвращать то, что исключено явно
+	if !opts.newerThan.IsZero() {
+		opts.Matchers = append(opts.Matchers, serializer.ModifiedBetween(opts.newerThan, time.Time{}))
+	}
+	opts.NoGitignore = opts.NoGitignore || !*useGitignore
+	opts.GitignoreFold = opts.ignoreCase
+	glob, gitignore := serializer.Glob, serializer.Gitignore
+	if opts.ignoreCase {
+		glob, gitignore = serializer.GlobFold, serializer.GitignoreFold
+	}
+	if len(excludes) > 0 {
+		m, _ := glob(serializer.Exclude, excludes...)
+		opts.Matchers = append(opts.Matchers, m)
+	}
+	for _, rules := range ignores {
+		m, _ := gitignore(".", bytes.NewReader(rules))
+		opts.Matchers = append(opts.Matchers, m)
+	}
+	if len(includes) > 0 {
+		opts.Include, _ = glob(serializer.Include, includes...)
+	} else if opts.IncludeTree {
+		fmt.Fprintln(os.Stderr, "Error: --full-tree needs --include")
+		os.Exit(1)
+	}
+
+	formatSet := false
+	fs.Visit(func(f *flag.Flag) { formatSet = formatSet || f.Name == "format" })
+	if err := resolveOutputs(&opts, outputs, formatSet); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	sqlite, cas, k8s, graph, structured := false, false, false, false, false
+	for _, t := range append([]serializer.Target{{Format: opts.Format, Output: opts.Output}}, opts.Targets...) {
+		switch t.Format {
+		case serializer.FormatText, serializer.FormatRepomix, serializer.FormatGitingest, serializer.FormatTreeJSON, serializer.FormatTreeXML, serializer.FormatCSV:
+		case serializer.FormatJSON, serializer.FormatYAML:
+			structured = true
+		case serializer.FormatConfigMap, serializer.FormatSecret:
+			k8s = true
+		case serializer.FormatDOT, serializer.FormatMermaid:
+			graph = true
+		case serializer.FormatSQLite, serializer.FormatCAS:
+			if t.Output == "" {
+				fmt.Fprintf(os.Stderr, "Error: --format %s requires --output\n", t.Format)
+				os.Exit(1)
+			}
+			sqlite = sqlite || t.Format == serializer.FormatSQLite
+			cas = cas || t.Format == serializer.FormatCAS
+			if t.Format == serializer.FormatSQLite && !sqliteAvailable() {
+				fmt.Fprintln(os.Stderr, "Error: --format sqlite is not available on this platform")
+				os.Exit(1)
+			}
+		default:
+			fmt.Fprintf(os.Stderr, "Error: unknown format %q\n", t.Format)
+			os.Exit(1)
+		}
+	}
+
+	// хранилище cas держит файлы как есть: секреты из dotenv попали бы в него без маски
+	if opts.MaskEnv && cas {
+		fmt.Fprintln(os.Stderr, "Error: --mask-env does not apply to --format cas, which stores files as they are")
+		os.Exit(1)
+	}
+
+	switch opts.ContentEncoding {
+	case serializer.ContentRaw:
+	case serializer.ContentEscaped, serializer.ContentBase64:
+		// в JSON и YAML строка и так экранируется при необходимости, escaped там не нужен
+		if !sqlite && !(structured && opts.ContentEncoding == serializer.ContentBase64) {
+			fmt.Fprintf(os.Stderr, "Error: --content-encoding %s applies only to structured formats (sqlite; json and yaml take only base64)\n", opts.ContentEncoding)
+			os.Exit(1)
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "Error: unknown --content-encoding %q (expected raw, escaped or base64)\n", opts.ContentEncoding)
+		os.Exit(1)
+	}
+
+	switch opts.Tests {
+	case serializer.TestsInclude, serializer.TestsExclude, serializer.TestsOnly:
+	default:
+		fmt.Fprintf(os.Stderr, "Error: unknown --tests %q (expected include, exclude or only)\n", opts.Tests)
+		os.Exit(1)
+	}
+
+	switch opts.GroupBy {
+	case "", serializer.GroupByDir, serializer.GroupByExt, serializer.GroupByLang:
+	default:
+		fmt.Fprintf(os.Stderr, "Error: unknown --group-by %q (expected dir, ext or lang)\n", opts.GroupBy)
+		os.Exit(1)
+	}
+
+	if opts.Canonical && opts.Format != serializer.FormatText && !slices.ContainsFunc(opts.Targets, func(t serializer.Target) bool { return t.Format == serializer.FormatText }) {
+		fmt.Fprintln(os.Stderr, "Error: --canonical applies to the text format")
+		os.Exit(1)
+	}
+	switch opts.GoDoc {
+	case "", serializer.GoDocAdd, serializer.GoDocOnly:
+	default:
+		fmt.Fprintf(os.Stderr, "Error: unknown --go-doc %q (expected add or only)\n", opts.GoDoc)
+		os.Exit(1)
+	}
+	if opts.GoDoc != "" && opts.Format != serializer.FormatText && !slices.ContainsFunc(opts.Targets, func(t serializer.Target) bool { return t.Format == serializer.FormatText }) {
+		fmt.Fprintln(os.Stderr, "Error: --go-doc applies to the text format")
+		os.Exit(1)
+	}
+	if opts.ByteExact {
+		if opts.Format != serializer.FormatText && !slices.ContainsFunc(opts.Targets, func(t serializer.Target) bool { return t.Format == serializer.FormatText }) {
+			fmt.Fprintln(os.Stderr, "Error: --byte-exact applies to the text format")
+			os.Exit(1)
+		}
+		if opts.HeadLines > 0 || opts.SummarizeDocs > 0 || opts.MaxFileSize > 0 || opts.Wrap > 0 || opts.ChunkLines > 0 || opts.ChunkTokens > 0 || len(opts.Transformers) > 0 || opts.MaskEnv {
+			fmt.Fprintln(os.Stderr, "Error: --byte-exact cannot be combined with --head, --summarize-docs, --max-file-size, --wrap, --chunk-lines, --chunk-tokens, --transform or --mask-env")
+			os.Exit(1)
+		}
+	}
+	if opts.K8sName != "" {
+		if !k8s {
+			fmt.Fprintln(os.Stderr, "Error: --k8s-name applies to --format k8s-configmap and k8s-secret")
+			os.Exit(1)
+		}
+		if serializer.K8sName(opts.K8sName) != opts.K8sName {
+			fmt.Fprintf(os.Stderr, "Error: --k8s-name %q is not a valid Kubernetes name (lowercase letters, digits, - and ., e.g. %q)\n", opts.K8sName, serializer.K8sName(opts.K8sName))
+			os.Exit(1)
+		}
+	}
+
+	if opts.GraphFiles && !graph {
+		fmt.Fprintln(os.Stderr, "Error: --graph-files applies to --format dot and mermaid")
+		os.Exit(1)
+	}
+
+	if !slices.Contains(serializer.HashAlgos, opts.HashAlgo) {
+		fmt.Fprintf(os.Stderr, "Error: unknown --hash-algo %q (expected sha256, sha1, xxhash or blake3)\n", opts.HashAlgo)
+		os.Exit(1)
+	}
+	// объекты хранилища адресуются по SHA-256, иначе снимки с разными алгоритмами не делили бы объекты
+	if cas && opts.HashAlgo != serializer.HashSHA256 {
+		fmt.Fprintln(os.Stderr, "Error: --format cas addresses objects by SHA-256 and does not take --hash-algo")
+		os.Exit(1)
+	}
+
+	switch opts.MtimeFormat {
+	case "", serializer.MtimeUnix, serializer.MtimeISO8601:
+	default:
+		fmt.Fprintf(os.Stderr, "Error: unknown --mtime-format %q (expected unix or iso8601)\n", opts.MtimeFormat)
+		os.Exit(1)
+	}
+	if opts.MtimeFormat != "" && opts.ManifestPath == "" && !cas {
+		fmt.Fprintln(os.Stderr, "Error: --mtime-format applies to the manifest, add --manifest")
+		os.Exit(1)
+	}
+	// воспроизводимые сборки (https://reproducible-builds.org/specs/source-date-epoch/) задают время сборки сами
+	if epoch := os.Getenv("SOURCE_DATE_EPOCH"); epoch != "" {
+		sec, err := strconv.ParseInt(epoch, 10, 64)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: SOURCE_DATE_EPOCH must be a number of seconds, got %q\n", epoch)
+			os.Exit(1)
+		}
+		opts.SourceDate = time.Unix(sec, 0).UTC()
+	}
+
+	if opts.model != "" {
+		limit, err := modelContext(opts.model)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		opts.contextLimit = limit
+	} else if opts.failOverContext {
+		fmt.Fprintln(os.Stderr, "Error: --fail-over-context needs --model")
+		os.Exit(1)
+	}
+
+	if opts.failOnSecrets {
+		opts.ScanSecrets = true
+	}
+
+	if opts.Xattrs && opts.ManifestPath == "" {
+		fmt.Fprintln(os.Stderr, "Error: --xattrs are recorded in the manifest, add --manifest")
+		os.Exit(1)
+	}
+	if opts.asCommitted != "" && opts.Sandbox {
+		fmt.Fprintln(os.Stderr, "Error: --as-committed reads through git and cannot be combined with --sandbox")
+		os.Exit(1)
+	}
+	if opts.ownership && opts.Format != serializer.FormatText && !slices.ContainsFunc(opts.Targets, func(t serializer.Target) bool { return t.Format == serializer.FormatText }) {
+		fmt.Fprintln(os.Stderr, "Error: --ownership applies to the text format")
+		os.Exit(1)
+	}
+	if opts.ownership && (opts.sftp != "" || opts.dockerImage != "") {
+		fmt.Fprintln(os.Stderr, "Error: --ownership needs a local git repository and cannot be combined with --sftp or --docker-image")
+		os.Exit(1)
+	}
+	if (opts.sftp != "" || opts.dockerImage != "") && (opts.asCommitted != "" || opts.Sandbox) {
+		fmt.Fprintln(os.Stderr, "Error: --sftp and --docker-image cannot be combined with --as-committed or --sandbox")
+		os.Exit(1)
+	}
+	if opts.sftpRequests < 1 {
+		fmt.Fprintln(os.Stderr, "Error: --sftp-requests must be at least 1")
+		os.Exit(1)
+	}
+	if opts.MaxOpenFiles < 0 {
+		fmt.Fprintln(os.Stderr, "Error: --max-open-files must not be negative")
+		os.Exit(1)
+	}
+	if opts.Deadline < 0 {
+		fmt.Fprintln(os.Stderr, "Error: --deadline must not be negative")
+		os.Exit(1)
+	}
+	if opts.HeadLines < 0 || opts.SummarizeDocs < 0 || opts.Wrap < 0 || opts.ChunkLines < 0 || opts.ChunkTokens < 0 || opts.ChunkOverlap < 0 || opts.Fit < 0 || opts.BinaryPreview < 0 || opts.Skeleton < 0 {
+		fmt.Fprintln(os.Stderr, "Error: --head, --summarize-docs, --wrap, --chunk-lines, --chunk-tokens, --chunk-overlap, --fit, --binary-preview and --skeleton must not be negative")
+		os.Exit(1)
+	}
+	if opts.ChunkLines > 0 && opts.ChunkOverlap >= opts.ChunkLines {
+		fmt.Fprintln(os.Stderr, "Error: --chunk-overlap must be smaller than --chunk-lines")
+		os.Exit(1)
+	}
+
+	return opts
+}
+
+// restrictMtime сужает окно по времени изменения: при нескольких флагах действует самый строгий
+func (o *options) restrictMtime(t time.Time) {
+	if t.After(o.newerThan) {
+		o.newerThan = t
+	}
+}
+
+// fileOrString возвращает содержимое файла, если s — путь к существующему файлу, иначе саму строку
+func fileOrString(s string) (string, error) {
+	info, err := os.Stat(s)
+	if err != nil || info.IsDir() {
+		return s, nil
+	}
+	data, err := os.ReadFile(s)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(string(data), "\n"), nil
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/asquebay/directory-serialization/serializer"
+)
+
+// --output можно указать несколько раз: все выводы делаются за один обход директории
+// формат каждого берётся из префикса (repomix:dump.xml), иначе из расширения, иначе из --format
+
+// formatManifest — псевдоформат вывода: манифест (то же, что --manifest)
+const formatManifest = "manifest"
+
+// outputFormats — форматы, которые можно указать префиксом "формат:путь"
+var outputFormats = []string{
+	serializer.FormatText, serializer.FormatRepomix, serializer.FormatGitingest, serializer.FormatJSON, serializer.FormatYAML,
+	serializer.FormatTreeJSON, serializer.FormatTreeXML, serializer.FormatConfigMap, serializer.FormatSecret,
+	serializer.FormatDOT, serializer.FormatMermaid, serializer.FormatCSV,
+	serializer.FormatSQLite, serializer.FormatCAS, formatManifest,
+}
+
+// sqliteAvailable сообщает, подключён ли в этой сборке драйвер SQLite (см. sqlite_driver.go)
+func sqliteAvailable() bool {
+	return slices.Contains(sql.Drivers(), serializer.SQLiteDriver)
+}
+
+// outputExtensions — формат по расширению файла вывода
+var outputExtensions = map[string]string{
+	".md": serializer.FormatText, ".txt": serializer.FormatText,
+	".xml":  serializer.FormatRepomix,
+	".yaml": serializer.FormatYAML, ".yml": serializer.FormatYAML,
+	".db": serializer.FormatSQLite, ".sqlite": serializer.FormatSQLite, ".sqlite3": serializer.FormatSQLite,
+	".json": formatManifest,
+	".dot":  serializer.FormatDOT, ".gv": serializer.FormatDOT, ".mmd": serializer.FormatMermaid,
+	".csv": serializer.FormatCSV,
+}
+
+// splitOutput разбирает "формат:путь"; если префикс не формат (C:\dump.md), весь аргумент — путь
+func splitOutput(spec string) (format, path string) {
+	if prefix, rest, ok := strings.Cut(spec, ":"); ok {
+		for _, f := range outputFormats {
+			if prefix == f {
+				return prefix, rest
+			}
+		}
+	}
+	return "", spec
+}
+
+// resolveOutputs раскладывает --output по форматам: первый вывод дампа — основной (opts.Format, opts.Output),
+// остальные — opts.Targets (Writer им назначает main), манифест — opts.ManifestPath
+// formatSet — указан ли --format явно: тогда единственный --output пишется в этом формате, как раньше
+// из нескольких выводов каждый должен назвать свой формат (префиксом, расширением или явным --format),
+// иначе незнакомое расширение молча дало бы текстовый дамп; один и тот же файл дважды — ошибка
+func resolveOutputs(opts *options, specs []string, formatSet bool) error {
+	var dumps []serializer.Target
+	seen := make(map[string]string) // очищенный путь → как он указан
+	if opts.ManifestPath != "" {
+		seen[filepath.Clean(opts.ManifestPath)] = "--manifest " + opts.ManifestPath
+	}
+	for _, spec := range specs {
+		format, path := splitOutput(spec)
+		if path == "" {
+			return fmt.Errorf("empty path in --output %q", spec)
+		}
+		if prev, ok := seen[filepath.Clean(path)]; ok {
+			return fmt.Errorf("--output %s writes the same file as %s", spec, prev)
+		}
+		seen[filepath.Clean(path)] = "--output " + spec
+		if format == "" {
+			ext := strings.ToLower(filepath.Ext(path))
+			inferred, ok := outputExtensions[ext]
+			switch {
+			case ok && !(formatSet && len(specs) == 1):
+				format = inferred
+			case len(specs) > 1 && !formatSet:
+				return fmt.Errorf("--output %s: cannot tell the format from the extension %q (use a prefix such as text:%s)", spec, ext, path)
+			default:
+				format = opts.Format
+			}
+		}
+		if format == formatManifest {
+			if opts.ManifestPath != "" {
+				return fmt.Errorf("more than one manifest: %s and %s", opts.ManifestPath, path)
+			}
+			opts.ManifestPath = path
+			continue
+		}
+		dumps = append(dumps, serializer.Target{Format: format, Output: path})
+	}
+	if len(dumps) == 0 {
+		// только манифест: сам дамп никуда не пишем
+		opts.discard = len(specs) > 0
+		return nil
+	}
+	opts.Format, opts.Output = dumps[0].Format, dumps[0].Output
+	opts.Targets = dumps[1:]
+	return nil
+}
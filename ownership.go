@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// --ownership: к заголовку файла в дампе добавляются его главные авторы и дата последнего изменения по истории git —
+// "(authors: Alice 12; Bob 3, last changed 2025-03-01)", чтобы ревьюер или LLM знали, чей это код и насколько он свежий
+// история читается одним git log --name-only по всему корню, а не git blame на файл; авторы считаются по числу
+// коммитов, которые меняли файл (с учётом .mailmap), переименования не прослеживаются
+// итог кешируется в пользовательском кеше по репозиторию и корню: при следующем запуске дочитываются только
+// коммиты после запомненного HEAD; кеш хранит пути и авторов репозитория, поэтому директория создаётся с правами 0700,
+// а файл пишется во временный (0600) и переименовывается — соседний процесс не увидит недописанный JSON
+
+// ownershipTop — сколько авторов файла показывать
+const ownershipTop = 3
+
+// fileOwnership — что известно о файле из истории
+type fileOwnership struct {
+	Authors map[string]int `json:"authors"` // автор → сколько его коммитов меняли файл
+	Last    string         `json:"last"`    // дата последнего коммита, YYYY-MM-DD
+}
+
+// ownershipCache — файл кеша: сведения о файлах корня по истории до Head
+type ownershipCache struct {
+	Head  string                    `json:"head"`
+	Files map[string]*fileOwnership `json:"files"` // путь от корня через "/" → сведения
+}
+
+// loadOwnership собирает сведения об авторах файлов директории root (внутри репозитория git) по истории до rev;
+// cacheDir — где хранить кеш (пусто — пользовательский кеш, "off" — не кешировать)
+func loadOwnership(root, rev, cacheDir string) (*ownershipCache, error) {
+	head, err := git(root, "rev-parse", "--verify", rev+"^{commit}")
+	if err != nil {
+		return nil, fmt.Errorf("not a git repository with commits: %v", err)
+	}
+	head = strings.TrimSpace(head)
+	key, err := git(root, "rev-parse", "--show-toplevel", "--show-prefix")
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256([]byte(key))
+	if cacheDir == "" {
+		if dir, err := os.UserCacheDir(); err == nil {
+			cacheDir = filepath.Join(dir, "directory-serialization")
+		}
+	}
+	cachePath := ""
+	if cacheDir != "" && cacheDir != "off" {
+		cachePath = filepath.Join(cacheDir, "ownership", hex.EncodeToString(sum[:8])+".json")
+	}
+
+	cache := &ownershipCache{Files: make(map[string]*fileOwnership)}
+	revs := head
+	if data, err := readOwnershipCache(cachePath); err == nil {
+		var old ownershipCache
+		// кеш годится, только если запомненный HEAD — предок нынешнего: тогда новое — это коммиты между ними
+		if json.Unmarshal(data, &old) == nil && old.Files != nil && exec.Command("git", "-C", root, "merge-base", "--is-ancestor", old.Head, head).Run() == nil {
+			if old.Head == head {
+				return &old, nil
+			}
+			cache, revs = &old, old.Head+".."+head
+		}
+	}
+	if err := cache.read(root, revs); err != nil {
+		return nil, err
+	}
+	cache.Head = head
+	if cachePath != "" {
+		// кеш не удалось записать — не беда, в следующий раз история прочитается заново
+		if data, err := json.Marshal(cache); err == nil {
+			writeOwnershipCache(cachePath, data)
+		}
+	}
+	return cache, nil
+}
+
+// readOwnershipCache читает файл кеша; пустой путь — кеша нет
+func readOwnershipCache(path string) ([]byte, error) {
+	if path == "" {
+		return nil, os.ErrNotExist
+	}
+	return os.ReadFile(path)
+}
+
+// writeOwnershipCache атомарно заменяет файл кеша: пишет во временный файл рядом и переименовывает его
+func writeOwnershipCache(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	// директорию могла создать старая версия с правами 0755 — сужаем их до 0700
+	if err := os.Chmod(dir, 0o700); err != nil {
+		return err
+	}
+	// CreateTemp создаёт файл с правами 0600 и случайным именем, так что параллельные запуски не пишут в один файл
+	f, err := os.CreateTemp(dir, ".ownership-*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+// read добавляет в c коммиты revs, меняющие файлы под root
+func (c *ownershipCache) read(root, revs string) error {
+	// каждый коммит начинается с "\x1eавтор\x1fдата", за ним идут пути; -z разделяет всё нулями
+	cmd := exec.Command("git", "-C", root, "log", "--format=%x1e%aN%x1f%cs", "--name-only", "--no-renames", "-z", "--relative", revs, "--", ".")
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	// коммиты идут от новых к старым: дата первого встреченного коммита файла — последнее изменение
+	// (уже известные файлы из кеша изменены не раньше новых коммитов)
+	seen := make(map[string]bool)
+	author, date := "", ""
+	r := bufio.NewReader(stdout)
+	for {
+		field, err := r.ReadString(0)
+		field = strings.TrimPrefix(strings.TrimSuffix(field, "\x00"), "\n")
+		if commit, ok := strings.CutPrefix(field, "\x1e"); ok {
+			author, date, _ = strings.Cut(commit, "\x1f")
+		} else if field != "" && author != "" {
+			f := c.Files[field]
+			if f == nil {
+				f = &fileOwnership{Authors: make(map[string]int)}
+				c.Files[field] = f
+			}
+			f.Authors[author]++
+			if !seen[field] {
+				seen[field] = true
+				f.Last = date
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			cmd.Process.Kill()
+			cmd.Wait()
+			return err
+		}
+	}
+	if err := cmd.Wait(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("git log: %v: %s", err, msg)
+		}
+		return fmt.Errorf("git log: %v", err)
+	}
+	return nil
+}
+
+// authorName убирает из имени автора то, что спутало бы разбор заголовка
+var authorName = strings.NewReplacer(", ", " ", ";", "", "(", "", ")", "", "\n", " ")
+
+// notes возвращает пометки --ownership для заголовка файла relPath (nil — файла нет в истории)
+func (c *ownershipCache) notes(relPath string) []string {
+	f := c.Files[relPath]
+	if f == nil {
+		return nil
+	}
+	authors := make([]string, 0, len(f.Authors))
+	for name := range f.Authors {
+		authors = append(authors, name)
+	}
+	sort.Slice(authors, func(i, j int) bool {
+		if a, b := f.Authors[authors[i]], f.Authors[authors[j]]; a != b {
+			return a > b
+		}
+		return authors[i] < authors[j]
+	})
+	if len(authors) > ownershipTop {
+		authors = authors[:ownershipTop]
+	}
+	// авторы — одной пометкой через "; ": пометки заголовка разделены ", ", и имя не должно их разбить
+	for i, name := range authors {
+		authors[i] = fmt.Sprintf("%s %d", authorName.Replace(name), f.Authors[name])
+	}
+	return []string{"authors: " + strings.Join(authors, "; "), "last changed " + f.Last}
+}
@@ -0,0 +1,521 @@
+package main
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/asquebay/directory-serialization/format"
+)
+
+// runRestore реализует подкоманду restore: воссоздаёт по текстовому дампу директории и файлы на диске,
+// так что дамп можно отдать LLM, получить обратно с правками и развернуть
+// пишется всё через os.Root, поэтому пути из дампа ("../x", симлинки в директории) не выведут запись за её пределы
+// файлы без содержимого в дампе (бинарные, пропущенные) и обрезанные воссоздать нельзя: они пропускаются
+// с --to tar дамп вместо диска пишется архивом tar в файл или в stdout, например контекстом для docker build -
+// права в дампе не хранятся: по умолчанию файлы и директории создаются с учётом umask, с --manifest — с правами
+// из манифеста, а --chmod-files/--chmod-dirs задают их явно; символьные ссылки из манифеста воссоздаются ссылками
+// возвращает код выхода: 0 — всё воссоздано, 1 — часть файлов пропущена или не записана, 2 — ошибка
+func runRestore(args []string) int {
+	fs := flag.NewFlagSet("restore", flag.ExitOnError)
+	to := fs.String("to", "dir", "where to restore: dir (a directory on disk) or tar (a tar stream written to the given file, or stdout without one or with -)")
+	overwrite := fs.Bool("overwrite", false, "write into an existing non-empty directory, replacing files that are in the dump and keeping the rest")
+	dryRun := fs.Bool("dry-run", false, "only list what would be created or updated")
+	manifestPath := fs.String("manifest", "", "apply file modes recorded in the manifest `file` written with --manifest and recreate the symbolic links it records")
+	chmodFiles := fs.String("chmod-files", "", "give every restored file these permission `bits` (octal, e.g. 644) instead of the recorded or default ones")
+	chmodDirs := fs.String("chmod-dirs", "", "give every restored directory these permission `bits` (octal, e.g. 755)")
+	dereference := fs.Bool("dereference", false, "with --manifest, write symbolic links as regular files with the contents from the dump instead of recreating the links")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: directory-serialization restore [flags] <dump> <directory>\n       directory-serialization restore --to tar <dump> [file.tar]\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	switch {
+	case *to != "dir" && *to != "tar":
+		fmt.Fprintf(os.Stderr, "Error: unknown --to %q (expected dir or tar)\n", *to)
+		return 2
+	case *to == "tar" && (*overwrite || *dryRun):
+		fmt.Fprintln(os.Stderr, "Error: --overwrite and --dry-run apply only to --to dir")
+		return 2
+	case *to == "dir" && fs.NArg() != 2, *to == "tar" && (fs.NArg() < 1 || fs.NArg() > 2):
+		fs.Usage()
+		return 2
+	case *dereference && *manifestPath == "":
+		fmt.Fprintln(os.Stderr, "Error: --dereference needs --manifest: the dump itself does not record symbolic links")
+		return 2
+	}
+	attrs, err := loadRestoreAttrs(*manifestPath, *chmodFiles, *chmodDirs, *dereference)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 2
+	}
+	dumpPath, dir := fs.Arg(0), fs.Arg(1)
+
+	f, err := os.Open(dumpPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error opening dump %s: %v\n", dumpPath, err)
+		return 2
+	}
+	dump, err := format.Parse(f)
+	f.Close()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error parsing dump %s: %v\n", dumpPath, err)
+		return 2
+	}
+	// ссылку на директорию или на бинарный файл дамп не содержит, но для самой ссылки содержимое не нужно
+	isLink := func(s format.Skipped) bool {
+		_, ok := attrs.links[s.Path]
+		return ok
+	}
+	// в tar пишется fs.FS с путями только в UTF-8, а на диск — всё, что не выводит за директорию, с исходными байтами имён
+	if *to == "tar" {
+		fsys, skipped := dump.RestoreFS()
+		return restoreTar(fsys, slices.DeleteFunc(skipped, isLink), dir, attrs)
+	}
+	restored, skipped := dump.RestoreEntries()
+	skipped = slices.DeleteFunc(skipped, isLink)
+
+	// в чужую непустую директорию без --overwrite не пишем: дамп легко развернуть не туда
+	entries, err := os.ReadDir(dir)
+	switch {
+	case err == nil && len(entries) > 0 && !*overwrite:
+		fmt.Fprintf(os.Stderr, "Error: %s is not empty (use --overwrite to write the dump into it)\n", dir)
+		return 2
+	case err != nil && !errors.Is(err, os.ErrNotExist):
+		fmt.Fprintf(os.Stderr, "Error reading %s: %v\n", dir, err)
+		return 2
+	}
+	var root *os.Root
+	if !*dryRun {
+		if err := os.MkdirAll(dir, 0o777); err != nil {
+			fmt.Fprintf(os.Stderr, "Error creating %s: %v\n", dir, err)
+			return 2
+		}
+		if root, err = os.OpenRoot(dir); err != nil {
+			fmt.Fprintf(os.Stderr, "Error opening %s: %v\n", dir, err)
+			return 2
+		}
+		defer root.Close()
+	}
+
+	for _, s := range skipped {
+		fmt.Printf("%-8s %s (%s)\n", "SKIPPED", format.QuoteName(s.Path), s.Reason)
+	}
+	written, failed := restoreTree(root, dir, restored, *dryRun, attrs)
+
+	verb := "Restored"
+	if *dryRun {
+		verb = "Would restore"
+	}
+	fmt.Fprintf(os.Stderr, "%s %d file(s) into %s\n", verb, written, dir)
+	if len(skipped) > 0 {
+		fmt.Fprintf(os.Stderr, "%d file(s) without full contents in the dump were skipped\n", len(skipped))
+	}
+	if len(skipped) > 0 || failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// restoreAttrs — то, чего нет в самом дампе: права файлов и символьные ссылки из манифеста
+// и права, заданные --chmod-files/--chmod-dirs
+type restoreAttrs struct {
+	modes       map[string]fs.FileMode // права файлов из манифеста
+	links       map[string]string      // символьные ссылки из манифеста: путь → цель, как записана в ссылке
+	dereference bool                   // ссылки пишутся обычными файлами с содержимым из дампа
+
+	fileMode, dirMode       fs.FileMode // --chmod-files, --chmod-dirs
+	setFileMode, setDirMode bool
+}
+
+// loadRestoreAttrs читает манифест (если он задан) и разбирает --chmod-files/--chmod-dirs
+func loadRestoreAttrs(manifestPath, chmodFiles, chmodDirs string, dereference bool) (*restoreAttrs, error) {
+	attrs := &restoreAttrs{dereference: dereference}
+	var err error
+	if attrs.fileMode, attrs.setFileMode, err = parseChmod("--chmod-files", chmodFiles); err != nil {
+		return nil, err
+	}
+	if attrs.dirMode, attrs.setDirMode, err = parseChmod("--chmod-dirs", chmodDirs); err != nil {
+		return nil, err
+	}
+	if manifestPath == "" {
+		return attrs, nil
+	}
+	data, err := os.ReadFile(manifestPath)
+	if err != nil {
+		return nil, err
+	}
+	var m struct {
+		Files []struct {
+			Path    string `json:"path"`
+			RawPath []byte `json:"raw_path"`
+			Mode    string `json:"mode"`
+			Symlink string `json:"symlink"`
+		} `json:"files"`
+	}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("parsing manifest %s: %v", manifestPath, err)
+	}
+	attrs.modes = make(map[string]fs.FileMode)
+	attrs.links = make(map[string]string)
+	for _, f := range m.Files {
+		// путь не в UTF-8 в строке path искажён, исходные байты — в raw_path
+		if f.RawPath != nil {
+			f.Path = string(f.RawPath)
+		}
+		// без прав (файловая система их не хранит, см. metadata_gaps) файл получит права по умолчанию
+		if f.Mode != "" {
+			mode, err := strconv.ParseUint(f.Mode, 8, 32)
+			if err != nil || mode > 0o777 {
+				return nil, fmt.Errorf("manifest %s: invalid mode %q of %s", manifestPath, f.Mode, f.Path)
+			}
+			attrs.modes[f.Path] = fs.FileMode(mode)
+		}
+		if f.Symlink != "" && !dereference {
+			attrs.links[f.Path] = f.Symlink
+		}
+	}
+	return attrs, nil
+}
+
+// parseChmod разбирает восьмеричные права из флага name; пустое значение — флаг не задан
+func parseChmod(name, value string) (fs.FileMode, bool, error) {
+	if value == "" {
+		return 0, false, nil
+	}
+	mode, err := strconv.ParseUint(value, 8, 32)
+	if err != nil || mode > 0o777 {
+		return 0, false, fmt.Errorf("%s %q: expected octal permission bits such as 644", name, value)
+	}
+	return fs.FileMode(mode), true, nil
+}
+
+// fileModeFor возвращает права, которые нужно выставить файлу p; false — оставить те, что дал umask
+func (a *restoreAttrs) fileModeFor(p string) (fs.FileMode, bool) {
+	if a.setFileMode {
+		return a.fileMode, true
+	}
+	mode, ok := a.modes[p]
+	return mode, ok
+}
+
+// restoreTree пишет файлы и директории entries в root (dir — его путь на диске, для dryRun, когда root nil)
+// и печатает, что создано и обновлено; возвращает, сколько файлов записано и сколько записать не удалось
+// ссылки создаются после файлов, а права директорий выставляются в самом конце, от глубоких к корню:
+// директория без права записи, выставленная раньше, не дала бы записать в неё остальное
+func restoreTree(root *os.Root, dir string, entries []format.RestoreEntry, dryRun bool, attrs *restoreAttrs) (written, failed int) {
+	var dirs []string
+	contents := make(map[string][]byte)
+	for _, e := range entries {
+		p := e.Path
+		if e.IsDir {
+			dirs = append(dirs, p)
+			if !dryRun {
+				if err := restoreDir(root, p); err != nil {
+					fmt.Fprintf(os.Stderr, "Error creating %s: %v\n", format.QuoteName(p), err)
+					failed++
+				}
+			}
+			continue
+		}
+		contents[p] = e.Data
+		if _, ok := attrs.links[p]; ok {
+			continue
+		}
+		mode, setMode := attrs.fileModeFor(p)
+		kind, err := restoreFile(root, filepath.Join(dir, filepath.FromSlash(p)), p, e.Data, mode, setMode, dryRun)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing %s: %v\n", format.QuoteName(p), err)
+			failed++
+		} else if kind != "" {
+			fmt.Printf("%-8s %s\n", kind, format.QuoteName(p))
+			written++
+		}
+	}
+
+	for _, p := range slices.Sorted(maps.Keys(attrs.links)) {
+		target := attrs.links[p]
+		kind, err := restoreLink(root, dir, p, target, dryRun)
+		if err != nil && runtime.GOOS == "windows" {
+			// создавать ссылки на Windows можно только с привилегией или в режиме разработчика:
+			// без них пишем файл, на который ссылка вела, если он есть в дампе
+			if data, ok := contents[p]; ok {
+				fmt.Fprintf(os.Stderr, "Warning: cannot create symbolic link %s (%v), writing the file it points to instead\n", format.QuoteName(p), err)
+				mode, setMode := attrs.fileModeFor(p)
+				kind, err = restoreFile(root, filepath.Join(dir, filepath.FromSlash(p)), p, data, mode, setMode, dryRun)
+				target = ""
+			}
+		}
+		switch {
+		case err != nil:
+			fmt.Fprintf(os.Stderr, "Error creating symbolic link %s: %v\n", format.QuoteName(p), err)
+			failed++
+		case kind != "" && target != "":
+			fmt.Printf("%-8s %s -> %s\n", kind, format.QuoteName(p), strconv.Quote(target))
+			written++
+		case kind != "":
+			fmt.Printf("%-8s %s\n", kind, format.QuoteName(p))
+			written++
+		}
+	}
+
+	if attrs.setDirMode && !dryRun {
+		for _, p := range slices.Backward(dirs) {
+			if err := chmodRestored(root, p, attrs.dirMode, true); err != nil {
+				fmt.Fprintf(os.Stderr, "Error changing mode of %s: %v\n", format.QuoteName(p), err)
+				failed++
+			}
+		}
+	}
+	return written, failed
+}
+
+// restoreTar пишет fsys архивом tar в файл name (пусто или "-" — в stdout); о пропущенных файлах — в stderr,
+// потому что stdout занят архивом
+// в дампе нет времени изменения файлов, поэтому у всех элементов архива оно одно — начало эпохи Unix:
+// одинаковый дамп даёт одинаковый архив; права — из манифеста или --chmod-*, иначе 0644 и 0755
+func restoreTar(fsys fs.FS, skipped []format.Skipped, name string, attrs *restoreAttrs) int {
+	var out io.Writer = os.Stdout
+	if name == "" || name == "-" {
+		if isTerminal(os.Stdout) {
+			fmt.Fprintln(os.Stderr, "Error: refusing to write a tar stream to a terminal (redirect stdout or give a file name)")
+			return 2
+		}
+		name = "stdout"
+	} else {
+		f, err := os.Create(name)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error creating %s: %v\n", name, err)
+			return 2
+		}
+		defer f.Close()
+		out = f
+	}
+	bw := bufio.NewWriter(out)
+	tw := tar.NewWriter(bw)
+	written := 0
+	err := fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil || p == "." {
+			return err
+		}
+		hdr := &tar.Header{Name: p, Mode: 0o644, ModTime: time.Unix(0, 0), Typeflag: tar.TypeReg}
+		if d.IsDir() {
+			hdr.Name, hdr.Mode, hdr.Typeflag = p+"/", 0o755, tar.TypeDir
+			if attrs.setDirMode {
+				hdr.Mode = int64(attrs.dirMode)
+			}
+			return tw.WriteHeader(hdr)
+		}
+		if _, ok := attrs.links[p]; ok {
+			return nil
+		}
+		if mode, ok := attrs.fileModeFor(p); ok {
+			hdr.Mode = int64(mode)
+		}
+		data, err := fs.ReadFile(fsys, p)
+		if err != nil {
+			return err
+		}
+		hdr.Size = int64(len(data))
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		written++
+		_, err = tw.Write(data)
+		return err
+	})
+	for _, p := range slices.Sorted(maps.Keys(attrs.links)) {
+		if err != nil {
+			break
+		}
+		hdr := &tar.Header{Name: p, Linkname: attrs.links[p], Mode: 0o777, ModTime: time.Unix(0, 0), Typeflag: tar.TypeSymlink}
+		if err = tw.WriteHeader(hdr); err == nil {
+			written++
+		}
+	}
+	if err == nil {
+		err = tw.Close()
+	}
+	if err == nil {
+		err = bw.Flush()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing %s: %v\n", name, err)
+		return 2
+	}
+	for _, s := range skipped {
+		fmt.Fprintf(os.Stderr, "%-8s %s (%s)\n", "SKIPPED", format.QuoteName(s.Path), s.Reason)
+	}
+	fmt.Fprintf(os.Stderr, "Restored %d file(s) into %s\n", written, name)
+	if len(skipped) > 0 {
+		fmt.Fprintf(os.Stderr, "%d file(s) without full contents in the dump were skipped\n", len(skipped))
+		return 1
+	}
+	return 0
+}
+
+// restoreDir создаёт директорию p (через "/") со всеми родителями внутри root; права — 0777 за вычетом umask
+func restoreDir(root *os.Root, p string) error {
+	if p == "." || p == "" {
+		return nil
+	}
+	if err := restoreDir(root, path.Dir(p)); err != nil {
+		return err
+	}
+	err := root.Mkdir(filepath.FromSlash(p), 0o777)
+	if errors.Is(err, fs.ErrExist) {
+		if info, statErr := root.Stat(filepath.FromSlash(p)); statErr == nil && info.IsDir() {
+			return nil
+		}
+	}
+	return err
+}
+
+// restoreFile записывает файл p и возвращает, что с ним сделано: "CREATE", "UPDATE", "CHMOD" (содержимое
+// то же, права другие) или "" (уже такой же); без setMode новый файл получает 0666 за вычетом umask,
+// а у существующего права не меняются; diskPath — путь для чтения текущего содержимого при dryRun (root тогда nil)
+func restoreFile(root *os.Root, diskPath, p string, data []byte, mode fs.FileMode, setMode, dryRun bool) (string, error) {
+	var old []byte
+	var err error
+	if dryRun {
+		old, err = os.ReadFile(diskPath)
+	} else {
+		old, err = readRootFile(root, filepath.FromSlash(p))
+	}
+	kind := "UPDATE"
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		kind = "CREATE"
+	case err != nil:
+		return "", err
+	case bytes.Equal(old, data):
+		if !setMode {
+			return "", nil
+		}
+		var info fs.FileInfo
+		if dryRun {
+			info, err = os.Stat(diskPath)
+		} else {
+			info, err = root.Stat(filepath.FromSlash(p))
+		}
+		if err != nil || info.Mode().Perm() == mode || runtime.GOOS == "windows" && info.Mode()&0o200 == mode&0o200 {
+			return "", err
+		}
+		if dryRun {
+			return "CHMOD", nil
+		}
+		return "CHMOD", chmodRestored(root, p, mode, false)
+	}
+	if dryRun {
+		return kind, nil
+	}
+	if err := restoreDir(root, path.Dir(p)); err != nil {
+		return "", err
+	}
+	f, err := root.OpenFile(filepath.FromSlash(p), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o666)
+	if err != nil {
+		return "", err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return "", err
+	}
+	// права выставляются явно, а не через OpenFile: тот применяет umask и не меняет права существующего файла
+	if setMode {
+		if err := f.Chmod(mode); err != nil {
+			f.Close()
+			return "", err
+		}
+	}
+	return kind, f.Close()
+}
+
+// chmodRestored выставляет права файлу или директории p внутри root через открытый файл, а не по пути,
+// чтобы не выйти за пределы root по ссылке
+// на Windows File.Chmod переключает только атрибут «только чтение» и ACL не трогает; у директорий этот
+// атрибут значит другое (Проводник по нему ищет desktop.ini), поэтому права директорий там не применяются
+func chmodRestored(root *os.Root, p string, mode fs.FileMode, isDir bool) error {
+	flag := os.O_RDONLY
+	if runtime.GOOS == "windows" {
+		if isDir {
+			return nil
+		}
+		// для смены атрибутов Windows требует открыть файл на запись
+		flag = os.O_WRONLY
+	}
+	f, err := root.OpenFile(filepath.FromSlash(p), flag, 0)
+	if err != nil {
+		return err
+	}
+	if err := f.Chmod(mode); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// restoreLink создаёт символьную ссылку p → target внутри root (dir — его путь на диске) и возвращает
+// "CREATE", "UPDATE" или "" (такая ссылка уже есть); существующий на её месте файл заменяется
+// os.Root создаёт ссылки только с Go 1.25, поэтому ссылка создаётся по пути на диске, а перед этим
+// проверяется, что ни одна директория на пути к ней не ссылка: иначе она появилась бы за пределами dir
+// цель не проверяется: ссылка, как и в исходном древе, может вести куда угодно
+func restoreLink(root *os.Root, dir, p, target string, dryRun bool) (string, error) {
+	// путь берётся из манифеста, а не из дампа: проверяем, что он не выводит за dir
+	if !filepath.IsLocal(filepath.FromSlash(p)) {
+		return "", fmt.Errorf("unsafe path %s", format.QuoteName(p))
+	}
+	diskPath := filepath.Join(dir, filepath.FromSlash(p))
+	kind := "UPDATE"
+	old, err := os.Readlink(diskPath)
+	switch {
+	case err == nil && old == target:
+		return "", nil
+	case errors.Is(err, fs.ErrNotExist):
+		kind = "CREATE"
+	}
+	if dryRun {
+		return kind, nil
+	}
+	if err := restoreDir(root, path.Dir(p)); err != nil {
+		return "", err
+	}
+	for d := path.Dir(p); d != "."; d = path.Dir(d) {
+		info, err := root.Lstat(filepath.FromSlash(d))
+		if err != nil {
+			return "", err
+		}
+		if info.Mode()&fs.ModeSymlink != 0 {
+			return "", fmt.Errorf("%s is a symbolic link", format.QuoteName(d))
+		}
+	}
+	if kind == "UPDATE" {
+		if err := root.Remove(filepath.FromSlash(p)); err != nil {
+			return "", err
+		}
+	}
+	return kind, os.Symlink(target, diskPath)
+}
+
+// readRootFile читает файл внутри root (os.Root.ReadFile появился только в Go 1.25)
+func readRootFile(root *os.Root, name string) ([]byte, error) {
+	f, err := root.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
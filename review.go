@@ -0,0 +1,313 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/asquebay/directory-serialization/detector"
+	"github.com/asquebay/directory-serialization/serializer"
+)
+
+// runReview реализует подкоманду review: собирает бандл для ревью изменений с помощью LLM —
+// список изменённых файлов, diff, полное содержимое изменённых файлов и фрагменты связанных файлов
+// (тех, что ссылаются на изменённые: импорты, вызовы), найденных простым поиском имён
+func runReview(args []string) int {
+	fs := flag.NewFlagSet("review", flag.ExitOnError)
+	base := fs.String("base", "main", "branch or commit to compare against (changes since the merge base with HEAD, including uncommitted ones)")
+	context := fs.Int("context", 3, "lines of context around each reference in related files")
+	maxRelated := fs.Int("max-related", 20, "include at most `n` related files")
+	output := fs.String("output", "", "write the bundle to `file` instead of stdout")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: directory-serialization review [flags] [repository]\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() > 1 || *context < 0 || *maxRelated < 0 {
+		fs.Usage()
+		return 2
+	}
+	dir := "."
+	if fs.NArg() == 1 {
+		dir = fs.Arg(0)
+	}
+
+	top, err := git(dir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: not inside a git repository: %v\n", err)
+		return 2
+	}
+	top = strings.TrimSpace(top)
+	mergeBase, err := git(top, "merge-base", *base, "HEAD")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: cannot find the merge base of %s and HEAD: %v\n", *base, err)
+		return 2
+	}
+	mergeBase = strings.TrimSpace(mergeBase)
+
+	changes, err := changedFiles(top, mergeBase)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error listing changes: %v\n", err)
+		return 2
+	}
+	if len(changes) == 0 {
+		fmt.Fprintf(os.Stderr, "No changes against %s\n", *base)
+		return 0
+	}
+	diff, err := git(top, "diff", mergeBase)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error running git diff: %v\n", err)
+		return 2
+	}
+
+	var out io.Writer = os.Stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error creating %s: %v\n", *output, err)
+			return 2
+		}
+		defer f.Close()
+		bw := bufio.NewWriter(f)
+		defer bw.Flush()
+		out = bw
+	}
+	sanitize := *output == "" && isTerminal(os.Stdout)
+	block := func(header string, data []byte) {
+		if sanitize {
+			data = serializer.SanitizeControls(data)
+		}
+		fmt.Fprintf(out, "%s:\n```\n%s\n```\n", header, bytes.TrimSuffix(data, []byte("\n")))
+	}
+
+	rootName := filepath.Base(top)
+	shortBase := mergeBase
+	if len(shortBase) > 12 {
+		shortBase = shortBase[:12]
+	}
+	fmt.Fprintf(out, "Review bundle: changes in %s against %s (merge base %s)\n\n", rootName, *base, shortBase)
+
+	fmt.Fprintln(out, "## Changed files")
+	fmt.Fprintln(out)
+	for _, c := range changes {
+		fmt.Fprintf(out, "%s  %s\n", c.status, c.path)
+	}
+	fmt.Fprintln(out)
+
+	fmt.Fprintln(out, "## Diff")
+	fmt.Fprintln(out)
+	fmt.Fprintf(out, "```diff\n%s```\n\n", diff)
+
+	// полное содержимое изменённых файлов и имена, по которым ищем связанные файлы
+	fmt.Fprintln(out, "## Full content of changed files")
+	fmt.Fprintln(out)
+	symbols := make(map[string]bool)
+	changed := make(map[string]bool)
+	for _, c := range changes {
+		changed[c.path] = true
+		if c.status == "D" {
+			// удалённый файл берём из базы: кто на него ссылался, тот теперь сломан
+			old, err := git(top, "show", mergeBase+":"+c.path)
+			if err == nil {
+				collectSymbols(c.path, []byte(old), symbols)
+			}
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(top, filepath.FromSlash(c.path)))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading %s: %v\n", c.path, err)
+			continue
+		}
+		if !detector.IsText(data) {
+			fmt.Fprintf(out, "%s/%s: binary file, content omitted\n", rootName, c.path)
+			continue
+		}
+		collectSymbols(c.path, data, symbols)
+		block(rootName+"/"+c.path, data)
+	}
+	fmt.Fprintln(out)
+
+	related, err := relatedFiles(top, changed, symbols, *context)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error scanning for related files: %v\n", err)
+		return 2
+	}
+	if len(related) > *maxRelated {
+		fmt.Fprintf(os.Stderr, "%d related file(s) found, keeping the %d with the most references\n", len(related), *maxRelated)
+		related = related[:*maxRelated]
+	}
+	if len(related) > 0 {
+		fmt.Fprintf(out, "## Related code (%d lines of context around references)\n\n", *context)
+		for _, r := range related {
+			for _, ex := range r.excerpts {
+				block(fmt.Sprintf("%s/%s (lines %d-%d, refers to: %s)", rootName, r.path, ex.first, ex.last, strings.Join(ex.symbols, " ")), ex.data)
+			}
+		}
+	}
+	return 0
+}
+
+// git запускает git в директории dir и возвращает его вывод
+func git(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%v: %s", err, msg)
+		}
+		return "", err
+	}
+	return string(out), nil
+}
+
+// change — изменённый файл: статус git (A, M, D, R...) и путь
+type change struct {
+	status string
+	path   string
+}
+
+// changedFiles перечисляет файлы, изменённые с коммита base (с учётом незакоммиченных и неотслеживаемых)
+func changedFiles(top, base string) ([]change, error) {
+	out, err := git(top, "diff", "--name-status", "-z", "--no-renames", base)
+	if err != nil {
+		return nil, err
+	}
+	var changes []change
+	fields := strings.Split(strings.TrimSuffix(out, "\x00"), "\x00")
+	for i := 0; i+1 < len(fields); i += 2 {
+		changes = append(changes, change{status: fields[i][:1], path: fields[i+1]})
+	}
+	// новые файлы, ещё не добавленные в индекс, git diff не видит
+	untracked, err := git(top, "ls-files", "-z", "--others", "--exclude-standard")
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range strings.Split(untracked, "\x00") {
+		if p != "" {
+			changes = append(changes, change{status: "A", path: p})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].path < changes[j].path })
+	return changes, nil
+}
+
+// definition находит объявления в распространённых языках: func/def/class/function и т.п. (в том числе методы с отступом)
+// и type/const/var/let — только в начале строки, чтобы не цеплять локальные переменные
+var definition = regexp.MustCompile(`(?m)^(?:\s*(?:export\s+|pub\s+|public\s+|static\s+|async\s+)*(?:func|def|class|function|interface|struct|enum|fn)|(?:export\s+)?(?:type|const|var|let))\s+(?:\([^)]*\)\s*)?([A-Za-z_][A-Za-z0-9_]*)`)
+
+// minSymbolLen — более короткие имена (i, ok, err) встречаются повсюду и только зашумляют поиск
+const minSymbolLen = 4
+
+// commonNames — имена, которые встречаются почти в каждом файле (package main, index.js) и ничего не связывают
+var commonNames = map[string]bool{"main": true, "index": true, "init": true, "utils": true, "types": true, "common": true, "README": true, "LICENSE": true}
+
+// collectSymbols добавляет имена, по которым другие файлы могут ссылаться на файл:
+// имя файла без расширения (импорты, include) и объявленные в нём функции, типы и т.п.
+func collectSymbols(slashPath string, data []byte, symbols map[string]bool) {
+	stem := strings.TrimSuffix(path.Base(slashPath), path.Ext(slashPath))
+	if len(stem) >= minSymbolLen && !commonNames[stem] {
+		symbols[stem] = true
+	}
+	for _, m := range definition.FindAllSubmatch(data, -1) {
+		if name := string(m[1]); len(name) >= minSymbolLen && !commonNames[name] {
+			symbols[name] = true
+		}
+	}
+}
+
+// excerpt — фрагмент связанного файла вокруг ссылок
+type excerpt struct {
+	first, last int // номера строк, с 1
+	data        []byte
+	symbols     []string // на что ссылается фрагмент
+}
+
+// relatedFile — файл, ссылающийся на изменённые
+type relatedFile struct {
+	path     string
+	refs     int
+	excerpts []excerpt
+}
+
+// maxRelatedSize — файлы больше этого не сканируем: это скорее данные, чем код
+const maxRelatedSize = 1 << 20
+
+// relatedFiles ищет среди отслеживаемых файлов те, что упоминают symbols, и вырезает фрагменты вокруг упоминаний
+// результат отсортирован по числу ссылок (больше — раньше)
+func relatedFiles(top string, changed, symbols map[string]bool, context int) ([]relatedFile, error) {
+	if len(symbols) == 0 {
+		return nil, nil
+	}
+	names := make([]string, 0, len(symbols))
+	for s := range symbols {
+		names = append(names, regexp.QuoteMeta(s))
+	}
+	sort.Strings(names)
+	re := regexp.MustCompile(`\b(?:` + strings.Join(names, "|") + `)\b`)
+
+	tracked, err := git(top, "ls-files", "-z")
+	if err != nil {
+		return nil, err
+	}
+	var related []relatedFile
+	for _, p := range strings.Split(tracked, "\x00") {
+		if p == "" || changed[p] {
+			continue
+		}
+		full := filepath.Join(top, filepath.FromSlash(p))
+		if info, err := os.Stat(full); err != nil || !info.Mode().IsRegular() || info.Size() > maxRelatedSize {
+			continue
+		}
+		data, err := os.ReadFile(full)
+		if err != nil || !detector.IsText(data) {
+			continue
+		}
+		if r, ok := scanReferences(p, data, re, context); ok {
+			related = append(related, r)
+		}
+	}
+	sort.SliceStable(related, func(i, j int) bool { return related[i].refs > related[j].refs })
+	return related, nil
+}
+
+// scanReferences находит строки с упоминаниями и склеивает перекрывающиеся фрагменты
+func scanReferences(slashPath string, data []byte, re *regexp.Regexp, context int) (relatedFile, bool) {
+	lines := bytes.SplitAfter(data, []byte("\n"))
+	r := relatedFile{path: slashPath}
+	for i, line := range lines {
+		found := re.FindAll(line, -1)
+		if len(found) == 0 {
+			continue
+		}
+		r.refs += len(found)
+		first, last := max(1, i+1-context), min(len(lines), i+1+context)
+		if n := len(r.excerpts); n > 0 && first <= r.excerpts[n-1].last+1 {
+			r.excerpts[n-1].last = last
+		} else {
+			r.excerpts = append(r.excerpts, excerpt{first: first, last: last})
+		}
+		ex := &r.excerpts[len(r.excerpts)-1]
+		for _, f := range found {
+			if name := string(f); !slices.Contains(ex.symbols, name) {
+				ex.symbols = append(ex.symbols, name)
+			}
+		}
+	}
+	for i := range r.excerpts {
+		ex := &r.excerpts[i]
+		ex.data = bytes.Join(lines[ex.first-1:ex.last], nil)
+	}
+	return r, r.refs > 0
+}
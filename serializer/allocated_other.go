@@ -0,0 +1,10 @@
+//go:build !unix
+
+package serializer
+
+import "io/fs"
+
+// allocatedSize на этой платформе занятое на диске место не узнать
+func allocatedSize(fs.FileInfo) (int64, bool) {
+	return 0, false
+}
@@ -0,0 +1,17 @@
+//go:build unix
+
+package serializer
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// allocatedSize возвращает, сколько байт файл занимает на диске (блоки по 512 байт из stat)
+func allocatedSize(info fs.FileInfo) (int64, bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, false
+	}
+	return int64(st.Blocks) * 512, true
+}
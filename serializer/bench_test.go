@@ -0,0 +1,101 @@
+package serializer
+
+import (
+	"flag"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/asquebay/directory-serialization/detector"
+	"github.com/asquebay/directory-serialization/internal/benchtree"
+)
+
+// бенчмарки тех же этапов и на тех же деревьях, что у подкоманды bench:
+//
+//	go test -run '^$' -bench . ./serializer
+//	go test -run '^$' -bench Walk/wide ./serializer -args -bench-scale 1
+//
+// по умолчанию деревья уменьшены вчетверо, чтобы бенчмарки не генерировали сотню мегабайт
+
+var benchScale = flag.Float64("bench-scale", 0.25, "grow or shrink the synthetic benchmark trees by this factor")
+
+// benchTrees генерирует деревья benchtree.Trees во временной директории и для каждого запускает fn
+// как под-бенчмарк
+func benchTrees(b *testing.B, fn func(b *testing.B, root string)) {
+	base := b.TempDir()
+	for _, tree := range benchtree.Trees {
+		root := filepath.Join(base, tree.Name)
+		if err := tree.Make(root, *benchScale); err != nil {
+			b.Fatal(err)
+		}
+		b.Run(tree.Name, func(b *testing.B) { fn(b, root) })
+	}
+}
+
+// treeBytes считает файлы и байты дерева
+func treeBytes(b *testing.B, root string) (files [][]byte, size int64) {
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		files = append(files, data)
+		size += int64(len(data))
+		return err
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+	return files, size
+}
+
+// BenchmarkWalk — обход с определением типа файлов, без содержимого (--format tree-json)
+func BenchmarkWalk(b *testing.B) {
+	benchTrees(b, func(b *testing.B, root string) {
+		_, size := treeBytes(b, root)
+		b.SetBytes(size)
+		b.ResetTimer()
+		for range b.N {
+			if _, err := Run(io.Discard, root, Options{Format: FormatTreeJSON}); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// BenchmarkDetect — определение текста и кодировки по началу файлов, в памяти, без диска
+func BenchmarkDetect(b *testing.B) {
+	benchTrees(b, func(b *testing.B, root string) {
+		files, _ := treeBytes(b, root)
+		samples := make([][]byte, len(files))
+		var size int64
+		for i, data := range files {
+			samples[i] = data[:min(len(data), detector.SampleSize)]
+			size += int64(len(samples[i]))
+		}
+		b.SetBytes(size)
+		b.ResetTimer()
+		for range b.N {
+			for _, sample := range samples {
+				detector.IsText(sample)
+				detector.EncodingDetector(sample, detector.None)
+			}
+		}
+	})
+}
+
+// BenchmarkDump — полный текстовый дамп
+func BenchmarkDump(b *testing.B) {
+	benchTrees(b, func(b *testing.B, root string) {
+		_, size := treeBytes(b, root)
+		b.SetBytes(size)
+		b.ResetTimer()
+		for range b.N {
+			if _, err := Run(io.Discard, root, Options{}); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,269 @@
+package serializer
+
+import (
+	"encoding/binary"
+	"hash"
+	"io"
+	"math/bits"
+	"runtime"
+	"sync"
+)
+
+// BLAKE3 (https://github.com/BLAKE3-team/BLAKE3-specs) без ключа и с 32-байтным результатом — для --hash-algo blake3
+// вход делится на куски по 1KB, которые хешируются независимо и сводятся бинарным деревом; поэтому большой файл
+// можно хешировать сразу в несколько потоков (blake3ReaderAt), а результат тот же, что при чтении подряд
+
+const (
+	blake3BlockLen = 64
+	blake3ChunkLen = 1024
+
+	blake3ChunkStart = 1 << 0
+	blake3ChunkEnd   = 1 << 1
+	blake3Parent     = 1 << 2
+	blake3Root       = 1 << 3
+
+	// blake3SegmentChunks — сколько кусков хеширует один поток за раз (1MB); степень двойки, чтобы сегмент был поддеревом
+	blake3SegmentChunks = 1024
+	blake3SegmentLen    = blake3SegmentChunks * blake3ChunkLen
+)
+
+var blake3IV = [8]uint32{0x6A09E667, 0xBB67AE85, 0x3C6EF372, 0xA54FF53A, 0x510E527F, 0x9B05688C, 0x1F83D9AB, 0x5BE0CD19}
+
+var blake3Permutation = [16]int{2, 6, 3, 10, 7, 0, 4, 13, 1, 11, 12, 5, 9, 14, 15, 8}
+
+func blake3G(s *[16]uint32, a, b, c, d int, x, y uint32) {
+	s[a] += s[b] + x
+	s[d] = bits.RotateLeft32(s[d]^s[a], -16)
+	s[c] += s[d]
+	s[b] = bits.RotateLeft32(s[b]^s[c], -12)
+	s[a] += s[b] + y
+	s[d] = bits.RotateLeft32(s[d]^s[a], -8)
+	s[c] += s[d]
+	s[b] = bits.RotateLeft32(s[b]^s[c], -7)
+}
+
+// blake3Compress — функция сжатия: 7 раундов над состоянием из cv, IV, счётчика, длины блока и флагов
+func blake3Compress(cv *[8]uint32, m [16]uint32, counter uint64, blockLen, flags uint32) [16]uint32 {
+	s := [16]uint32{
+		cv[0], cv[1], cv[2], cv[3], cv[4], cv[5], cv[6], cv[7],
+		blake3IV[0], blake3IV[1], blake3IV[2], blake3IV[3],
+		uint32(counter), uint32(counter >> 32), blockLen, flags,
+	}
+	for round := 0; round < 7; round++ {
+		blake3G(&s, 0, 4, 8, 12, m[0], m[1])
+		blake3G(&s, 1, 5, 9, 13, m[2], m[3])
+		blake3G(&s, 2, 6, 10, 14, m[4], m[5])
+		blake3G(&s, 3, 7, 11, 15, m[6], m[7])
+		blake3G(&s, 0, 5, 10, 15, m[8], m[9])
+		blake3G(&s, 1, 6, 11, 12, m[10], m[11])
+		blake3G(&s, 2, 7, 8, 13, m[12], m[13])
+		blake3G(&s, 3, 4, 9, 14, m[14], m[15])
+		if round < 6 {
+			var p [16]uint32
+			for i, j := range blake3Permutation {
+				p[i] = m[j]
+			}
+			m = p
+		}
+	}
+	for i := 0; i < 8; i++ {
+		s[i] ^= s[i+8]
+		s[i+8] ^= cv[i]
+	}
+	return s
+}
+
+func blake3Words(block []byte) [16]uint32 {
+	var buf [blake3BlockLen]byte
+	copy(buf[:], block)
+	var m [16]uint32
+	for i := range m {
+		m[i] = binary.LittleEndian.Uint32(buf[i*4:])
+	}
+	return m
+}
+
+func blake3CV(s [16]uint32) [8]uint32 {
+	return [8]uint32(s[:8])
+}
+
+// blake3Output — последнее сжатие узла, отложенное до того, как станет известно, корень ли это
+type blake3Output struct {
+	cv       [8]uint32
+	block    [16]uint32
+	counter  uint64
+	blockLen uint32
+	flags    uint32
+}
+
+func (o blake3Output) chainingValue() [8]uint32 {
+	return blake3CV(blake3Compress(&o.cv, o.block, o.counter, o.blockLen, o.flags))
+}
+
+func (o blake3Output) rootHash() []byte {
+	s := blake3Compress(&o.cv, o.block, 0, o.blockLen, o.flags|blake3Root)
+	out := make([]byte, 32)
+	for i := 0; i < 8; i++ {
+		binary.LittleEndian.PutUint32(out[i*4:], s[i])
+	}
+	return out
+}
+
+func blake3ParentOutput(left, right [8]uint32) blake3Output {
+	var block [16]uint32
+	copy(block[:8], left[:])
+	copy(block[8:], right[:])
+	return blake3Output{cv: blake3IV, block: block, blockLen: blake3BlockLen, flags: blake3Parent}
+}
+
+// blake3Chunk — состояние хеширования одного куска
+type blake3Chunk struct {
+	cv         [8]uint32
+	counter    uint64
+	block      [blake3BlockLen]byte
+	blockLen   int
+	compressed int // сколько блоков куска уже сжато
+}
+
+func newBlake3Chunk(counter uint64) blake3Chunk {
+	return blake3Chunk{cv: blake3IV, counter: counter}
+}
+
+func (c *blake3Chunk) len() int { return c.compressed*blake3BlockLen + c.blockLen }
+
+func (c *blake3Chunk) startFlag() uint32 {
+	if c.compressed == 0 {
+		return blake3ChunkStart
+	}
+	return 0
+}
+
+func (c *blake3Chunk) update(p []byte) {
+	for len(p) > 0 {
+		// полный блок сжимаем, только когда за ним есть ещё данные: последний блок куска сжимается с другим флагом
+		if c.blockLen == blake3BlockLen {
+			c.cv = blake3CV(blake3Compress(&c.cv, blake3Words(c.block[:]), c.counter, blake3BlockLen, c.startFlag()))
+			c.compressed++
+			c.blockLen = 0
+		}
+		n := copy(c.block[c.blockLen:], p)
+		c.blockLen += n
+		p = p[n:]
+	}
+}
+
+func (c *blake3Chunk) output() blake3Output {
+	return blake3Output{
+		cv: c.cv, block: blake3Words(c.block[:c.blockLen]), counter: c.counter,
+		blockLen: uint32(c.blockLen), flags: c.startFlag() | blake3ChunkEnd,
+	}
+}
+
+// blake3Hasher — hash.Hash для BLAKE3
+type blake3Hasher struct {
+	chunk blake3Chunk
+	stack [][8]uint32 // значения поддеревьев, ещё не сведённых с соседями справа
+}
+
+func newBlake3() hash.Hash {
+	return &blake3Hasher{chunk: newBlake3Chunk(0)}
+}
+
+// pushSubtree добавляет значение поддерева; total — сколько таких поддеревьев уже есть слева вместе с ним:
+// сколько у total нулевых младших бит, столько пар сводится в родителя
+func (h *blake3Hasher) pushSubtree(cv [8]uint32, total uint64) {
+	for total&1 == 0 {
+		cv = blake3ParentOutput(h.stack[len(h.stack)-1], cv).chainingValue()
+		h.stack = h.stack[:len(h.stack)-1]
+		total >>= 1
+	}
+	h.stack = append(h.stack, cv)
+}
+
+func (h *blake3Hasher) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		if h.chunk.len() == blake3ChunkLen {
+			h.pushSubtree(h.chunk.output().chainingValue(), h.chunk.counter+1)
+			h.chunk = newBlake3Chunk(h.chunk.counter + 1)
+		}
+		take := min(blake3ChunkLen-h.chunk.len(), len(p))
+		h.chunk.update(p[:take])
+		p = p[take:]
+	}
+	return n, nil
+}
+
+func (h *blake3Hasher) Sum(b []byte) []byte {
+	out := h.chunk.output()
+	for i := len(h.stack) - 1; i >= 0; i-- {
+		out = blake3ParentOutput(h.stack[i], out.chainingValue())
+	}
+	return append(b, out.rootHash()...)
+}
+
+func (h *blake3Hasher) Reset() {
+	h.chunk = newBlake3Chunk(0)
+	h.stack = h.stack[:0]
+}
+
+func (h *blake3Hasher) Size() int      { return 32 }
+func (h *blake3Hasher) BlockSize() int { return blake3BlockLen }
+
+// blake3Segment возвращает значение поддерева из blake3SegmentChunks полных кусков, первый из которых — номер first
+func blake3Segment(data []byte, first uint64) [8]uint32 {
+	h := &blake3Hasher{}
+	for i := 0; i < blake3SegmentChunks; i++ {
+		c := newBlake3Chunk(first + uint64(i))
+		c.update(data[i*blake3ChunkLen : (i+1)*blake3ChunkLen])
+		h.pushSubtree(c.output().chainingValue(), uint64(i+1))
+	}
+	return h.stack[0]
+}
+
+// blake3ReaderAt хеширует size байт r: сегменты по 1MB — параллельно, по потоку на ядро, хвост — подряд
+func blake3ReaderAt(r io.ReaderAt, size int64) ([]byte, error) {
+	// последний сегмент, даже полный, хешируется обычным образом: корень дерева должен получить флаг ROOT
+	segments := int((size - 1) / blake3SegmentLen)
+	if size == 0 {
+		segments = 0
+	}
+	cvs := make([][8]uint32, segments)
+	errs := make([]error, segments)
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for range min(runtime.GOMAXPROCS(0), max(segments, 1)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			buf := make([]byte, blake3SegmentLen)
+			for i := range next {
+				if _, err := r.ReadAt(buf, int64(i)*blake3SegmentLen); err != nil {
+					errs[i] = err
+					continue
+				}
+				cvs[i] = blake3Segment(buf, uint64(i)*blake3SegmentChunks)
+			}
+		}()
+	}
+	for i := range segments {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+
+	h := &blake3Hasher{}
+	for i, cv := range cvs {
+		if errs[i] != nil {
+			return nil, errs[i]
+		}
+		h.pushSubtree(cv, uint64(i+1))
+	}
+	// дальше счёт идёт по кускам: сегмент — степень двойки кусков, так что пары сводятся так же, как при чтении подряд
+	offset := int64(segments) * blake3SegmentLen
+	h.chunk = newBlake3Chunk(uint64(offset / blake3ChunkLen))
+	if _, err := io.Copy(h, io.NewSectionReader(r, offset, size-offset)); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
@@ -0,0 +1,23 @@
+//go:build darwin || freebsd || netbsd
+
+package serializer
+
+import (
+	"io/fs"
+	"syscall"
+	"time"
+)
+
+// birthTime на macOS (APFS, HFS+) и BSD берёт время создания прямо из stat
+func birthTime(_ string, info fs.FileInfo) (time.Time, bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return time.Time{}, false
+	}
+	sec, nsec := st.Birthtimespec.Unix()
+	if sec <= 0 {
+		// ФС время создания не хранит
+		return time.Time{}, false
+	}
+	return time.Unix(sec, nsec), true
+}
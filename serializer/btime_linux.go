@@ -0,0 +1,23 @@
+package serializer
+
+import (
+	"io/fs"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// birthTime на Linux берёт время создания через statx (ядро 4.11+, и не всякая ФС его хранит)
+func birthTime(path string, _ fs.FileInfo) (time.Time, bool) {
+	if path == "" {
+		return time.Time{}, false
+	}
+	var stx unix.Statx_t
+	if err := unix.Statx(unix.AT_FDCWD, path, unix.AT_SYMLINK_NOFOLLOW, unix.STATX_BTIME, &stx); err != nil {
+		return time.Time{}, false
+	}
+	if stx.Mask&unix.STATX_BTIME == 0 {
+		return time.Time{}, false
+	}
+	return time.Unix(stx.Btime.Sec, int64(stx.Btime.Nsec)), true
+}
@@ -0,0 +1,13 @@
+//go:build !(linux || darwin || freebsd || netbsd || windows)
+
+package serializer
+
+import (
+	"io/fs"
+	"time"
+)
+
+// birthTime на этой платформе время создания не узнать
+func birthTime(string, fs.FileInfo) (time.Time, bool) {
+	return time.Time{}, false
+}
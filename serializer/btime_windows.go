@@ -0,0 +1,16 @@
+package serializer
+
+import (
+	"io/fs"
+	"syscall"
+	"time"
+)
+
+// birthTime на Windows берёт время создания из атрибутов файла (NTFS хранит его всегда)
+func birthTime(_ string, info fs.FileInfo) (time.Time, bool) {
+	attrs, ok := info.Sys().(*syscall.Win32FileAttributeData)
+	if !ok {
+		return time.Time{}, false
+	}
+	return time.Unix(0, attrs.CreationTime.Nanoseconds()), true
+}
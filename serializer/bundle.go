@@ -0,0 +1,168 @@
+package serializer
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"strings"
+
+	"github.com/asquebay/directory-serialization/format"
+)
+
+// форматы, повторяющие раскладку других популярных утилит «репозиторий → промпт»,
+// чтобы промпты и парсеры, написанные под них, принимали наш вывод без переделок:
+//
+//	--format repomix   — XML-стиль Repomix (<directory_structure>, <files>, <file path="...">)
+//	--format gitingest — дайджест gitingest ("Directory structure:", блоки "FILE: path" между линиями "=")
+
+// bundleContents перебирает текстовые файлы, которые попадают в вывод, с их содержимым
+// (с учётом --content-match, обрезки и --deadline); ошибки чтения уже записаны в лог
+func (w *walker) bundleContents(files []fileInfo, emit func(relPath string, data []byte)) {
+	for i := range files {
+		if data, ok := w.bundleContent(&files[i]); ok {
+			emit(files[i].relPath, data)
+		}
+	}
+}
+
+// bundleContent возвращает содержимое одного файла для bundleContents; false — содержимого в выводе нет
+func (w *walker) bundleContent(file *fileInfo) ([]byte, bool) {
+	if !file.isText {
+		return nil, false
+	}
+	if w.expired() {
+		file.skip = decisionDeadline
+		return nil, false
+	}
+	data, _, err := w.content(file)
+	// пустые файлы Repomix и gitingest показывают пустым блоком, так и оставляем
+	if err != nil || file.skip != "" && file.skip != decisionEmpty {
+		return nil, false
+	}
+	if w.opts.Sanitize {
+		data = SanitizeControls(data)
+	}
+	return data, true
+}
+
+// exportRepomix пишет дамп в XML-стиле Repomix
+func exportRepomix(w *walker, out io.Writer, rootName string, files []fileInfo) {
+	opts := w.opts
+	fmt.Fprintln(out, "This file is a merged representation of the entire codebase, combined into a single document by directory-serialization in the Repomix XML layout.")
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, "<file_summary>")
+	fmt.Fprintln(out, "This section contains a summary of this file.")
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, "<purpose>")
+	fmt.Fprintln(out, "This file contains a packed representation of the entire repository's contents.")
+	fmt.Fprintln(out, "It is designed to be easily consumable by AI systems for analysis, code review,")
+	fmt.Fprintln(out, "or other automated processes.")
+	fmt.Fprintln(out, "</purpose>")
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, "<file_format>")
+	fmt.Fprintln(out, "The content is organized as follows:")
+	fmt.Fprintln(out, "1. This summary section")
+	fmt.Fprintln(out, "2. Directory structure")
+	fmt.Fprintln(out, "3. Repository files, each consisting of:")
+	fmt.Fprintln(out, "  - File path as an attribute")
+	fmt.Fprintln(out, "  - Full contents of the file")
+	fmt.Fprintln(out, "</file_format>")
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, "<notes>")
+	fmt.Fprintf(out, "- Repository root: %s\n", rootName)
+	fmt.Fprintln(out, "- Binary files are listed in the directory structure but their contents are not included")
+	fmt.Fprintln(out, "</notes>")
+	fmt.Fprintln(out, "</file_summary>")
+	fmt.Fprintln(out)
+
+	if opts.Preamble != "" {
+		fmt.Fprintln(out, "<user_provided_header>")
+		fmt.Fprintln(out, opts.Preamble)
+		fmt.Fprintln(out, "</user_provided_header>")
+		fmt.Fprintln(out)
+	}
+
+	// древо Repomix: без линий, каждый уровень сдвинут на два пробела, у директорий "/" в конце
+	fmt.Fprintln(out, "<directory_structure>")
+	var printTree func(n *treeNode, indent string)
+	printTree = func(n *treeNode, indent string) {
+		for _, c := range n.children {
+			if c.isDir {
+				fmt.Fprintf(out, "%s%s/\n", indent, format.QuoteName(c.name))
+				printTree(c, indent+"  ")
+			} else {
+				fmt.Fprintf(out, "%s%s\n", indent, format.QuoteName(c.name))
+			}
+		}
+	}
+	printTree(w.treeRoot, "")
+	fmt.Fprintln(out, "</directory_structure>")
+	fmt.Fprintln(out)
+
+	fmt.Fprintln(out, "<files>")
+	fmt.Fprintln(out, "This section contains the contents of the repository's files.")
+	fmt.Fprintln(out)
+	w.bundleContents(files, func(relPath string, data []byte) {
+		fmt.Fprintf(out, "<file path=\"%s\">\n", html.EscapeString(relPath))
+		out.Write(data)
+		if len(data) > 0 && !strings.HasSuffix(string(data), "\n") {
+			fmt.Fprintln(out)
+		}
+		fmt.Fprintln(out, "</file>")
+		fmt.Fprintln(out)
+	})
+	fmt.Fprintln(out, "</files>")
+
+	if opts.Postamble != "" {
+		fmt.Fprintln(out)
+		fmt.Fprintln(out, "<instruction>")
+		fmt.Fprintln(out, opts.Postamble)
+		fmt.Fprintln(out, "</instruction>")
+	}
+}
+
+// gitingestSeparator — линия, которой gitingest обрамляет заголовок файла
+var gitingestSeparator = strings.Repeat("=", 48)
+
+// exportGitingest пишет дамп в виде дайджеста gitingest
+func exportGitingest(w *walker, out io.Writer, rootName string, files []fileInfo) {
+	opts := w.opts
+	if opts.Preamble != "" {
+		fmt.Fprintln(out, opts.Preamble)
+		fmt.Fprintln(out)
+	}
+
+	// древо gitingest: корень тоже рисуется как элемент "└── root/"
+	fmt.Fprintln(out, "Directory structure:")
+	fmt.Fprintf(out, "└── %s/\n", format.QuoteName(rootName))
+	var printTree func(n *treeNode, prefix string)
+	printTree = func(n *treeNode, prefix string) {
+		for i, c := range n.children {
+			last := i == len(n.children)-1
+			connector, next := "├── ", "│   "
+			if last {
+				connector, next = "└── ", "    "
+			}
+			if c.isDir {
+				fmt.Fprintf(out, "%s%s%s/\n", prefix, connector, format.QuoteName(c.name))
+				printTree(c, prefix+next)
+			} else {
+				fmt.Fprintf(out, "%s%s%s\n", prefix, connector, format.QuoteName(c.name))
+			}
+		}
+	}
+	printTree(w.treeRoot, "    ")
+	fmt.Fprintln(out)
+
+	w.bundleContents(files, func(relPath string, data []byte) {
+		fmt.Fprintln(out, gitingestSeparator)
+		fmt.Fprintf(out, "FILE: %s\n", format.QuoteName(relPath))
+		fmt.Fprintln(out, gitingestSeparator)
+		out.Write(data)
+		fmt.Fprint(out, "\n\n")
+	})
+
+	if opts.Postamble != "" {
+		fmt.Fprintln(out, opts.Postamble)
+	}
+}
@@ -0,0 +1,118 @@
+package serializer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// --format cas складывает снимок в хранилище, адресуемое содержимым (по образцу объектов git):
+//
+//	DIR/objects/ab/cdef...    — содержимое файлов, имя — SHA-256 (одинаковые файлы хранятся один раз)
+//	DIR/snapshots/<время>.json — древо снимка: манифест со списком директорий и файлов
+//	DIR/HEAD                  — имя последнего снимка
+//
+// объекты общие для всех снимков, поэтому повторные снимки почти ничего не весят
+
+// exportCAS пишет снимок директории в хранилище
+func exportCAS(w *walker, store, rootName string, files []fileInfo) error {
+	objects := filepath.Join(store, "objects")
+	snapshots := filepath.Join(store, "snapshots")
+	for _, dir := range []string{objects, snapshots} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+	}
+
+	written := 0
+	for i := range files {
+		file := &files[i]
+		if file.readErr || file.skip != "" && file.skip != decisionEmpty {
+			continue
+		}
+		if w.expired() {
+			file.skip = decisionDeadline
+			continue
+		}
+		data, err := w.readFile(file)
+		if err != nil {
+			fmt.Fprintf(w.log, "Error reading %s: %v\n", w.displayPath(file.relPath), err)
+			w.recordError(file.relPath, err)
+			file.readErr = true
+			continue
+		}
+		// файл мог измениться после обхода, поэтому хеш считаем по тому, что сохраняем
+		// объекты адресуются по SHA-256 (другие алгоритмы с --format cas запрещены)
+		sum := sha256.Sum256(data)
+		file.hash = hex.EncodeToString(sum[:])
+		file.size = int64(len(data))
+
+		isNew, err := writeObject(objects, file.hash, data)
+		if err != nil {
+			return err
+		}
+		if isNew {
+			written++
+		}
+	}
+
+	m := buildManifest(w, rootName, files)
+	m.Dirs = w.dirs
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	// имя снимка: время плюс начало хеша древа, чтобы два снимка в одну секунду не затёрли друг друга
+	sum := sha256.Sum256(data)
+	name := w.opts.now().Format("20060102T150405Z") + "-" + hex.EncodeToString(sum[:4]) + ".json"
+	if err := os.WriteFile(filepath.Join(snapshots, name), data, 0o644); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(store, "HEAD"), []byte("snapshots/"+name+"\n"), 0o644); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w.log, "Snapshot %s: %d file(s), %d new object(s)\n", name, len(files), written)
+	return nil
+}
+
+// writeObject сохраняет объект, если его ещё нет; возвращает true, если объект новый
+func writeObject(objects, hash string, data []byte) (bool, error) {
+	dir := filepath.Join(objects, hash[:2])
+	path := filepath.Join(dir, hash[2:])
+	if _, err := os.Stat(path); err == nil {
+		return false, nil
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return false, err
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return false, err
+	}
+	// пишем во временный файл и переименовываем, чтобы прерванный запуск не оставил битый объект
+	tmp, err := os.CreateTemp(dir, ".tmp-*")
+	if err != nil {
+		return false, err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return false, err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return false, err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,57 @@
+package serializer
+
+import "bytes"
+
+// chunk — часть длинного файла, выводимая отдельной секцией "path (part 2/5, lines 181-400)"
+type chunk struct {
+	firstLine int // номер первой строки (с 1)
+	lastLine  int // номер последней строки включительно
+	data      []byte
+}
+
+// splitChunks режет файл на части не длиннее maxLines строк и maxTokens токенов (0 — без ограничения),
+// токены строки считает estimate
+// соседние части перекрываются на overlap строк, границы всегда проходят по концам строк,
+// поэтому разбиение стабильно между запусками
+// если файл помещается целиком, возвращается одна часть
+func splitChunks(data []byte, maxLines, maxTokens, overlap int, estimate func([]byte) int) []chunk {
+	lines := bytes.SplitAfter(data, []byte("\n"))
+	if len(lines) > 0 && len(lines[len(lines)-1]) == 0 {
+		lines = lines[:len(lines)-1]
+	}
+
+	var chunks []chunk
+	start := 0
+	for {
+		end := start // end — индекс строки сразу за частью
+		tokens := 0
+		for end < len(lines) {
+			if maxLines > 0 && end-start >= maxLines {
+				break
+			}
+			t := estimate(lines[end])
+			// хотя бы одну строку в часть кладём всегда, даже если она одна больше лимита
+			if maxTokens > 0 && end > start && tokens+t > maxTokens {
+				break
+			}
+			tokens += t
+			end++
+		}
+
+		chunks = append(chunks, chunk{
+			firstLine: start + 1,
+			lastLine:  end,
+			data:      bytes.Join(lines[start:end], nil),
+		})
+		if end >= len(lines) {
+			return chunks
+		}
+
+		// следующая часть начинается с перекрытия, но обязательно продвигается вперёд
+		next := end - overlap
+		if next <= start {
+			next = start + 1
+		}
+		start = next
+	}
+}
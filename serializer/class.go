@@ -0,0 +1,109 @@
+package serializer
+
+import (
+	"path"
+	"strings"
+)
+
+// назначение файла (класс) по пути, языку и содержимому: чтобы автоматические потребители дампа понимали,
+// что смотреть в первую очередь, а --only-class мог оставить, например, только код и конфигурацию
+// правила проверяются по порядку: CI, сборка, документация, конфигурация, данные, код; что не подошло — other
+
+// классы файлов
+const (
+	ClassSource = "source"       // код на языке программирования
+	ClassConfig = "config"       // настройки: YAML, TOML, INI, dotfiles, манифесты пакетов
+	ClassDocs   = "docs"         // README, LICENSE, документация и проза
+	ClassData   = "data"         // таблицы, выгрузки, lock-файлы, нетекстовые файлы
+	ClassBuild  = "build-script" // Makefile, Dockerfile, CMake, Gradle, Bazel
+	ClassCI     = "ci"           // настройки CI: GitHub Actions, GitLab CI, Jenkinsfile
+	ClassOther  = "other"        // ни одно правило не подошло
+)
+
+// Classes — все классы в порядке проверки правил
+var Classes = []string{ClassCI, ClassBuild, ClassDocs, ClassConfig, ClassData, ClassSource, ClassOther}
+
+// ciDirs — директории, всё в которых относится к CI
+var ciDirs = []string{".github/workflows/", ".github/actions/", ".circleci/", ".buildkite/", ".gitlab/ci/"}
+
+// ciNames — файлы CI (имена в нижнем регистре)
+var ciNames = map[string]bool{
+	".gitlab-ci.yml": true, ".travis.yml": true, "jenkinsfile": true, "azure-pipelines.yml": true,
+	".drone.yml": true, "bitbucket-pipelines.yml": true, "appveyor.yml": true, ".appveyor.yml": true,
+	"cloudbuild.yaml": true, "cloudbuild.yml": true, ".woodpecker.yml": true,
+}
+
+// buildNames — сценарии сборки (имена в нижнем регистре)
+var buildNames = map[string]bool{
+	"makefile": true, "gnumakefile": true, "cmakelists.txt": true, "meson.build": true, "configure": true,
+	"configure.ac": true, "build.gradle": true, "build.gradle.kts": true, "settings.gradle": true,
+	"settings.gradle.kts": true, "pom.xml": true, "build.xml": true, "build": true, "build.bazel": true,
+	"workspace": true, "workspace.bazel": true, "module.bazel": true, "dockerfile": true, "containerfile": true,
+	"justfile": true, "taskfile.yml": true, "rakefile": true, "build.rs": true, "setup.py": true,
+	"magefile.go": true, "gulpfile.js": true, "gruntfile.js": true, "build.sh": true, "build.ps1": true,
+}
+
+// buildExts — расширения сценариев сборки
+var buildExts = map[string]bool{".mk": true, ".cmake": true, ".bzl": true, ".gradle": true}
+
+// configNames — файлы настроек и манифесты пакетов, которые по языку не отличить от данных или кода
+var configNames = map[string]bool{
+	"go.mod": true, "package.json": true, "composer.json": true, "tsconfig.json": true, "jsconfig.json": true,
+	"deno.json": true, "app.json": true, "manifest.json": true, "gemfile": true, "pipfile": true,
+	"requirements.txt": true, "procfile": true, "vagrantfile": true, ".tool-versions": true, "codecov.yml": true,
+}
+
+// configLangs — языки файлов настроек
+var configLangs = map[string]bool{
+	"yaml": true, "toml": true, "ini": true, "conf": true, "dotenv": true, "hcl": true, "terraform": true,
+}
+
+// dataLangs — языки данных
+var dataLangs = map[string]bool{"json": true, "xml": true, "csv": true, "tsv": true, "go-sum": true}
+
+// dataExts — расширения данных, которых нет в таблице языков, и медиа: короткий файл с картинкой детектор
+// может принять за текст
+var dataExts = map[string]bool{
+	".jsonl": true, ".ndjson": true, ".geojson": true, ".parquet": true, ".avro": true,
+	".png": true, ".jpg": true, ".jpeg": true, ".gif": true, ".webp": true, ".ico": true, ".svg": true, ".pdf": true,
+	".woff": true, ".woff2": true, ".ttf": true, ".mp3": true, ".mp4": true, ".zip": true, ".gz": true, ".tar": true,
+}
+
+// classify возвращает класс файла; langID — язык файла (по имени или по первой строке),
+// binary — содержимое проверено и оно нетекстовое
+func classify(relPath, langID string, binary bool) string {
+	base := strings.ToLower(path.Base(relPath))
+	ext := path.Ext(base)
+	stem := strings.TrimSuffix(base, ext)
+	dotfile := strings.HasPrefix(base, ".") && !strings.Contains(base[1:], ".") // .gitignore, .npmrc
+	switch {
+	case ciNames[base] || hasAnyPrefix(relPath, ciDirs):
+		return ClassCI
+	case buildNames[base] || buildExts[ext] || strings.HasPrefix(base, "dockerfile.") || langID == "makefile" || langID == "dockerfile":
+		return ClassBuild
+	// requirements-dev.txt по языку — текст, но это список зависимостей
+	case configNames[base] || strings.HasPrefix(base, "requirements") && ext == ".txt":
+		return ClassConfig
+	case !binary && (isDoc(relPath, langID) || proseLangs[langID]):
+		return ClassDocs
+	// vite.config.ts и .eslintrc.js — настройки, хоть и написаны на JS
+	case configLangs[langID], strings.HasSuffix(stem, ".config"), strings.HasPrefix(stem, ".") && strings.HasSuffix(stem, "rc"),
+		dotfile && !binary && langID == "",
+		langID == "json" && (strings.Contains(stem, "config") || strings.Contains(stem, "settings")):
+		return ClassConfig
+	case binary || dataLangs[langID] || dataExts[ext] || secretExempt[path.Base(relPath)]:
+		return ClassData
+	case langID != "":
+		return ClassSource
+	}
+	return ClassOther
+}
+
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if strings.HasPrefix(s, p) {
+			return true
+		}
+	}
+	return false
+}
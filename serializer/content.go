@@ -0,0 +1,180 @@
+package serializer
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/asquebay/directory-serialization/format"
+)
+
+// content читает содержимое текстового файла для вывода с учётом --content-match, преобразований и обрезки
+// возвращает данные и пометки для заголовка ("truncated: ...")
+// если файл не подошёл под --content-match, выставляет file.skip и возвращает пустые данные
+func (w *walker) content(file *fileInfo) ([]byte, []string, error) {
+	opts := w.opts
+
+	// с --content-match выводим только файлы, в которых нашлось совпадение (проверено при обходе),
+	// а минифицированные не выводим вовсе
+	if file.skip == decisionNoMatch || file.skip == decisionMinified {
+		return nil, nil, nil
+	}
+
+	w.warnOverBudget(file)
+	data, err := w.readFile(file)
+	if err != nil {
+		fmt.Fprintf(w.log, "Error reading %s: %v\n", w.displayPath(file.relPath), err)
+		w.recordError(file.relPath, err)
+		return nil, nil, err
+	}
+	// при обходе читалось только начало файла, хеш и точный размер считаем здесь
+	file.setContentHash(opts, data)
+	file.lines = countLines(data, file.encoding)
+
+	var notes []string
+	if file.suspect != "" {
+		notes = append(notes, "encoding suspect: "+file.suspect)
+	}
+	if file.grew {
+		notes = append(notes, fmt.Sprintf("grew while being read, first %d bytes", file.limit))
+	}
+	// преобразования и обрезка (см. transform.go)
+	data, stepNotes, err := w.transform(file, data)
+	if err != nil {
+		fmt.Fprintf(w.log, "Error transforming %s: %v\n", w.displayPath(file.relPath), err)
+		w.recordError(file.relPath, err)
+		return nil, nil, err
+	}
+	notes = append(notes, stepNotes...)
+
+	if opts.ScanSecrets {
+		w.scanSecrets(file.relPath, data)
+	}
+	file.tokens = opts.Tokens.EstimateTokens(data, file.lang)
+	file.outSize = int64(len(data))
+	// с --per-dir-budget то же самое, но бюджет свой у каждой директории (см. dirbudget.go)
+	if w.dirLimit() > 0 {
+		if dir := w.overDirBudget(file); dir != "" {
+			if w.dirOverflow == nil {
+				w.dirOverflow = make(map[string]string)
+			}
+			w.dirOverflow[file.relPath] = dir
+			file.skip = decisionDirBudget
+			return nil, nil, nil
+		}
+	}
+	// с --fit файл, который уже не влезает в бюджет, пропускаем, но следующие, помельче, ещё пробуем
+	if opts.Fit > 0 {
+		if w.fitUsed+file.tokens > opts.Fit {
+			file.skip = decisionOverBudget
+			return nil, nil, nil
+		}
+		w.fitUsed += file.tokens
+	}
+	if w.dirLimit() > 0 {
+		w.takeDirBudget(file)
+	}
+	return data, notes, nil
+}
+
+// setContentHash запоминает хеш и размер по содержимому файла, если они ещё не известны
+func (file *fileInfo) setContentHash(opts *Options, data []byte) {
+	if file.hash != "" {
+		return
+	}
+	file.hash = opts.hashSum(data)
+	file.size = int64(len(data))
+}
+
+// ensureHash дочитывает файл, если его хеш ещё не посчитан (при обходе читается только начало файла)
+// нужен там, где хеш требуется без вывода содержимого: в манифесте и при сравнении со старым снимком
+// файл хешируется потоком, в память целиком не читается: бинарники бывают по нескольку гигабайт
+func (w *walker) ensureHash(file *fileInfo) {
+	switch file.skip {
+	case decisionDeadline, decisionSpecial, decisionJunction, decisionReparse:
+		return
+	}
+	if file.hash != "" || file.readErr {
+		return
+	}
+	if err := w.hashFile(file); err != nil {
+		fmt.Fprintf(w.log, "Error reading %s: %v\n", w.displayPath(file.relPath), err)
+		w.recordError(file.relPath, err)
+	}
+}
+
+// hashFile считает хеш и размер файла, читая его потоком (не дальше file.limit, как readFile)
+func (w *walker) hashFile(file *fileInfo) error {
+	f, err := w.openFile(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	sum, n, err := w.opts.hashReader(f, file.limit)
+	if err != nil {
+		return err
+	}
+	// лишний байт за пределом значит, что файл вырос, пока его читали (см. growing.go)
+	if extra, _ := f.Read(make([]byte, 1)); extra > 0 {
+		w.markGrown(file)
+	}
+	file.hash = sum
+	file.size = n
+	return nil
+}
+
+// headerStats — пометки --header-stats о файле на диске (до обрезки и преобразований): "342 lines", "12KB", "UTF-8", "go"
+func headerStats(file *fileInfo) []string {
+	lines := fmt.Sprintf("%d lines", file.lines)
+	if file.lines == 1 {
+		lines = "1 line"
+	}
+	stats := []string{lines, FormatSize(file.size)}
+	if file.encoding != "" {
+		stats = append(stats, file.encoding)
+	}
+	if file.lang != "" {
+		stats = append(stats, file.lang)
+	}
+	return stats
+}
+
+// writeTextFile печатает секцию содержимого файла в текстовом дампе
+// langID — язык файла для метки у открывающего fence (ставится только с --fence-lang)
+func writeTextFile(out io.Writer, opts *Options, displayPath, langID string, data []byte, notes []string) {
+	open := "```"
+	if opts.FenceLang {
+		open += langID
+	}
+	if opts.ByteExact && format.NeedsBase64(data) {
+		notes = append(slices.Clip(notes), format.Base64Note)
+		data = format.EncodeBase64(data)
+	}
+	// длинные файлы выводим несколькими пронумерованными секциями
+	chunks := []chunk{{data: data}}
+	if opts.ChunkLines > 0 || opts.ChunkTokens > 0 {
+		estimate := func(line []byte) int { return opts.Tokens.EstimateTokens(line, langID) }
+		chunks = splitChunks(data, opts.ChunkLines, opts.ChunkTokens, opts.ChunkOverlap, estimate)
+	}
+	for n, c := range chunks {
+		chunkNotes := notes
+		if opts.Canonical && n > 0 {
+			fmt.Fprintln(out)
+		}
+		if len(chunks) > 1 {
+			chunkNotes = append([]string{fmt.Sprintf("part %d/%d, lines %d-%d", n+1, len(chunks), c.firstLine, c.lastLine)}, notes...)
+		}
+		if len(chunkNotes) == 0 {
+			fmt.Fprintf(out, "%s:\n", displayPath)
+		} else {
+			fmt.Fprintf(out, "%s (%s):\n", displayPath, strings.Join(chunkNotes, ", "))
+		}
+		fmt.Fprintln(out, open)
+		if opts.Sanitize {
+			c.data = SanitizeControls(c.data)
+		}
+		fmt.Fprintln(out, string(c.data))
+		fmt.Fprintln(out, "```")
+	}
+}
@@ -0,0 +1,27 @@
+package serializer
+
+import (
+	"encoding/base64"
+	"strconv"
+)
+
+// способы записи содержимого в структурированных форматах (--content-encoding)
+// нужны, чтобы дамп можно было встроить в другой JSON/YAML-документ:
+// raw читается легче всего, escaped и base64 сохраняют байты в точности, даже если файл не в UTF-8
+const (
+	ContentRaw     = "raw"     // как есть
+	ContentEscaped = "escaped" // строка в кавычках по правилам Go (strconv.Unquote возвращает исходные байты)
+	ContentBase64  = "base64"  // стандартный base64
+)
+
+// encodeContent записывает содержимое файла выбранным способом
+func encodeContent(data []byte, encoding string) string {
+	switch encoding {
+	case ContentEscaped:
+		return strconv.Quote(string(data))
+	case ContentBase64:
+		return base64.StdEncoding.EncodeToString(data)
+	default:
+		return string(data)
+	}
+}
@@ -0,0 +1,55 @@
+package serializer
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// --format csv — опись файлов таблицей для аудита в Excel или LibreOffice: строка на файл,
+// столбцы path, size, mtime, encoding, type (text или binary), class (назначение, см. class.go), хеш (столбец назван по --hash-algo, sha256 по умолчанию), decision
+// пишется после остальных выводов того же запуска, чтобы решения (--fit, --deadline) были окончательными
+// путь, начинающийся с =, +, -, @, в таблице стал бы формулой, поэтому перед ним ставится апостроф
+
+const FormatCSV = "csv" // опись файлов таблицей CSV
+
+var csvHeader = []string{"path", "size", "mtime", "encoding", "type", "class", HashSHA256, "decision"}
+
+// exportCSV пишет опись файлов в CSV (RFC 4180, строки через CRLF)
+func exportCSV(w *walker, out io.Writer, files []fileInfo) error {
+	cw := csv.NewWriter(out)
+	cw.UseCRLF = true
+	header := append([]string(nil), csvHeader...)
+	header[6] = w.opts.hashAlgo()
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	for i := range files {
+		file := &files[i]
+		w.ensureHash(file)
+		kind := "binary"
+		if file.isText {
+			kind = "text"
+		}
+		mtime := ""
+		if t := w.opts.timestamp(file.mtime); t != nil && !w.hasGap(GapMtime) {
+			mtime = fmt.Sprint(t)
+		}
+		record := []string{csvCell(treeName(file.relPath)), strconv.FormatInt(file.size, 10), mtime, file.encoding, kind, file.class, file.hash, file.decision()}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// csvCell защищает ячейку от толкования как формулы
+func csvCell(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
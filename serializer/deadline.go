@@ -0,0 +1,44 @@
+package serializer
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/asquebay/directory-serialization/format"
+)
+
+// --deadline ограничивает время работы: когда бюджет исчерпан, обход и вывод останавливаются,
+// но дамп остаётся корректным — древо текущих директорий допечатывается (в необойдённые директории не заходим),
+// файлы без прочитанного содержимого получают решение "deadline", а их список пишется в лог (у CLI это stderr)
+
+// maxDeadlineReport — сколько необработанных путей перечислять в логе (полный список — в манифесте)
+const maxDeadlineReport = 20
+
+// expired сообщает, исчерпан ли бюджет времени
+func (w *walker) expired() bool {
+	return !w.expiresAt.IsZero() && time.Now().After(w.expiresAt)
+}
+
+// reportDeadline печатает сводку необработанных путей, если бюджет времени был исчерпан
+func (w *walker) reportDeadline(files []fileInfo) {
+	var paths []string
+	for _, dir := range w.unvisited {
+		paths = append(paths, format.QuoteName(dir+"/"))
+	}
+	for _, file := range files {
+		if file.skip == decisionDeadline {
+			paths = append(paths, format.QuoteName(file.relPath))
+		}
+	}
+	if len(paths) == 0 {
+		return
+	}
+	fmt.Fprintf(w.log, "Deadline of %s exceeded: %d file(s) not read, %d dir(s) not entered\n", w.opts.Deadline, len(paths)-len(w.unvisited), len(w.unvisited))
+	for i, path := range paths {
+		if i == maxDeadlineReport {
+			fmt.Fprintf(w.log, "  ... and %d more\n", len(paths)-i)
+			break
+		}
+		fmt.Fprintf(w.log, "  %s\n", path)
+	}
+}
@@ -0,0 +1,84 @@
+package serializer
+
+import (
+	"fmt"
+	"path"
+	"sort"
+)
+
+// бюджет содержимого на директорию (--per-dir-budget): ни одна директория вместе с поддиректориями не даёт в вывод
+// больше заданного числа токенов (Options.PerDirTokens) или байт (Options.PerDirBytes), чтобы одна папка с данными
+// или сгенерированным кодом не вытеснила остальной репозиторий
+// файл, который не влезает в бюджет хотя бы одной директории над ним, пропускается (решение over-dir-budget),
+// следующие, поменьше, ещё пробуются, как с --fit; файлы в корне ограничивает только --fit
+
+// dirCost возвращает, сколько бюджета директории занимает выведенное содержимое файла
+func (w *walker) dirCost(file *fileInfo) int64 {
+	if w.opts.PerDirTokens > 0 {
+		return int64(file.tokens)
+	}
+	return file.outSize
+}
+
+func (w *walker) dirLimit() int64 {
+	if w.opts.PerDirTokens > 0 {
+		return int64(w.opts.PerDirTokens)
+	}
+	return w.opts.PerDirBytes
+}
+
+// fileDirs возвращает директории над файлом, от ближней к корню (сам корень не входит)
+func fileDirs(relPath string) []string {
+	var dirs []string
+	for dir := path.Dir(relPath); dir != "."; dir = path.Dir(dir) {
+		dirs = append(dirs, dir)
+	}
+	return dirs
+}
+
+// overDirBudget возвращает директорию, в бюджет которой файл уже не влезает, или ""
+func (w *walker) overDirBudget(file *fileInfo) string {
+	cost, limit := w.dirCost(file), w.dirLimit()
+	dirs := fileDirs(file.relPath)
+	// если переполнено несколько, называем самую верхнюю: её бюджет и надо поднимать
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if w.dirUsed[dirs[i]]+cost > limit {
+			return dirs[i]
+		}
+	}
+	return ""
+}
+
+// takeDirBudget занимает место под файл в бюджетах директорий над ним
+func (w *walker) takeDirBudget(file *fileInfo) {
+	if w.dirUsed == nil {
+		w.dirUsed = make(map[string]int64)
+	}
+	cost := w.dirCost(file)
+	for _, dir := range fileDirs(file.relPath) {
+		w.dirUsed[dir] += cost
+	}
+}
+
+// reportDirBudget печатает в лог, сколько файлов каких директорий не влезло в --per-dir-budget
+func (w *walker) reportDirBudget(files []fileInfo) {
+	skipped := make(map[string]int)
+	for _, file := range files {
+		if file.skip == decisionDirBudget {
+			skipped[w.dirOverflow[file.relPath]]++
+		}
+	}
+	dirs := make([]string, 0, len(skipped))
+	for dir := range skipped {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	unit := "tokens"
+	if w.opts.PerDirTokens == 0 {
+		unit = "bytes"
+	}
+	for _, dir := range dirs {
+		fmt.Fprintf(w.log, "Skipped %d file(s) in %s/ that did not fit in its %d %s (--per-dir-budget)\n",
+			skipped[dir], w.displayPath(dir), w.dirLimit(), unit)
+	}
+}
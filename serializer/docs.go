@@ -0,0 +1,37 @@
+package serializer
+
+import (
+	"path"
+	"strings"
+)
+
+// --summarize-docs: у длинных документов (README, LICENSE, CHANGELOG, docs/*.md) выводится только начало,
+// хвост заменяется строкой format.ElisionLine — он съедает бюджет промпта, а пользы от него мало
+
+// docNames — имена документов в верхнем регистре без расширения; LICENSE-MIT, COPYING.LESSER и т.п. тоже считаются
+var docNames = map[string]bool{
+	"README": true, "LICENSE": true, "LICENCE": true, "COPYING": true, "NOTICE": true,
+	"CHANGELOG": true, "CHANGES": true, "HISTORY": true, "NEWS": true, "RELEASE_NOTES": true,
+	"AUTHORS": true, "CONTRIBUTORS": true, "CONTRIBUTING": true, "CODE_OF_CONDUCT": true, "SECURITY": true,
+}
+
+// proseLangs — языки прозы: в директориях doc/ и docs/ сокращаются только они, примеры кода не трогаем
+var proseLangs = map[string]bool{"markdown": true, "rst": true, "text": true, "latex": true}
+
+// isDoc сообщает, считается ли файл документом для --summarize-docs
+func isDoc(relPath, langID string) bool {
+	base := path.Base(relPath)
+	name := strings.ToUpper(strings.TrimSuffix(base, path.Ext(base)))
+	if docNames[name] {
+		return true
+	}
+	if i := strings.IndexAny(name, "-_."); i > 0 && docNames[name[:i]] {
+		return true
+	}
+	for _, dir := range strings.Split(path.Dir(relPath), "/") {
+		if dir == "doc" || dir == "docs" {
+			return proseLangs[langID]
+		}
+	}
+	return false
+}
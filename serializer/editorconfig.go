@@ -0,0 +1,326 @@
+package serializer
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// charset из .editorconfig (https://spec.editorconfig.org): если проект явно объявил кодировку файлов,
+// она важнее догадки детектора, который на коротких или почти ASCII-файлах нередко ошибается
+// объявление применяется только к текстовым файлам и уступает BOM; charset = unset возвращает догадку детектора
+// .editorconfig ищутся в обходимых директориях и, для директории на диске, выше корня, пока не встретится root = true;
+// из ближайшего к файлу .editorconfig берётся последняя подходящая секция, дальние учитываются, только если в ближних
+// charset для файла не задан
+
+// editorConfigCharsets — значения charset и соответствующие им имена кодировок детектора
+var editorConfigCharsets = map[string]string{
+	"utf-8":     "UTF-8",
+	"utf-8-bom": "UTF-8", // без BOM в начале файла; с BOM детектор сам скажет UTF-8-BOM
+	"latin1":    "iso-8859-1",
+	"utf-16be":  "UTF-16BE",
+	"utf-16le":  "UTF-16LE",
+	"unset":     "",
+}
+
+// editorConfig — разобранный .editorconfig (только то, что касается кодировки)
+type editorConfig struct {
+	dir      string // директория файла относительно корня обхода ("." — корень или выше него)
+	prefix   string // путь от директории файла до корня обхода, если файл выше корня
+	root     bool   // root = true: дальше вверх не искать
+	sections []editorConfigSection
+}
+
+// editorConfigSection — секция [glob] с объявленным charset
+type editorConfigSection struct {
+	glob    *regexp.Regexp
+	ranges  [][2]int // диапазоны {n1..n2} по порядку групп glob
+	charset string
+}
+
+// parseEditorConfig разбирает .editorconfig; строки, которые не удалось понять, пропускаются, как у редакторов
+func parseEditorConfig(r io.Reader, dir, prefix string) *editorConfig {
+	c := &editorConfig{dir: dir, prefix: prefix}
+	var current *editorConfigSection
+	inSection := false
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+		if line[0] == '[' && line[len(line)-1] == ']' {
+			inSection = true
+			current = nil
+			if glob, ranges, ok := compileEditorConfigGlob(line[1 : len(line)-1]); ok {
+				c.sections = append(c.sections, editorConfigSection{glob: glob, ranges: ranges})
+				current = &c.sections[len(c.sections)-1]
+			}
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		key, value = strings.ToLower(strings.TrimSpace(key)), strings.ToLower(strings.TrimSpace(value))
+		switch {
+		case !inSection && key == "root":
+			c.root = value == "true"
+		case current != nil && key == "charset":
+			if _, known := editorConfigCharsets[value]; known {
+				current.charset = value
+			}
+		}
+	}
+	// секции без charset при поиске не нужны
+	sections := c.sections[:0]
+	for _, s := range c.sections {
+		if s.charset != "" {
+			sections = append(sections, s)
+		}
+	}
+	c.sections = sections
+	return c
+}
+
+// charset возвращает значение charset для файла relPath (от корня обхода) и есть ли оно в этом .editorconfig
+func (c *editorConfig) charset(relPath string) (string, bool) {
+	rel := relPath
+	if c.dir != "." {
+		rel = strings.TrimPrefix(relPath, c.dir+"/")
+	}
+	rel = path.Join(c.prefix, rel)
+	for i := len(c.sections) - 1; i >= 0; i-- {
+		if s := c.sections[i]; s.matches(rel) {
+			return s.charset, true
+		}
+	}
+	return "", false
+}
+
+func (s editorConfigSection) matches(rel string) bool {
+	m := s.glob.FindStringSubmatch(rel)
+	if m == nil {
+		return false
+	}
+	for i, r := range s.ranges {
+		n, err := strconv.Atoi(m[i+1])
+		if err != nil || n < r[0] || n > r[1] {
+			return false
+		}
+	}
+	return true
+}
+
+// editorConfigEncoding возвращает кодировку, объявленную для файла в цепочке .editorconfig (от ближнего к дальнему),
+// или "", если её нет
+func editorConfigEncoding(chain []*editorConfig, relPath string) string {
+	for _, c := range chain {
+		if charset, ok := c.charset(relPath); ok {
+			return editorConfigCharsets[charset]
+		}
+	}
+	return ""
+}
+
+// withEditorConfig добавляет к цепочке родительской директории её собственный .editorconfig
+func withEditorConfig(parent []*editorConfig, own *editorConfig) []*editorConfig {
+	if own.root {
+		return []*editorConfig{own}
+	}
+	return append([]*editorConfig{own}, parent...)
+}
+
+// applyEditorConfig добавляет к цепочке директории n её собственный .editorconfig, если он есть
+func (w *walker) applyEditorConfig(n *treeNode) {
+	own, err := w.loadEditorConfig(n.relPath)
+	if err != nil {
+		n.logf("Could not read %s: %v\n", w.displayPath(path.Join(n.relPath, ".editorconfig")), err)
+		return
+	}
+	if own != nil {
+		n.editorconfig = withEditorConfig(n.editorconfig, own)
+	}
+}
+
+// loadEditorConfig читает .editorconfig директории dir внутри обхода; nil, если его нет
+func (w *walker) loadEditorConfig(dir string) (*editorConfig, error) {
+	f, err := w.open(path.Join(dir, ".editorconfig"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if info, err := f.Stat(); err == nil && info.IsDir() {
+		return nil, nil
+	}
+	data, err := io.ReadAll(io.LimitReader(f, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	return parseEditorConfig(bytes.NewReader(data), dir, ""), nil
+}
+
+// outerEditorConfigs собирает .editorconfig выше корня на диске, от ближнего к дальнему
+// (и с --sandbox, как outerGitignores)
+func (w *walker) outerEditorConfigs() []*editorConfig {
+	if w.rootPath == "" {
+		return nil
+	}
+	dir, err := filepath.Abs(w.rootPath)
+	if err != nil {
+		return nil
+	}
+	var chain []*editorConfig
+	prefix := ""
+	for {
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return chain
+		}
+		prefix = path.Join(filepath.Base(dir), prefix)
+		dir = parent
+		data, err := os.ReadFile(filepath.Join(dir, ".editorconfig"))
+		if err != nil {
+			continue
+		}
+		c := parseEditorConfig(bytes.NewReader(data), ".", prefix)
+		chain = append(chain, c)
+		if c.root {
+			return chain
+		}
+	}
+}
+
+// compileEditorConfigGlob переводит шаблон секции в регулярное выражение:
+// * — любые символы, кроме "/", ** — любые, ? — один символ, [abc] и [!abc] — классы, {a,b} — варианты,
+// {n1..n2} — целое из диапазона, \ экранирует; шаблон без "/" сравнивается с именем файла на любой глубине
+func compileEditorConfigGlob(glob string) (*regexp.Regexp, [][2]int, bool) {
+	var b strings.Builder
+	var ranges [][2]int
+	switch {
+	case strings.HasPrefix(glob, "/"):
+		glob = glob[1:]
+	case !strings.Contains(glob, "/"):
+		b.WriteString("(?:.*/)?")
+	}
+	depth := 0 // вложенность {}
+	for i := 0; i < len(glob); i++ {
+		ch := glob[i]
+		switch ch {
+		case '\\':
+			if i+1 < len(glob) {
+				i++
+				b.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+			}
+		case '*':
+			if i+1 < len(glob) && glob[i+1] == '*' {
+				b.WriteString(".*")
+				i++
+			} else {
+				b.WriteString("[^/]*")
+			}
+		case '?':
+			b.WriteString("[^/]")
+		case '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				b.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+1+end]
+			negate := strings.HasPrefix(class, "!")
+			class = strings.TrimPrefix(class, "!")
+			class = strings.NewReplacer(`\`, `\\`, "^", `\^`, "[", `\[`).Replace(class)
+			if negate {
+				b.WriteString("[^/" + class + "]")
+			} else {
+				b.WriteString("[" + class + "]")
+			}
+			i += end + 1
+		case '{':
+			end := closingBrace(glob, i)
+			if end < 0 {
+				b.WriteString(`\{`)
+				continue
+			}
+			inner := glob[i+1 : end]
+			if lo, hi, ok := numericRange(inner); ok {
+				b.WriteString(`([+-]?\d+)`)
+				ranges = append(ranges, [2]int{lo, hi})
+				i = end
+				continue
+			}
+			if !strings.Contains(inner, ",") {
+				// {одно} — не набор вариантов, а буквальный текст
+				b.WriteString(regexp.QuoteMeta(glob[i : end+1]))
+				i = end
+				continue
+			}
+			b.WriteString("(?:")
+			depth++
+		case ',':
+			if depth > 0 {
+				b.WriteString("|")
+			} else {
+				b.WriteString(",")
+			}
+		case '}':
+			if depth > 0 {
+				b.WriteString(")")
+				depth--
+			} else {
+				b.WriteString(`\}`)
+			}
+		default:
+			b.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		}
+	}
+	re, err := regexp.Compile("^" + b.String() + "$")
+	if err != nil {
+		return nil, nil, false
+	}
+	return re, ranges, true
+}
+
+// closingBrace возвращает индекс "}", парной к "{" в позиции open, или -1
+func closingBrace(s string, open int) int {
+	depth := 0
+	for i := open; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '{':
+			depth++
+		case '}':
+			if depth--; depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// numericRange разбирает "n1..n2"
+func numericRange(s string) (int, int, bool) {
+	from, to, ok := strings.Cut(s, "..")
+	if !ok {
+		return 0, 0, false
+	}
+	lo, err1 := strconv.Atoi(from)
+	hi, err2 := strconv.Atoi(to)
+	if err1 != nil || err2 != nil {
+		return 0, 0, false
+	}
+	return min(lo, hi), max(lo, hi), true
+}
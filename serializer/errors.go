@@ -0,0 +1,55 @@
+package serializer
+
+import (
+	"errors"
+	"io/fs"
+)
+
+// ошибки, из-за которых сериализация не удалась целиком, — *Error с кодом: обёртки сверяют код,
+// а не разбирают текст (он по-английски и может меняться); перевести сообщение — забота того, кто его показывает
+//
+//	var e *serializer.Error
+//	if errors.As(err, &e) && e.Code == serializer.CodeRootNotFound {
+//		...
+//	}
+// ошибки отдельных файлов в Report.Errors — PathError, они сериализацию не прерывают
+
+// ErrorCode — причина ошибки; значения — стабильные строки, их можно отдавать наружу (JSON, коды выхода обёрток)
+type ErrorCode string
+
+const (
+	CodeRootNotFound   ErrorCode = "root-not-found"  // корня нет
+	CodeRootNotDir     ErrorCode = "root-not-dir"    // корень — не директория
+	CodeRootAccess     ErrorCode = "root-access"     // корень не прочитать: нет прав и т.п.
+	CodeInvalidOptions ErrorCode = "invalid-options" // настройки несовместимы или неполны
+	CodeSandbox        ErrorCode = "sandbox"         // не удалось войти в песочницу (Options.Sandbox)
+	CodeWalk           ErrorCode = "walk"            // обход не удался
+	CodeNotConfirmed   ErrorCode = "not-confirmed"   // содержимого больше Options.ConfirmOver, а Confirm не согласился
+	CodeOutput         ErrorCode = "output"          // не удалось записать вывод или манифест
+)
+
+// Error — ошибка Run, RunFS и Bytes
+type Error struct {
+	Code ErrorCode
+	Path string // корень, файл вывода или манифест, к которому относится ошибка (может быть пусто)
+	Msg  string // что случилось, по-английски, без причины
+	Err  error  // причина: ошибка ОС, записи и т.п. (может быть nil)
+}
+
+func (e *Error) Error() string {
+	if e.Err == nil {
+		return e.Msg
+	}
+	return e.Msg + ": " + e.Err.Error()
+}
+
+func (e *Error) Unwrap() error { return e.Err }
+
+// rootError переводит ошибку stat корня в *Error с кодом
+func rootError(root string, err error) error {
+	code := CodeRootAccess
+	if errors.Is(err, fs.ErrNotExist) {
+		code = CodeRootNotFound
+	}
+	return &Error{Code: code, Path: root, Msg: "accessing " + root, Err: err}
+}
@@ -0,0 +1,22 @@
+package serializer
+
+import "strings"
+
+// DefaultExcludes — мусор ОС и редакторов, который не попадает в дамп, если не задан Options.NoDefaultExcludes
+// имена сравниваются без учёта регистра (на Windows и macOS бывает и thumbs.db, и .ds_store)
+var DefaultExcludes = []string{
+	".DS_Store", ".Spotlight-V100", ".Trashes", // macOS
+	"Thumbs.db", "ehthumbs.db", "desktop.ini", "$RECYCLE.BIN", // Windows
+	"__pycache__", ".pytest_cache", // Python
+	".idea", ".vscode", // редакторы
+}
+
+// isDefaultExcluded сообщает, что имя файла или директории — из DefaultExcludes
+func isDefaultExcluded(name string) bool {
+	for _, junk := range DefaultExcludes {
+		if strings.EqualFold(name, junk) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,35 @@
+package serializer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+)
+
+// идентификаторы файлов для --file-ids
+// ID выводится из хеша содержимого, поэтому файл сохраняет его и после переименования или переноса,
+// и в разговоре с LLM на него можно сослаться как на "файл F3a9c01" между версиями дампа
+// одинаковые файлы (и редкие совпадения префикса хеша) различаются суффиксом .2, .3 и т.д.
+// в порядке обхода, который детерминирован
+
+const fileIDHexLen = 6
+
+// assignID выдаёт файлу идентификатор, уникальный в пределах дампа
+func (w *walker) assignID(file fileInfo) string {
+	hash := file.hash
+	if hash == "" {
+		// содержимого нет (файл не прочитан) — остаётся только путь
+		sum := sha256.Sum256([]byte(file.relPath))
+		hash = hex.EncodeToString(sum[:])
+	}
+	base := "F" + hash[:fileIDHexLen]
+
+	if w.ids == nil {
+		w.ids = make(map[string]int)
+	}
+	w.ids[base]++
+	if n := w.ids[base]; n > 1 {
+		return base + "." + strconv.Itoa(n)
+	}
+	return base
+}
@@ -0,0 +1,52 @@
+package serializer
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"regexp"
+)
+
+// keepFile решает, попадает ли файл в дамп (и в древо, и в содержимое)
+// relPath — путь от корня через "/", fullPath — путь на диске (пусто, если файл не с диска)
+func (o *Options) keepFile(relPath, fullPath string, info os.FileInfo) bool {
+	if !o.NewerThan.IsZero() && !info.ModTime().After(o.NewerThan) {
+		return false
+	}
+	if o.OwnedByMe {
+		// если владельца определить нельзя (не unix), фильтр не применяем
+		if owned, ok := ownedByCurrentUser(info); ok && !owned {
+			return false
+		}
+	}
+	if o.MinPerms != 0 && !hasAccess(fullPath, info, o.MinPerms) {
+		return false
+	}
+	if o.Filter != nil && !o.Filter(relPath, info) {
+		return false
+	}
+	switch o.Tests {
+	case TestsExclude:
+		return !isTestPath(relPath)
+	case TestsOnly:
+		return isTestPath(relPath)
+	}
+	return true
+}
+
+// ownerHasAccess проверяет права mode по битам владельца из режима файла
+func ownerHasAccess(info os.FileInfo, mode uint32) bool {
+	ownerBits := uint32(info.Mode().Perm()>>6) & 7
+	return ownerBits&mode == mode
+}
+
+// matchesContent проверяет, есть ли в файле совпадение с re
+// файл читается потоком, так что большие файлы целиком в память не загружаются
+func (w *walker) matchesContent(file *fileInfo, re *regexp.Regexp) (bool, error) {
+	f, err := w.openFile(file)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	return re.MatchReader(bufio.NewReader(io.LimitReader(f, file.limit))), nil
+}
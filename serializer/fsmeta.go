@@ -0,0 +1,52 @@
+package serializer
+
+import "slices"
+
+// метаданные, которых файловая система не хранит: FAT не знает прав (их выдумывает опция монтирования)
+// и хранит время изменения с точностью до 2 секунд, SMB выдаёт всем файлам одни и те же права,
+// а 9p и fs.FS в памяти (embed.FS, тестовые) часто отдают нулевые права и время; чтобы манифест не выдавал
+// такие значения за настоящие, он перечисляет пробелы в metadata_gaps и опускает эти поля у файлов
+// пробелы узнаются по типу файловой системы корня и по самим файлам: если права (или время) нулевые у всех,
+// их нет
+
+// пробелы в метаданных (manifest.metadata_gaps)
+const (
+	GapMode        = "mode"     // права не хранятся: выдуманы или нулевые
+	GapMtime       = "mtime"    // времени изменения нет
+	GapMtimeCoarse = "mtime-2s" // время изменения округлено до 2 секунд (FAT)
+)
+
+// fsGaps — чего не хранят известные файловые системы (имена — как их отдаёт fsType)
+var fsGaps = map[string][]string{
+	"vfat": {GapMode, GapMtimeCoarse}, "msdos": {GapMode, GapMtimeCoarse}, "fat": {GapMode, GapMtimeCoarse}, "fat32": {GapMode, GapMtimeCoarse},
+	"exfat": {GapMode},
+	"cifs":  {GapMode}, "smb2": {GapMode}, "smb": {GapMode}, "smbfs": {GapMode},
+}
+
+// metadataGaps определяет тип файловой системы корня (только на диске) и пробелы в метаданных файлов
+func (w *walker) metadataGaps(files []fileInfo) (string, []string) {
+	var fsName string
+	var gaps []string
+	if w.rootPath != "" {
+		fsName = fsType(w.rootPath)
+		gaps = slices.Clone(fsGaps[fsName])
+	}
+	if len(files) == 0 {
+		return fsName, gaps
+	}
+	noMode := !slices.ContainsFunc(files, func(f fileInfo) bool { return f.perm != 0 })
+	noMtime := !slices.ContainsFunc(files, func(f fileInfo) bool { return !f.mtime.IsZero() && f.mtime.Unix() != 0 })
+	if noMode && !slices.Contains(gaps, GapMode) {
+		gaps = append(gaps, GapMode)
+	}
+	if noMtime {
+		gaps = slices.DeleteFunc(gaps, func(g string) bool { return g == GapMtimeCoarse })
+		gaps = append(gaps, GapMtime)
+	}
+	return fsName, gaps
+}
+
+// hasGap сообщает, что метаданных gap у файлов нет (см. metadataGaps)
+func (w *walker) hasGap(gap string) bool {
+	return slices.Contains(w.gaps, gap)
+}
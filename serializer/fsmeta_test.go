@@ -0,0 +1,132 @@
+package serializer
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// gapsManifest — то из манифеста, что касается пробелов в метаданных
+type gapsManifest struct {
+	MetadataGaps []string `json:"metadata_gaps"`
+	Files        []struct {
+		Path  string `json:"path"`
+		Mode  string `json:"mode"`
+		Mtime any    `json:"mtime"`
+	} `json:"files"`
+}
+
+func TestMetadataGaps(t *testing.T) {
+	mtime := time.Date(2024, 3, 1, 12, 30, 15, 123456789, time.UTC)
+	coarse := time.Date(2024, 3, 1, 12, 30, 16, 0, time.UTC) // как у FAT: чётные секунды без долей
+	tests := []struct {
+		name      string
+		fsys      fstest.MapFS
+		gaps      []string
+		wantMode  bool // у файлов есть mode
+		wantMtime bool // у файлов есть mtime
+	}{
+		{
+			name: "full metadata",
+			fsys: fstest.MapFS{
+				"a.txt": {Data: []byte("a\n"), Mode: 0o644, ModTime: mtime},
+				"b.txt": {Data: []byte("b\n"), Mode: 0o600, ModTime: mtime},
+			},
+			wantMode: true, wantMtime: true,
+		},
+		{
+			name: "zero modes",
+			fsys: fstest.MapFS{
+				"a.txt": {Data: []byte("a\n"), ModTime: mtime},
+				"b.txt": {Data: []byte("b\n"), ModTime: mtime},
+			},
+			gaps:      []string{GapMode},
+			wantMtime: true,
+		},
+		{
+			name: "absent mtimes",
+			fsys: fstest.MapFS{
+				"a.txt": {Data: []byte("a\n"), Mode: 0o644},
+				"b.txt": {Data: []byte("b\n"), Mode: 0o644},
+			},
+			gaps:     []string{GapMtime},
+			wantMode: true,
+		},
+		{
+			name: "mtimes at the Unix epoch",
+			fsys: fstest.MapFS{
+				"a.txt": {Data: []byte("a\n"), Mode: 0o644, ModTime: time.Unix(0, 0)},
+				"b.txt": {Data: []byte("b\n"), Mode: 0o644, ModTime: time.Unix(0, 0)},
+			},
+			gaps:     []string{GapMtime},
+			wantMode: true,
+		},
+		{
+			name: "neither modes nor mtimes",
+			fsys: fstest.MapFS{
+				"dir/a.txt": {Data: []byte("a\n")},
+				"b.bin":     {Data: []byte{0, 1, 2}},
+			},
+			gaps: []string{GapMode, GapMtime},
+		},
+		{
+			// один файл с правами и временем — значит, файловая система их хранит
+			name: "some files with metadata",
+			fsys: fstest.MapFS{
+				"a.txt": {Data: []byte("a\n"), Mode: 0o644, ModTime: mtime},
+				"b.txt": {Data: []byte("b\n")},
+			},
+			wantMode: true, wantMtime: true,
+		},
+		{
+			// округлённое время по самим файлам не распознать: mtime-2s ставится только по типу
+			// файловой системы на диске, а время всё равно пишется
+			name: "coarse mtimes",
+			fsys: fstest.MapFS{
+				"a.txt": {Data: []byte("a\n"), Mode: 0o644, ModTime: coarse},
+				"b.txt": {Data: []byte("b\n"), Mode: 0o644, ModTime: coarse.Add(-2 * time.Second)},
+			},
+			wantMode: true, wantMtime: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "manifest.json")
+			opts := Options{ManifestPath: path, MtimeFormat: MtimeISO8601}
+			if _, err := RunFS(io.Discard, tt.fsys, "root", opts); err != nil {
+				t.Fatal(err)
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var m gapsManifest
+			if err := json.Unmarshal(data, &m); err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(m.MetadataGaps, tt.gaps) {
+				t.Errorf("metadata_gaps = %q, want %q", m.MetadataGaps, tt.gaps)
+			}
+			if len(m.Files) != len(tt.fsys) {
+				t.Fatalf("%d files in the manifest, want %d", len(m.Files), len(tt.fsys))
+			}
+			for _, f := range m.Files {
+				if (f.Mode != "") != tt.wantMode {
+					t.Errorf("%s: mode = %q, want present: %v", f.Path, f.Mode, tt.wantMode)
+				}
+				// у файла без времени в смешанном древе mtime нет и так
+				if tt.fsys[f.Path].ModTime.IsZero() {
+					continue
+				}
+				if (f.Mtime != nil) != tt.wantMtime {
+					t.Errorf("%s: mtime = %v, want present: %v", f.Path, f.Mtime, tt.wantMtime)
+				}
+			}
+		})
+	}
+}
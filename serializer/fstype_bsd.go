@@ -0,0 +1,18 @@
+//go:build darwin || freebsd
+
+package serializer
+
+import (
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// fsType возвращает имя типа файловой системы, на которой лежит path ("msdos", "exfat", "smbfs"), или ""
+func fsType(path string) string {
+	var st unix.Statfs_t
+	if err := unix.Statfs(path, &st); err != nil {
+		return ""
+	}
+	return strings.TrimRight(string(st.Fstypename[:]), "\x00")
+}
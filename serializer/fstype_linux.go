@@ -0,0 +1,22 @@
+package serializer
+
+import "golang.org/x/sys/unix"
+
+// fsMagic — типы файловых систем по f_type из statfs, о которых известно, каких метаданных они не хранят
+var fsMagic = map[int64]string{
+	0x4d44:     "vfat",
+	0x2011bab0: "exfat",
+	0x01021997: "9p",
+	0xff534d42: "cifs",
+	0xfe534d42: "smb2",
+	0x517b:     "smb",
+}
+
+// fsType возвращает тип файловой системы, на которой лежит path, или "", если он не из fsMagic
+func fsType(path string) string {
+	var st unix.Statfs_t
+	if err := unix.Statfs(path, &st); err != nil {
+		return ""
+	}
+	return fsMagic[int64(st.Type)]
+}
@@ -0,0 +1,6 @@
+//go:build !(linux || darwin || freebsd || windows)
+
+package serializer
+
+// fsType: тип файловой системы здесь не определяется, остаются признаки по самим файлам
+func fsType(string) string { return "" }
@@ -0,0 +1,25 @@
+package serializer
+
+import (
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/sys/windows"
+)
+
+// fsType возвращает имя файловой системы тома, на котором лежит path ("ntfs", "fat32", "exfat"), или ""
+func fsType(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return ""
+	}
+	root, err := windows.UTF16PtrFromString(filepath.VolumeName(abs) + `\`)
+	if err != nil {
+		return ""
+	}
+	name := make([]uint16, windows.MAX_PATH+1)
+	if err := windows.GetVolumeInformation(root, nil, 0, nil, nil, nil, &name[0], uint32(len(name))); err != nil {
+		return ""
+	}
+	return strings.ToLower(windows.UTF16ToString(name))
+}
@@ -0,0 +1,95 @@
+package serializer
+
+import (
+	"strconv"
+	"strings"
+)
+
+// нечёткий хеш в стиле ssdeep (spamsum, context triggered piecewise hashing)
+// нужен, чтобы по двум дампам можно было понять, изменился ли бинарник, не встраивая его содержимое
+// формат результата такой же, как у ssdeep: "размер_блока:подпись:подпись_для_удвоенного_блока"
+
+const (
+	fuzzyRollingWindow = 7
+	fuzzyMinBlockSize  = 3
+	fuzzySpamSumLength = 64
+	fuzzyHashPrime     = 0x01000193
+	fuzzyHashInit      = 0x28021967
+	fuzzyB64           = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/"
+)
+
+// rollingHash — скользящий хеш по окну из последних 7 байт
+type rollingHash struct {
+	window     [fuzzyRollingWindow]uint32
+	h1, h2, h3 uint32
+	n          uint32
+}
+
+func (r *rollingHash) roll(c byte) uint32 {
+	r.h2 -= r.h1
+	r.h2 += fuzzyRollingWindow * uint32(c)
+
+	r.h1 += uint32(c)
+	r.h1 -= r.window[r.n%fuzzyRollingWindow]
+
+	r.window[r.n%fuzzyRollingWindow] = uint32(c)
+	r.n++
+
+	r.h3 <<= 5
+	r.h3 ^= uint32(c)
+
+	return r.h1 + r.h2 + r.h3
+}
+
+func fuzzySumHash(c byte, h uint32) uint32 {
+	return (h * fuzzyHashPrime) ^ uint32(c)
+}
+
+// fuzzyHash вычисляет нечёткий хеш данных
+func fuzzyHash(data []byte) string {
+	blockSize := uint32(fuzzyMinBlockSize)
+	for blockSize*fuzzySpamSumLength < uint32(len(data)) {
+		blockSize *= 2
+	}
+
+	for {
+		var sig1, sig2 strings.Builder
+		var roll rollingHash
+		h1, h2 := uint32(fuzzyHashInit), uint32(fuzzyHashInit)
+
+		for _, c := range data {
+			h1 = fuzzySumHash(c, h1)
+			h2 = fuzzySumHash(c, h2)
+			r := roll.roll(c)
+
+			// границы кусков определяются содержимым, а не смещением,
+			// поэтому вставка байта в начало файла не ломает всю подпись
+			if r%blockSize == blockSize-1 {
+				if sig1.Len() < fuzzySpamSumLength-1 {
+					sig1.WriteByte(fuzzyB64[h1%64])
+					h1 = fuzzyHashInit
+				}
+			}
+			if r%(blockSize*2) == blockSize*2-1 {
+				if sig2.Len() < fuzzySpamSumLength/2-1 {
+					sig2.WriteByte(fuzzyB64[h2%64])
+					h2 = fuzzyHashInit
+				}
+			}
+		}
+
+		// хвост после последней границы тоже учитываем
+		if roll.h1+roll.h2+roll.h3 != 0 {
+			sig1.WriteByte(fuzzyB64[h1%64])
+			sig2.WriteByte(fuzzyB64[h2%64])
+		}
+
+		// слишком короткая подпись малоинформативна — пробуем блок поменьше
+		if blockSize > fuzzyMinBlockSize && sig1.Len() < fuzzySpamSumLength/2 {
+			blockSize /= 2
+			continue
+		}
+
+		return strconv.FormatUint(uint64(blockSize), 10) + ":" + sig1.String() + ":" + sig2.String()
+	}
+}
@@ -0,0 +1,131 @@
+package serializer
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// правила .gitignore (если не задан Options.NoGitignore): node_modules, артефакты сборки и кеши,
+// которые проект сам не хранит в git, не попадают и в дамп
+// .gitignore читается в каждой обходимой директории и действует на всё под ней; ближний файл важнее дальних,
+// так что "!" во вложенном .gitignore возвращает исключённое выше, как в git (но, как и там, не изнутри
+// исключённой директории: в неё не заходят); для директории на диске учитываются ещё .gitignore выше корня
+// до корня репозитория и его .git/info/exclude
+// правила .gitignore спрашиваются, только если Options.Matchers не решили о пути сами
+//
+// .dsignore — такие же правила только для дампа, чтобы не трогать .gitignore проекта; читается в каждой
+// обходимой директории и с Options.NoGitignore тоже; в одной директории .dsignore важнее .gitignore,
+// так что его "!" возвращает в дамп то, что git игнорирует
+
+// DSIgnoreName — имя файла правил, которые действуют только на дамп
+const DSIgnoreName = ".dsignore"
+
+// gitignored сообщает, что путь relPath исключён правилами .gitignore и .dsignore директории n
+func (n *treeNode) gitignored(relPath string, d fs.DirEntry) bool {
+	for _, m := range n.gitignore {
+		switch m.Match(relPath, d) {
+		case Include:
+			return false
+		case Exclude:
+			return true
+		}
+	}
+	return false
+}
+
+// applyGitignore добавляет к правилам директории n её собственные .gitignore (без Options.NoGitignore)
+// и .dsignore, если они есть
+func (w *walker) applyGitignore(n *treeNode) {
+	names := []string{DSIgnoreName}
+	if !w.opts.NoGitignore {
+		names = append(names, ".gitignore")
+	}
+	var own []Matcher
+	for _, name := range names {
+		m, err := w.loadGitignore(n.relPath, name)
+		if err != nil {
+			n.logf("Could not read %s: %v\n", w.displayPath(path.Join(n.relPath, name)), err)
+		} else if m != nil {
+			own = append(own, m)
+		}
+	}
+	if own != nil {
+		n.gitignore = append(own, n.gitignore...)
+	}
+}
+
+// loadGitignore читает файл правил name директории dir внутри обхода; nil, если его нет
+func (w *walker) loadGitignore(dir, name string) (Matcher, error) {
+	f, err := w.open(path.Join(dir, name))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if info, err := f.Stat(); err == nil && info.IsDir() {
+		return nil, nil
+	}
+	data, err := io.ReadAll(io.LimitReader(f, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	return w.gitignoreRules(dir, data)
+}
+
+// gitignoreRules разбирает правила с учётом Options.GitignoreFold
+func (w *walker) gitignoreRules(dir string, data []byte) (Matcher, error) {
+	if w.opts.GitignoreFold {
+		return GitignoreFold(dir, bytes.NewReader(data))
+	}
+	return Gitignore(dir, bytes.NewReader(data))
+}
+
+// outerGitignores собирает правила выше корня на диске, от ближних к дальним: .gitignore директорий
+// до корня репозитория и его .git/info/exclude; если корень не внутри репозитория git, правил нет
+// читаются и с --sandbox: это не содержимое дампа, а правила, без которых в дамп попало бы лишнее
+// (RestrictProcess разрешает читать именно эти файлы, см. outerConfigFiles)
+func (w *walker) outerGitignores() []Matcher {
+	if w.rootPath == "" {
+		return nil
+	}
+	dir, err := filepath.Abs(w.rootPath)
+	if err != nil {
+		return nil
+	}
+	var chain []Matcher
+	// prefix — путь от dir до корня обхода: правила внешнего файла отсчитываются от его директории
+	outer := func(prefix, name string) {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			return
+		}
+		m, err := w.gitignoreRules(".", data)
+		if err != nil {
+			return
+		}
+		chain = append(chain, MatcherFunc(func(relPath string, d fs.DirEntry) Verdict {
+			return m.Match(path.Join(prefix, relPath), d)
+		}))
+	}
+	prefix := "."
+	for {
+		if _, err := os.Lstat(filepath.Join(dir, ".git")); err == nil {
+			outer(prefix, filepath.Join(dir, ".git", "info", "exclude"))
+			return chain
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil // репозитория нет
+		}
+		prefix = path.Join(filepath.Base(dir), prefix)
+		dir = parent
+		outer(prefix, filepath.Join(dir, ".gitignore"))
+	}
+}
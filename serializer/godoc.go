@@ -0,0 +1,203 @@
+package serializer
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/doc"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/asquebay/directory-serialization/format"
+)
+
+// документация Go-пакетов (--go-doc): для каждой директории с исходниками на Go через go/doc собирается то,
+// что показал бы go doc -all, — комментарий пакета и экспортированные константы, переменные, функции и типы
+// с методами, без тел функций; для большого Go-репозитория это в разы короче исходников и почти не теряет смысла
+// сводки идут перед секциями файлов заголовками "root/dir/ (go doc):", которых format.Parse не принимает
+// за файлы; с GoDocOnly исходники (кроме тестов) получают решение go-doc и показываются только в древе
+
+// режимы --go-doc
+const (
+	GoDocAdd  = "add"  // сводки пакетов вдобавок к исходникам
+	GoDocOnly = "only" // сводки пакетов вместо исходников
+)
+
+// isGoDocSource сообщает, что файл relPath с языком lang попадает в документацию пакета:
+// тесты и testdata go doc не смотрит
+func isGoDocSource(relPath, lang string) bool {
+	if lang != "go" || strings.HasSuffix(relPath, "_test.go") {
+		return false
+	}
+	for _, elem := range strings.Split(path.Dir(relPath), "/") {
+		if elem == "testdata" {
+			return false
+		}
+	}
+	return true
+}
+
+// goDocPackage — пакет одной директории; в директории их может быть несколько (например, main у генератора)
+type goDocPackage struct {
+	name  string
+	files []*ast.File
+}
+
+// writeGoDocs печатает сводки документации пакетов по директориям в порядке вывода файлов
+// и сообщает, напечатано ли что-нибудь
+func (w *walker) writeGoDocs(out io.Writer, rootName string, files []fileInfo) bool {
+	var dirs []string
+	sources := make(map[string][]*fileInfo)
+	modulePath := ""
+	for i := range files {
+		file := &files[i]
+		if file.relPath == "go.mod" && file.isText && !file.readErr {
+			if data, err := w.readFile(file); err == nil {
+				modulePath = goModulePath(data)
+			}
+		}
+		if d := file.decision(); d != decisionContent && d != decisionGoDoc || !isGoDocSource(file.relPath, file.lang) {
+			continue
+		}
+		dir := path.Dir(file.relPath)
+		if sources[dir] == nil {
+			dirs = append(dirs, dir)
+		}
+		sources[dir] = append(sources[dir], file)
+	}
+
+	written := false
+	for _, dir := range dirs {
+		if w.expired() {
+			break
+		}
+		importPath := "" // без go.mod путь импорта неизвестен
+		if modulePath != "" {
+			importPath = path.Join(modulePath, dir)
+		}
+		fset := token.NewFileSet()
+		for _, p := range w.parseGoPackages(fset, sources[dir]) {
+			pkg, err := doc.NewFromFiles(fset, p.files, importPath)
+			if err != nil {
+				fmt.Fprintf(w.log, "Error extracting Go documentation of %s: %v\n", w.displayPath(dir), err)
+				w.recordError(dir, err)
+				continue
+			}
+			var buf bytes.Buffer
+			writeGoDoc(&buf, fset, pkg)
+			if w.opts.Canonical && written {
+				fmt.Fprintln(out)
+			}
+			fmt.Fprintf(out, "%s/ (go doc):\n", format.QuoteName(path.Join(rootName, dir)))
+			fmt.Fprintln(out, "```")
+			fmt.Fprintln(out, strings.TrimRight(buf.String(), "\n"))
+			fmt.Fprintln(out, "```")
+			written = true
+		}
+	}
+	return written
+}
+
+// parseGoPackages разбирает исходники одной директории и раскладывает их по пакетам;
+// файлы, которые не читаются или не разбираются, пропускаются с предупреждением
+func (w *walker) parseGoPackages(fset *token.FileSet, files []*fileInfo) []goDocPackage {
+	var pkgs []goDocPackage
+	for _, file := range files {
+		data, err := w.readFile(file)
+		if err == nil {
+			var f *ast.File
+			if f, err = parser.ParseFile(fset, file.relPath, data, parser.ParseComments); err == nil {
+				i := 0
+				for i < len(pkgs) && pkgs[i].name != f.Name.Name {
+					i++
+				}
+				if i == len(pkgs) {
+					pkgs = append(pkgs, goDocPackage{name: f.Name.Name})
+				}
+				pkgs[i].files = append(pkgs[i].files, f)
+				continue
+			}
+		}
+		fmt.Fprintf(w.log, "Error extracting Go documentation of %s: %v\n", w.displayPath(file.relPath), err)
+		w.recordError(file.relPath, err)
+	}
+	return pkgs
+}
+
+// writeGoDoc печатает документацию пакета в раскладке go doc -all: комментарий пакета,
+// затем константы, переменные, функции и типы; комментарии объявлений — с отступом под ними
+func writeGoDoc(out *bytes.Buffer, fset *token.FileSet, pkg *doc.Package) {
+	if pkg.ImportPath != "" {
+		fmt.Fprintf(out, "package %s // import %q\n\n", pkg.Name, pkg.ImportPath)
+	} else {
+		fmt.Fprintf(out, "package %s\n\n", pkg.Name)
+	}
+	if pkg.Doc != "" {
+		out.Write(pkg.Text(pkg.Doc))
+		out.WriteString("\n")
+	}
+	text := pkg.Printer()
+	text.TextPrefix = "    "
+	// как у go doc: отступы табуляцией, выравнивание пробелами
+	config := printer.Config{Mode: printer.UseSpaces | printer.TabIndent, Tabwidth: 8}
+	decl := func(node ast.Node, comment string) {
+		config.Fprint(out, fset, node)
+		out.WriteString("\n")
+		if comment != "" {
+			out.Write(text.Text(pkg.Parser().Parse(comment)))
+		}
+		out.WriteString("\n")
+	}
+	values := func(title string, values []*doc.Value) {
+		if len(values) == 0 {
+			return
+		}
+		fmt.Fprintf(out, "%s\n\n", title)
+		for _, v := range values {
+			v.Decl.Doc = nil // комментарий печатается отдельно, с отступом
+			decl(v.Decl, v.Doc)
+		}
+	}
+	funcs := func(funcs []*doc.Func) {
+		for _, f := range funcs {
+			f.Decl.Doc, f.Decl.Body = nil, nil
+			decl(f.Decl, f.Doc)
+		}
+	}
+
+	values("CONSTANTS", pkg.Consts)
+	values("VARIABLES", pkg.Vars)
+	if len(pkg.Funcs) > 0 {
+		out.WriteString("FUNCTIONS\n\n")
+		funcs(pkg.Funcs)
+	}
+	if len(pkg.Types) > 0 {
+		out.WriteString("TYPES\n\n")
+	}
+	for _, t := range pkg.Types {
+		t.Decl.Doc = nil
+		decl(t.Decl, t.Doc)
+		// константы и переменные типа, конструкторы и методы идут под ним, как у go doc
+		for _, v := range append(t.Consts, t.Vars...) {
+			v.Decl.Doc = nil
+			decl(v.Decl, v.Doc)
+		}
+		funcs(t.Funcs)
+		funcs(t.Methods)
+	}
+}
+
+// goModulePath достаёт путь модуля из go.mod ("" — не нашёлся)
+func goModulePath(data []byte) string {
+	for _, line := range strings.Split(string(data), "\n") {
+		if rest, ok := strings.CutPrefix(strings.TrimSpace(line), "module"); ok && rest != "" && (rest[0] == ' ' || rest[0] == '\t') {
+			rest, _, _ = strings.Cut(rest, "//")
+			return strings.Trim(strings.TrimSpace(rest), `"`)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,116 @@
+package serializer
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// древо директорий диаграммой для документации:
+//
+//	--format dot     — граф Graphviz (dot -Tsvg tree.dot > tree.svg)
+//	--format mermaid — блок-схема Mermaid (вставляется в Markdown в ```mermaid, GitHub и GitLab её рисуют)
+//
+// по умолчанию в диаграмме только директории, с Options.GraphFiles — и файлы; фильтры и пропуски те же, что у дампа
+
+const (
+	FormatDOT     = "dot"     // древо графом Graphviz
+	FormatMermaid = "mermaid" // древо блок-схемой Mermaid
+)
+
+// graphEdge — связь «директория → элемент» с номерами узлов
+type graphEdge struct {
+	from, to int
+}
+
+// graphNodes нумерует узлы диаграммы в порядке древа: корень — 0; возвращает подписи, признак директории и связи
+func (w *walker) graphNodes(rootName string) (labels []string, dirs []bool, edges []graphEdge) {
+	labels, dirs = []string{treeName(rootName) + "/"}, []bool{true}
+	var walk func(n *treeNode, id int)
+	walk = func(n *treeNode, id int) {
+		for _, c := range n.children {
+			if !c.isDir && !w.opts.GraphFiles {
+				continue
+			}
+			label := treeName(c.name)
+			if c.isDir {
+				label += "/"
+			}
+			labels, dirs = append(labels, label), append(dirs, c.isDir)
+			child := len(labels) - 1
+			edges = append(edges, graphEdge{id, child})
+			if c.isDir {
+				walk(c, child)
+			}
+		}
+	}
+	walk(w.treeRoot, 0)
+	return labels, dirs, edges
+}
+
+// graphComments пишет текст построчно комментариями с префиксом prefix
+func graphComments(out io.Writer, prefix, text string) {
+	if text == "" {
+		return
+	}
+	for _, line := range strings.Split(text, "\n") {
+		fmt.Fprintf(out, "%s %s\n", prefix, line)
+	}
+}
+
+// exportDOT пишет древо графом Graphviz
+func exportDOT(w *walker, out io.Writer, rootName string) {
+	labels, dirs, edges := w.graphNodes(rootName)
+	graphComments(out, "//", w.opts.Preamble)
+	fmt.Fprintf(out, "digraph %s {\n", dotString(treeName(rootName)))
+	fmt.Fprintln(out, "  rankdir=LR;")
+	fmt.Fprintln(out, `  node [shape=folder, fontname="monospace"];`)
+	for id, label := range labels {
+		if dirs[id] {
+			fmt.Fprintf(out, "  n%d [label=%s];\n", id, dotString(label))
+		} else {
+			fmt.Fprintf(out, "  n%d [label=%s, shape=note];\n", id, dotString(label))
+		}
+	}
+	for _, e := range edges {
+		fmt.Fprintf(out, "  n%d -> n%d;\n", e.from, e.to)
+	}
+	fmt.Fprintln(out, "}")
+	graphComments(out, "//", w.opts.Postamble)
+}
+
+// dotString записывает строку в кавычках DOT: внутри экранируются только " и \
+func dotString(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// exportMermaid пишет древо блок-схемой Mermaid
+func exportMermaid(w *walker, out io.Writer, rootName string) {
+	labels, dirs, edges := w.graphNodes(rootName)
+	graphComments(out, "%%", w.opts.Preamble)
+	fmt.Fprintln(out, "graph LR")
+	// у директорий прямоугольник, у файлов — скруглённый
+	node := func(id int) string {
+		if dirs[id] {
+			return fmt.Sprintf(`n%d["%s"]`, id, mermaidText(labels[id]))
+		}
+		return fmt.Sprintf(`n%d("%s")`, id, mermaidText(labels[id]))
+	}
+	if len(edges) == 0 {
+		fmt.Fprintf(out, "  %s\n", node(0))
+	}
+	// корень с подписью объявляется в первой связи, дальше узлы упоминаются по номеру
+	for i, e := range edges {
+		from := fmt.Sprintf("n%d", e.from)
+		if i == 0 {
+			from = node(0)
+		}
+		fmt.Fprintf(out, "  %s --> %s\n", from, node(e.to))
+	}
+	graphComments(out, "%%", w.opts.Postamble)
+}
+
+// mermaidText экранирует подпись узла Mermaid: кавычки и угловые скобки — сущностями вида #quot;
+func mermaidText(s string) string {
+	return strings.NewReplacer(`#`, "#35;", `"`, "#quot;", "<", "#lt;", ">", "#gt;").Replace(s)
+}
@@ -0,0 +1,80 @@
+package serializer
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/asquebay/directory-serialization/format"
+	"github.com/asquebay/directory-serialization/lang"
+)
+
+// способы группировки содержимого (--group-by)
+const (
+	GroupByDir  = "dir"  // по директориям
+	GroupByExt  = "ext"  // по расширениям
+	GroupByLang = "lang" // по языкам
+)
+
+// group — раздел содержимого с подзаголовком
+type group struct {
+	label string
+	files []int // индексы файлов в порядке древа
+}
+
+// groupFiles раскладывает файлы по разделам
+// директории идут в порядке древа, расширения и языки — по алфавиту (файлы без расширения и «прочее» в конце)
+func groupFiles(files []fileInfo, by, rootName string) []group {
+	var groups []group
+	index := make(map[string]int)
+	for i, file := range files {
+		var label string
+		switch by {
+		case GroupByDir:
+			label = path.Join(rootName, path.Dir(file.relPath)) + "/"
+		case GroupByExt:
+			label = noExtension
+			if ext := path.Ext(file.relPath); ext != "" {
+				label = "*" + strings.ToLower(ext)
+			}
+		case GroupByLang:
+			label = otherLanguage
+			if file.lang != "" {
+				label = lang.Name(file.lang)
+			}
+		}
+		n, ok := index[label]
+		if !ok {
+			n = len(groups)
+			index[label] = n
+			groups = append(groups, group{label: label})
+		}
+		groups[n].files = append(groups[n].files, i)
+	}
+	if by != GroupByDir {
+		sort.SliceStable(groups, func(i, j int) bool {
+			li, lj := groups[i].label, groups[j].label
+			if last := li == noExtension || li == otherLanguage; last != (lj == noExtension || lj == otherLanguage) {
+				return !last
+			}
+			return li < lj
+		})
+	}
+	return groups
+}
+
+const (
+	noExtension   = "(no extension)"
+	otherLanguage = "Other"
+)
+
+// groupHeading — подзаголовок раздела в текстовом дампе
+func groupHeading(by, label string) string {
+	switch by {
+	case GroupByLang:
+		return fmt.Sprintf("## %s files", label)
+	default:
+		return "## " + format.QuoteName(label)
+	}
+}
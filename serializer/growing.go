@@ -0,0 +1,57 @@
+package serializer
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+)
+
+// файлы читаются не дальше размера, увиденного при обходе: иначе лог, в который дописывают прямо сейчас,
+// попал бы в дамп каждый раз разной длины, а то и не дочитался бы вовсе
+// у псевдофайлов (procfs, sysfs) stat врёт: размер 0 при непустом содержимом или заведомо огромный (/proc/kcore),
+// таким даём читать не больше pseudoFileLimit; устройства, каналы и сокеты не читаем совсем
+
+const (
+	pseudoFileLimit = 1 << 20 // сколько читать у файла нулевого или неправдоподобного размера
+	absurdFileSize  = 1 << 40 // размер больше этого stat выдумал
+)
+
+// readLimit возвращает, сколько байт файла читать, по его размеру при обходе
+func readLimit(size int64) int64 {
+	if size <= 0 || size > absurdFileSize {
+		return pseudoFileLimit
+	}
+	return size
+}
+
+// isSpecial сообщает, что это устройство, канал или сокет: чтение из них может не закончиться или зависнуть
+func isSpecial(mode fs.FileMode) bool {
+	return mode&(fs.ModeDevice|fs.ModeCharDevice|fs.ModeNamedPipe|fs.ModeSocket) != 0
+}
+
+// limitReader ограничивает чтение файла его пределом; лишний байт показывает, что файл вырос
+func limitReader(r io.Reader, file *fileInfo) io.Reader {
+	return io.LimitReader(r, file.limit+1)
+}
+
+// capGrowth обрезает прочитанное до предела файла и, если файл вырос, помечает его
+// в лог не пишет: при обходе файлы читаются параллельно, и сообщение откладывается до печати древа (см. treeNode.logf)
+func capGrowth(file *fileInfo, data []byte) []byte {
+	if int64(len(data)) <= file.limit {
+		return data
+	}
+	file.grew = true
+	return data[:file.limit]
+}
+
+// markGrown помечает файл выросшим и сообщает об этом один раз
+func (w *walker) markGrown(file *fileInfo) {
+	if !file.grew {
+		file.grew = true
+		io.WriteString(w.log, w.growthMessage(file))
+	}
+}
+
+func (w *walker) growthMessage(file *fileInfo) string {
+	return fmt.Sprintf("File %s grew while being read, using its first %d bytes\n", w.displayPath(file.relPath), file.limit)
+}
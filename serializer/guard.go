@@ -0,0 +1,87 @@
+package serializer
+
+import (
+	"fmt"
+	"sync"
+)
+
+// мягкие ограничения ресурсов (--max-open-files, --max-memory) для тесных CI-раннеров:
+// они не обрывают работу, а сужают параллельный обход, чтобы не упереться в лимит дескрипторов
+// и не разбудить OOM killer
+
+// reservedFiles — дескрипторы, которые нужны помимо обхода: stdin/stdout/stderr, --output, --manifest, база SQLite
+const reservedFiles = 8
+
+// filesPerWorker — сколько дескрипторов держит один обработчик: директорию он читает порциями и не закрывает,
+// пока разбирает её записи, а разбор каждого файла открывает ещё и сам файл
+const filesPerWorker = 2
+
+// workers возвращает, сколько директорий можно обрабатывать одновременно
+func (o *Options) workers() int {
+	n := walkWorkers
+	if o.MaxOpenFiles > 0 {
+		n = min(n, max(1, (o.MaxOpenFiles-reservedFiles)/filesPerWorker))
+	}
+	return n
+}
+
+// keptLimit — сколько байт содержимого небольших файлов можно держать с обхода до вывода (см. keepContent)
+const keptLimit = 64 << 20
+
+// keepContent решает, оставить ли до вывода n байт файла, который при обходе уже прочитан целиком (он меньше
+// выборки детектора): так файл открывается один раз, а не при обходе и снова при выводе; дескриптор держать
+// нельзя (древо печатается раньше, и дескрипторов не хватит), а байты — можно, пока их не больше keptLimit
+// с --max-memory не держим ничего: этот бюджет считает только файлы, которые читаются сейчас
+func (w *walker) keepContent(n int) bool {
+	if w.opts.MaxMemory > 0 {
+		return false
+	}
+	if w.kept.Add(int64(n)) > keptLimit {
+		w.kept.Add(-int64(n))
+		return false
+	}
+	return true
+}
+
+// memoryBudget — взвешенный семафор: сколько байт содержимого файлов можно держать в памяти одновременно
+type memoryBudget struct {
+	mu    sync.Mutex
+	cond  *sync.Cond
+	total int64
+	free  int64
+}
+
+func newMemoryBudget(total int64) *memoryBudget {
+	b := &memoryBudget{total: total, free: total}
+	b.cond = sync.NewCond(&b.mu)
+	return b
+}
+
+// acquire ждёт, пока освободится n байт, и возвращает функцию, которая их вернёт
+// запрос больше всего бюджета урезается до бюджета: такой файл читается, когда остальные уже отпустили память
+func (b *memoryBudget) acquire(n int64) func() {
+	if b == nil {
+		return func() {}
+	}
+	n = min(n, b.total)
+	b.mu.Lock()
+	for b.free < n {
+		b.cond.Wait()
+	}
+	b.free -= n
+	b.mu.Unlock()
+	return func() {
+		b.mu.Lock()
+		b.free += n
+		b.mu.Unlock()
+		b.cond.Broadcast()
+	}
+}
+
+// warnOverBudget предупреждает, если файл для вывода больше --max-memory:
+// содержимое выводится целиком, так что ограничение для него не соблюдается
+func (w *walker) warnOverBudget(file *fileInfo) {
+	if w.opts.MaxMemory > 0 && file.size > w.opts.MaxMemory {
+		fmt.Fprintf(w.log, "Warning: %s (%d bytes) is larger than --max-memory and is read into memory whole\n", file.relPath, file.size)
+	}
+}
@@ -0,0 +1,100 @@
+package serializer
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"slices"
+)
+
+// хеш содержимого файлов (--hash-algo): в манифесте, SQLite, CSV и для --file-ids
+// SHA-256 по умолчанию; sha1 — для сверки с системами, которые знают только его; xxhash и blake3 — когда хеширование
+// гигабайтов упирается в процессор: xxhash не криптографический, blake3 криптографический и большие файлы
+// хеширует во все ядра
+// хранилище cas всегда адресует объекты по SHA-256, иначе снимки с разными алгоритмами не делили бы объекты
+
+const (
+	HashSHA256 = "sha256"
+	HashSHA1   = "sha1"
+	HashXXHash = "xxhash" // XXH64
+	HashBLAKE3 = "blake3"
+)
+
+// HashAlgos — поддерживаемые алгоритмы
+var HashAlgos = []string{HashSHA256, HashSHA1, HashXXHash, HashBLAKE3}
+
+// blake3ParallelMin — с какого размера файл хешируется BLAKE3 в несколько потоков
+const blake3ParallelMin = 4 << 20
+
+// hashAlgo возвращает алгоритм хеша (пусто — SHA-256)
+func (o *Options) hashAlgo() string {
+	if o.HashAlgo == "" {
+		return HashSHA256
+	}
+	return o.HashAlgo
+}
+
+// newHash возвращает хеш выбранного алгоритма
+func (o *Options) newHash() hash.Hash {
+	switch o.hashAlgo() {
+	case HashSHA1:
+		return sha1.New()
+	case HashXXHash:
+		return newXXHash64()
+	case HashBLAKE3:
+		return newBlake3()
+	}
+	return sha256.New()
+}
+
+// HashReader хеширует r алгоритмом algo (из HashAlgos; пусто — SHA-256) и возвращает хеш в hex, как в манифесте:
+// так файлы на диске можно сверить с манифестом (verify --manifest)
+func HashReader(algo string, r io.Reader) (string, error) {
+	o := Options{HashAlgo: algo}
+	if !slices.Contains(HashAlgos, o.hashAlgo()) {
+		return "", fmt.Errorf("unknown hash algorithm %q", algo)
+	}
+	h := o.newHash()
+	if _, err := io.Copy(h, r); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// hashSum возвращает хеш data в hex
+func (o *Options) hashSum(data []byte) string {
+	h := o.newHash()
+	h.Write(data)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// hashReader хеширует limit байт файла f; большие файлы с BLAKE3 — параллельно, если f умеет читать с произвольного места
+// возвращает хеш в hex и сколько байт прочитано
+func (o *Options) hashReader(f fs.File, limit int64) (string, int64, error) {
+	if ra, ok := f.(io.ReaderAt); ok && o.hashAlgo() == HashBLAKE3 && limit >= blake3ParallelMin {
+		// файл мог укоротиться после обхода; тогда ReadAt упрётся в конец, и хешируем подряд, как обычно
+		if sum, err := blake3ReaderAt(ra, limit); err == nil {
+			// читаем дальше с того места, где кончился хеш, чтобы вызывающий мог проверить, не вырос ли файл
+			if s, ok := f.(io.Seeker); ok {
+				if _, err := s.Seek(limit, io.SeekStart); err == nil {
+					return hex.EncodeToString(sum), limit, nil
+				}
+			}
+		}
+		if s, ok := f.(io.Seeker); !ok {
+			return "", 0, fs.ErrInvalid
+		} else if _, err := s.Seek(0, io.SeekStart); err != nil {
+			return "", 0, err
+		}
+	}
+	h := o.newHash()
+	n, err := io.Copy(h, io.LimitReader(f, limit))
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), n, nil
+}
@@ -0,0 +1,70 @@
+package serializer
+
+import (
+	"io/fs"
+	"os"
+)
+
+// точки повторной обработки (reparse points) Windows: соединения (junctions) вроде "Application Data"
+// в профиле пользователя ведут в другие директории, в том числе в свою же родительскую, и обход по ним зацикливался
+// Go отдаёт их как fs.ModeIrregular, а не как директорию; по умолчанию соединение только показывается в древе
+// с целью и пометкой [junction], а с FollowJunctions обходится как директория, если не ведёт в одну из директорий
+// над собой; прочие точки (например, файлы OneDrive, которые ещё не скачаны) помечаются [reparse-point]
+// и не читаются: чтение такого файла скачало бы его
+
+// isReparsePoint сообщает, что элемент — точка повторной обработки (соединение, файл облака и т.п.)
+func isReparsePoint(mode fs.FileMode) bool {
+	return mode&fs.ModeIrregular != 0
+}
+
+// junction возвращает цель соединения и сведения о директории, в которую оно ведёт; false — это не соединение
+func (w *walker) junction(relPath string) (string, fs.FileInfo, bool) {
+	osPath := w.osPath(relPath)
+	if osPath == "" {
+		return "", nil, false
+	}
+	target, err := os.Readlink(osPath)
+	if err != nil {
+		return "", nil, false
+	}
+	info, err := os.Stat(osPath)
+	if err != nil || !info.IsDir() {
+		return "", nil, false
+	}
+	return target, info, true
+}
+
+// dirStat возвращает os.Stat директории на диске (nil — не удалось или читаем не с диска)
+func (w *walker) dirStat(relPath string) fs.FileInfo {
+	osPath := w.osPath(relPath)
+	if osPath == "" {
+		return nil
+	}
+	info, _ := os.Stat(osPath)
+	return info
+}
+
+// followJunction решает, обходить ли соединение child директории n (с FollowJunctions):
+// true — child стал директорией; false — соединение ведёт в директорию над собой, и обходить его нельзя
+func (w *walker) followJunction(n, child *treeNode) bool {
+	target, info, ok := w.junction(child.relPath)
+	if !ok {
+		return false
+	}
+	for p := n; p != nil; p = p.parent {
+		if p.stat != nil && os.SameFile(p.stat, info) {
+			n.logf("Not following junction %s: it leads back to %s\n", w.displayPath(child.relPath), w.displayPath(p.relPath))
+			return false
+		}
+	}
+	child.isDir, child.stat, child.parent, child.junction = true, info, n, target
+	return true
+}
+
+// reparseDecision возвращает решение о точке повторной обработки, которую не обходят, и цель, если это соединение
+func (w *walker) reparseDecision(relPath string) (string, string) {
+	if target, _, ok := w.junction(relPath); ok {
+		return decisionJunction, target
+	}
+	return decisionReparse, ""
+}
@@ -0,0 +1,214 @@
+package serializer
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// форматы-манифесты Kubernetes: файлы упаковываются в один объект, который можно сразу отдать kubectl apply
+//
+//	--format k8s-configmap — ConfigMap: текст в data блоком YAML, не UTF-8 — в binaryData (base64)
+//	--format k8s-secret    — Secret типа Opaque: всё в data, base64
+//
+// ключ — путь от корня, где "/" заменён на "_" (при монтировании том плоский), остальные недопустимые
+// символы тоже "_"; если два файла дают один ключ, второй пропускается с предупреждением
+
+const (
+	FormatConfigMap = "k8s-configmap" // ConfigMap Kubernetes в YAML
+	FormatSecret    = "k8s-secret"    // Secret Kubernetes в YAML
+)
+
+// k8sObjectLimit — сколько байт данных помещается в ConfigMap или Secret (больше API-сервер не примет)
+const k8sObjectLimit = 1 << 20
+
+var (
+	k8sKeyInvalid  = regexp.MustCompile(`[^-._a-zA-Z0-9]`)
+	k8sNameInvalid = regexp.MustCompile(`[^a-z0-9.-]+`)
+	yamlPlain      = regexp.MustCompile(`^[A-Za-z_][-._A-Za-z0-9]*$`)
+)
+
+// yamlReserved — слова, которые парсер YAML 1.1 прочитает как bool или null, а не как строку
+var yamlReserved = map[string]bool{
+	"y": true, "n": true, "yes": true, "no": true, "on": true, "off": true, "true": true, "false": true, "null": true,
+}
+
+// K8sName приводит имя к виду, допустимому для имени объекта Kubernetes (DNS-поддомен RFC 1123):
+// строчные буквы, цифры, "-" и ".", по краям — буква или цифра, не длиннее 253 символов
+func K8sName(name string) string {
+	name = k8sNameInvalid.ReplaceAllString(strings.ToLower(name), "-")
+	if len(name) > 253 {
+		name = name[:253]
+	}
+	name = strings.Trim(name, "-.")
+	if name == "" {
+		return "files"
+	}
+	return name
+}
+
+// k8sKey превращает путь файла в ключ data
+func k8sKey(relPath string) string {
+	key := k8sKeyInvalid.ReplaceAllString(strings.ReplaceAll(relPath, "/", "_"), "_")
+	if key == "." || key == ".." {
+		key = "_" + key
+	}
+	return key
+}
+
+// exportK8s пишет ConfigMap или (secret) Secret с текстовыми файлами, попавшими в вывод
+func exportK8s(w *walker, out io.Writer, rootName string, files []fileInfo, secret bool) {
+	opts := w.opts
+	kind := "ConfigMap"
+	if secret {
+		kind = "Secret"
+	}
+	name := opts.K8sName
+	if name == "" {
+		name = K8sName(rootName)
+	}
+
+	type value struct {
+		key    string
+		data   []byte
+		binary bool
+	}
+	var values []value
+	keys := make(map[string]string)
+	total := 0
+	w.bundleContents(files, func(relPath string, data []byte) {
+		key := k8sKey(relPath)
+		if len(key) > 253 {
+			fmt.Fprintf(w.log, "Skipped %s in the %s: key longer than 253 characters\n", relPath, kind)
+			return
+		}
+		if other, dup := keys[key]; dup {
+			fmt.Fprintf(w.log, "Skipped %s in the %s: key %s is already used by %s\n", relPath, kind, key, other)
+			return
+		}
+		keys[key] = relPath
+		v := value{key: key, data: data, binary: !utf8.Valid(data)}
+		values = append(values, v)
+		// в секрете и в binaryData значение хранится в base64 — в предел идёт его длина, а не исходная
+		size := len(data)
+		if secret || v.binary {
+			size = base64.StdEncoding.EncodedLen(size)
+		}
+		total += len(key) + size
+	})
+	if total > k8sObjectLimit {
+		fmt.Fprintf(w.log, "Warning: %s %s holds %d bytes, over the %d-byte limit Kubernetes accepts; narrow the selection with --max-file-size or --filter\n", kind, name, total, k8sObjectLimit)
+	}
+
+	for _, line := range strings.Split(opts.Preamble, "\n") {
+		if opts.Preamble != "" {
+			fmt.Fprintf(out, "# %s\n", line)
+		}
+	}
+	fmt.Fprintln(out, "apiVersion: v1")
+	fmt.Fprintf(out, "kind: %s\n", kind)
+	fmt.Fprintln(out, "metadata:")
+	fmt.Fprintf(out, "  name: %s\n", yamlScalar(name))
+	if secret {
+		fmt.Fprintln(out, "type: Opaque")
+	}
+
+	// у ConfigMap текст и двоичные данные лежат в разных полях, у Secret — всё в data
+	for _, binary := range []bool{false, true} {
+		field := "data"
+		if binary {
+			field = "binaryData"
+		}
+		written := false
+		for _, v := range values {
+			if !secret && v.binary != binary || secret && binary {
+				continue
+			}
+			if !written {
+				fmt.Fprintf(out, "%s:\n", field)
+				written = true
+			}
+			if secret || v.binary {
+				fmt.Fprintf(out, "  %s: %s\n", yamlScalar(v.key), yamlScalar(base64.StdEncoding.EncodeToString(v.data)))
+			} else {
+				fmt.Fprintf(out, "  %s: %s", yamlScalar(v.key), yamlText(v.data, "    "))
+			}
+		}
+		if !written && !binary {
+			fmt.Fprintf(out, "%s: {}\n", field)
+		}
+	}
+
+	for _, line := range strings.Split(opts.Postamble, "\n") {
+		if opts.Postamble != "" {
+			fmt.Fprintf(out, "# %s\n", line)
+		}
+	}
+}
+
+// yamlScalar записывает строку без кавычек, если YAML прочитает её именно как строку, иначе в кавычках
+// (ключ 1.0 стал бы числом, no — false, .env — ошибкой в некоторых парсерах)
+func yamlScalar(s string) string {
+	if yamlPlain.MatchString(s) && !yamlReserved[strings.ToLower(s)] {
+		return s
+	}
+	return strconv.Quote(s)
+}
+
+// yamlText записывает строку UTF-8 значением YAML с отступом indent и переводом строки в конце:
+// литеральным блоком (|), если его можно прочитать обратно байт в байт, иначе строкой в кавычках с экранированием
+func yamlText(data []byte, indent string) string {
+	s := string(data)
+	if !yamlLiteralSafe(s) {
+		return strconv.Quote(s) + "\n"
+	}
+	// индикатор сохранения концевых переводов строк: | — ровно один, |- — ни одного, |+ — несколько
+	body := strings.TrimRight(s, "\n")
+	header := "|"
+	switch trailing := len(s) - len(body); {
+	case trailing == 0:
+		header = "|-"
+	case trailing > 1:
+		header = "|+"
+	}
+	// отступ содержимого YAML угадывает по первой строке; если она начинается с пробела или пустая, задаём его явно
+	// (indicator считается от ключа, а indent на два пробела глубже ключа)
+	if body[0] == ' ' || body[0] == '\n' {
+		header += "2"
+	}
+	var b strings.Builder
+	b.WriteString(header + "\n")
+	// каждая строка пишется со своим переводом строки, поэтому последний перевод строки s уже учтён
+	for _, line := range strings.Split(strings.TrimSuffix(s, "\n"), "\n") {
+		if line != "" {
+			b.WriteString(indent)
+		}
+		b.WriteString(line + "\n")
+	}
+	return b.String()
+}
+
+// yamlLiteralSafe сообщает, переживёт ли s литеральный блок YAML без искажений: в блоке нельзя
+// экранировать, а CR, управляющие символы, BOM и разделители строк Unicode парсер заменит или отвергнет;
+// пустую строку и строку из одних переводов строк блоком не записать
+func yamlLiteralSafe(s string) bool {
+	if strings.Trim(s, "\n") == "" {
+		return false
+	}
+	for _, r := range s {
+		switch {
+		case r == '\t' || r == '\n':
+		case r < 0x20 || r == 0x7f || r >= 0x80 && r <= 0x9f:
+			return false
+		case r == '\uFEFF' || r == '\u2028' || r == '\u2029':
+			return false
+		}
+	}
+	// строку из одних пробелов в конце блока парсеры читают по-разному, надёжнее кавычки
+	lines := strings.Split(strings.TrimRight(s, "\n"), "\n")
+	return strings.TrimLeft(lines[len(lines)-1], " \t") != ""
+}
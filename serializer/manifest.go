@@ -0,0 +1,163 @@
+package serializer
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"unicode/utf8"
+)
+
+// решения о судьбе файла, которые попадают в манифест
+const (
+	decisionContent    = "content"         // содержимое выведено в дамп
+	decisionBinary     = "binary"          // файл нетекстовый, показан только в древе
+	decisionUnreadable = "unreadable"      // файл не удалось прочитать
+	decisionNoMatch    = "no-match"        // текстовый файл не подошёл под --content-match
+	decisionDeadline   = "deadline"        // до файла не дошли: истёк --deadline
+	decisionSpecial    = "special"         // устройство, канал или сокет: не читается
+	decisionOverBudget = "over-budget"     // текстовый файл не влез в --fit
+	decisionEmpty      = "empty"           // файл пустой: в древе помечен, секции содержимого нет
+	decisionMinified   = "minified"        // минифицированный или сгенерированный файл в одну строку, показан только в древе
+	decisionDirBudget  = "over-dir-budget" // текстовый файл не влез в --per-dir-budget своей директории или директории выше
+	decisionSkeleton   = "skeleton"        // текстовый файл не попал в --skeleton: у его директории уже выбраны другие
+	decisionJunction   = "junction"        // соединение Windows (junction): не обходится без --follow-junctions или ведёт в цикл
+	decisionReparse    = "reparse-point"   // другая точка повторной обработки Windows (например, нескачанный файл облака): не читается
+	decisionGoDoc      = "go-doc"          // исходник на Go с --go-doc only: вместо содержимого — документация пакета (см. godoc.go)
+	decisionNoInclude  = "not-included"    // файл не подошёл под Include, но с IncludeTree показан в древе
+)
+
+// manifestEntry — запись о файле в манифесте (схема — manifest.schema.json)
+type manifestEntry struct {
+	ID   string `json:"id,omitempty"`
+	Path string `json:"path"`
+	// RawPath — исходные байты пути (в JSON это base64), если путь не в UTF-8:
+	// в строке path encoding/json заменил бы такие байты на U+FFFD
+	RawPath []byte `json:"raw_path,omitempty"`
+	Size    int64  `json:"size"`
+	// Mode — права доступа четырьмя восьмеричными цифрами ("0644"), чтобы их можно было вернуть при восстановлении;
+	// на Windows отражают только атрибут «только чтение» (0444 или 0666)
+	// пусто, если файловая система прав не хранит (см. manifest.MetadataGaps)
+	Mode     string `json:"mode,omitempty"`
+	SHA256   string `json:"sha256,omitempty"`
+	Hash     string `json:"hash,omitempty"` // хеш алгоритмом manifest.HashAlgo вместо SHA256 (--hash-algo)
+	Encoding string `json:"encoding,omitempty"`
+	// EncodingSuspect — что не так с кодировкой дальше начала файла (с --check-encoding)
+	EncodingSuspect string `json:"encoding_suspect,omitempty"`
+	Language        string `json:"language,omitempty"`
+	Class           string `json:"class,omitempty"` // назначение файла (см. class.go)
+	Decision        string `json:"decision"`
+	// FuzzyHash позволяет сравнить бинарники двух дампов, не встраивая их содержимое
+	FuzzyHash string `json:"fuzzy_hash,omitempty"`
+	// Xattrs — расширенные атрибуты и ACL (с --xattrs); значения в JSON — base64
+	Xattrs map[string][]byte `json:"xattrs,omitempty"`
+	// ShortcutTarget — куда ведёт ярлык .url/.lnk/.desktop (с --resolve-shortcuts) или соединение Windows (junction)
+	ShortcutTarget string `json:"shortcut_target,omitempty"`
+	// Symlink — цель символьной ссылки как есть (относительная остаётся относительной); содержимое, размер
+	// и права у такой записи — файла, на который она ведёт; restore --manifest воссоздаёт по нему ссылку
+	Symlink string `json:"symlink,omitempty"`
+	// Grew — файл рос, пока его читали: size и хеш относятся к первым байтам, увиденным при обходе
+	Grew bool `json:"grew,omitempty"`
+	// Allocated — сколько байт файл на самом деле занимает на диске; пишется, только если это меньше size
+	// (разреженный или сжатый файловой системой файл)
+	Allocated *int64 `json:"allocated,omitempty"`
+	// Created — время создания файла (statx на Linux, stat на macOS и BSD, атрибуты на Windows), если ФС его хранит
+	// Created и Modified — строка RFC 3339 или число секунд (--mtime-format unix)
+	Created any `json:"created,omitempty"`
+	// Modified — время изменения файла (с --mtime-format)
+	Modified any `json:"mtime,omitempty"`
+}
+
+// manifest — машиночитаемое описание дампа, пишется рядом с основным выводом
+type manifest struct {
+	Root      string            `json:"root"`
+	Preamble  string            `json:"preamble,omitempty"`
+	Postamble string            `json:"postamble,omitempty"`
+	Dirs      []string          `json:"dirs,omitempty"`
+	Unvisited []string          `json:"unvisited_dirs,omitempty"` // директории, не обойдённые из-за --deadline
+	HashAlgo  string            `json:"hash_algorithm,omitempty"` // алгоритм поля hash у файлов, если это не SHA-256
+	IDs       map[string]string `json:"ids,omitempty"`            // ID → путь (с --file-ids)
+	Files     []manifestEntry   `json:"files"`
+
+	// Filesystem — тип файловой системы корня, если он из тех, что хранят не все метаданные ("vfat", "cifs")
+	Filesystem string `json:"filesystem,omitempty"`
+	// MetadataGaps — каких метаданных у файлов нет (GapMode, GapMtime, GapMtimeCoarse, см. fsmeta.go)
+	MetadataGaps []string `json:"metadata_gaps,omitempty"`
+}
+
+// writeManifest сохраняет манифест в JSON-файл
+func writeManifest(w *walker, rootName string, files []fileInfo) error {
+	data, err := json.MarshalIndent(buildManifest(w, rootName, files), "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(w.opts.ManifestPath, append(data, '\n'), 0o644)
+}
+
+// buildManifest собирает манифест по результатам обхода
+func buildManifest(w *walker, rootName string, files []fileInfo) manifest {
+	m := manifest{
+		Root:      rootName,
+		Preamble:  w.opts.Preamble,
+		Postamble: w.opts.Postamble,
+		Unvisited: w.unvisited,
+		Files:     make([]manifestEntry, 0, len(files)),
+
+		Filesystem:   w.fsName,
+		MetadataGaps: w.gaps,
+	}
+	sha256 := w.opts.hashAlgo() == HashSHA256
+	if !sha256 {
+		m.HashAlgo = w.opts.hashAlgo()
+	}
+	for _, file := range files {
+		var mtime, created any
+		if w.opts.MtimeFormat != "" && !w.hasGap(GapMtime) {
+			mtime = w.opts.timestamp(file.mtime)
+		}
+		if !w.hasGap(GapMtime) {
+			created = w.opts.timestamp(file.created)
+		}
+		mode := fmt.Sprintf("%04o", uint32(file.perm))
+		if w.hasGap(GapMode) {
+			mode = ""
+		}
+		if file.id != "" {
+			if m.IDs == nil {
+				m.IDs = make(map[string]string)
+			}
+			m.IDs[file.id] = file.relPath
+		}
+		path := file.relPath
+		var raw []byte
+		if !utf8.ValidString(path) {
+			raw = []byte(path)
+		}
+		entry := manifestEntry{
+			ID:              file.id,
+			Path:            path,
+			RawPath:         raw,
+			Size:            file.size,
+			Mode:            mode,
+			Encoding:        file.encoding,
+			EncodingSuspect: file.suspect,
+			Language:        file.lang,
+			Class:           file.class,
+			Decision:        file.decision(),
+			FuzzyHash:       file.fuzzy,
+			Xattrs:          file.xattrs,
+			ShortcutTarget:  file.target,
+			Symlink:         file.linkTarget,
+			Grew:            file.grew,
+			Created:         created,
+			Allocated:       file.allocated,
+			Modified:        mtime,
+		}
+		if sha256 {
+			entry.SHA256 = file.hash
+		} else {
+			entry.Hash = file.hash
+		}
+		m.Files = append(m.Files, entry)
+	}
+	return m
+}
@@ -0,0 +1,265 @@
+package serializer
+
+import (
+	"bufio"
+	"io"
+	"io/fs"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// отбор путей при обходе: Options.Matchers решают, попадает ли файл или директория в дамп вообще
+// (исключённой директории нет в древе, и в неё не заходят); кроме встроенных — шаблонов, правил .gitignore,
+// размера и времени изменения — сюда можно подключить что угодно, например базу известных сгенерированных файлов
+//
+//	gen := serializer.MatcherFunc(func(relPath string, d fs.DirEntry) serializer.Verdict {
+//		if db.IsGenerated(relPath) {
+//			return serializer.Exclude
+//		}
+//		return serializer.Undecided
+//	})
+
+// Verdict — решение Matcher о пути
+type Verdict int
+
+const (
+	Undecided Verdict = iota // решать дальше: следующим Matcher, а если их нет — путь остаётся
+	Include                  // оставить, не спрашивая следующие
+	Exclude                  // исключить, не спрашивая следующие
+)
+
+// Matcher решает, попадает ли путь в дамп; Options.Matchers опрашиваются по порядку до первого решения
+// директория спрашивается раньше своего содержимого, так что её файлы исключённой директорией не проверяются вовсе
+type Matcher interface {
+	// Match решает о пути relPath (от корня через "/"); d — его элемент директории
+	Match(relPath string, d fs.DirEntry) Verdict
+}
+
+// MatcherFunc делает Matcher из функции
+type MatcherFunc func(relPath string, d fs.DirEntry) Verdict
+
+func (f MatcherFunc) Match(relPath string, d fs.DirEntry) Verdict { return f(relPath, d) }
+
+// match опрашивает Options.Matchers и возвращает первое решение; Undecided — никто не решил, путь остаётся,
+// если его не исключают правила .gitignore (см. gitignore.go)
+func (o *Options) match(relPath string, d fs.DirEntry) Verdict {
+	for _, m := range o.Matchers {
+		if v := m.Match(relPath, d); v != Undecided {
+			return v
+		}
+	}
+	return Undecided
+}
+
+// Glob выносит verdict файлам и директориям, подходящим под любой из шаблонов path.Match;
+// шаблон сверяется и с путём целиком, и с именем, как в ForFiles: "*.log" ловит логи на любой глубине,
+// а "build/*" — только в build; ошибка — если шаблон записан неверно
+func Glob(verdict Verdict, patterns ...string) (Matcher, error) {
+	return glob(verdict, false, patterns)
+}
+
+// GlobFold — Glob без учёта регистра: "*.jpg" ловит и photo.JPG (как на Windows и macOS, где регистр в именах не важен)
+func GlobFold(verdict Verdict, patterns ...string) (Matcher, error) {
+	return glob(verdict, true, patterns)
+}
+
+func glob(verdict Verdict, fold bool, patterns []string) (Matcher, error) {
+	for _, p := range patterns {
+		if _, err := path.Match(p, ""); err != nil {
+			return nil, err
+		}
+	}
+	if fold {
+		patterns = slices.Clone(patterns)
+		for i, p := range patterns {
+			patterns[i] = strings.ToLower(p)
+		}
+	}
+	return MatcherFunc(func(relPath string, d fs.DirEntry) Verdict {
+		if fold {
+			relPath = strings.ToLower(relPath)
+		}
+		for _, p := range patterns {
+			if ok, _ := path.Match(p, relPath); ok {
+				return verdict
+			}
+			if ok, _ := path.Match(p, path.Base(relPath)); ok {
+				return verdict
+			}
+		}
+		return Undecided
+	}), nil
+}
+
+// SizeRange исключает файлы меньше min или больше max байт (max 0 — без верхнего предела); о директориях не решает
+func SizeRange(min, max int64) Matcher {
+	return MatcherFunc(func(relPath string, d fs.DirEntry) Verdict {
+		if d.IsDir() {
+			return Undecided
+		}
+		info, err := d.Info()
+		if err != nil {
+			return Undecided
+		}
+		if info.Size() < min || max > 0 && info.Size() > max {
+			return Exclude
+		}
+		return Undecided
+	})
+}
+
+// ModifiedBetween исключает файлы, изменённые не позже after или не раньше before (нулевое время — без предела);
+// о директориях не решает
+func ModifiedBetween(after, before time.Time) Matcher {
+	return MatcherFunc(func(relPath string, d fs.DirEntry) Verdict {
+		if d.IsDir() {
+			return Undecided
+		}
+		info, err := d.Info()
+		if err != nil {
+			return Undecided
+		}
+		mtime := info.ModTime()
+		if !after.IsZero() && !mtime.After(after) || !before.IsZero() && !mtime.Before(before) {
+			return Exclude
+		}
+		return Undecided
+	})
+}
+
+// gitignoreRule — одна строка .gitignore
+type gitignoreRule struct {
+	re      *regexp.Regexp // путь от директории файла правил
+	negate  bool           // "!": вернуть исключённое
+	dirOnly bool           // "/" на конце: только директории
+}
+
+type gitignoreMatcher struct {
+	dir   string // директория файла правил от корня через "/" ("." — корень)
+	rules []gitignoreRule
+}
+
+// Gitignore читает правила в синтаксисе .gitignore из r; dir — директория (от корня через "/", "." — корень),
+// в которой лежит файл правил: пути с "/" в середине или в начале отсчитываются от неё
+// как в git, из нескольких подходящих правил действует последнее; "!" возвращает путь (Include),
+// остальные правила его исключают; строки, которые git посчитал бы ошибочными, пропускаются
+func Gitignore(dir string, r io.Reader) (Matcher, error) {
+	return gitignore(dir, r, false)
+}
+
+// GitignoreFold — Gitignore без учёта регистра, как в git с core.ignorecase
+func GitignoreFold(dir string, r io.Reader) (Matcher, error) {
+	return gitignore(dir, r, true)
+}
+
+func gitignore(dir string, r io.Reader, fold bool) (Matcher, error) {
+	m := &gitignoreMatcher{dir: path.Clean(dir)}
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		if rule, ok := parseGitignoreLine(sc.Text(), fold); ok {
+			m.rules = append(m.rules, rule)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (m *gitignoreMatcher) Match(relPath string, d fs.DirEntry) Verdict {
+	rel := relPath
+	if m.dir != "." {
+		var ok bool
+		if rel, ok = strings.CutPrefix(relPath, m.dir+"/"); !ok {
+			return Undecided
+		}
+	}
+	for i := len(m.rules) - 1; i >= 0; i-- {
+		rule := m.rules[i]
+		if rule.dirOnly && !d.IsDir() || !rule.re.MatchString(rel) {
+			continue
+		}
+		if rule.negate {
+			return Include
+		}
+		return Exclude
+	}
+	return Undecided
+}
+
+// parseGitignoreLine переводит строку .gitignore в регулярное выражение (с fold — без учёта регистра);
+// false — пустая строка, комментарий или ошибка
+func parseGitignoreLine(line string, fold bool) (gitignoreRule, bool) {
+	var rule gitignoreRule
+	line = strings.TrimSuffix(line, "\r")
+	// пробелы на конце не значимы, если перед ними нет "\"
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, "\\ ") {
+		line = line[:len(line)-1]
+	}
+	if line == "" || line[0] == '#' {
+		return rule, false
+	}
+	if line[0] == '!' {
+		rule.negate, line = true, line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly, line = true, strings.TrimRight(line, "/")
+	}
+	if line == "" {
+		return rule, false
+	}
+	// шаблон со "/" в начале или в середине привязан к директории файла правил, без него — ищется на любой глубине
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+
+	var re strings.Builder
+	if fold {
+		re.WriteString("(?i)")
+	}
+	re.WriteString("^")
+	if !anchored {
+		re.WriteString("(?:.*/)?")
+	}
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case strings.HasPrefix(line[i:], "**/") && (i == 0 || line[i-1] == '/'):
+			re.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(line[i:], "**") && i+2 == len(line) && (i == 0 || line[i-1] == '/'):
+			re.WriteString(".*")
+			i++
+		case c == '*':
+			re.WriteString("[^/]*")
+		case c == '?':
+			re.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(line[i+1:], ']')
+			if end < 0 {
+				return rule, false
+			}
+			class := line[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			re.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		case c == '\\' && i+1 < len(line):
+			i++
+			re.WriteString(regexp.QuoteMeta(line[i : i+1]))
+		default:
+			re.WriteString(regexp.QuoteMeta(line[i : i+1]))
+		}
+	}
+	// правило для директории действует и на всё внутри неё
+	re.WriteString("(?:/.*)?$")
+	compiled, err := regexp.Compile(re.String())
+	if err != nil {
+		return rule, false
+	}
+	rule.re = compiled
+	return rule, true
+}
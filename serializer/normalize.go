@@ -0,0 +1,161 @@
+package serializer
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"sync"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// нормализация имён (Options.NormalizeNames): macOS хранит имена в NFD ("й" — "и" и отдельная кратка),
+// Linux обычно в NFC, и один и тот же проект с двух машин даёт разные пути в дампах, diff и манифестах;
+// с нормализацией пути в древе, заголовках, манифесте и остальных форматах приводятся к одной форме,
+// а файлы по-прежнему открываются по именам на диске (normalizedFS помнит, какое имя во что превратилось)
+// два имени в одной директории, ставшие после нормализации одинаковыми, — коллизия: выводится первое
+// по порядку чтения, о втором — предупреждение в лог и ошибка в Report.Errors
+
+// формы нормализации для Options.NormalizeNames
+const (
+	NormalizeKeep = "keep" // имена как на диске (то же, что пусто)
+	NormalizeNFC  = "nfc"  // составные символы (как обычно на Linux и Windows)
+	NormalizeNFD  = "nfd"  // разложенные символы (как на macOS)
+)
+
+// NormalizeName приводит имя или путь к форме form (NormalizeNFC, NormalizeNFD; "" и NormalizeKeep — как есть);
+// строки не в UTF-8 не меняются
+func NormalizeName(name, form string) string {
+	if !utf8.ValidString(name) {
+		return name
+	}
+	switch form {
+	case NormalizeNFC:
+		return norm.NFC.String(name)
+	case NormalizeNFD:
+		return norm.NFD.String(name)
+	}
+	return name
+}
+
+// normalizedFS отдаёт base с нормализованными именами
+type normalizedFS struct {
+	base fs.FS
+	form string
+
+	mu         sync.Mutex        // директории читаются параллельно
+	real       map[string]string // нормализованный путь → путь в base (только если они различаются)
+	collisions []PathError       // имена, отброшенные из-за коллизий
+}
+
+func newNormalizedFS(base fs.FS, form string) *normalizedFS {
+	return &normalizedFS{base: base, form: form, real: make(map[string]string)}
+}
+
+// realPath возвращает путь в base по нормализованному пути
+func (n *normalizedFS) realPath(name string) string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if real, ok := n.real[name]; ok {
+		return real
+	}
+	return name
+}
+
+func (n *normalizedFS) Open(name string) (fs.File, error) {
+	real := n.realPath(name)
+	f, err := n.base.Open(real)
+	if err != nil {
+		return nil, err
+	}
+	if dir, ok := f.(fs.ReadDirFile); ok {
+		return &normalizedDir{ReadDirFile: dir, fsys: n, name: name, real: real, seen: make(map[string]string)}, nil
+	}
+	return f, nil
+}
+
+// normalizedDir — открытая директория, отдающая нормализованные имена
+type normalizedDir struct {
+	fs.ReadDirFile
+	fsys *normalizedFS
+	name string            // нормализованный путь директории
+	real string            // её путь в base
+	seen map[string]string // нормализованное имя → имя на диске, уже отданное
+}
+
+func (d *normalizedDir) ReadDir(count int) ([]fs.DirEntry, error) {
+	var entries []fs.DirEntry
+	// порция может целиком состоять из отброшенных имён, тогда читаем следующую
+	for {
+		batch, err := d.ReadDirFile.ReadDir(count)
+		for _, e := range batch {
+			if e, ok := d.add(e); ok {
+				entries = append(entries, e)
+			}
+		}
+		if len(entries) > 0 || err != nil || len(batch) == 0 || count <= 0 {
+			return entries, err
+		}
+	}
+}
+
+// add нормализует имя элемента и запоминает путь на диске; false — коллизия
+func (d *normalizedDir) add(e fs.DirEntry) (fs.DirEntry, bool) {
+	name := NormalizeName(e.Name(), d.fsys.form)
+	relPath := path.Join(d.name, name)
+	if prev, ok := d.seen[name]; ok {
+		d.fsys.mu.Lock()
+		d.fsys.collisions = append(d.fsys.collisions, PathError{Path: relPath,
+			Err: fmt.Errorf("%+q and %+q are the same name after %s normalization, only the first is included", prev, e.Name(), d.fsys.form)})
+		d.fsys.mu.Unlock()
+		return nil, false
+	}
+	d.seen[name] = e.Name()
+	if realPath := path.Join(d.real, e.Name()); realPath != relPath {
+		d.fsys.mu.Lock()
+		d.fsys.real[relPath] = realPath
+		d.fsys.mu.Unlock()
+	}
+	if name == e.Name() {
+		return e, true
+	}
+	return normalizedEntry{DirEntry: e, name: name}, true
+}
+
+// normalizedEntry — элемент директории под нормализованным именем
+type normalizedEntry struct {
+	fs.DirEntry
+	name string
+}
+
+func (e normalizedEntry) Name() string { return e.name }
+
+func (e normalizedEntry) Info() (fs.FileInfo, error) {
+	info, err := e.DirEntry.Info()
+	if err != nil {
+		return nil, err
+	}
+	return normalizedInfo{FileInfo: info, name: e.name}, nil
+}
+
+type normalizedInfo struct {
+	fs.FileInfo
+	name string
+}
+
+func (i normalizedInfo) Name() string { return i.name }
+
+// reportCollisions сообщает о коллизиях имён после обхода, в порядке путей
+func (w *walker) reportCollisions() {
+	if w.names == nil {
+		return
+	}
+	collisions := w.names.collisions
+	sort.Slice(collisions, func(i, j int) bool { return collisions[i].Path < collisions[j].Path })
+	for _, c := range collisions {
+		fmt.Fprintf(w.log, "Warning: %s: %v\n", w.displayPath(c.Path), c.Err)
+		w.errors = append(w.errors, c)
+	}
+}
@@ -0,0 +1,92 @@
+package serializer
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// между обходом директории и чтением содержимого файл могут подменить: обычный файл — симлинком, ведущим
+// за пределы корня, или именованным каналом, на чтении из которого процесс повиснет; поэтому файлы с диска
+// открываются с O_NOFOLLOW (если при обходе это был обычный файл, а не ссылка) и O_NONBLOCK, где они есть,
+// а после открытия по дескриптору проверяется, что открыт именно обычный файл
+// другие fs.FS (архив, SFTP, RunFS) открывают файлы как раньше
+
+// errReplaced — на месте обычного файла оказалось что-то другое
+var errReplaced = errors.New("no longer a regular file (replaced while being read?)")
+
+// errNotRegular — символьная ссылка ведёт не на обычный файл (например, на директорию): это не подмена,
+// такой она могла быть уже при обходе
+var errNotRegular = errors.New("not a regular file")
+
+// regularOpener — fs.FS, который умеет открывать файлы с этими проверками
+// follow — при обходе файл был символьной ссылкой: по ней переходим
+type regularOpener interface {
+	openRegular(name string, follow bool) (fs.File, error)
+}
+
+func (dir dirFS) openRegular(name string, follow bool) (fs.File, error) {
+	f, err := os.OpenFile(filepath.Join(string(dir), filepath.FromSlash(name)), openFlags(follow), 0)
+	return checkRegular(f, err, follow)
+}
+
+// os.Root сам разрешает ссылки внутри корня и O_NOFOLLOW для последнего элемента не соблюдает,
+// поэтому подмену обычного файла ссылкой видно только по Lstat после открытия (выйти за корень ссылка всё равно не даст)
+func (r rootFS) openRegular(name string, follow bool) (fs.File, error) {
+	opened, err := r.root.OpenFile(filepath.FromSlash(name), openFlags(follow), 0)
+	f, err := checkRegular(opened, err, follow)
+	if err != nil || follow {
+		return f, err
+	}
+	if info, err := r.root.Lstat(filepath.FromSlash(name)); err != nil || info.Mode()&fs.ModeSymlink != 0 {
+		f.Close()
+		return nil, &fs.PathError{Op: "open", Path: name, Err: errReplaced}
+	}
+	return f, nil
+}
+
+func (n *normalizedFS) openRegular(name string, follow bool) (fs.File, error) {
+	if o, ok := n.base.(regularOpener); ok {
+		return o.openRegular(n.realPath(name), follow)
+	}
+	return n.base.Open(n.realPath(name))
+}
+
+func (v *virtualFS) openRegular(name string, follow bool) (fs.File, error) {
+	if o, ok := v.base.(regularOpener); ok && !v.isVirtual(name) {
+		return o.openRegular(name, follow)
+	}
+	return v.Open(name)
+}
+
+// checkRegular проверяет, что открытый файл — обычный; follow — файл открыт по символьной ссылке
+func checkRegular(f *os.File, err error, follow bool) (fs.File, error) {
+	if err != nil {
+		if pe, ok := err.(*fs.PathError); ok && isNoFollowError(pe.Err) {
+			pe.Err = errReplaced
+		}
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if !info.Mode().IsRegular() {
+		f.Close()
+		if follow {
+			return nil, &fs.PathError{Op: "open", Path: f.Name(), Err: errNotRegular}
+		}
+		return nil, &fs.PathError{Op: "open", Path: f.Name(), Err: errReplaced}
+	}
+	return f, nil
+}
+
+// openFile открывает файл для чтения содержимого (см. regularOpener)
+func (w *walker) openFile(file *fileInfo) (fs.File, error) {
+	if o, ok := w.fsys.(regularOpener); ok {
+		return o.openRegular(fsPath(file.relPath), file.link)
+	}
+	return w.open(file.relPath)
+}
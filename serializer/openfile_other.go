@@ -0,0 +1,10 @@
+//go:build !unix
+
+package serializer
+
+import "os"
+
+// openFlags: O_NOFOLLOW и O_NONBLOCK на этой платформе нет, остаётся проверка после открытия
+func openFlags(bool) int { return os.O_RDONLY }
+
+func isNoFollowError(error) bool { return false }
@@ -0,0 +1,24 @@
+//go:build unix
+
+package serializer
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// openFlags — флаги открытия файла для чтения содержимого: без O_NONBLOCK open подменённого
+// именованного канала ждал бы писателя, а O_NOFOLLOW не даёт открыть ссылку вместо обычного файла
+func openFlags(follow bool) int {
+	flags := os.O_RDONLY | syscall.O_NONBLOCK
+	if !follow {
+		flags |= syscall.O_NOFOLLOW
+	}
+	return flags
+}
+
+// isNoFollowError сообщает, что open не удался из-за O_NOFOLLOW (FreeBSD отвечает EMLINK вместо ELOOP)
+func isNoFollowError(err error) bool {
+	return errors.Is(err, syscall.ELOOP) || errors.Is(err, syscall.EMLINK)
+}
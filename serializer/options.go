@@ -0,0 +1,182 @@
+package serializer
+
+import (
+	"io"
+	"io/fs"
+	"regexp"
+	"time"
+
+	"github.com/asquebay/directory-serialization/detector"
+	"github.com/asquebay/directory-serialization/lang"
+)
+
+// форматы вывода
+const (
+	FormatText   = "text"   // древо и содержимое файлов (по умолчанию)
+	FormatSQLite = "sqlite" // база SQLite в Output
+	FormatCAS    = "cas"    // хранилище, адресуемое содержимым, в директории Output
+
+	FormatRepomix   = "repomix"   // раскладка Repomix (XML-стиль)
+	FormatGitingest = "gitingest" // раскладка дайджеста gitingest
+
+	FormatJSON = "json" // древо и содержимое одним JSON-документом
+	FormatYAML = "yaml" // то же YAML-документом
+
+	FormatTreeJSON = "tree-json" // только древо, как tree -J
+	FormatTreeXML  = "tree-xml"  // только древо, как tree -X
+)
+
+// Streams сообщает, пишется ли формат потоком в io.Writer (иначе — в файл или директорию Output)
+func Streams(format string) bool {
+	switch format {
+	case "", FormatText, FormatRepomix, FormatGitingest, FormatJSON, FormatYAML, FormatTreeJSON, FormatTreeXML, FormatConfigMap, FormatSecret, FormatDOT, FormatMermaid, FormatCSV:
+		return true
+	}
+	return false
+}
+
+// Target — ещё один вывод того же обхода
+type Target struct {
+	Format string    // формат вывода
+	Output string    // файл (для cas — директория); для sqlite и cas обязателен
+	Writer io.Writer // куда писать потоковые форматы (text, repomix, gitingest, json, yaml, tree-json, tree-xml, k8s-configmap, k8s-secret, dot, mermaid, csv)
+}
+
+// Options — настройки сериализации; нулевое значение даёт обычный текстовый дамп
+type Options struct {
+	Format       string // формат вывода (пусто — FormatText)
+	Output       string // файл (для cas — директория) вывода для sqlite и cas; для потоковых форматов — только чтобы --sandbox разрешил туда писать
+	ManifestPath string // куда писать манифест (пусто — не писать)
+	MtimeFormat  string // записывать в манифест время изменения файлов: MtimeUnix или MtimeISO8601 (пусто — не записывать)
+	FuzzyHash    bool   // считать нечёткий хеш для нетекстовых файлов
+	FileIDs      bool   // показывать стабильные ID файлов в древе и заголовках
+	Sandbox      bool   // читать только внутри корня (процесс целиком ограничивает RestrictProcess)
+	Sanitize     bool   // экранировать управляющие символы в содержимом (для вывода в терминал)
+	Preamble     string // текст перед дампом (например, инструкции для LLM)
+	Postamble    string // текст после дампа
+
+	// SourceDate — время сборки для воспроизводимых дампов (SOURCE_DATE_EPOCH): им помечается сам дамп,
+	// и времена файлов в манифесте не бывают позже него
+	SourceDate time.Time
+
+	// фильтры
+	NewerThan time.Time // пропускать файлы, изменённые не позже этого момента
+	OwnedByMe bool      // только файлы текущего пользователя
+	MinPerms  uint32    // права, которые должны быть у текущего пользователя (r=4, w=2, x=1)
+	Tests     string    // тесты (см. tests.go): TestsInclude или пусто — как обычные файлы, TestsExclude — без них, TestsOnly — только они
+	// Filter — своё условие на файл (relPath от корня через "/"); директории через него не проходят
+	Filter func(relPath string, info fs.FileInfo) bool
+	// Matchers — какие пути оставить (см. matcher.go): опрашиваются по порядку до первого решения,
+	// исключённая директория не обходится
+	Matchers []Matcher
+	// Include — белый список файлов: в дамп попадают только те, о которых он выносит вердикт Include
+	// (nil — все); директории через него не проходят и остаются в древе, даже если в них ничего не подошло
+	Include Matcher
+	// IncludeTree — с Include не подошедшие файлы не исчезают из древа, а показываются с решением not-included
+	IncludeTree bool
+	// NormalizeNames — к какой форме Unicode привести имена в выводе (NormalizeNFC, NormalizeNFD; пусто или NormalizeKeep —
+	// как на диске), см. normalize.go
+	NormalizeNames string
+	// Virtual — файлы, которых нет в директории (вывод `go env`, `git log -5`, см. virtual.go): в древе и содержимом
+	// они выводятся как настоящие и проходят те же фильтры; файл на диске с тем же путём ими заменяется
+	Virtual []VirtualFile
+	// OnlyClasses — оставить только файлы этих классов (ClassSource, ClassConfig и т.д., см. class.go; пусто — все)
+	OnlyClasses []string
+
+	// FollowJunctions — обходить соединения Windows (junctions) как директории, кроме ведущих в директорию над собой
+	// (см. junction.go); без него соединение только показывается в древе с целью
+	FollowJunctions bool
+
+	NoDefaultExcludes bool // не пропускать мусор ОС и редакторов из DefaultExcludes
+	NoEditorConfig    bool // не брать кодировку из charset в .editorconfig (см. editorconfig.go)
+	NoGitignore       bool // не пропускать то, что исключают правила .gitignore (см. gitignore.go); .dsignore действует и так
+	GitignoreFold     bool // сверять правила .gitignore и .dsignore без учёта регистра, как git с core.ignorecase
+
+	ContentMatch *regexp.Regexp // выводить содержимое только файлов, где есть совпадение
+	KeepMinified bool           // выводить и минифицированные файлы (по умолчанию они только в древе с пометкой [minified])
+	ScanSecrets  bool           // искать в выводимом содержимом строки, похожие на ключи (см. secrets.go), — в Report.Secrets и лог
+
+	// обрезка содержимого
+	HeadLines     int   // выводить только первые N строк каждого файла (0 — все)
+	BinaryPreview int   // показывать hexdump первых N байт нетекстовых файлов (0 — не показывать)
+	SummarizeDocs int   // у длинных документов (README, LICENSE, CHANGELOG, docs/*.md) выводить первые N строк и пометку (0 — целиком)
+	MaxFileSize   int64 // выводить не больше N байт каждого файла (0 — без ограничения)
+	Wrap          int   // переносить строки длиннее N символов с пометкой format.WrapMarker (0 — не переносить)
+
+	// ByteExact — содержимое файлов в текстовом дампе байт в байт такое, как на диске: файлы, которые Parse
+	// вернул бы иначе (со строкой ``` внутри), выводятся в base64 с пометкой format.Base64Note;
+	// несовместимо с настройками, меняющими содержимое (обрезка, переносы, части, Transformers, Sanitize)
+	ByteExact bool
+
+	// разбиение длинных файлов на части
+	ChunkLines   int // максимум строк в части (0 — без ограничения)
+	ChunkTokens  int // максимум (оценочных) токенов в части (0 — без ограничения)
+	ChunkOverlap int // на сколько строк соседние части перекрываются
+
+	// MaskEnv — значения в файлах dotenv (.env, .env.local, *.env) заменяются на "***" (см. MaskEnv), имена переменных остаются;
+	// шаг идёт до Transformers; на cas не действует: хранилище держит файлы как есть
+	MaskEnv bool
+
+	Transformers []Transformer // свои преобразования содержимого; идут до встроенной обрезки (см. transform.go)
+
+	Tokens TokenEstimator // оценка токенов для ChunkTokens, Fit и Report.Tokens (nil — HeuristicEstimator)
+	Fit    int            // сколько (оценочных) токенов содержимого уместить в вывод; что не влезло — пропускается (0 — без ограничения)
+
+	// Skeleton — выводить содержимое не больше чем N файлов каждой директории (см. skeleton.go), древо — целиком (0 — все)
+	Skeleton int
+
+	// PerDirTokens и PerDirBytes — сколько токенов (или байт) содержимого может дать в вывод одна директория
+	// вместе с поддиректориями; что не влезло — пропускается (0 — без ограничения; если заданы оба, действует PerDirTokens)
+	PerDirTokens int
+	PerDirBytes  int64
+
+	GroupBy string // как разбить содержимое на разделы: dir, ext, lang (пусто — одним списком)
+
+	// Canonical — раскладка текстового дампа для git diff двух дампов: одинаковые знаки древа на всех строках,
+	// пустая строка между секциями файлов и "." вместо имени корня
+	Canonical bool
+
+	K8sName    string // имя объекта для k8s-configmap и k8s-secret (пусто — K8sName от имени корня)
+	GraphFiles bool   // показывать в диаграммах dot и mermaid и файлы, а не только директории
+	HashAlgo   string // алгоритм хеша содержимого: sha256 (по умолчанию, и если пусто), sha1, xxhash или blake3
+
+	Langs     *lang.Table // определение языка файла (nil — встроенные правила)
+	FenceLang bool        // ставить язык у открывающего fence (```go)
+
+	// GoDoc — документация Go-пакетов (см. godoc.go): GoDocAdd — перед содержимым файлов, GoDocOnly — вместо исходников на Go
+	// (пусто — без неё); пишется только в текстовый формат
+	GoDoc string
+
+	// FileNotes — свои пометки в заголовке файла текстового дампа, после встроенных (--ownership в CLI);
+	// получает путь от корня через "/", nil — пометок нет; пометки не должны содержать ", " и ")"
+	FileNotes func(relPath string) []string
+
+	HeaderStats bool // добавлять в заголовок файла строки, размер, кодировку и язык: "(342 lines, 12KB, UTF-8, go)"
+
+	ResolveShortcuts bool // показывать в древе цели ярлыков .url, .lnk и .desktop
+
+	CheckEncoding bool // проверять кодировку текстовых файлов целиком, а не по началу (см. suspect.go)
+
+	// Detector — какие части файла смотреть при определении кодировки: с detector.SampleSpread у больших файлов
+	// ещё середину и конец, расхождение между ними помечается [encoding suspect] (нулевое значение — только начало)
+	Detector detector.Config
+
+	Xattrs bool // записывать в манифест расширенные атрибуты и ACL файлов (только для директорий на диске)
+
+	Deadline time.Duration // бюджет времени на обход и вывод (0 — без ограничения)
+
+	// ConfirmOver — если после обхода оценка содержимого в выводе больше стольких байт, перед чтением содержимого
+	// спросить Confirm (0 — не спрашивать); без Confirm или при его отказе Run возвращает ошибку
+	ConfirmOver int64
+	Confirm     func(size int64, files int) bool // получает оценку: байт содержимого и сколько файлов его дадут
+
+	ContentEncoding string // как записывать содержимое в структурированных форматах: raw, escaped, base64 (json и yaml — raw или base64)
+
+	// мягкие ограничения ресурсов
+	MaxOpenFiles int   // сколько дескрипторов можно занять (0 — без ограничения)
+	MaxMemory    int64 // сколько байт содержимого файлов держать в памяти одновременно (0 — без ограничения)
+
+	Targets []Target // дополнительные выводы в других форматах (файлы читаются заново, но обход один)
+
+	Log io.Writer // куда писать предупреждения и ошибки отдельных файлов (nil — никуда)
+}
@@ -0,0 +1,15 @@
+//go:build !unix
+
+package serializer
+
+import "os"
+
+// ownedByCurrentUser на платформах без uid владельца определить нельзя
+func ownedByCurrentUser(info os.FileInfo) (bool, bool) {
+	return false, false
+}
+
+// hasAccess без access(2) приходится довольствоваться битами владельца из режима файла
+func hasAccess(_ string, info os.FileInfo, mode uint32) bool {
+	return ownerHasAccess(info, mode)
+}
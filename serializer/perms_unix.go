@@ -0,0 +1,28 @@
+//go:build unix
+
+package serializer
+
+import (
+	"os"
+	"syscall"
+)
+
+// ownedByCurrentUser сообщает, принадлежит ли файл текущему пользователю
+// второе значение false, если владельца определить нельзя
+func ownedByCurrentUser(info os.FileInfo) (bool, bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return false, false
+	}
+	return int(st.Uid) == os.Geteuid(), true
+}
+
+// hasAccess проверяет, есть ли у текущего пользователя права mode (битовая маска r=4, w=2, x=1) на файл
+// используем access(2), чтобы учесть и владельца, и группу, и остальных;
+// для файлов не с диска (path пуст) остаются только биты владельца
+func hasAccess(path string, info os.FileInfo, mode uint32) bool {
+	if path == "" {
+		return ownerHasAccess(info, mode)
+	}
+	return syscall.Access(path, mode) == nil
+}
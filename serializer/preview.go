@@ -0,0 +1,30 @@
+package serializer
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// с --binary-preview у нетекстовых файлов в дампе появляется не содержимое, а hexdump первых байт
+// (как у hexdump -C: смещение, байты и ASCII справа) — по сигнатуре обычно видно, что это за файл
+// секция помечается "binary", и парсер дампа не принимает её за содержимое файла
+
+// binaryPreview читает первые opts.BinaryPreview байт файла и возвращает их hexdump и пометки для заголовка
+func (w *walker) binaryPreview(file *fileInfo) ([]byte, []string, error) {
+	f, err := w.openFile(file)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+	data, err := io.ReadAll(io.LimitReader(f, int64(w.opts.BinaryPreview)))
+	if err != nil {
+		return nil, nil, err
+	}
+	note := "hexdump"
+	if int64(len(data)) < file.size {
+		note = fmt.Sprintf("hexdump of first %d bytes", len(data))
+	}
+	return []byte(strings.TrimSuffix(hex.Dump(data), "\n")), []string{"binary", note}, nil
+}
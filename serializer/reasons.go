@@ -0,0 +1,42 @@
+package serializer
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/asquebay/directory-serialization/format"
+)
+
+// пометки причин в древе: почему у файла нет содержимого в дампе (или оно обрезано), например "main.bin [binary]"
+// в древо попадает только то, что известно при обходе; решения, принятые при выводе содержимого
+// (--fit, --deadline, истёкший посреди вывода), видны в манифесте
+
+// reasonTag возвращает пометку для строки древа или "", если содержимое файла выводится целиком
+func (w *walker) reasonTag(file fileInfo) string {
+	switch d := file.decision(); d {
+	case decisionEmpty:
+		return format.EmptyFileTag
+	case decisionContent:
+		if max := w.opts.MaxFileSize; max > 0 && file.size > max {
+			return ">" + FormatSize(max)
+		}
+		return ""
+	default:
+		return d
+	}
+}
+
+// FormatSize записывает размер в двоичных единицах так же, как его принимает --max-file-size (64KB, 1.5MB)
+func FormatSize(n int64) string {
+	units := []struct {
+		suffix string
+		size   int64
+	}{{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}}
+	for _, u := range units {
+		if n >= u.size {
+			s := strconv.FormatFloat(float64(n)/float64(u.size), 'f', 1, 64)
+			return strings.TrimSuffix(s, ".0") + u.suffix
+		}
+	}
+	return strconv.FormatInt(n, 10) + "B"
+}
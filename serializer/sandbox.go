@@ -0,0 +1,208 @@
+package serializer
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/asquebay/directory-serialization/detector"
+)
+
+// всё чтение сериализуемой директории идёт через open/readFile, то есть через w.fsys:
+// файлы открываются только на чтение, в дерево ничего не пишется
+// в режиме --sandbox w.fsys — rootFS поверх os.Root, который не даёт выйти за пределы корня
+// через симлинки и ".."; ограничить сам процесс (Landlock на Linux) может только программа целиком,
+// а не библиотека внутри чужого процесса, поэтому это отдельный RestrictProcess, который зовёт CLI
+
+// dirFS — как os.DirFS, но без проверки fs.ValidPath: та отвергает имена не в UTF-8, а на диске они бывают
+type dirFS string
+
+func (dir dirFS) Open(name string) (fs.File, error) {
+	return os.Open(filepath.Join(string(dir), filepath.FromSlash(name)))
+}
+
+// rootFS — то же для os.Root (его FS() проверяет имена так же строго)
+type rootFS struct{ root *os.Root }
+
+func (r rootFS) Open(name string) (fs.File, error) {
+	return r.root.Open(filepath.FromSlash(name))
+}
+
+// fsPath переводит путь относительно корня в путь для fs.FS (корень — ".")
+func fsPath(relPath string) string {
+	if relPath == "" {
+		return "."
+	}
+	return relPath
+}
+
+// open открывает файл или директорию по пути относительно корня только на чтение
+func (w *walker) open(relPath string) (fs.File, error) {
+	return w.fsys.Open(fsPath(relPath))
+}
+
+// osPath возвращает путь к файлу на диске или "", если читаем не с диска (или файл виртуальный)
+func (w *walker) osPath(relPath string) string {
+	if w.rootPath == "" || w.virtualPath(relPath) {
+		return ""
+	}
+	if w.names != nil {
+		relPath = w.names.realPath(relPath)
+	}
+	return filepath.Join(w.rootPath, filepath.FromSlash(relPath))
+}
+
+// displayPath возвращает путь для сообщений в логе
+func (w *walker) displayPath(relPath string) string {
+	if w.rootPath == "" {
+		return path.Join(w.rootName, relPath)
+	}
+	return filepath.Join(w.rootPath, filepath.FromSlash(relPath))
+}
+
+// readFile читает файл целиком, но не дальше его предела (см. growing.go); небольшой файл, уже прочитанный
+// целиком при обходе, второй раз не открывается
+func (w *walker) readFile(file *fileInfo) ([]byte, error) {
+	if file.data != nil {
+		return file.data, nil
+	}
+	f, err := w.openFile(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	data, err := io.ReadAll(limitReader(f, file))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > file.limit {
+		w.markGrown(file)
+	}
+	return capGrowth(file, data), nil
+}
+
+// readSamples читает по detector.SampleSize байт с каждого смещения (см. detector.Config.Offsets);
+// nil без ошибки — файл нельзя читать с произвольного места (сжатый архив), тогда обходимся началом
+func (w *walker) readSamples(file *fileInfo, offsets []int64) ([][]byte, error) {
+	f, err := w.openFile(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r, ok := f.(io.ReaderAt)
+	if !ok {
+		return nil, nil
+	}
+	samples := make([][]byte, 0, len(offsets))
+	for _, offset := range offsets {
+		buf := make([]byte, detector.SampleSize)
+		n, err := r.ReadAt(buf, offset)
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		samples = append(samples, buf[:n])
+	}
+	return samples, nil
+}
+
+// readHead читает начало файла, которого хватает детектору (detector.SampleSize байт)
+// если full или файл короче, читает файл целиком; второе значение — прочитан ли файл целиком
+// вызывается при обходе, параллельно, поэтому о выросшем файле не сообщает, а только помечает его
+func (w *walker) readHead(file *fileInfo, full bool) ([]byte, bool, error) {
+	f, err := w.openFile(file)
+	if err != nil {
+		return nil, false, err
+	}
+	defer f.Close()
+	r := limitReader(f, file)
+	head := make([]byte, detector.SampleSize+1) // лишний байт показывает, есть ли что-то дальше
+	n, err := io.ReadFull(r, head)
+	switch {
+	case err == io.EOF || err == io.ErrUnexpectedEOF:
+		return capGrowth(file, head[:n]), true, nil
+	case err != nil:
+		return nil, false, err
+	case !full:
+		return head[:detector.SampleSize], false, nil
+	}
+	rest, err := io.ReadAll(r)
+	if err != nil {
+		return nil, false, err
+	}
+	return capGrowth(file, append(head, rest...)), true, nil
+}
+
+// enterSandbox включает режим --sandbox; возвращённый корень нужно закрыть после вывода
+func (w *walker) enterSandbox() (*os.Root, error) {
+	root, err := os.OpenRoot(w.rootPath)
+	if err != nil {
+		return nil, err
+	}
+	w.fsys = rootFS{root}
+	return root, nil
+}
+
+// RestrictProcess необратимо ограничивает весь процесс средствами ядра (Landlock на Linux): читать можно
+// только директорию root и файлы правил над ней (.gitignore, .git/info/exclude, .editorconfig — без них
+// ограничение расширило бы дамп), писать — только рядом с opts.Output, opts.ManifestPath и выводами opts.Targets
+// это для программы, которая только сериализует: хост, встроивший пакет, ограничит так и себя, поэтому
+// Run с Options.Sandbox этого не делает; ошибка — ядро или сборка ограничения не поддерживают
+func RestrictProcess(root string, opts Options) error {
+	var writable []string
+	paths := []string{opts.Output, opts.ManifestPath}
+	for _, t := range opts.Targets {
+		paths = append(paths, t.Output)
+	}
+	for _, path := range paths {
+		if path != "" {
+			abs, err := filepath.Abs(path)
+			if err != nil {
+				return err
+			}
+			writable = append(writable, filepath.Dir(abs))
+		}
+	}
+
+	// часовой пояс подгружается лениво из /etc/localtime, после ограничения его уже не прочитать
+	time.Now().Local().Zone()
+
+	return restrictProcess(root, outerConfigFiles(root, opts), writable)
+}
+
+// outerConfigFiles перечисляет существующие файлы правил над корнем, которые читают outerGitignores
+// и outerEditorConfigs: .gitignore и .editorconfig всех директорий выше и .git/info/exclude репозитория
+func outerConfigFiles(root string, opts Options) []string {
+	dir, err := filepath.Abs(root)
+	if err != nil {
+		return nil
+	}
+	var names []string
+	add := func(name string) {
+		if info, err := os.Stat(name); err == nil && info.Mode().IsRegular() {
+			names = append(names, name)
+		}
+	}
+	inRepo := !opts.NoGitignore
+	for {
+		if inRepo {
+			if _, err := os.Lstat(filepath.Join(dir, ".git")); err == nil {
+				add(filepath.Join(dir, ".git", "info", "exclude"))
+				inRepo = false // выше корня репозитория .gitignore не действуют
+			}
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return names
+		}
+		dir = parent
+		if inRepo {
+			add(filepath.Join(dir, ".gitignore"))
+		}
+		if !opts.NoEditorConfig {
+			add(filepath.Join(dir, ".editorconfig"))
+		}
+	}
+}
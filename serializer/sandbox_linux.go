@@ -0,0 +1,100 @@
+//go:build linux
+
+package serializer
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// права Landlock по версиям ABI: чем новее ядро, тем больше видов доступа можно запретить
+const (
+	landlockAccessABI1 = unix.LANDLOCK_ACCESS_FS_EXECUTE | unix.LANDLOCK_ACCESS_FS_WRITE_FILE |
+		unix.LANDLOCK_ACCESS_FS_READ_FILE | unix.LANDLOCK_ACCESS_FS_READ_DIR |
+		unix.LANDLOCK_ACCESS_FS_REMOVE_DIR | unix.LANDLOCK_ACCESS_FS_REMOVE_FILE |
+		unix.LANDLOCK_ACCESS_FS_MAKE_CHAR | unix.LANDLOCK_ACCESS_FS_MAKE_DIR |
+		unix.LANDLOCK_ACCESS_FS_MAKE_REG | unix.LANDLOCK_ACCESS_FS_MAKE_SOCK |
+		unix.LANDLOCK_ACCESS_FS_MAKE_FIFO | unix.LANDLOCK_ACCESS_FS_MAKE_BLOCK |
+		unix.LANDLOCK_ACCESS_FS_MAKE_SYM
+	landlockAccessABI2 = landlockAccessABI1 | unix.LANDLOCK_ACCESS_FS_REFER
+	landlockAccessABI3 = landlockAccessABI2 | unix.LANDLOCK_ACCESS_FS_TRUNCATE
+
+	landlockRead  = unix.LANDLOCK_ACCESS_FS_READ_FILE | unix.LANDLOCK_ACCESS_FS_READ_DIR
+	landlockWrite = landlockRead | unix.LANDLOCK_ACCESS_FS_WRITE_FILE | unix.LANDLOCK_ACCESS_FS_MAKE_REG |
+		unix.LANDLOCK_ACCESS_FS_MAKE_DIR | unix.LANDLOCK_ACCESS_FS_REMOVE_FILE
+)
+
+// restrictProcess ограничивает процесс через Landlock: читать можно только readRoot и отдельные файлы readFiles,
+// писать — только внутри директорий writable; всё остальное ядро запрещает
+// no_new_privs и landlock_restrict_self действуют на один поток, а файлы читают горутины на любых потоках,
+// поэтому оба вызова делаются сразу во всех потоках через syscall.AllThreadsSyscall (новые потоки наследуют
+// ограничение от создавшего); в сборке с cgo runtime так не умеет, и тогда ограничения нет вовсе
+func restrictProcess(readRoot string, readFiles, writable []string) error {
+	abi, _, errno := unix.Syscall(unix.SYS_LANDLOCK_CREATE_RULESET, 0, 0, unix.LANDLOCK_CREATE_RULESET_VERSION)
+	if errno != 0 {
+		return fmt.Errorf("landlock: %w", errno)
+	}
+	handled := uint64(landlockAccessABI1)
+	switch {
+	case abi >= 3:
+		handled = landlockAccessABI3
+	case abi == 2:
+		handled = landlockAccessABI2
+	}
+
+	attr := unix.LandlockRulesetAttr{Access_fs: handled}
+	fd, _, errno := unix.Syscall(unix.SYS_LANDLOCK_CREATE_RULESET, uintptr(unsafe.Pointer(&attr)), unsafe.Sizeof(attr), 0)
+	if errno != 0 {
+		return fmt.Errorf("landlock: create ruleset: %w", errno)
+	}
+	defer unix.Close(int(fd))
+
+	if err := landlockAllow(int(fd), readRoot, landlockRead&handled); err != nil {
+		return err
+	}
+	for _, name := range readFiles {
+		// правило на файл допускает только права файла, без чтения директорий
+		if err := landlockAllow(int(fd), name, unix.LANDLOCK_ACCESS_FS_READ_FILE); err != nil {
+			return err
+		}
+	}
+	for _, dir := range writable {
+		if err := landlockAllow(int(fd), dir, landlockWrite&handled|handled&unix.LANDLOCK_ACCESS_FS_TRUNCATE); err != nil {
+			return err
+		}
+	}
+
+	// без no_new_privs непривилегированному процессу ограничивать себя нельзя
+	_, _, errno = syscall.AllThreadsSyscall(unix.SYS_PRCTL, unix.PR_SET_NO_NEW_PRIVS, 1, 0)
+	if errno == syscall.ENOTSUP {
+		return errors.New("a cgo build cannot restrict all threads; rebuild with CGO_ENABLED=0")
+	}
+	if errno != 0 {
+		return fmt.Errorf("prctl(PR_SET_NO_NEW_PRIVS): %w", errno)
+	}
+	if _, _, errno := syscall.AllThreadsSyscall(unix.SYS_LANDLOCK_RESTRICT_SELF, fd, 0, 0); errno != 0 {
+		return fmt.Errorf("landlock: restrict self: %w", errno)
+	}
+	return nil
+}
+
+// landlockAllow разрешает доступ access ко всему, что лежит внутри path
+func landlockAllow(rulesetFD int, path string, access uint64) error {
+	pathFD, err := unix.Open(path, unix.O_PATH|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return fmt.Errorf("landlock: open %s: %w", path, err)
+	}
+	defer unix.Close(pathFD)
+
+	rule := unix.LandlockPathBeneathAttr{Allowed_access: access, Parent_fd: int32(pathFD)}
+	_, _, errno := unix.Syscall6(unix.SYS_LANDLOCK_ADD_RULE, uintptr(rulesetFD), unix.LANDLOCK_RULE_PATH_BENEATH,
+		uintptr(unsafe.Pointer(&rule)), 0, 0, 0)
+	if errno != 0 {
+		return fmt.Errorf("landlock: add rule for %s: %w", path, errno)
+	}
+	return nil
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package serializer
+
+import "errors"
+
+// restrictProcess на этой платформе ограничить процесс средствами ядра нельзя
+func restrictProcess(string, []string, []string) error {
+	return errors.New("not supported on this platform")
+}
@@ -0,0 +1,48 @@
+package serializer
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+// содержимое файлов может содержать ANSI escape-последовательности и прочие управляющие символы,
+// которые при выводе в терминал меняют его состояние (цвета, заголовок окна, очистка экрана и хуже)
+// поэтому при выводе в терминал управляющие символы C0/C1 (кроме \n, \t и \r в составе \r\n)
+// заменяются видимыми экранированными последовательностями вида \x1b (Options.Sanitize; в CLI --raw отключает замену)
+
+// SanitizeControls экранирует опасные управляющие символы; невалидные байты UTF-8 оставляет как есть
+func SanitizeControls(data []byte) []byte {
+	// быстрый путь: обычно управляющих символов нет вовсе
+	clean := true
+	for _, b := range data {
+		// 0xc2 — первый байт символов C1 (U+0080–U+009F) в UTF-8
+		if b < 0x20 && b != '\n' && b != '\t' || b == 0x7f || b == 0xc2 {
+			clean = false
+			break
+		}
+	}
+	if clean {
+		return data
+	}
+
+	out := make([]byte, 0, len(data)+16)
+	for i := 0; i < len(data); {
+		r, size := utf8.DecodeRune(data[i:])
+		switch {
+		case r == utf8.RuneError && size == 1:
+			out = append(out, data[i])
+		case r == '\n' || r == '\t':
+			out = append(out, data[i])
+		case r == '\r' && i+1 < len(data) && data[i+1] == '\n':
+			out = append(out, '\r')
+		case r < 0x20 || r == 0x7f:
+			out = fmt.Appendf(out, `\x%02x`, r)
+		case r >= 0x80 && r <= 0x9f:
+			out = fmt.Appendf(out, `\u%04x`, r)
+		default:
+			out = append(out, data[i:i+size]...)
+		}
+		i += size
+	}
+	return out
+}
@@ -0,0 +1,9 @@
+package serializer
+
+import _ "embed"
+
+// ManifestSchema — JSON Schema манифеста (--manifest); по ней потребители проверяют манифест
+// и генерируют типы на других языках; при изменении manifestEntry/manifest схему нужно обновлять вместе с ними
+//
+//go:embed manifest.schema.json
+var ManifestSchema []byte
@@ -0,0 +1,141 @@
+package serializer
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"regexp"
+
+	"github.com/asquebay/directory-serialization/detector"
+)
+
+// поиск секретов в выводимом содержимом (--scan-secrets): регулярки --transform redact ловят известные форматы,
+// а здесь ищутся строки, похожие на ключи по энтропии — случайные base64 и hex, каких в коде и тексте не бывает,
+// — и блоки закрытых ключей PEM
+// проверяется то, что попадёт в дамп (после преобразований), так что скрытое redact уже не находится;
+// номера строк — в выведенном содержимом, с преобразованиями, меняющими строки, они могут разойтись с файлом
+
+// SecretFinding — строка дампа, похожая на секрет
+type SecretFinding struct {
+	Path string // путь от корня через "/"
+	Line int    // номер строки в выведенном содержимом, с 1
+	Kind string // что найдено: "private key", "high-entropy string"
+}
+
+func (f SecretFinding) String() string { return fmt.Sprintf("%s:%d: %s", f.Path, f.Line, f.Kind) }
+
+const (
+	secretMinLength  = 24  // кандидаты короче не проверяем: на коротких строках энтропия ничего не говорит
+	secretBase64Bits = 4.5 // бит на символ у base64-подобной строки, начиная с которых она похожа на ключ
+	secretHexBits    = 3.5 // то же для hex (у hex не больше 4)
+	secretHexLength  = 32
+)
+
+var (
+	secretToken   = regexp.MustCompile(`[A-Za-z0-9+/=_-]{24,}`)
+	secretHex     = regexp.MustCompile(`^[0-9a-fA-F]+$`)
+	secretKeyword = regexp.MustCompile(`(?i)key|secret|token|passw|pwd|auth|credential|private|signature`)
+	secretPEM     = regexp.MustCompile(`-----BEGIN [A-Z0-9 ]*PRIVATE KEY( BLOCK)?-----`)
+	// хост и путь URL: ID коммитов и документов в ссылках — не секреты; учётные данные и параметры запроса остаются
+	secretURLPath = regexp.MustCompile(`://[^\s/?#@]+(?:/[^\s?#]*)?`)
+)
+
+// secretExempt — файлы, где случайные строки — это контрольные суммы зависимостей, а не секреты
+var secretExempt = map[string]bool{
+	"go.sum": true, "package-lock.json": true, "yarn.lock": true, "pnpm-lock.yaml": true, "Cargo.lock": true,
+	"poetry.lock": true, "Pipfile.lock": true, "composer.lock": true, "Gemfile.lock": true, "flake.lock": true,
+}
+
+// scanSecrets ищет в содержимом файла строки, похожие на секреты, и запоминает их (по одной находке на строку)
+func (w *walker) scanSecrets(relPath string, data []byte) {
+	if secretExempt[path.Base(relPath)] {
+		return
+	}
+	n := 0
+	for line := range bytes.Lines(data) {
+		n++
+		if kind := secretKind(line); kind != "" {
+			w.addSecret(SecretFinding{Path: relPath, Line: n, Kind: kind})
+		}
+	}
+}
+
+// secretKind возвращает, на какой секрет похожа строка, или ""
+func secretKind(line []byte) string {
+	if secretPEM.Match(line) {
+		return "private key"
+	}
+	for _, token := range secretToken.FindAll(secretURLPath.ReplaceAll(line, []byte("://")), -1) {
+		token = bytes.TrimRight(token, "=")
+		if len(token) < secretMinLength || !hasLetterAndDigit(token) {
+			continue
+		}
+		if secretHex.Match(token) {
+			// хеши коммитов и контрольные суммы тоже hex, поэтому hex считаем ключом только рядом с его названием
+			if len(token) >= secretHexLength && detector.Entropy(token) > secretHexBits && secretKeyword.Match(line) {
+				return "high-entropy string"
+			}
+			continue
+		}
+		if detector.Entropy(token) > secretBase64Bits && looksRandom(token) {
+			return "high-entropy string"
+		}
+	}
+	return ""
+}
+
+// looksRandom отсеивает то, у чего энтропия высокая, но случайности нет: длинные идентификаторы
+// (TestHandleRequestWithRetry2 — серии строчных букв в среднем от 2.5, у случайной строки ~1.7)
+// и алфавиты вроде ABCDEF...0123456789
+func looksRandom(s []byte) bool {
+	runs, lower, sequential := 0, 0, 0
+	for i, b := range s {
+		if b >= 'a' && b <= 'z' {
+			lower++
+			if i == 0 || s[i-1] < 'a' || s[i-1] > 'z' {
+				runs++
+			}
+		}
+		if i > 0 && b == s[i-1]+1 {
+			sequential++
+		}
+	}
+	if runs > 0 && 2*lower >= 5*runs {
+		return false
+	}
+	return sequential < len(s)/2
+}
+
+func hasLetterAndDigit(s []byte) bool {
+	letter := bytes.IndexFunc(s, func(r rune) bool { return r >= 'A' && r <= 'Z' || r >= 'a' && r <= 'z' }) >= 0
+	digit := bytes.IndexFunc(s, func(r rune) bool { return r >= '0' && r <= '9' }) >= 0
+	return letter && digit
+}
+
+// addSecret запоминает находку; каждый вывод перечитывает файлы, поэтому повторы отбрасываются
+func (w *walker) addSecret(f SecretFinding) {
+	if w.secretsSeen[f] {
+		return
+	}
+	if w.secretsSeen == nil {
+		w.secretsSeen = make(map[SecretFinding]bool)
+	}
+	w.secretsSeen[f] = true
+	w.secrets = append(w.secrets, f)
+}
+
+// reportSecrets печатает находки в лог
+func (w *walker) reportSecrets() {
+	if len(w.secrets) == 0 {
+		return
+	}
+	for _, f := range w.secrets {
+		fmt.Fprintf(w.log, "Possible secret: %s\n", f)
+	}
+	files := make(map[string]bool)
+	for _, f := range w.secrets {
+		files[f.Path] = true
+	}
+	fmt.Fprintf(w.log, "Warning: %d line(s) in %d file(s) look like keys or secrets; check them before sharing the dump (hide them with --transform \"* redact REGEXP\")\n",
+		len(w.secrets), len(files))
+}
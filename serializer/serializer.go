@@ -0,0 +1,468 @@
+package serializer
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/asquebay/directory-serialization/detector"
+	"github.com/asquebay/directory-serialization/format"
+	"github.com/asquebay/directory-serialization/lang"
+)
+
+// здесь лежит API для встраивания в другие программы на Go (плагины редакторов, боты):
+// сериализация без запуска CLI и разбора его stdout
+//
+//	data, report, err := serializer.Bytes("./project", serializer.Options{HeadLines: 200})
+
+// Report — итоги сериализации
+type Report struct {
+	Dirs             int             // директорий в древе
+	Files            int             // файлов в древе
+	Contents         int             // файлов, содержимое которых попало в вывод
+	Skipped          map[string]int  // файлов без содержимого по причинам: "binary", "unreadable", "no-match", "deadline", "special", "over-budget", "empty", "minified", "over-dir-budget", "skeleton", "junction", "reparse-point", "go-doc", "not-included"
+	Denied           int             // из них нечитаемых из-за прав доступа
+	Tokens           int             // оценка токенов выведенного содержимого (Options.Tokens)
+	ContentSize      int64           // байт выведенного содержимого; остальное в выводе — древо и заголовки
+	LangTokens       map[string]int  // она же по языкам ("" — язык неизвестен)
+	QuotedNames      int             // имён, выведенных в кавычках: с управляющими символами или не в UTF-8
+	QuotedLookalikes int             // имён, выведенных в кавычках, потому что похожи на пометку древа или начинаются с кавычки
+	Unvisited        []string        // директории, в которые не зашли из-за Deadline
+	Errors           []PathError     // ошибки отдельных файлов и директорий (обход при них не прерывается)
+	Secrets          []SecretFinding // строки, похожие на ключи и секреты (только с ScanSecrets)
+}
+
+// Bytes сериализует директорию root в память; годится только для потоковых форматов (text, repomix, gitingest, tree-json, tree-xml)
+func Bytes(root string, opts Options) ([]byte, Report, error) {
+	if !Streams(opts.Format) {
+		return nil, Report{}, &Error{Code: CodeInvalidOptions, Msg: fmt.Sprintf("format %q writes to Options.Output, use Run", opts.Format)}
+	}
+	var buf bytes.Buffer
+	report, err := Run(&buf, root, opts)
+	if err != nil {
+		return nil, report, err
+	}
+	return buf.Bytes(), report, nil
+}
+
+// Run сериализует директорию root: потоковые форматы пишутся в out, sqlite и cas — в opts.Output;
+// opts.Targets получают тот же обход в других форматах
+// ошибка возвращается, только если сериализация не удалась целиком; ошибки отдельных файлов — в Report.Errors
+func Run(out io.Writer, root string, opts Options) (Report, error) {
+	info, err := os.Stat(root)
+	if err != nil {
+		return Report{}, rootError(root, err)
+	}
+	if !info.IsDir() {
+		return Report{}, &Error{Code: CodeRootNotDir, Path: root, Msg: root + " is not a directory"}
+	}
+	w, err := newWalker(out, opts)
+	if err != nil {
+		return Report{}, err
+	}
+	w.fsys, w.rootPath, w.rootName = dirFS(root), root, filepath.Base(root)
+	if opts.Sandbox {
+		sandbox, err := w.enterSandbox()
+		if err != nil {
+			return Report{}, &Error{Code: CodeSandbox, Path: root, Msg: "entering sandbox", Err: err}
+		}
+		defer sandbox.Close()
+	}
+	return w.serialize(out)
+}
+
+// RunFS сериализует fsys (его корень ".") под именем rootName — так можно сериализовать то, чего нет на диске:
+// архив, embed.FS, папку, переданную из браузера в wasm-сборке
+// права (MinPerms) проверяются только по битам владельца, Sandbox не поддерживается
+func RunFS(out io.Writer, fsys fs.FS, rootName string, opts Options) (Report, error) {
+	if opts.Sandbox {
+		return Report{}, &Error{Code: CodeInvalidOptions, Msg: "Options.Sandbox applies only to directories on disk"}
+	}
+	info, err := fs.Stat(fsys, ".")
+	if err != nil {
+		return Report{}, rootError(rootName, err)
+	}
+	if !info.IsDir() {
+		return Report{}, &Error{Code: CodeRootNotDir, Path: rootName, Msg: rootName + " is not a directory"}
+	}
+	w, err := newWalker(out, opts)
+	if err != nil {
+		return Report{}, err
+	}
+	w.fsys, w.rootName = fsys, rootName
+	return w.serialize(out)
+}
+
+// newWalker проверяет настройки и подставляет значения по умолчанию
+func newWalker(out io.Writer, opts Options) (*walker, error) {
+	if opts.Format == "" {
+		opts.Format = FormatText
+	}
+	if opts.Langs == nil {
+		opts.Langs = lang.Default()
+	}
+	if opts.ContentEncoding == "" {
+		opts.ContentEncoding = ContentRaw
+	}
+	if opts.Tokens == nil {
+		opts.Tokens = HeuristicEstimator{}
+	}
+	if opts.ByteExact && (opts.HeadLines > 0 || opts.SummarizeDocs > 0 || opts.MaxFileSize > 0 || opts.Wrap > 0 ||
+		opts.ChunkLines > 0 || opts.ChunkTokens > 0 || len(opts.Transformers) > 0 || opts.MaskEnv || opts.Sanitize) {
+		return nil, &Error{Code: CodeInvalidOptions, Msg: "ByteExact cannot be combined with options that change file contents"}
+	}
+	switch opts.NormalizeNames {
+	case "", NormalizeKeep, NormalizeNFC, NormalizeNFD:
+	default:
+		return nil, &Error{Code: CodeInvalidOptions, Msg: fmt.Sprintf("unknown NormalizeNames %q (expected nfc, nfd or keep)", opts.NormalizeNames)}
+	}
+	switch opts.GoDoc {
+	case "", GoDocAdd, GoDocOnly:
+	default:
+		return nil, &Error{Code: CodeInvalidOptions, Msg: fmt.Sprintf("unknown GoDoc %q (expected add or only)", opts.GoDoc)}
+	}
+	switch opts.Detector.Sampling {
+	case "", detector.SampleHead, detector.SampleSpread:
+	default:
+		return nil, &Error{Code: CodeInvalidOptions, Msg: fmt.Sprintf("unknown Detector.Sampling %q (expected head or spread)", opts.Detector.Sampling)}
+	}
+	if err := checkVirtual(opts.Virtual); err != nil {
+		return nil, &Error{Code: CodeInvalidOptions, Msg: "invalid Options.Virtual", Err: err}
+	}
+	if !Streams(opts.Format) && opts.Output == "" {
+		return nil, &Error{Code: CodeInvalidOptions, Msg: fmt.Sprintf("format %s requires Options.Output", opts.Format)}
+	}
+	for _, t := range opts.Targets {
+		if Streams(t.Format) && t.Writer == nil || !Streams(t.Format) && t.Output == "" {
+			return nil, &Error{Code: CodeInvalidOptions, Msg: fmt.Sprintf("target %s %s: streaming formats need Writer, sqlite and cas need Output", t.Format, t.Output)}
+		}
+	}
+	w := &walker{opts: &opts, log: opts.Log, steps: opts.pipeline()}
+	if w.log == nil {
+		w.log = io.Discard
+	}
+	if opts.Deadline > 0 {
+		w.expiresAt = time.Now().Add(opts.Deadline)
+	}
+	return w, nil
+}
+
+// serialize обходит w.fsys один раз и пишет вывод во все выбранные форматы
+func (w *walker) serialize(out io.Writer) (Report, error) {
+	opts := w.opts
+	targets := append([]Target{{Format: opts.Format, Output: opts.Output, Writer: out}}, opts.Targets...)
+	// имена нормализуются под виртуальными файлами: их пути задаёт вызывающий
+	if opts.NormalizeNames == NormalizeNFC || opts.NormalizeNames == NormalizeNFD {
+		w.names = newNormalizedFS(w.fsys, opts.NormalizeNames)
+		w.fsys, w.rootName = w.names, NormalizeName(w.rootName, opts.NormalizeNames)
+	}
+	if len(opts.Virtual) > 0 {
+		v := newVirtualFS(w.fsys, opts.Virtual)
+		for _, p := range v.shadowed(opts.Virtual) {
+			fmt.Fprintf(w.log, "Warning: virtual file replaces %s on disk\n", p)
+		}
+		w.fsys = v
+	}
+
+	// древо текстовых выводов печатается при обходе сразу во все; остальные форматы рисуют его сами из w.treeRoot
+	var texts []io.Writer
+	for _, t := range targets {
+		if t.Format == FormatText {
+			texts = append(texts, t.Writer)
+		}
+	}
+	w.tree = io.MultiWriter(texts...)
+
+	// преамбула (инструкции для LLM и т.п.) идёт перед древом и отделяется пустой строкой
+	if opts.Preamble != "" {
+		fmt.Fprintln(w.tree, opts.Preamble)
+		fmt.Fprintln(w.tree)
+	}
+
+	// Этап 1: построение древа директории
+	rootName := w.rootName
+	if opts.Canonical {
+		// у двух дампов разных копий проекта иначе различались бы все заголовки
+		rootName = "."
+	}
+	fmt.Fprintln(w.tree, format.QuoteName(rootName)+"/")
+
+	files, err := w.walk()
+	if err != nil {
+		return Report{}, &Error{Code: CodeWalk, Path: w.rootName, Msg: "walking directory", Err: err}
+	}
+	w.reportCollisions()
+	w.fsName, w.gaps = w.metadataGaps(files)
+
+	denied := 0
+	for _, file := range files {
+		if file.denied {
+			denied++
+		}
+	}
+	if denied > 0 {
+		fmt.Fprintf(w.log, "Skipped %d file(s) without read permission\n", denied)
+	}
+	if w.quotedNames > 0 {
+		fmt.Fprintf(w.log, "Quoted %d name(s) with control characters or invalid UTF-8\n", w.quotedNames)
+	}
+	if w.quotedLookalikes > 0 {
+		fmt.Fprintf(w.log, "Quoted %d name(s) that look like tree annotations or start with a quote\n", w.quotedLookalikes)
+	}
+
+	// перед этапом 2 можно остановиться: древо уже прочитано, а содержимое гигабайтной шары — ещё нет
+	if opts.ConfirmOver > 0 {
+		size, n := contentEstimate(opts, files)
+		if size > opts.ConfirmOver && (opts.Confirm == nil || !opts.Confirm(size, n)) {
+			return Report{}, &Error{Code: CodeNotConfirmed, Path: w.rootName, Msg: fmt.Sprintf("reading contents: about %s in %d files is over the limit of %s and was not confirmed", FormatSize(size), n, FormatSize(opts.ConfirmOver))}
+		}
+	}
+
+	// Этап 2: содержимое файлов; каждый вывод читает файлы заново, обход же был один
+	for _, t := range targets {
+		if err := w.export(t, rootName, files); err != nil {
+			return Report{}, &Error{Code: CodeOutput, Path: t.Output, Msg: "writing " + t.Output, Err: err}
+		}
+	}
+	// опись в CSV — после остальных выводов, когда решения по файлам окончательные
+	for _, t := range targets {
+		if t.Format != FormatCSV {
+			continue
+		}
+		if err := exportCSV(w, t.Writer, files); err != nil {
+			return Report{}, &Error{Code: CodeOutput, Path: t.Output, Msg: "writing " + t.Output, Err: err}
+		}
+	}
+
+	w.reportDeadline(files)
+	w.reportSecrets()
+	if w.dirLimit() > 0 {
+		w.reportDirBudget(files)
+	}
+	if opts.Fit > 0 {
+		over := 0
+		for _, file := range files {
+			if file.skip == decisionOverBudget {
+				over++
+			}
+		}
+		if over > 0 {
+			fmt.Fprintf(w.log, "Skipped %d file(s) that did not fit in %d tokens\n", over, opts.Fit)
+		}
+	}
+
+	// манифест пишем после вывода содержимого, чтобы в нём были окончательные решения по файлам
+	if opts.ManifestPath != "" {
+		for i := range files {
+			w.ensureHash(&files[i])
+		}
+		if err := writeManifest(w, rootName, files); err != nil {
+			return Report{}, &Error{Code: CodeOutput, Path: opts.ManifestPath, Msg: "writing manifest " + opts.ManifestPath, Err: err}
+		}
+	}
+
+	if opts.Postamble != "" {
+		fmt.Fprintln(w.tree)
+		fmt.Fprintln(w.tree, opts.Postamble)
+	}
+	return w.report(files), nil
+}
+
+// export пишет содержимое в один вывод
+func (w *walker) export(t Target, rootName string, files []fileInfo) error {
+	w.fitUsed = 0   // у каждого вывода свой бюджет --fit
+	w.dirUsed = nil // и --per-dir-budget
+	switch t.Format {
+	case FormatText:
+		// добавляем пустую строку для визуального разделения
+		fmt.Fprintln(t.Writer)
+		// выводим содержимое только текстовых файлов
+		w.writeContents(t.Writer, rootName, files)
+	case FormatSQLite:
+		return exportSQLite(w, t.Output, rootName, files)
+	case FormatCAS:
+		return exportCAS(w, t.Output, rootName, files)
+	case FormatRepomix:
+		exportRepomix(w, t.Writer, rootName, files)
+	case FormatGitingest:
+		exportGitingest(w, t.Writer, rootName, files)
+	case FormatJSON:
+		return exportJSON(w, t.Writer, rootName, files)
+	case FormatYAML:
+		exportYAML(w, t.Writer, rootName, files)
+	case FormatTreeJSON:
+		exportTreeJSON(w, t.Writer, rootName)
+	case FormatTreeXML:
+		exportTreeXML(w, t.Writer, rootName)
+	case FormatCSV:
+		// пишется отдельно, после всех выводов (см. Run)
+	case FormatDOT:
+		exportDOT(w, t.Writer, rootName)
+	case FormatMermaid:
+		exportMermaid(w, t.Writer, rootName)
+	case FormatConfigMap, FormatSecret:
+		exportK8s(w, t.Writer, rootName, files, t.Format == FormatSecret)
+	default:
+		return errors.New("unknown format " + t.Format)
+	}
+	return nil
+}
+
+// writeContents печатает содержимое текстовых файлов (этап 2 текстового дампа)
+func (w *walker) writeContents(out io.Writer, rootName string, files []fileInfo) {
+	opts := w.opts
+	// без --group-by все файлы идут одним разделом без подзаголовка
+	groups := []group{{}}
+	for i := range files {
+		groups[0].files = append(groups[0].files, i)
+	}
+	if opts.GroupBy != "" {
+		groups = groupFiles(files, opts.GroupBy, rootName)
+	}
+	sections := 0
+	// с Canonical секции файлов разделены пустой строкой: git diff выравнивает изменения по границам файлов
+	written := false
+	// документация пакетов (--go-doc) идёт перед всеми файлами: там format.Parse её пропускает
+	if opts.GoDoc != "" && w.writeGoDocs(out, rootName, files) {
+		written = true
+		if opts.GroupBy != "" {
+			sections++ // перед первым подзаголовком нужна пустая строка
+		}
+	}
+	gap := func() {
+		if opts.Canonical && written {
+			fmt.Fprintln(out)
+		}
+		written = true
+	}
+	for _, g := range groups {
+		// подзаголовок печатаем перед первым выведенным файлом раздела, чтобы не было пустых разделов
+		headed := g.label == ""
+		heading := func() {
+			if !headed {
+				if sections > 0 {
+					fmt.Fprintln(out)
+				}
+				sections++
+				fmt.Fprintln(out, groupHeading(opts.GroupBy, g.label))
+				fmt.Fprintln(out)
+				headed, written = true, false
+			}
+		}
+		for _, i := range g.files {
+			file := &files[i]
+			// нетекстовые файлы пропускаем, с --binary-preview показываем их начало (см. preview.go)
+			if !file.isText && (opts.BinaryPreview == 0 || file.skip != "" || file.readErr) {
+				continue
+			}
+			if w.expired() {
+				if file.isText {
+					file.skip = decisionDeadline
+				}
+				continue
+			}
+
+			displayPath := format.QuoteName(path.Join(rootName, file.relPath))
+			if !file.isText {
+				data, notes, err := w.binaryPreview(file)
+				if err != nil {
+					fmt.Fprintf(w.log, "Error reading %s: %v\n", w.displayPath(file.relPath), err)
+					w.recordError(file.relPath, err)
+					continue
+				}
+				heading()
+				gap()
+				fmt.Fprintf(out, "%s (%s):\n", displayPath, strings.Join(notes, ", "))
+				fmt.Fprintln(out, "```")
+				fmt.Fprintln(out, string(data))
+				fmt.Fprintln(out, "```")
+				continue
+			}
+
+			data, notes, err := w.content(file)
+			if opts.HeaderStats {
+				notes = append(headerStats(file), notes...)
+			}
+			if opts.FileNotes != nil {
+				notes = append(notes, opts.FileNotes(file.relPath)...)
+			}
+			if file.id != "" {
+				notes = append([]string{"id " + file.id}, notes...)
+			}
+			if err != nil {
+				heading()
+				gap()
+				fmt.Fprintf(out, "%s:\n", displayPath)
+				fmt.Fprintln(out, "```")
+				fmt.Fprintf(out, "Error reading file: %v\n", err)
+				fmt.Fprintln(out, "```")
+				continue
+			}
+			if file.skip != "" {
+				continue
+			}
+			heading()
+			gap()
+			writeTextFile(out, opts, displayPath, file.lang, data, notes)
+		}
+	}
+}
+
+// contentEstimate оценивает по итогам обхода, сколько байт содержимого попадёт в вывод и из скольких файлов:
+// текстовые файлы с учётом MaxFileSize и начала нетекстовых с BinaryPreview; решения этапа 2 (Fit, PerDirTokens) не учтены
+func contentEstimate(opts *Options, files []fileInfo) (int64, int) {
+	var size int64
+	n := 0
+	for _, file := range files {
+		switch {
+		case file.decision() == decisionContent:
+			if opts.MaxFileSize > 0 {
+				size += min(file.size, opts.MaxFileSize)
+			} else {
+				size += file.size
+			}
+		case !file.isText && opts.BinaryPreview > 0 && file.skip == "" && !file.readErr:
+			size += min(file.size, int64(opts.BinaryPreview))
+		default:
+			continue
+		}
+		n++
+	}
+	return size, n
+}
+
+// report подводит итоги по результатам обхода и вывода
+func (w *walker) report(files []fileInfo) Report {
+	r := Report{
+		Dirs:             len(w.dirs),
+		Files:            len(files),
+		Skipped:          make(map[string]int),
+		LangTokens:       make(map[string]int),
+		QuotedNames:      w.quotedNames,
+		QuotedLookalikes: w.quotedLookalikes,
+		Unvisited:        w.unvisited,
+		Errors:           w.errors,
+		Secrets:          w.secrets,
+	}
+	for _, file := range files {
+		if d := file.decision(); d == decisionContent {
+			r.Contents++
+			r.Tokens += file.tokens
+			r.ContentSize += file.outSize
+			r.LangTokens[file.lang] += file.tokens
+		} else {
+			r.Skipped[d]++
+		}
+		if file.denied {
+			r.Denied++
+		}
+	}
+	return r
+}
@@ -0,0 +1,192 @@
+package serializer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"path"
+	"strings"
+	"unicode/utf16"
+)
+
+// ярлыки (--resolve-shortcuts): .url и .lnk из Windows и .desktop из Linux
+// сами по себе они в дампе бесполезны (.lnk — вообще бинарник), поэтому в древе рядом с ярлыком
+// пишется, куда он ведёт: "docs.url [-> "https://example.com"]"; по ссылке при этом ничего не читается
+
+// shortcutTarget возвращает цель ярлыка по имени файла и его началу ("" — не ярлык или цель не найдена)
+func shortcutTarget(name string, head []byte) string {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".url":
+		return iniValue(head, "InternetShortcut", "URL")
+	case ".desktop":
+		// Type=Link ведёт на URL, Type=Application — на команду
+		if target := iniValue(head, "Desktop Entry", "URL"); target != "" {
+			return target
+		}
+		return iniValue(head, "Desktop Entry", "Exec")
+	case ".lnk":
+		return lnkTarget(head)
+	}
+	return ""
+}
+
+// iniValue ищет key в секции section файла в формате INI
+func iniValue(data []byte, section, key string) string {
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	inSection := false
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			inSection = strings.EqualFold(line[1:len(line)-1], section)
+			continue
+		}
+		if k, v, ok := strings.Cut(line, "="); inSection && ok && strings.TrimSpace(k) == key {
+			return strings.TrimSpace(v)
+		}
+	}
+	return ""
+}
+
+// флаги и смещения формата Shell Link (MS-SHLLINK)
+const (
+	lnkHeaderSize      = 0x4c
+	lnkHasIDList       = 0x01
+	lnkHasLinkInfo     = 0x02
+	lnkHasName         = 0x04
+	lnkHasRelativePath = 0x08
+	lnkIsUnicode       = 0x80
+	lnkLocalBasePath   = 0x01 // LinkInfoFlags: есть локальный путь
+	lnkNetworkPath     = 0x02 // LinkInfoFlags: есть сетевой путь
+)
+
+// lnkTarget достаёт путь цели из .lnk: из LinkInfo (локальный или сетевой путь),
+// а если его нет — относительный путь из строковых данных
+func lnkTarget(data []byte) string {
+	le := binary.LittleEndian
+	if len(data) < lnkHeaderSize || le.Uint32(data) != lnkHeaderSize {
+		return ""
+	}
+	flags := le.Uint32(data[0x14:])
+	off := lnkHeaderSize
+	if flags&lnkHasIDList != 0 {
+		if off+2 > len(data) {
+			return ""
+		}
+		off += 2 + int(le.Uint16(data[off:]))
+	}
+	if flags&lnkHasLinkInfo != 0 {
+		if off+0x1c > len(data) {
+			return ""
+		}
+		info := data[off:]
+		size := int(le.Uint32(info))
+		if size > len(info) || size < 0x1c {
+			return ""
+		}
+		info = info[:size]
+		if target := linkInfoPath(info); target != "" {
+			return target
+		}
+		off += size
+	}
+	// строковые данные идут подряд: имя, относительный путь, ...; каждая строка — длина в символах и символы
+	for _, bit := range []uint32{lnkHasName, lnkHasRelativePath} {
+		if flags&bit == 0 {
+			continue
+		}
+		if off+2 > len(data) {
+			return ""
+		}
+		n := int(le.Uint16(data[off:]))
+		off += 2
+		width := 1
+		if flags&lnkIsUnicode != 0 {
+			width = 2
+		}
+		if off+n*width > len(data) {
+			return ""
+		}
+		s := data[off : off+n*width]
+		off += n * width
+		if bit == lnkHasRelativePath {
+			if width == 2 {
+				return utf16String(s)
+			}
+			return string(s)
+		}
+	}
+	return ""
+}
+
+// linkInfoPath собирает путь из структуры LinkInfo: базовый путь плюс общий суффикс
+func linkInfoPath(info []byte) string {
+	le := binary.LittleEndian
+	headerSize := le.Uint32(info[4:])
+	flags := le.Uint32(info[8:])
+	var base string
+	switch {
+	case flags&lnkLocalBasePath != 0:
+		// Unicode-версии смещений есть, только если заголовок длиннее 0x1c
+		if headerSize >= 0x24 && len(info) >= 0x24 {
+			base = cStringUTF16(info, int(le.Uint32(info[0x1c:])))
+		} else {
+			base = cString(info, int(le.Uint32(info[0x10:])))
+		}
+	case flags&lnkNetworkPath != 0:
+		// CommonNetworkRelativeLink: NetNameOffset лежит по смещению 8 внутри структуры
+		link := int(le.Uint32(info[0x14:]))
+		if link+12 > len(info) {
+			return ""
+		}
+		base = cString(info, link+int(le.Uint32(info[link+8:])))
+	default:
+		return ""
+	}
+	var suffix string
+	if headerSize >= 0x24 && len(info) >= 0x24 {
+		suffix = cStringUTF16(info, int(le.Uint32(info[0x20:])))
+	} else {
+		suffix = cString(info, int(le.Uint32(info[0x18:])))
+	}
+	if base == "" {
+		return ""
+	}
+	if suffix != "" && !strings.HasSuffix(base, `\`) {
+		base += `\`
+	}
+	return base + suffix
+}
+
+// cString читает строку, заканчивающуюся нулём, со смещения off
+func cString(data []byte, off int) string {
+	if off <= 0 || off >= len(data) {
+		return ""
+	}
+	s, _, _ := bytes.Cut(data[off:], []byte{0})
+	return string(s)
+}
+
+// cStringUTF16 читает строку UTF-16LE, заканчивающуюся нулём, со смещения off
+func cStringUTF16(data []byte, off int) string {
+	if off <= 0 || off >= len(data) {
+		return ""
+	}
+	var units []uint16
+	for i := off; i+1 < len(data); i += 2 {
+		u := binary.LittleEndian.Uint16(data[i:])
+		if u == 0 {
+			break
+		}
+		units = append(units, u)
+	}
+	return string(utf16.Decode(units))
+}
+
+// utf16String декодирует строку UTF-16LE без завершающего нуля
+func utf16String(data []byte) string {
+	units := make([]uint16, len(data)/2)
+	for i := range units {
+		units[i] = binary.LittleEndian.Uint16(data[2*i:])
+	}
+	return string(utf16.Decode(units))
+}
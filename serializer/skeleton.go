@@ -0,0 +1,51 @@
+package serializer
+
+import (
+	"path"
+	"sort"
+	"strings"
+)
+
+// скелет (--skeleton N): древо выводится целиком, а содержимое — только у N файлов каждой директории,
+// чтобы за один короткий дамп понять, как устроен незнакомый огромный репозиторий
+// выбираются сначала README, затем сценарии сборки и конфигурация (go.mod, package.json), код,
+// документация и всё остальное; внутри одной очереди — файлы поменьше; остальные получают решение skeleton
+
+// skeletonRank возвращает очередь файла при выборе для скелета: чем меньше, тем раньше
+func skeletonRank(file fileInfo) int {
+	if strings.HasPrefix(strings.ToLower(path.Base(file.relPath)), "readme") {
+		return 0
+	}
+	switch file.class {
+	case ClassBuild, ClassConfig:
+		return 1
+	case ClassSource:
+		return 2
+	case ClassDocs:
+		return 3
+	}
+	return 4
+}
+
+// pickSkeleton оставляет содержимое у Options.Skeleton файлов директории n, остальным ставит решение skeleton
+func (w *walker) pickSkeleton(n *treeNode) {
+	var candidates []*treeNode
+	for _, child := range n.children {
+		if !child.isDir && child.file.decision() == decisionContent {
+			candidates = append(candidates, child)
+		}
+	}
+	if len(candidates) <= w.opts.Skeleton {
+		return
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i].file, candidates[j].file
+		if ra, rb := skeletonRank(a), skeletonRank(b); ra != rb {
+			return ra < rb
+		}
+		return a.size < b.size
+	})
+	for _, child := range candidates[w.opts.Skeleton:] {
+		child.file.skip = decisionSkeleton
+	}
+}
@@ -0,0 +1,245 @@
+package serializer
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/asquebay/directory-serialization/detector"
+)
+
+// драйвер SQLite пакет сам не подключает: modernc.org/sqlite собирается не на всех платформах, и его импорт
+// сломал бы сборку пакета целиком; программа подключает драйвер database/sql с именем "sqlite" сама
+// (CLI — modernc.org/sqlite там, где он собирается, см. sqlite_driver.go в корне), иначе формата sqlite нет
+
+// SQLiteDriver — имя драйвера database/sql, через который пишется --format sqlite
+const SQLiteDriver = "sqlite"
+
+// схема базы для --format sqlite
+// пример запроса: SELECT path FROM files WHERE is_text AND encoding != 'UTF-8'
+// contents.content записан способом из metadata.content_encoding (raw, escaped или base64)
+// files.sha256 — хеш алгоритмом из metadata.hash_algorithm (--hash-algo; столбец назван по алгоритму по умолчанию)
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS metadata (
+	key   TEXT PRIMARY KEY,
+	value TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS dirs (
+	path TEXT PRIMARY KEY
+);
+CREATE TABLE IF NOT EXISTS files (
+	path       TEXT PRIMARY KEY,
+	size       INTEGER NOT NULL,
+	sha256     TEXT,
+	encoding   TEXT,
+	is_text    INTEGER NOT NULL,
+	decision   TEXT NOT NULL,
+	fuzzy_hash TEXT
+);
+CREATE TABLE IF NOT EXISTS contents (
+	path    TEXT PRIMARY KEY,
+	notes   TEXT NOT NULL,
+	content TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS errors (
+	path    TEXT NOT NULL,
+	message TEXT NOT NULL
+);
+`
+
+// exportSQLite пишет снимок директории в базу SQLite
+// если база уже есть, обновляет её: содержимое перечитывается только у изменившихся файлов
+// (или у всех, если поменялись настройки, влияющие на содержимое), исчезнувшие файлы удаляются
+func exportSQLite(w *walker, dbPath, rootName string, files []fileInfo) error {
+	if !slices.Contains(sql.Drivers(), SQLiteDriver) {
+		return errors.New(`format sqlite needs a database/sql driver registered as "sqlite" (import modernc.org/sqlite), which this build lacks`)
+	}
+	db, err := sql.Open(SQLiteDriver, dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// настройки, от которых зависит содержимое: если они поменялись, старое содержимое не годится
+	// строка хранится в базе, поэтому выражение пишется своим шаблоном, а не тем, как печатается *regexp.Regexp
+	contentMatch := ""
+	if w.opts.ContentMatch != nil {
+		contentMatch = w.opts.ContentMatch.String()
+	}
+	contentOptions := fmt.Sprintf("head=%d max-file-size=%d content-match=%q content-encoding=%s",
+		w.opts.HeadLines, w.opts.MaxFileSize, contentMatch, w.opts.ContentEncoding)
+	if w.opts.SummarizeDocs > 0 {
+		contentOptions += fmt.Sprintf(" summarize-docs=%d", w.opts.SummarizeDocs)
+	}
+	if w.opts.CheckEncoding {
+		contentOptions += " check-encoding"
+	}
+	if w.opts.Wrap > 0 {
+		contentOptions += fmt.Sprintf(" wrap=%d", w.opts.Wrap)
+	}
+	if w.opts.MaskEnv {
+		contentOptions += " mask-env"
+	}
+	if w.opts.KeepMinified {
+		contentOptions += " keep-minified"
+	}
+	if w.opts.Detector.Sampling == detector.SampleSpread {
+		// от выборки зависят кодировка и пометка encoding suspect
+		contentOptions += fmt.Sprintf(" detect-sampling=spread:%d", w.opts.Detector.SpreadFrom)
+	}
+	var prevOptions string
+	tx.QueryRow(`SELECT value FROM metadata WHERE key = 'content_options'`).Scan(&prevOptions)
+	// с --fit, --per-dir-budget и --skeleton решение о файле зависит от соседей, так что прежние решения не годятся
+	// свои преобразования по строке настроек не опишешь, с ними тоже перечитываем всё
+	reuse := prevOptions == contentOptions && w.opts.Fit == 0 && w.dirLimit() == 0 && w.opts.Skeleton == 0 && len(w.opts.Transformers) == 0
+
+	// что уже лежит в базе: хеш и решение по каждому файлу
+	type prevFile struct{ sha256, decision string }
+	prev := make(map[string]prevFile)
+	rows, err := tx.Query(`SELECT path, COALESCE(sha256, ''), decision FROM files`)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var path string
+		var p prevFile
+		if err := rows.Scan(&path, &p.sha256, &p.decision); err != nil {
+			rows.Close()
+			return err
+		}
+		prev[path] = p
+	}
+	rows.Close()
+
+	upsertFile, err := tx.Prepare(`INSERT INTO files (path, size, sha256, encoding, is_text, decision, fuzzy_hash)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(path) DO UPDATE SET size = excluded.size, sha256 = excluded.sha256, encoding = excluded.encoding,
+			is_text = excluded.is_text, decision = excluded.decision, fuzzy_hash = excluded.fuzzy_hash`)
+	if err != nil {
+		return err
+	}
+	defer upsertFile.Close()
+	upsertContent, err := tx.Prepare(`INSERT INTO contents (path, notes, content) VALUES (?, ?, ?)
+		ON CONFLICT(path) DO UPDATE SET notes = excluded.notes, content = excluded.content`)
+	if err != nil {
+		return err
+	}
+	defer upsertContent.Close()
+	deleteContent, err := tx.Prepare(`DELETE FROM contents WHERE path = ?`)
+	if err != nil {
+		return err
+	}
+	defer deleteContent.Close()
+
+	reused := 0
+	for i := range files {
+		file := &files[i]
+		path := file.relPath
+		p, known := prev[path]
+		delete(prev, path) // всё, что останется в prev, на диске больше нет
+
+		if file.isText {
+			w.ensureHash(file)
+			if reuse && known && file.hash != "" && p.sha256 == file.hash {
+				// файл не менялся: содержимое в базе актуально, повторяем прежнее решение
+				if p.decision != decisionContent {
+					file.skip = p.decision
+				}
+				reused++
+			} else if w.expired() {
+				file.skip = decisionDeadline
+				if _, err := deleteContent.Exec(path); err != nil {
+					return err
+				}
+			} else {
+				data, notes, err := w.content(file)
+				switch {
+				case err != nil || file.skip != "":
+					_, err = deleteContent.Exec(path)
+				default:
+					_, err = upsertContent.Exec(path, strings.Join(notes, ", "), encodeContent(data, w.opts.ContentEncoding))
+				}
+				if err != nil {
+					return err
+				}
+			}
+		} else if _, err := deleteContent.Exec(path); err != nil {
+			return err
+		}
+
+		var sha, encoding, fuzzy any
+		if file.hash != "" {
+			sha = file.hash
+		}
+		if file.encoding != "" {
+			encoding = file.encoding
+		}
+		if file.fuzzy != "" {
+			fuzzy = file.fuzzy
+		}
+		if _, err := upsertFile.Exec(path, file.size, sha, encoding, file.isText, file.decision(), fuzzy); err != nil {
+			return err
+		}
+	}
+
+	for path := range prev {
+		if _, err := tx.Exec(`DELETE FROM files WHERE path = ?`, path); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(`DELETE FROM contents WHERE path = ?`, path); err != nil {
+			return err
+		}
+	}
+
+	// директории и ошибки дешевле переписать целиком
+	if _, err := tx.Exec(`DELETE FROM dirs; DELETE FROM errors`); err != nil {
+		return err
+	}
+	for _, dir := range w.dirs {
+		if _, err := tx.Exec(`INSERT INTO dirs (path) VALUES (?)`, dir); err != nil {
+			return err
+		}
+	}
+	for _, e := range w.errors {
+		if _, err := tx.Exec(`INSERT INTO errors (path, message) VALUES (?, ?)`, e.Path, e.Err.Error()); err != nil {
+			return err
+		}
+	}
+
+	meta := map[string]string{
+		"root":             rootName,
+		"generated_at":     w.opts.now().Format(time.RFC3339),
+		"content_options":  contentOptions,
+		"content_encoding": w.opts.ContentEncoding,
+		"hash_algorithm":   w.opts.hashAlgo(),
+		"preamble":         w.opts.Preamble,
+		"postamble":        w.opts.Postamble,
+	}
+	for key, value := range meta {
+		if _, err := tx.Exec(`INSERT INTO metadata (key, value) VALUES (?, ?)
+			ON CONFLICT(key) DO UPDATE SET value = excluded.value`, key, value); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	if reused > 0 {
+		fmt.Fprintf(w.log, "%d unchanged file(s) reused from %s\n", reused, dbPath)
+	}
+	return nil
+}
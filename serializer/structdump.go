@@ -0,0 +1,167 @@
+package serializer
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"unicode/utf8"
+)
+
+// структурированные форматы — древо и содержимое одним документом, для программ, которым разбирать текстовый дамп
+// неудобно, и для правки дампа руками перед тем, как передать его дальше:
+//
+//	--format json — JSON
+//	--format yaml — YAML с теми же полями, содержимое — литеральными блоками (|)
+//
+//	{
+//	  "root": {"name": "project", "type": "directory", "path": ".", "children": [
+//	    {"name": "main.go", "type": "file", "path": "main.go", "size": 120, "language": "go",
+//	     "encoding": "UTF-8", "decision": "content", "content": "package main\n..."},
+//	    ...
+//	  ]}
+//	}
+//
+// у файлов без содержимого в выводе (бинарные, пропущенные фильтрами содержимого) нет поля content,
+// а почему его нет — видно по decision (те же значения, что в манифесте); содержимое не в UTF-8
+// и содержимое с --content-encoding base64 записывается в base64 с "content_encoding": "base64"
+
+// dumpNode — элемент древа в --format json и yaml
+type dumpNode struct {
+	Name string `json:"name"`
+	Type string `json:"type"` // "directory" или "file"
+	Path string `json:"path"` // путь от корня через "/"; у корня "."
+	// RawPath — исходные байты пути (в JSON это base64), если путь не в UTF-8, как в манифесте
+	RawPath []byte `json:"raw_path,omitempty"`
+	// Target — куда ведёт соединение Windows, по которому пришли в директорию (с --follow-junctions)
+	Target string `json:"target,omitempty"`
+	// Unvisited — в директорию не заходили (--deadline)
+	Unvisited bool        `json:"unvisited,omitempty"`
+	Children  []*dumpNode `json:"children,omitempty"`
+
+	Size            int64   `json:"size,omitempty"`
+	Language        string  `json:"language,omitempty"`
+	Encoding        string  `json:"encoding,omitempty"` // кодировка файла на диске, определённая детектором
+	Decision        string  `json:"decision,omitempty"`
+	ContentEncoding string  `json:"content_encoding,omitempty"` // как записано content, если не как есть: base64
+	Content         *string `json:"content,omitempty"`
+}
+
+// jsonDump — документ --format json
+type jsonDump struct {
+	Preamble  string    `json:"preamble,omitempty"`
+	Root      *dumpNode `json:"root"`
+	Postamble string    `json:"postamble,omitempty"`
+}
+
+// dumpTree собирает древо с содержимым файлов для структурированных форматов
+func (w *walker) dumpTree(rootName string, files []fileInfo) *dumpNode {
+	// решения (пропуск по --fit, --deadline и т.д.) записываются в files, а не в копии в древе
+	byPath := make(map[string]*fileInfo, len(files))
+	for i := range files {
+		byPath[files[i].relPath] = &files[i]
+	}
+	var build func(n *treeNode, name, relPath string) *dumpNode
+	build = func(n *treeNode, name, relPath string) *dumpNode {
+		node := &dumpNode{Name: name, Path: relPath}
+		if !utf8.ValidString(relPath) {
+			node.RawPath = []byte(relPath)
+		}
+		if n.isDir {
+			node.Type, node.Target, node.Unvisited = "directory", n.junction, n.unvisited
+			for _, c := range n.children {
+				node.Children = append(node.Children, build(c, c.name, c.relPath))
+			}
+			return node
+		}
+		file, ok := byPath[n.relPath]
+		if !ok {
+			file = &n.file
+		}
+		node.Type = "file"
+		data, ok := w.bundleContent(file)
+		node.Size, node.Language, node.Encoding, node.Decision = file.size, file.lang, file.encoding, file.decision()
+		if ok {
+			content := string(data)
+			if w.opts.ContentEncoding == ContentBase64 || !utf8.Valid(data) {
+				content, node.ContentEncoding = base64.StdEncoding.EncodeToString(data), ContentBase64
+			}
+			node.Content = &content
+		}
+		return node
+	}
+	return build(w.treeRoot, rootName, ".")
+}
+
+// exportJSON пишет древо с содержимым файлов одним JSON-документом
+func exportJSON(w *walker, out io.Writer, rootName string, files []fileInfo) error {
+	enc := json.NewEncoder(out)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	return enc.Encode(jsonDump{
+		Preamble:  w.opts.Preamble,
+		Root:      w.dumpTree(rootName, files),
+		Postamble: w.opts.Postamble,
+	})
+}
+
+// exportYAML пишет древо с содержимым файлов одним YAML-документом с теми же полями, что у JSON
+func exportYAML(w *walker, out io.Writer, rootName string, files []fileInfo) {
+	root := w.dumpTree(rootName, files)
+	if w.opts.Preamble != "" {
+		fmt.Fprintf(out, "preamble: %s", yamlText([]byte(w.opts.Preamble), "  "))
+	}
+	fmt.Fprintln(out, "root:")
+	writeYAMLNode(out, root, "  ", "  ")
+	if w.opts.Postamble != "" {
+		fmt.Fprintf(out, "postamble: %s", yamlText([]byte(w.opts.Postamble), "  "))
+	}
+}
+
+// writeYAMLNode пишет элемент древа отображением YAML; first — отступ первой строки ("- " у элементов списка),
+// indent — остальных
+func writeYAMLNode(out io.Writer, n *dumpNode, first, indent string) {
+	field := func(key, value string) {
+		fmt.Fprintf(out, "%s%s: %s\n", first, key, value)
+		first = indent
+	}
+	field("name", yamlScalar(n.Name))
+	field("type", n.Type)
+	field("path", yamlScalar(n.Path))
+	if n.RawPath != nil {
+		field("raw_path", yamlScalar(base64.StdEncoding.EncodeToString(n.RawPath)))
+	}
+	if n.Target != "" {
+		field("target", yamlScalar(n.Target))
+	}
+	if n.Unvisited {
+		field("unvisited", "true")
+	}
+	if n.Type == "file" {
+		if n.Size != 0 {
+			field("size", strconv.FormatInt(n.Size, 10))
+		}
+		if n.Language != "" {
+			field("language", yamlScalar(n.Language))
+		}
+		if n.Encoding != "" {
+			field("encoding", yamlScalar(n.Encoding))
+		}
+		field("decision", yamlScalar(n.Decision))
+		switch {
+		case n.Content == nil:
+		case n.ContentEncoding != "":
+			field("content_encoding", n.ContentEncoding)
+			field("content", yamlScalar(*n.Content))
+		default:
+			fmt.Fprintf(out, "%scontent: %s", indent, yamlText([]byte(*n.Content), indent+"  "))
+		}
+	}
+	if len(n.Children) > 0 {
+		fmt.Fprintf(out, "%schildren:\n", indent)
+		for _, c := range n.Children {
+			writeYAMLNode(out, c, indent+"  - ", indent+"    ")
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package serializer
+
+import (
+	"io"
+
+	"github.com/asquebay/directory-serialization/detector"
+)
+
+// с --check-encoding текстовые файлы проверяются целиком: кодировка угадывается по первым detector.SampleSize байтам,
+// а склеенный из разных кодировок или оборванный посреди символа файл видно только по остальной части
+// такие файлы помечаются в древе [encoding suspect], а в заголовке содержимого пишется, что не так
+
+// checkEncoding проверяет кодировку всего файла; data — уже прочитанное начало, complete — прочитан ли файл целиком
+func (w *walker) checkEncoding(file *fileInfo, data []byte, complete bool) (string, error) {
+	check := detector.NewEncodingCheck(file.encoding)
+	if complete {
+		check.Write(data)
+		return check.Suspect(), nil
+	}
+	f, err := w.openFile(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if _, err := io.Copy(check, io.LimitReader(f, file.limit)); err != nil {
+		return "", err
+	}
+	return check.Suspect(), nil
+}
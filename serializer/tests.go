@@ -0,0 +1,66 @@
+package serializer
+
+import (
+	"path"
+	"strings"
+)
+
+// тесты и их данные по соглашениям языков (--tests): «код без тестов» и «только тесты» — два самых частых
+// ручных отбора перед тем, как отдать репозиторий LLM
+// тестом считается файл с тестовым именем (foo_test.go, test_foo.py, foo.spec.ts, FooTest.java)
+// или любой файл внутри тестовой директории (tests/, __tests__/, testdata/, fixtures/)
+
+// значения Options.Tests
+const (
+	TestsInclude = "include" // тесты выводятся, как обычные файлы (по умолчанию)
+	TestsExclude = "exclude" // тестов нет ни в древе, ни в содержимом; тестовые директории не обходятся
+	TestsOnly    = "only"    // только тесты (директории древа остаются, как и с Filter)
+)
+
+// testDirs — имена тестовых директорий (без учёта регистра: Tests/ у Swift, Test/ у .NET)
+var testDirs = map[string]bool{
+	"test": true, "tests": true, "__tests__": true, "spec": true, "specs": true,
+	"testdata": true, "test-data": true, "test_data": true, "fixtures": true, "__fixtures__": true,
+	"__mocks__": true, "__snapshots__": true,
+}
+
+// testSuffixes — окончания имён тестовых файлов
+var testSuffixes = []string{
+	"_test.go", "_test.py", "_test.rb", "_spec.rb", "_test.exs", "_test.dart", "_test.c", "_test.cc", "_test.cpp",
+	"_unittest.cc", "_unittest.cpp", "Test.java", "Tests.java", "IT.java", "Test.kt", "Tests.kt", "Test.scala",
+	"Spec.scala", "Test.php", "Tests.cs", "Test.cs", "Tests.swift", "Test.swift",
+}
+
+// testInfixes — части имён JS/TS-тестов перед расширением: foo.test.js, foo.spec.tsx, foo.e2e-spec.ts
+var testInfixes = []string{".test.", ".spec.", ".e2e-spec.", ".e2e."}
+
+// isTestDir сообщает, что директория с таким именем — тестовая (и проекты тестов .NET вида MyApp.Tests)
+func isTestDir(name string) bool {
+	lower := strings.ToLower(name)
+	return testDirs[lower] || strings.HasSuffix(lower, ".tests")
+}
+
+// isTestPath сообщает, что файл relPath (от корня через "/") — тест или данные тестов
+func isTestPath(relPath string) bool {
+	for dir := path.Dir(relPath); dir != "."; dir = path.Dir(dir) {
+		if isTestDir(path.Base(dir)) {
+			return true
+		}
+	}
+	name := path.Base(relPath)
+	if strings.HasPrefix(name, "test_") && strings.HasSuffix(name, ".py") || name == "conftest.py" {
+		return true
+	}
+	for _, suffix := range testSuffixes {
+		// у FooTest.java граница — заглавная буква, у foo_test.go — подчёркивание; без неё Test.java — не тест
+		if strings.HasSuffix(name, suffix) && len(name) > len(suffix) {
+			return true
+		}
+	}
+	for _, infix := range testInfixes {
+		if strings.Contains(name, infix) {
+			return true
+		}
+	}
+	return false
+}
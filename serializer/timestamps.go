@@ -0,0 +1,33 @@
+package serializer
+
+import "time"
+
+// форматы времени файлов в манифесте (--mtime-format)
+const (
+	MtimeUnix    = "unix"    // секунды с начала эпохи, числом
+	MtimeISO8601 = "iso8601" // строка RFC 3339 в UTC с точностью до секунды
+)
+
+// timestamp записывает время файла для манифеста в формате MtimeFormat (по умолчанию iso8601)
+// с SourceDate время берётся не позже него (как --clamp-mtime у tar), чтобы файлы, тронутые сборкой,
+// не меняли дамп от запуска к запуску; нулевое время не пишется
+func (o *Options) timestamp(t time.Time) any {
+	if t.IsZero() {
+		return nil
+	}
+	if !o.SourceDate.IsZero() && t.After(o.SourceDate) {
+		t = o.SourceDate
+	}
+	if o.MtimeFormat == MtimeUnix {
+		return t.Unix()
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// now — момент создания дампа для метаданных (имя снимка cas, generated_at в sqlite): SourceDate, если задано
+func (o *Options) now() time.Time {
+	if !o.SourceDate.IsZero() {
+		return o.SourceDate.UTC()
+	}
+	return time.Now().UTC()
+}
@@ -0,0 +1,60 @@
+package serializer
+
+import (
+	"math"
+	"unicode/utf8"
+)
+
+// оценка размера в токенах без словарей токенизатора: для --chunk-tokens, --fit и --stats
+// точность — в пределах десятков процентов, зато работает офлайн и мгновенно;
+// если нужен настоящий токенизатор модели, его можно подключить через Options.Tokens
+
+// TokenEstimator оценивает, сколько токенов займёт текст на языке langID (ID из пакета lang, "" — неизвестен)
+type TokenEstimator interface {
+	EstimateTokens(data []byte, langID string) int
+}
+
+// DefaultCharsPerToken — сколько ASCII-символов в среднем приходится на токен, если поправки для языка нет
+const DefaultCharsPerToken = 4.0
+
+// charsPerToken — поправки по языкам: в коде много пунктуации и коротких идентификаторов,
+// поэтому токены короче, чем в прозе; в JSON, CSV и go.sum текст дробится ещё мельче
+var charsPerToken = map[string]float64{
+	"text": 4.2, "markdown": 4.2, "rst": 4.2, "latex": 3.6,
+	"go": 3.3, "python": 3.5, "javascript": 3.3, "typescript": 3.3, "jsx": 3.2, "tsx": 3.2,
+	"java": 3.6, "kotlin": 3.5, "scala": 3.4, "csharp": 3.5, "c": 3.2, "cpp": 3.1, "objectivec": 3.3,
+	"rust": 3.1, "swift": 3.4, "ruby": 3.5, "php": 3.3, "perl": 3.0, "lua": 3.4, "r": 3.3,
+	"sh": 3.2, "bash": 3.2, "fish": 3.2, "powershell": 3.4, "batch": 3.3, "make": 3.3, "dockerfile": 3.6,
+	"html": 3.0, "css": 3.0, "scss": 3.0, "less": 3.0, "sql": 3.4, "graphql": 3.4, "protobuf": 3.5,
+	"json": 2.9, "yaml": 3.3, "toml": 3.2, "ini": 3.3, "hcl": 3.3, "nix": 3.2, "xml": 3.0,
+	"csv": 2.5, "tsv": 2.5, "go-sum": 1.8, "go-mod": 3.0,
+}
+
+// HeuristicEstimator — оценка «символы / 4» с поправкой на язык;
+// не-ASCII символы (кириллица, CJK) токенизаторы режут мельче, их считаем по два на токен
+type HeuristicEstimator struct {
+	CharsPerToken map[string]float64 // свои поправки по ID языка (nil — встроенные)
+}
+
+func (e HeuristicEstimator) EstimateTokens(data []byte, langID string) int {
+	table := e.CharsPerToken
+	if table == nil {
+		table = charsPerToken
+	}
+	cpt := table[langID]
+	if cpt <= 0 {
+		cpt = DefaultCharsPerToken
+	}
+	ascii, other := 0, 0
+	for i := 0; i < len(data); {
+		if data[i] < utf8.RuneSelf {
+			ascii++
+			i++
+			continue
+		}
+		_, size := utf8.DecodeRune(data[i:])
+		i += size
+		other++
+	}
+	return int(math.Ceil(float64(ascii)/cpt)) + (other+1)/2
+}
@@ -0,0 +1,140 @@
+package serializer
+
+import (
+	"fmt"
+	"path"
+
+	"github.com/asquebay/directory-serialization/format"
+)
+
+// содержимое текстового файла после чтения проходит цепочку преобразований:
+// сначала маскировка значений в dotenv (--mask-env), затем пользовательские (Options.Transformers — вырезание комментариев, редактирование секретов,
+// развёртка блокнотов), затем встроенные: --summarize-docs, --head, --max-file-size, --wrap
+// каждое преобразование может оставить пометки для заголовка файла ("truncated: ...")
+
+// TransformFile — что известно о файле, содержимое которого преобразуется
+type TransformFile struct {
+	Path string // путь относительно корня через "/"
+	Lang string // ID языка ("" — неизвестен)
+	Size int64  // размер прочитанного файла (содержимое к этому шагу могло уже измениться)
+	// Encoding — кодировка файла, как в манифесте ("" — не определена); по ней обрезка находит границы символов
+	Encoding string
+}
+
+// Transformer — шаг обработки содержимого текстового файла перед выводом
+type Transformer interface {
+	// Match сообщает, применять ли шаг к файлу
+	Match(f TransformFile) bool
+	// Transform возвращает новое содержимое и пометки для заголовка (можно без них)
+	Transform(f TransformFile, data []byte) ([]byte, []string, error)
+}
+
+// TransformFunc — преобразование без условия; ForFiles делает из него Transformer
+type TransformFunc func(f TransformFile, data []byte) ([]byte, []string, error)
+
+// ForFiles применяет fn к файлам, подходящим под шаблон path.Match: он сверяется и с путём целиком,
+// и с именем файла, так что "*.go" ловит Go-файлы на любой глубине, а "docs/*.md" — только в docs
+func ForFiles(pattern string, fn TransformFunc) Transformer {
+	return patternTransformer{pattern: pattern, fn: fn}
+}
+
+type patternTransformer struct {
+	pattern string
+	fn      TransformFunc
+}
+
+func (t patternTransformer) Match(f TransformFile) bool {
+	if ok, _ := path.Match(t.pattern, f.Path); ok {
+		return true
+	}
+	ok, _ := path.Match(t.pattern, path.Base(f.Path))
+	return ok
+}
+
+func (t patternTransformer) Transform(f TransformFile, data []byte) ([]byte, []string, error) {
+	return t.fn(f, data)
+}
+
+// stepTransformer — встроенный шаг с условием в виде функции
+type stepTransformer struct {
+	match func(f TransformFile) bool
+	fn    TransformFunc
+}
+
+func (t stepTransformer) Match(f TransformFile) bool {
+	return t.match == nil || t.match(f)
+}
+
+func (t stepTransformer) Transform(f TransformFile, data []byte) ([]byte, []string, error) {
+	return t.fn(f, data)
+}
+
+// pipeline собирает цепочку: маскировку dotenv, пользовательские шаги, затем встроенные обрезка и перенос строк
+func (opts *Options) pipeline() []Transformer {
+	var steps []Transformer
+	// значения в dotenv маскируем раньше всего, чтобы их не увидел ни один следующий шаг
+	if opts.MaskEnv {
+		steps = append(steps, stepTransformer{
+			match: func(f TransformFile) bool { return isDotenv(f.Path, f.Lang) },
+			fn:    MaskEnv,
+		})
+	}
+	steps = append(steps, opts.Transformers...)
+	// документы сокращаются до --summarize-docs строк, если --head не режет их ещё сильнее
+	if n := opts.SummarizeDocs; n > 0 && (opts.HeadLines == 0 || n < opts.HeadLines) {
+		steps = append(steps, stepTransformer{
+			match: func(f TransformFile) bool { return isDoc(f.Path, f.Lang) },
+			fn: func(f TransformFile, data []byte) ([]byte, []string, error) {
+				cut, truncated := headLines(data, n, f.Encoding)
+				if !truncated {
+					return data, nil, nil
+				}
+				total := countLines(data, f.Encoding)
+				note := fmt.Sprintf("summarized: first %d of %d lines", n, total)
+				return append(cut[:len(cut):len(cut)], format.ElisionLine(total-n)+"\n"...), []string{note}, nil
+			},
+		})
+	}
+	// обрезка: сначала по строкам, затем по байтам; о ней сообщаем в заголовке, а не внутри содержимого
+	if n := opts.HeadLines; n > 0 {
+		steps = append(steps, stepTransformer{fn: Head(n)})
+	}
+	if max := opts.MaxFileSize; max > 0 {
+		steps = append(steps, stepTransformer{fn: func(f TransformFile, data []byte) ([]byte, []string, error) {
+			cut, truncated := truncateBytes(data, int(max), f.Encoding)
+			if !truncated {
+				return data, nil, nil
+			}
+			return cut, []string{fmt.Sprintf("truncated: %d of %d bytes", len(cut), f.Size)}, nil
+		}})
+	}
+	// длинные строки переносим уже после обрезки, чтобы --head и --max-file-size считали исходные строки
+	if width := opts.Wrap; width > 0 {
+		steps = append(steps, stepTransformer{fn: func(_ TransformFile, data []byte) ([]byte, []string, error) {
+			wrapped, ok := wrapLines(data, width)
+			if !ok {
+				return data, nil, nil
+			}
+			return wrapped, []string{fmt.Sprintf("wrapped at %d columns", width)}, nil
+		}})
+	}
+	return steps
+}
+
+// transform пропускает содержимое через цепочку w.steps
+func (w *walker) transform(file *fileInfo, data []byte) ([]byte, []string, error) {
+	f := TransformFile{Path: file.relPath, Lang: file.lang, Size: file.size, Encoding: file.encoding}
+	var notes []string
+	for _, step := range w.steps {
+		if !step.Match(f) {
+			continue
+		}
+		out, stepNotes, err := step.Transform(f, data)
+		if err != nil {
+			return nil, nil, err
+		}
+		data = out
+		notes = append(notes, stepNotes...)
+	}
+	return data, notes, nil
+}
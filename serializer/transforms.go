@@ -0,0 +1,195 @@
+package serializer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// готовые преобразования для Options.Transformers (и для --transform в CLI)
+
+// Head оставляет первые n строк (то же, что --head, но только для выбранных файлов)
+func Head(n int) TransformFunc {
+	return func(f TransformFile, data []byte) ([]byte, []string, error) {
+		cut, truncated := headLines(data, n, f.Encoding)
+		if !truncated {
+			return data, nil, nil
+		}
+		return cut, []string{fmt.Sprintf("truncated: %d of %d lines", n, countLines(data, f.Encoding))}, nil
+	}
+}
+
+// Redact заменяет совпадения re на "[REDACTED]" (ключи API, пароли в конфигах)
+func Redact(re *regexp.Regexp) TransformFunc {
+	return Replace(re, "[REDACTED]", "redacted")
+}
+
+// Replace заменяет совпадения re на repl (с $1 и т.п., как regexp.ReplaceAll); note — слово для пометки в заголовке
+func Replace(re *regexp.Regexp, repl, note string) TransformFunc {
+	return func(_ TransformFile, data []byte) ([]byte, []string, error) {
+		n := len(re.FindAllIndex(data, -1))
+		if n == 0 {
+			return data, nil, nil
+		}
+		return re.ReplaceAll(data, []byte(repl)), []string{fmt.Sprintf("%s: %d match(es)", note, n)}, nil
+	}
+}
+
+// lineComments — чем начинается однострочный комментарий в языке
+var lineComments = map[string]string{
+	"go": "//", "c": "//", "cpp": "//", "objectivec": "//", "java": "//", "kotlin": "//", "scala": "//", "csharp": "//",
+	"javascript": "//", "typescript": "//", "jsx": "//", "tsx": "//", "rust": "//", "swift": "//", "php": "//", "protobuf": "//",
+	"python": "#", "ruby": "#", "perl": "#", "r": "#", "sh": "#", "bash": "#", "zsh": "#", "fish": "#", "powershell": "#",
+	"yaml": "#", "toml": "#", "make": "#", "makefile": "#", "dockerfile": "#", "nix": "#", "hcl": "#", "terraform": "#", "dotenv": "#",
+	"sql": "--", "lua": "--", "haskell": "--",
+	"ini": ";",
+}
+
+// keptComments — комментарии, которые на самом деле директивы: их не вырезаем
+var keptComments = []string{"#!", "//go:", "// +build", "# -*-", "#region", "#endregion"}
+
+// StripComments вырезает строки, целиком состоящие из однострочного комментария (по языку файла);
+// комментарии в конце строки с кодом не трогает: без разбора синтаксиса их не отличить от "//" внутри строки
+func StripComments(f TransformFile, data []byte) ([]byte, []string, error) {
+	prefix, ok := lineComments[f.Lang]
+	if !ok {
+		return data, nil, nil
+	}
+	var out bytes.Buffer
+	stripped := 0
+	for _, line := range bytes.SplitAfter(data, []byte("\n")) {
+		trimmed := strings.TrimSpace(string(line))
+		if strings.HasPrefix(trimmed, prefix) && !keptComment(trimmed) {
+			stripped++
+			continue
+		}
+		out.Write(line)
+	}
+	if stripped == 0 {
+		return data, nil, nil
+	}
+	return out.Bytes(), []string{fmt.Sprintf("stripped: %d comment line(s)", stripped)}, nil
+}
+
+func keptComment(line string) bool {
+	for _, kept := range keptComments {
+		if strings.HasPrefix(line, kept) {
+			return true
+		}
+	}
+	return false
+}
+
+// FlattenNotebook разворачивает блокнот Jupyter (.ipynb) в текст в духе jupytext:
+// ячейки идут подряд под маркерами "# %%" и "# %% [markdown]", выводы ячеек и метаданные отбрасываются
+func FlattenNotebook(_ TransformFile, data []byte) ([]byte, []string, error) {
+	var nb struct {
+		Cells []struct {
+			Type   string          `json:"cell_type"`
+			Source json.RawMessage `json:"source"`
+		} `json:"cells"`
+	}
+	if err := json.Unmarshal(data, &nb); err != nil {
+		return nil, nil, fmt.Errorf("not a notebook: %w", err)
+	}
+	var out bytes.Buffer
+	for i, cell := range nb.Cells {
+		// source бывает строкой или массивом строк
+		var source string
+		var lines []string
+		if err := json.Unmarshal(cell.Source, &lines); err == nil {
+			source = strings.Join(lines, "")
+		} else if err := json.Unmarshal(cell.Source, &source); err != nil {
+			return nil, nil, fmt.Errorf("cell %d: bad source", i+1)
+		}
+		if i > 0 {
+			out.WriteString("\n")
+		}
+		switch cell.Type {
+		case "code":
+			out.WriteString("# %%\n")
+		default:
+			fmt.Fprintf(&out, "# %%%% [%s]\n", cell.Type)
+		}
+		out.WriteString(source)
+		if !strings.HasSuffix(source, "\n") {
+			out.WriteString("\n")
+		}
+	}
+	return out.Bytes(), []string{fmt.Sprintf("notebook: %d cell(s), outputs dropped", len(nb.Cells))}, nil
+}
+
+// maskedValue — чем MaskEnv заменяет значения
+const maskedValue = "***"
+
+// isDotenv сообщает, что файл — dotenv: ".env", ".env.local", "prod.env" и всё, чему таблица языков дала "dotenv"
+func isDotenv(relPath, langID string) bool {
+	base := strings.ToLower(path.Base(relPath))
+	return langID == "dotenv" || base == ".env" || strings.HasPrefix(base, ".env.") || strings.HasSuffix(base, ".env")
+}
+
+// MaskEnv заменяет значения в файле dotenv на "***", оставляя имена переменных (API_KEY=***):
+// по ним видно, какие настройки нужны, а сами секреты в дамп не попадают; пустые значения, комментарии
+// и пустые строки остаются как есть, многострочное значение в кавычках маскируется целиком
+func MaskEnv(_ TransformFile, data []byte) ([]byte, []string, error) {
+	var out bytes.Buffer
+	masked := 0
+	quote := byte(0) // кавычка незакрытого многострочного значения: его строки пропускаем до закрывающей
+	for line := range bytes.Lines(data) {
+		text := strings.TrimRight(string(line), "\r\n")
+		eol := string(line[len(text):])
+		if quote != 0 {
+			if closingQuote(text, quote) >= 0 {
+				quote = 0
+			}
+			continue
+		}
+		trimmed := strings.TrimSpace(text)
+		key, value, ok := strings.Cut(text, "=")
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") || !ok || strings.TrimSpace(value) == "" {
+			out.WriteString(text + eol)
+			continue
+		}
+		value = strings.TrimSpace(value)
+		comment := ""
+		if q := value[0]; q == '"' || q == '\'' || q == '`' {
+			if end := closingQuote(value[1:], q); end >= 0 {
+				comment = inlineComment(value[1+end+1:])
+			} else {
+				quote = q
+			}
+		} else if i := strings.Index(value, " #"); i >= 0 {
+			comment = inlineComment(value[i:])
+		}
+		masked++
+		out.WriteString(key + "=" + maskedValue + comment + eol)
+	}
+	if masked == 0 {
+		return data, nil, nil
+	}
+	return out.Bytes(), []string{fmt.Sprintf("masked: %d value(s)", masked)}, nil
+}
+
+// closingQuote возвращает индекс закрывающей кавычки q в s (экранированные "\" пропускаются) или -1
+func closingQuote(s string, q byte) int {
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && q == '"':
+			i++
+		case s[i] == q:
+			return i
+		}
+	}
+	return -1
+}
+
+// inlineComment возвращает комментарий в конце строки после значения (" # ...") или ""
+func inlineComment(rest string) string {
+	if trimmed := strings.TrimSpace(rest); strings.HasPrefix(trimmed, "#") {
+		return " " + trimmed
+	}
+	return ""
+}
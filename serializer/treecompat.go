@@ -0,0 +1,105 @@
+package serializer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/asquebay/directory-serialization/format"
+)
+
+// форматы, повторяющие вывод GNU tree, чтобы скрипты, которые его разбирают, работали и с нами:
+//
+//	--format tree-json — как tree -J: массив с корневой директорией и отчётом {"type":"report",...}
+//	--format tree-xml  — как tree -X: <tree><directory name="...">...<report>...</report></tree>
+//
+// содержимое файлов в них не попадает, зато фильтры и пропуски работают как в обычном дампе
+
+// treeCounts считает директории (без корня) и файлы древа, как строка отчёта tree
+func treeCounts(n *treeNode) (dirs, files int) {
+	for _, c := range n.children {
+		if c.isDir {
+			d, f := treeCounts(c)
+			dirs += d + 1
+			files += f
+		} else {
+			files++
+		}
+	}
+	return dirs, files
+}
+
+// treeName возвращает имя как есть, если оно в UTF-8 и без управляющих символов (их не допускает XML),
+// а иначе — в кавычках с экранированием, как в древе текстового дампа
+func treeName(name string) string {
+	if !utf8.ValidString(name) || strings.ContainsFunc(name, unicode.IsControl) {
+		return format.QuoteName(name)
+	}
+	return name
+}
+
+// jsonString кодирует строку для JSON без экранирования <, > и & (tree их не экранирует)
+func jsonString(s string) string {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s)
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// exportTreeJSON пишет древо в формате tree -J
+func exportTreeJSON(w *walker, out io.Writer, rootName string) {
+	var printDir func(n *treeNode, name, indent string)
+	printDir = func(n *treeNode, name, indent string) {
+		fmt.Fprintf(out, `%s{"type":"directory","name":%s,"contents":[`+"\n", indent, jsonString(treeName(name)))
+		for i, c := range n.children {
+			if c.isDir {
+				printDir(c, c.name, indent+"  ")
+			} else {
+				fmt.Fprintf(out, `%s  {"type":"file","name":%s}`, indent, jsonString(treeName(c.name)))
+			}
+			if i < len(n.children)-1 {
+				fmt.Fprint(out, ",")
+			}
+			fmt.Fprintln(out)
+		}
+		fmt.Fprintf(out, "%s]}", indent)
+	}
+	dirs, files := treeCounts(w.treeRoot)
+	fmt.Fprintln(out, "[")
+	printDir(w.treeRoot, rootName, "  ")
+	fmt.Fprintln(out)
+	fmt.Fprintln(out, ",")
+	fmt.Fprintf(out, `  {"type":"report","directories":%d,"files":%d}`+"\n", dirs, files)
+	fmt.Fprintln(out, "]")
+}
+
+// exportTreeXML пишет древо в формате tree -X
+func exportTreeXML(w *walker, out io.Writer, rootName string) {
+	var printDir func(n *treeNode, name, indent string)
+	printDir = func(n *treeNode, name, indent string) {
+		fmt.Fprintf(out, "%s<directory name=\"%s\">\n", indent, html.EscapeString(treeName(name)))
+		for _, c := range n.children {
+			if c.isDir {
+				printDir(c, c.name, indent+"  ")
+			} else {
+				fmt.Fprintf(out, "%s  <file name=\"%s\"></file>\n", indent, html.EscapeString(treeName(c.name)))
+			}
+		}
+		fmt.Fprintf(out, "%s</directory>\n", indent)
+	}
+	dirs, files := treeCounts(w.treeRoot)
+	fmt.Fprintln(out, `<?xml version="1.0" encoding="UTF-8"?>`)
+	fmt.Fprintln(out, "<tree>")
+	printDir(w.treeRoot, rootName, "  ")
+	fmt.Fprintln(out, "  <report>")
+	fmt.Fprintf(out, "    <directories>%d</directories>\n", dirs)
+	fmt.Fprintf(out, "    <files>%d</files>\n", files)
+	fmt.Fprintln(out, "  </report>")
+	fmt.Fprintln(out, "</tree>")
+}
@@ -0,0 +1,165 @@
+package serializer
+
+import (
+	"bytes"
+	"strings"
+	"unicode/utf8"
+)
+
+// помощники для обрезки содержимого
+// обрезать наивно по байтам нельзя: можно разрезать многобайтовый символ пополам и испортить вывод,
+// поэтому режем по концу строки, а если строка одна и длинная — по границе символа
+// граница символа зависит от кодировки файла: в UTF-8 это начало последовательности, в однобайтовых
+// (cp1251, koi8-u, latin1) — любой байт, в UTF-16 — чётная позиция не посреди суррогатной пары;
+// перевод строки в UTF-16 — целая единица кода, а не байт 0x0A, который бывает и половиной другого символа
+
+// textLayout — как устроены символы кодировки с точки зрения обрезки
+type textLayout int
+
+const (
+	layoutUTF8    textLayout = iota // UTF-8, ASCII и всё неизвестное
+	layoutSingle                    // однобайтовые кодировки
+	layoutUTF16LE                   // UTF-16 с младшим байтом первым
+	layoutUTF16BE                   // UTF-16 со старшим байтом первым
+)
+
+// layoutOf возвращает раскладку для имени кодировки детектора или .editorconfig
+func layoutOf(encoding string) textLayout {
+	e := strings.ToLower(encoding)
+	switch {
+	case e == "utf-16le":
+		return layoutUTF16LE
+	case e == "utf-16be":
+		return layoutUTF16BE
+	case strings.HasPrefix(e, "cp125"), strings.HasPrefix(e, "iso-8859-"), strings.HasPrefix(e, "koi8-"),
+		strings.HasPrefix(e, "windows-125"), e == "ibm866", e == "latin1":
+		return layoutSingle
+	}
+	return layoutUTF8
+}
+
+// unit возвращает единицу кода UTF-16 на позиции i
+func (l textLayout) unit(data []byte, i int) uint16 {
+	if l == layoutUTF16LE {
+		return uint16(data[i]) | uint16(data[i+1])<<8
+	}
+	return uint16(data[i])<<8 | uint16(data[i+1])
+}
+
+// lineEnd возвращает позицию сразу за первым переводом строки в data или -1
+func (l textLayout) lineEnd(data []byte) int {
+	if l != layoutUTF16LE && l != layoutUTF16BE {
+		if nl := bytes.IndexByte(data, '\n'); nl != -1 {
+			return nl + 1
+		}
+		return -1
+	}
+	for i := 0; i+1 < len(data); i += 2 {
+		if l.unit(data, i) == '\n' {
+			return i + 2
+		}
+	}
+	return -1
+}
+
+// lastLineEnd возвращает позицию сразу за последним переводом строки в data или -1
+func (l textLayout) lastLineEnd(data []byte) int {
+	if l != layoutUTF16LE && l != layoutUTF16BE {
+		if nl := bytes.LastIndexByte(data, '\n'); nl != -1 {
+			return nl + 1
+		}
+		return -1
+	}
+	for i := len(data)&^1 - 2; i >= 0; i -= 2 {
+		if l.unit(data, i) == '\n' {
+			return i + 2
+		}
+	}
+	return -1
+}
+
+// boundary возвращает наибольшую позицию не больше max, не попадающую внутрь символа
+func (l textLayout) boundary(data []byte, max int) int {
+	if max >= len(data) {
+		return len(data)
+	}
+	switch l {
+	case layoutSingle:
+		// детектор принимает за однобайтовую и UTF-8 без кириллицы (одни эмодзи — за cp1252),
+		// поэтому верный UTF-8 режем всё-таки по границе символа UTF-8
+		if !utf8.Valid(data) {
+			return max
+		}
+	case layoutUTF16LE, layoutUTF16BE:
+		i := max &^ 1
+		// старший суррогат без младшего — половина символа за пределами BMP
+		if i >= 2 && l.unit(data, i-2)&0xFC00 == 0xD800 {
+			i -= 2
+		}
+		return i
+	}
+	return runeBoundary(data, max)
+}
+
+// headLines возвращает первые n строк data (с их переводами строк)
+// второе значение — была ли обрезка
+func headLines(data []byte, n int, encoding string) ([]byte, bool) {
+	l := layoutOf(encoding)
+	end := 0
+	for i := 0; i < n; i++ {
+		nl := l.lineEnd(data[end:])
+		if nl == -1 {
+			return data, false
+		}
+		end += nl
+	}
+	return data[:end], end < len(data)
+}
+
+// countLines считает строки; последняя строка без перевода строки тоже считается
+func countLines(data []byte, encoding string) int {
+	l := layoutOf(encoding)
+	n, end := 0, 0
+	for {
+		nl := l.lineEnd(data[end:])
+		if nl == -1 {
+			break
+		}
+		n++
+		end += nl
+	}
+	// в UTF-16 одинокий последний байт (файл нечётной длины) строкой не считаем
+	if rest := len(data) - end; rest > 0 && (l != layoutUTF16LE && l != layoutUTF16BE || rest > 1) {
+		n++
+	}
+	return n
+}
+
+// truncateBytes возвращает не больше max байт data, по возможности целыми строками
+// второе значение — была ли обрезка
+func truncateBytes(data []byte, max int, encoding string) ([]byte, bool) {
+	if len(data) <= max {
+		return data, false
+	}
+	l := layoutOf(encoding)
+	if nl := l.lastLineEnd(data[:max]); nl != -1 {
+		return data[:nl], true
+	}
+	return data[:l.boundary(data, max)], true
+}
+
+// runeBoundary возвращает наибольшую позицию не больше max, не попадающую внутрь символа UTF-8
+// для не-UTF-8 данных (latin1, cp1251 и т.п.) отступаем не больше чем на длину самого длинного символа,
+// чтобы не съесть лишнего
+func runeBoundary(data []byte, max int) int {
+	if max >= len(data) {
+		return len(data)
+	}
+	for i := max; i > max-utf8.UTFMax && i >= 0; i-- {
+		if utf8.RuneStart(data[i]) {
+			// data[:i] заканчивается на границе символа
+			return i
+		}
+	}
+	return max
+}
@@ -0,0 +1,158 @@
+package serializer
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"testing/fstest"
+	"unicode/utf16"
+
+	"github.com/asquebay/directory-serialization/format"
+)
+
+// utf16Bytes кодирует s в UTF-16 с нужным порядком байт (без BOM)
+func utf16Bytes(s string, bigEndian bool) []byte {
+	var out []byte
+	for _, u := range utf16.Encode([]rune(s)) {
+		if bigEndian {
+			out = append(out, byte(u>>8), byte(u))
+		} else {
+			out = append(out, byte(u), byte(u>>8))
+		}
+	}
+	return out
+}
+
+// cp1251 — "ЖЖёё\n" и "ёЖ" в windows-1251
+var (
+	cp1251Line  = []byte{0xC6, 0xC6, 0xB8, 0xB8, '\n'}
+	cp1251Short = []byte{0xB8, 0xC6}
+)
+
+func TestTruncateBytes(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     []byte
+		max      int
+		encoding string
+		want     []byte
+	}{
+		{"fits", []byte("abc"), 3, "UTF-8", []byte("abc")},
+		{"ascii", []byte("abcdef"), 4, "UTF-8", []byte("abcd")},
+		{"line boundary", []byte("ab\ncd\nef"), 7, "UTF-8", []byte("ab\ncd\n")},
+		{"2-byte rune", []byte("яяя"), 3, "UTF-8", []byte("я")},
+		{"3-byte rune", []byte("€€"), 5, "UTF-8", []byte("€")},
+		{"3-byte rune exact", []byte("€€"), 3, "UTF-8", []byte("€")},
+		{"4-byte rune", []byte("😀😀"), 7, "UTF-8", []byte("😀")},
+		{"4-byte rune inside first", []byte("😀😀"), 2, "UTF-8", []byte{}},
+		{"utf-8 taken for cp1252", []byte("😀😀"), 6, "cp1252", []byte("😀")},
+		{"utf-16le", utf16Bytes("жжж", false), 5, "UTF-16LE", utf16Bytes("жж", false)},
+		{"utf-16be", utf16Bytes("жжж", true), 3, "UTF-16BE", utf16Bytes("ж", true)},
+		{"utf-16le surrogate pair", utf16Bytes("ж😀", false), 5, "UTF-16LE", utf16Bytes("ж", false)},
+		{"utf-16be surrogate pair", utf16Bytes("😀😀", true), 6, "UTF-16BE", utf16Bytes("😀", true)},
+		{"utf-16le line", utf16Bytes("ж\nжж", false), 7, "UTF-16LE", utf16Bytes("ж\n", false)},
+		// U+010A в UTF-16LE — байты 0A 01: это не перевод строки
+		{"utf-16le 0x0A inside a char", utf16Bytes("Ċжж", false), 5, "UTF-16LE", utf16Bytes("Ċж", false)},
+		{"cp1251", append(cp1251Line[:4:4], cp1251Line[:4]...), 3, "cp1251", cp1251Line[:3]},
+		{"cp1251 line", append(cp1251Line, cp1251Short...), 6, "cp1251", cp1251Line},
+		{"koi8-u", []byte{0xC1, 0xC2, 0xD7}, 2, "koi8-u", []byte{0xC1, 0xC2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, truncated := truncateBytes(tt.data, tt.max, tt.encoding)
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("truncateBytes(% x, %d) = % x, want % x", tt.data, tt.max, got, tt.want)
+			}
+			if truncated != (len(tt.data) > tt.max) {
+				t.Errorf("truncated = %v", truncated)
+			}
+		})
+	}
+}
+
+func TestHeadLines(t *testing.T) {
+	tests := []struct {
+		name      string
+		data      []byte
+		n         int
+		encoding  string
+		want      []byte
+		truncated bool
+	}{
+		{"utf-8", []byte("а\nб\nв\n"), 2, "UTF-8", []byte("а\nб\n"), true},
+		{"utf-8 all lines", []byte("а\nб\n"), 2, "UTF-8", []byte("а\nб\n"), false},
+		{"utf-8 no final newline", []byte("а\nб"), 5, "UTF-8", []byte("а\nб"), false},
+		{"utf-16le", utf16Bytes("а\nб\nв", false), 1, "UTF-16LE", utf16Bytes("а\n", false), true},
+		{"utf-16be", utf16Bytes("а\nб\nв", true), 2, "UTF-16BE", utf16Bytes("а\nб\n", true), true},
+		{"utf-16le 0x0A inside a char", utf16Bytes("Ċ\nб", false), 1, "UTF-16LE", utf16Bytes("Ċ\n", false), true},
+		{"cp1251", append(cp1251Line, cp1251Short...), 1, "cp1251", cp1251Line, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, truncated := headLines(tt.data, tt.n, tt.encoding)
+			if !bytes.Equal(got, tt.want) || truncated != tt.truncated {
+				t.Errorf("headLines(% x, %d) = % x, %v, want % x, %v", tt.data, tt.n, got, truncated, tt.want, tt.truncated)
+			}
+		})
+	}
+}
+
+func TestCountLines(t *testing.T) {
+	tests := []struct {
+		data     []byte
+		encoding string
+		want     int
+	}{
+		{nil, "UTF-8", 0},
+		{[]byte("а\nб"), "UTF-8", 2},
+		{[]byte("а\nб\n"), "UTF-8", 2},
+		{utf16Bytes("а\nб", false), "UTF-16LE", 2},
+		{utf16Bytes("Ċ\nб\n", false), "UTF-16LE", 2},
+		{utf16Bytes("а\nб\n", true), "UTF-16BE", 2},
+		{append(cp1251Line, cp1251Short...), "cp1251", 2},
+	}
+	for _, tt := range tests {
+		if got := countLines(tt.data, tt.encoding); got != tt.want {
+			t.Errorf("countLines(% x, %s) = %d, want %d", tt.data, tt.encoding, got, tt.want)
+		}
+	}
+}
+
+// TestTruncateOptions проверяет, где --head и --max-file-size режут файл в готовом дампе
+func TestTruncateOptions(t *testing.T) {
+	fsys := fstest.MapFS{
+		"ru.txt":    {Data: []byte("первая\nвторая\nтретья\n")},
+		"emoji.txt": {Data: []byte("😀😀😀😀")},
+	}
+	tests := []struct {
+		name string
+		opts Options
+		want map[string]string
+	}{
+		{"head", Options{HeadLines: 2}, map[string]string{"ru.txt": "первая\nвторая\n", "emoji.txt": "😀😀😀😀"}},
+		{"max-file-size at a line", Options{MaxFileSize: 20}, map[string]string{"ru.txt": "первая\n", "emoji.txt": "😀😀😀😀"}},
+		{"max-file-size inside a rune", Options{MaxFileSize: 10}, map[string]string{"ru.txt": "перва", "emoji.txt": "😀😀"}},
+		{"head, then max-file-size", Options{HeadLines: 1, MaxFileSize: 5}, map[string]string{"ru.txt": "пе", "emoji.txt": "😀"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if _, err := RunFS(&buf, fsys, "root", tt.opts); err != nil {
+				t.Fatal(err)
+			}
+			dump, err := format.Parse(io.Reader(&buf))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := make(map[string]string)
+			for _, f := range dump.Files {
+				got[f.Path] = string(f.Content)
+			}
+			for path, want := range tt.want {
+				if got[path] != want {
+					t.Errorf("%s = %q, want %q", path, got[path], want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,226 @@
+package serializer
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"slices"
+	"time"
+)
+
+// виртуальные файлы (Options.Virtual) — то, чего в директории нет, но что полезно положить в дамп рядом с кодом:
+// вывод `go env`, `git log -5`, дерево зависимостей; обход видит их через virtualFS поверх w.fsys,
+// так что в древе, содержимом, манифесте и фильтрах они ничем не отличаются от настоящих
+//
+//	opts.Virtual = []serializer.VirtualFile{{Path: "context/go-env.txt", Data: out}}
+
+// VirtualFile — файл, добавленный в дамп без диска
+type VirtualFile struct {
+	Path    string    // путь от корня через "/"; недостающие директории появятся в древе
+	Data    []byte    // содержимое
+	ModTime time.Time // время изменения (нулевое — время запуска)
+}
+
+// checkVirtual проверяет пути виртуальных файлов: они не должны повторяться и лежать друг в друге
+func checkVirtual(files []VirtualFile) error {
+	seen := make(map[string]bool, len(files))
+	for _, f := range files {
+		if !fs.ValidPath(f.Path) || f.Path == "." {
+			return fmt.Errorf("virtual file %q: invalid path (expected a relative path with /)", f.Path)
+		}
+		if seen[f.Path] {
+			return fmt.Errorf("virtual file %q: duplicate path", f.Path)
+		}
+		seen[f.Path] = true
+	}
+	for _, f := range files {
+		for dir := path.Dir(f.Path); dir != "."; dir = path.Dir(dir) {
+			if seen[dir] {
+				return fmt.Errorf("virtual file %q: %s is a virtual file, not a directory", f.Path, dir)
+			}
+		}
+	}
+	return nil
+}
+
+// virtualFS добавляет виртуальные файлы к base; файл или директория на диске с тем же путём заменяется
+type virtualFS struct {
+	base  fs.FS
+	files map[string]VirtualFile
+	dirs  map[string][]string // директория → имена виртуальных файлов и директорий в ней
+	mtime time.Time           // время директорий, которых нет на диске
+}
+
+func newVirtualFS(base fs.FS, files []VirtualFile) *virtualFS {
+	v := &virtualFS{base: base, files: make(map[string]VirtualFile), dirs: make(map[string][]string), mtime: time.Now()}
+	for _, f := range files {
+		if f.ModTime.IsZero() {
+			f.ModTime = v.mtime
+		}
+		v.files[f.Path] = f
+		for child := f.Path; child != "."; child = path.Dir(child) {
+			dir := path.Dir(child)
+			if !slices.Contains(v.dirs[dir], path.Base(child)) {
+				v.dirs[dir] = append(v.dirs[dir], path.Base(child))
+			}
+		}
+	}
+	return v
+}
+
+// shadowed возвращает пути на диске, которые заменяют виртуальные файлы и директории, в порядке Options.Virtual:
+// замена — не ошибка (так можно подложить, например, отредактированный go.mod), но молча её делать не стоит
+func (v *virtualFS) shadowed(files []VirtualFile) []string {
+	var paths []string
+	seen := make(map[string]bool)
+	for _, f := range files {
+		// директории — от корня вглубь, чтобы файл на диске, заменённый директорией, встретился раньше самого файла
+		var dirs []string
+		for dir := path.Dir(f.Path); dir != "."; dir = path.Dir(dir) {
+			dirs = append(dirs, dir)
+		}
+		for _, dir := range slices.Backward(dirs) {
+			if info, err := fs.Stat(v.base, dir); err == nil && !info.IsDir() && !seen[dir] {
+				seen[dir] = true
+				paths = append(paths, dir)
+			}
+		}
+		if _, err := fs.Stat(v.base, f.Path); err == nil {
+			paths = append(paths, f.Path)
+		}
+	}
+	return paths
+}
+
+// isVirtual сообщает, что путь — виртуальный файл или директория, которой может не быть на диске
+func (v *virtualFS) isVirtual(name string) bool {
+	_, file := v.files[name]
+	_, dir := v.dirs[name]
+	return file || dir
+}
+
+func (v *virtualFS) Open(name string) (fs.File, error) {
+	if f, ok := v.files[name]; ok {
+		return &virtualFile{Reader: bytes.NewReader(f.Data), info: v.stat(name)}, nil
+	}
+	children, ok := v.dirs[name]
+	if !ok {
+		return v.base.Open(name)
+	}
+	dir := &virtualDir{info: v.stat(name), hidden: make(map[string]bool, len(children)), done: true}
+	for _, child := range children {
+		dir.extra = append(dir.extra, fs.FileInfoToDirEntry(v.stat(path.Join(name, child))))
+		dir.hidden[child] = true
+	}
+	// директория может быть и на диске: тогда её элементы идут первыми, а заменённые виртуальными скрываются
+	f, err := v.base.Open(name)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return dir, nil
+		}
+		return nil, err
+	}
+	if base, ok := f.(fs.ReadDirFile); ok {
+		if info, err := f.Stat(); err == nil && info.IsDir() {
+			dir.base, dir.info, dir.done = base, info, false
+			return dir, nil
+		}
+	}
+	// на диске с этим именем файл: его заменяет виртуальная директория
+	f.Close()
+	return dir, nil
+}
+
+// stat возвращает сведения о виртуальном файле или директории
+func (v *virtualFS) stat(name string) virtualStat {
+	if f, ok := v.files[name]; ok {
+		return virtualStat{name: path.Base(name), size: int64(len(f.Data)), mode: 0o644, mtime: f.ModTime}
+	}
+	return virtualStat{name: path.Base(name), mode: fs.ModeDir | 0o755, mtime: v.mtime}
+}
+
+type virtualStat struct {
+	name  string
+	size  int64
+	mode  fs.FileMode
+	mtime time.Time
+}
+
+func (s virtualStat) Name() string       { return s.name }
+func (s virtualStat) Size() int64        { return s.size }
+func (s virtualStat) Mode() fs.FileMode  { return s.mode }
+func (s virtualStat) ModTime() time.Time { return s.mtime }
+func (s virtualStat) IsDir() bool        { return s.mode.IsDir() }
+func (s virtualStat) Sys() any           { return nil }
+
+// virtualFile — открытый виртуальный файл
+type virtualFile struct {
+	*bytes.Reader
+	info virtualStat
+}
+
+func (f *virtualFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *virtualFile) Close() error               { return nil }
+
+// virtualDir — открытая директория с виртуальными элементами
+type virtualDir struct {
+	base   fs.ReadDirFile  // та же директория на диске (nil — её нет)
+	info   fs.FileInfo     // сведения о директории
+	extra  []fs.DirEntry   // виртуальные элементы, ещё не отданные; отдаются после элементов с диска
+	hidden map[string]bool // имена элементов с диска, которые заменены виртуальными
+	done   bool            // элементы с диска кончились
+}
+
+func (d *virtualDir) Stat() (fs.FileInfo, error) { return d.info, nil }
+
+func (d *virtualDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.info.Name(), Err: errors.New("is a directory")}
+}
+
+func (d *virtualDir) Close() error {
+	if d.base != nil {
+		return d.base.Close()
+	}
+	return nil
+}
+
+// ReadDir ведёт себя как fs.ReadDirFile: при n > 0 отдаёт не больше n элементов и io.EOF в конце
+func (d *virtualDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	var entries []fs.DirEntry
+	// порция с диска может целиком состоять из заменённых элементов, тогда читаем следующую
+	for !d.done && (n <= 0 || len(entries) == 0) {
+		batch, err := d.base.ReadDir(n)
+		for _, e := range batch {
+			if !d.hidden[e.Name()] {
+				entries = append(entries, e)
+			}
+		}
+		switch {
+		case err == io.EOF || err == nil && (n <= 0 || len(batch) == 0):
+			d.done = true
+		case err != nil:
+			return entries, err
+		}
+	}
+	if d.done {
+		take := len(d.extra)
+		if n > 0 {
+			take = min(take, n-len(entries))
+		}
+		entries = append(entries, d.extra[:take]...)
+		d.extra = d.extra[take:]
+	}
+	if n > 0 && len(entries) == 0 {
+		return nil, io.EOF
+	}
+	return entries, nil
+}
+
+// virtualPath сообщает, что relPath — виртуальный файл или директория (у них нет пути на диске)
+func (w *walker) virtualPath(relPath string) bool {
+	v, ok := w.fsys.(*virtualFS)
+	return ok && v.isVirtual(relPath)
+}
@@ -0,0 +1,32 @@
+package serializer
+
+import (
+	"bytes"
+	"testing"
+	"testing/fstest"
+)
+
+// TestVirtualShadowsDisk проверяет, что виртуальный файл, заменяющий файл на диске, не делает этого молча
+func TestVirtualShadowsDisk(t *testing.T) {
+	fsys := fstest.MapFS{
+		"README.md":   {Data: []byte("real\n")},
+		"docs":        {Data: []byte("a file, not a directory\n")},
+		"src/main.go": {Data: []byte("package main\n")},
+	}
+	virtual := []VirtualFile{
+		{Path: "README.md", Data: []byte("clash\n")},
+		{Path: "docs/notes.txt", Data: []byte("notes\n")},
+		{Path: "src/extra.txt", Data: []byte("extra\n")},
+	}
+	var out, log bytes.Buffer
+	if _, err := RunFS(&out, fsys, "root", Options{Virtual: virtual, Log: &log}); err != nil {
+		t.Fatal(err)
+	}
+	want := "Warning: virtual file replaces README.md on disk\nWarning: virtual file replaces docs on disk\n"
+	if log.String() != want {
+		t.Errorf("log = %q, want %q", log.String(), want)
+	}
+	if !bytes.Contains(out.Bytes(), []byte("clash\n")) || bytes.Contains(out.Bytes(), []byte("real\n")) {
+		t.Errorf("README.md is not replaced by the virtual file:\n%s", out.Bytes())
+	}
+}
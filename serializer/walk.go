@@ -0,0 +1,577 @@
+package serializer
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/asquebay/directory-serialization/detector"
+	"github.com/asquebay/directory-serialization/format"
+)
+
+// fileInfo содержит путь к файлу, флаг, является ли он текстовым, и сведения для манифеста
+type fileInfo struct {
+	relPath   string // путь относительно корня через "/"
+	isText    bool
+	readErr   bool        // файл не удалось прочитать
+	denied    bool        // причина — нет прав на чтение
+	size      int64       // размер в байтах
+	hash      string      // хеш содержимого (hex) алгоритмом Options.HashAlgo
+	encoding  string      // кодировка, определённая детектором
+	suspect   string      // что не так с кодировкой дальше начала файла (только с --check-encoding)
+	fuzzy     string      // нечёткий хеш (только для нетекстовых файлов и только с --fuzzy-hash)
+	skip      string      // почему содержимое текстового файла не выведено (пусто — выведено)
+	id        string      // короткий стабильный идентификатор (только с --file-ids)
+	lang      string      // ID языка текстового файла (пусто — неизвестен)
+	class     string      // назначение файла: ClassSource, ClassConfig и т.д. (см. class.go)
+	limit     int64       // дальше скольких байт не читать (см. growing.go)
+	grew      bool        // файл вырос, пока его читали: прочитано только limit байт
+	lines     int         // строк в файле (известно после чтения содержимого)
+	tokens    int         // оценка токенов выведенного содержимого
+	outSize   int64       // байт выведенного содержимого (после преобразований и обрезки)
+	created   time.Time   // время создания (только для манифеста и где ОС его знает)
+	mtime     time.Time   // время изменения
+	perm      fs.FileMode // права доступа (только биты прав)
+	allocated *int64      // сколько байт занято на диске, если меньше размера (разреженный файл; иначе nil)
+
+	data []byte // всё содержимое небольшого текстового файла, прочитанное при обходе (см. keepContent; nil — читать при выводе)
+
+	link       bool   // при обходе это была символьная ссылка: содержимое читается по ней (см. openfile.go)
+	linkTarget string // куда ведёт ссылка, как записано в ней самой (только на диске и с манифестом)
+
+	xattrs map[string][]byte // расширенные атрибуты (только с --xattrs)
+	target string            // куда ведёт ярлык (только с --resolve-shortcuts)
+}
+
+// decision возвращает решение о том, что сделано с файлом в дампе
+func (f fileInfo) decision() string {
+	switch {
+	case f.skip != "":
+		return f.skip
+	case f.readErr:
+		return decisionUnreadable
+	case f.isText:
+		return decisionContent
+	default:
+		return decisionBinary
+	}
+}
+
+// walker обходит директорию, строит и печатает древо и собирает сведения о файлах
+type walker struct {
+	opts     *Options
+	log      io.Writer      // куда писать предупреждения (Options.Log или io.Discard)
+	fsys     fs.FS          // откуда читаем: директория на диске (dirFS, rootFS с --sandbox) или любая fs.FS из RunFS
+	rootPath string         // путь к корню на диске (пусто, если читаем из произвольной fs.FS)
+	rootName string         // имя корня в выводе
+	tree     io.Writer      // куда печатать древо (io.Discard, если формат вывода не текстовый)
+	dirs     []string       // относительные пути обойдённых директорий
+	errors   []PathError    // ошибки, встреченные при обходе и выводе
+	ids      map[string]int // сколько раз встречался каждый базовый ID (для --file-ids)
+
+	quotedNames      int // сколько имён пришлось вывести в кавычках из-за управляющих символов или не UTF-8
+	quotedLookalikes int // и сколько — из-за сходства с пометкой древа (" [F3a9c01]", " [binary]") или кавычки в начале
+
+	expiresAt time.Time // когда истекает бюджет времени (--deadline; нулевое — без ограничения)
+	unvisited []string  // директории, в которые не зашли из-за --deadline
+
+	treeRoot *treeNode     // построенное древо (после walk)
+	fsName   string        // тип файловой системы корня, если он из известных (см. fsmeta.go)
+	gaps     []string      // каких метаданных у файлов нет: GapMode, GapMtime, GapMtimeCoarse
+	sem      chan struct{} // ограничивает число одновременно обрабатываемых директорий
+	names    *normalizedFS // имена с --normalize-names (nil — как на диске); w.fsys читает через него
+	memory   *memoryBudget // ограничивает память под содержимое файлов при обходе (--max-memory; nil — без ограничения)
+	kept     atomic.Int64  // сколько байт содержимого небольших файлов держим с обхода до вывода (см. keepContent)
+	fitUsed  int           // сколько токенов --fit занято в текущем выводе
+	steps    []Transformer // цепочка преобразований содержимого (Options.pipeline)
+
+	dirUsed     map[string]int64  // сколько бюджета --per-dir-budget занято в текущем выводе, по директориям
+	dirOverflow map[string]string // файл → директория, в бюджет которой он не влез (для сводки в лог)
+
+	secrets     []SecretFinding        // строки, похожие на секреты (--scan-secrets), в порядке вывода
+	secretsSeen map[SecretFinding]bool // уже найденные: каждый вывод перечитывает файлы
+}
+
+// PathError — ошибка, привязанная к пути относительно корня (через "/")
+type PathError struct {
+	Path string
+	Err  error
+}
+
+func (e PathError) Error() string { return e.Path + ": " + e.Err.Error() }
+
+// recordError запоминает ошибку для отчёта (сообщение в лог печатает вызывающий)
+func (w *walker) recordError(relPath string, err error) {
+	w.errors = append(w.errors, PathError{Path: relPath, Err: err})
+}
+
+// treeNode — элемент древа: директория с отсортированными детьми или файл со сведениями о нём
+// древо сначала целиком строится (параллельно, по директориям), а потом печатается по порядку,
+// так что форматам, которым древо нужно заранее, второй обход не нужен
+type treeNode struct {
+	name      string
+	relPath   string
+	isDir     bool
+	file      fileInfo    // сведения о файле (только для файлов)
+	children  []*treeNode // дети директории в порядке вывода
+	unvisited bool        // в директорию не заходили (--deadline)
+	entries   int         // сколько элементов в директории на диске, включая пропущенные
+	errs      []PathError // ошибки, встреченные на этом элементе; в w.errors попадают при печати, по порядку
+	logs      []string    // сообщения для лога, накопленные при обходе; в лог попадают при печати, по порядку
+
+	editorconfig []*editorConfig // .editorconfig, действующие в директории, от ближнего к дальнему (см. editorconfig.go)
+	gitignore    []Matcher       // правила .gitignore и .dsignore, действующие в директории, от ближних к дальним (см. gitignore.go)
+
+	// только с FollowJunctions, для поиска циклов (см. junction.go)
+	parent   *treeNode   // директория над этой
+	stat     fs.FileInfo // os.Stat директории
+	junction string      // куда ведёт соединение, по которому сюда пришли (пусто — обычная директория)
+}
+
+// logf запоминает сообщение для лога (см. logs): директории обходятся параллельно, и если бы обработчики писали
+// в лог сами, порядок сообщений зависел бы от планировщика, а Options.Log пришлось бы делать безопасным для горутин
+func (n *treeNode) logf(format string, args ...any) {
+	n.logs = append(n.logs, fmt.Sprintf(format, args...))
+}
+
+// flushNode переносит ошибки и сообщения элемента в отчёт и лог; вызывается при печати, в порядке древа
+func (w *walker) flushNode(n *treeNode) {
+	w.errors = append(w.errors, n.errs...)
+	for _, msg := range n.logs {
+		io.WriteString(w.log, msg)
+	}
+}
+
+// walkWorkers — сколько директорий обрабатывается одновременно (меньше с --max-open-files)
+const walkWorkers = 16
+
+const (
+	readDirBatch = 4096  // по сколько элементов читается директория
+	fanOutLarge  = 10000 // директория с таким числом элементов и больше помечается в древе [N entries]
+)
+
+// walk строит древо директории, печатает его и возвращает сведения о файлах в порядке древа
+func (w *walker) walk() ([]fileInfo, error) {
+	w.sem = make(chan struct{}, w.opts.workers())
+	if w.opts.MaxMemory > 0 {
+		w.memory = newMemoryBudget(w.opts.MaxMemory)
+	}
+	root := &treeNode{isDir: true}
+	if w.opts.FollowJunctions {
+		root.stat = w.dirStat("")
+	}
+	if !w.opts.NoEditorConfig {
+		root.editorconfig = w.outerEditorConfigs()
+	}
+	if !w.opts.NoGitignore {
+		root.gitignore = w.outerGitignores()
+	}
+	if err := w.buildDir(root); err != nil {
+		return nil, err
+	}
+	w.treeRoot = root
+	w.flushNode(root)
+	return w.render(root, "", nil), nil
+}
+
+// buildDir читает директорию и её файлы, а поддиректории обходит параллельно
+// ничего не печатает в вывод и в лог и не трогает общие поля walker, кроме семафора: всё, что зависит
+// от порядка, копится в узлах древа и выводится при печати, так что вывод не зависит от планировщика
+func (w *walker) buildDir(n *treeNode) error {
+	opts := w.opts
+	// семафор держим, только пока читаем саму директорию и её файлы, а не пока ждём поддиректории,
+	// иначе глубокое древо заняло бы все места и встало
+	w.sem <- struct{}{}
+	f, err := w.open(n.relPath)
+	if err != nil {
+		<-w.sem
+		return err
+	}
+	// директорию читаем порциями и каждую сразу разбираем в узлы древа: ReadDir(-1) на директории с миллионом
+	// элементов держал бы в памяти все DirEntry разом, а так кроме самих узлов (они нужны для печати древа
+	// в любом случае) в памяти только одна порция; поэтому и сортируются уже узлы, после чтения
+	var subdirs []*treeNode
+	dir, ok := f.(fs.ReadDirFile)
+	if !ok {
+		err = &fs.PathError{Op: "readdir", Path: fsPath(n.relPath), Err: errors.ErrUnsupported}
+	}
+	for first := true; ok; first = false {
+		var batch []fs.DirEntry
+		batch, err = dir.ReadDir(readDirBatch)
+		// .editorconfig нужен до файлов директории; если директория не уместилась в первую порцию,
+		// он может оказаться в следующих, поэтому его ищем сами
+		if first && !opts.NoEditorConfig && (len(batch) == readDirBatch || slices.ContainsFunc(batch, isEditorConfig)) {
+			w.applyEditorConfig(n)
+		}
+		// с .gitignore и .dsignore так же: их правила нужны до элементов директории
+		if first && (len(batch) == readDirBatch || slices.ContainsFunc(batch, w.isIgnoreFile)) {
+			w.applyGitignore(n)
+		}
+		n.entries += len(batch)
+		for _, item := range batch {
+			if sub := w.addEntry(n, item); sub != nil {
+				subdirs = append(subdirs, sub)
+			}
+		}
+		if err != nil || len(batch) == 0 {
+			break
+		}
+	}
+	f.Close()
+	if err != nil && err != io.EOF {
+		n.logf("Error reading directory %s: %v\n", w.displayPath(n.relPath), err)
+		n.errs = append(n.errs, PathError{Path: n.relPath, Err: err})
+		// НЕ возвращаем ошибку, чтобы продолжить обход других директорий
+	}
+	<-w.sem
+
+	// сортируем элементы для консистентного вывода; сортировка устойчивая: в архиве могут быть
+	// одинаковые имена, и тогда они остаются в порядке чтения, а не в случайном
+	sort.SliceStable(n.children, func(i, j int) bool {
+		// директории всегда идут первыми
+		if n.children[i].isDir != n.children[j].isDir {
+			return n.children[i].isDir
+		}
+		return n.children[i].name < n.children[j].name
+	})
+	if opts.Skeleton > 0 {
+		w.pickSkeleton(n)
+	}
+
+	var wg sync.WaitGroup
+	for _, sub := range subdirs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := w.buildDir(sub); err != nil {
+				// ошибку логируем, но не прерываем весь процесс
+				sub.logf("Error accessing %s: %v\n", w.displayPath(sub.relPath), err)
+				sub.errs = append(sub.errs, PathError{Path: sub.relPath, Err: err})
+			}
+		}()
+	}
+	wg.Wait()
+	return nil
+}
+
+// addEntry добавляет элемент директории в древо (файл сразу осматривает) и возвращает поддиректорию,
+// которую надо обойти, или nil
+func (w *walker) addEntry(n *treeNode, item fs.DirEntry) *treeNode {
+	opts := w.opts
+	// пропускаем .git и temp (temp я использую для всякой всячины, которую НЕ кладу в проект)
+	if item.Name() == ".git" {
+		return nil
+	}
+	if item.Name() == "temp" {
+		return nil
+	}
+	// и мусор ОС и редакторов (.DS_Store, __pycache__, .idea), если его не попросили оставить
+	if !opts.NoDefaultExcludes && isDefaultExcluded(item.Name()) {
+		return nil
+	}
+	// с --tests exclude тестовые директории не обходим вовсе, чтобы в древе не оставались пустые tests/
+	if opts.Tests == TestsExclude && item.IsDir() && isTestDir(item.Name()) {
+		return nil
+	}
+	child := &treeNode{name: item.Name(), relPath: path.Join(n.relPath, item.Name()), isDir: item.IsDir(), editorconfig: n.editorconfig, gitignore: n.gitignore}
+	switch opts.match(child.relPath, item) {
+	case Exclude:
+		return nil
+	case Undecided:
+		if n.gitignored(child.relPath, item) {
+			return nil
+		}
+	}
+	// соединение Windows обходим как директорию только по просьбе; иначе оно остаётся элементом с пометкой
+	if opts.FollowJunctions && isReparsePoint(item.Type()) && !w.followJunction(n, child) {
+		child.isDir = false
+	}
+	if opts.FollowJunctions && child.isDir && child.stat == nil {
+		child.parent = n
+		child.stat = w.dirStat(child.relPath)
+	}
+	if !child.isDir {
+		info, err := item.Info()
+		if err != nil {
+			// файл, удалённый между чтением директории и stat, просто не показываем
+			if !errors.Is(err, fs.ErrNotExist) {
+				n.logf("Error accessing %s: %v\n", w.displayPath(child.relPath), err)
+				n.errs = append(n.errs, PathError{Path: child.relPath, Err: err})
+			}
+			return nil
+		}
+		if !opts.keepFile(child.relPath, w.osPath(child.relPath), info) {
+			return nil
+		}
+		included := opts.Include == nil || opts.Include.Match(child.relPath, item) == Include
+		if !included && !opts.IncludeTree {
+			return nil
+		}
+		w.inspectFile(child, info, included)
+		// класс известен только после осмотра: он зависит и от первой строки файла
+		if len(opts.OnlyClasses) > 0 && !slices.Contains(opts.OnlyClasses, child.file.class) {
+			return nil
+		}
+		n.children = append(n.children, child)
+		return nil
+	}
+
+	n.children = append(n.children, child)
+	if w.expired() {
+		// время вышло: директорию покажем, но обходить не будем
+		child.unvisited = true
+		return nil
+	}
+	return child
+}
+
+func isEditorConfig(item fs.DirEntry) bool { return item.Name() == ".editorconfig" && !item.IsDir() }
+
+func (w *walker) isIgnoreFile(item fs.DirEntry) bool {
+	return (item.Name() == DSIgnoreName || item.Name() == ".gitignore" && !w.opts.NoGitignore) && !item.IsDir()
+}
+
+// inspectFile определяет, является ли файл текстовым, и собирает сведения для манифеста
+// (имеется в виду проверка, является ли файл "читабельным", а не бинарником или картинкой)
+// не подошедший под Options.Include файл (included false) не читается: он только показывается в древе
+func (w *walker) inspectFile(n *treeNode, item fs.FileInfo, included bool) {
+	opts := w.opts
+	link := item.Mode()&fs.ModeSymlink != 0
+	if link {
+		// item — сведения о самой ссылке (lstat): её размер — длина пути цели, а не файла,
+		// поэтому размер, предел чтения, права и занятое место берём у файла, на который она ведёт
+		if target, err := fs.Stat(w.fsys, n.relPath); err == nil {
+			item = target
+		}
+	}
+	file := fileInfo{relPath: n.relPath, size: item.Size(), limit: readLimit(item.Size()), mtime: item.ModTime(), perm: item.Mode().Perm(),
+		link: link}
+	binary := false // содержимое проверено и оно нетекстовое
+	defer func() {
+		// класс — по языку из имени и первой строки, а если файл не читали, то только из имени
+		langID := file.lang
+		if langID == "" {
+			langID = opts.Langs.Lookup(n.relPath)
+		}
+		file.class = classify(n.relPath, langID, binary)
+		// readHead только помечает выросший файл: сообщение уходит в лог при печати древа
+		if file.grew {
+			n.logf("%s", w.growthMessage(&file))
+		}
+		n.file = file
+	}()
+
+	if isSpecial(item.Mode()) {
+		file.skip = decisionSpecial
+		return
+	}
+	if isReparsePoint(item.Mode()) {
+		file.skip, file.target = w.reparseDecision(n.relPath)
+		return
+	}
+	if !included {
+		file.skip = decisionNoInclude
+		return
+	}
+
+	if opts.ManifestPath != "" {
+		// у ссылки запоминаем цель, чтобы restore мог воссоздать ссылку (права уже — файла за ней)
+		if osPath := w.osPath(n.relPath); file.link && osPath != "" {
+			file.linkTarget, _ = os.Readlink(osPath)
+		}
+		file.created, _ = birthTime(w.osPath(n.relPath), item)
+		if allocated, ok := allocatedSize(item); ok && allocated < item.Size() {
+			file.allocated = &allocated
+		}
+	}
+
+	if opts.Xattrs && w.osPath(n.relPath) != "" {
+		attrs, err := ReadXattrs(w.osPath(n.relPath))
+		if err != nil {
+			n.logf("Could not read extended attributes of %s: %v\n", w.displayPath(n.relPath), err)
+		}
+		file.xattrs = attrs
+	}
+
+	if w.expired() {
+		// время вышло: файл только показываем в древе
+		file.skip = decisionDeadline
+		return
+	}
+	// с --max-memory ждём, пока другие обработчики отпустят память под свои файлы
+	need := min(file.limit, detector.SampleSize+1)
+	if opts.FileIDs || opts.FuzzyHash {
+		need = file.limit
+	}
+	release := w.memory.acquire(need)
+	defer release()
+	data, complete, err := w.readHead(&file, opts.FileIDs)
+	if err != nil {
+		file.readErr = true
+		// файлы, которые видно, но нельзя прочитать, пропускаем молча и сообщаем о них одной строкой в конце,
+		// иначе на общих директориях stderr заваливает ошибками доступа
+		if errors.Is(err, fs.ErrPermission) {
+			file.denied = true
+		} else {
+			n.logf("Could not read file %s to determine type: %v\n", w.displayPath(n.relPath), err)
+		}
+		n.errs = append(n.errs, PathError{Path: n.relPath, Err: err})
+		return
+	}
+
+	// для определения типа хватает начала файла, целиком большой файл читается один раз — при выводе содержимого
+	// (держать файлы открытыми до вывода нельзя: древо печатается раньше, и дескрипторов не хватит),
+	// а небольшой, прочитанный целиком уже здесь, хранится до вывода (см. keepContent)
+	// целиком сразу читаем только когда хеш нужен уже в древе (--file-ids) или нужен нечёткий хеш бинарника
+	sample := data
+	if !complete {
+		sample = data[:runeBoundary(data, len(data)-1)] // не режем последний символ UTF-8 пополам
+	}
+	// используем функцию-обёртку для ответа (текстовый ли файл, али бинарник кракозябрный)
+	if opts.ResolveShortcuts {
+		file.target = shortcutTarget(n.name, data)
+	}
+	file.isText = detector.IsText(sample)
+	detected := detector.EncodingDetector(sample, detector.None)
+	// у больших файлов с выборкой SampleSpread смотрим ещё середину и конец (см. detector/sample.go)
+	if offsets := opts.Detector.Offsets(file.size); !complete && file.isText && len(offsets) > 1 {
+		samples, err := w.readSamples(&file, offsets[1:])
+		if err != nil {
+			n.logf("Could not sample %s beyond its start: %v\n", w.displayPath(n.relPath), err)
+		} else if samples != nil {
+			detected, file.suspect = detector.DetectSamples(append([][]byte{sample}, samples...), offsets, detector.None)
+			file.isText = !detected.IsBinary
+		}
+	}
+	binary = !file.isText
+	file.encoding = detected.Encoding
+	// кодировку, объявленную в .editorconfig, берём вместо догадки, но BOM в самом файле важнее
+	if declared := editorConfigEncoding(n.editorconfig, n.relPath); declared != "" && file.isText && detected.Source != detector.BOM {
+		file.encoding = declared
+	}
+	if file.isText {
+		// язык по имени, а для скриптов без расширения — по shebang в первой строке
+		file.lang = opts.Langs.Detect(n.relPath, sample)
+		if opts.CheckEncoding {
+			suspect, err := w.checkEncoding(&file, data, complete)
+			if err != nil {
+				n.logf("Error reading %s: %v\n", w.displayPath(n.relPath), err)
+				n.errs = append(n.errs, PathError{Path: n.relPath, Err: err})
+			}
+			// полная проверка точнее выборки: её вердикт заменяет вердикт DetectSamples
+			file.suspect = suspect
+		}
+		// минифицированные бандлы и данные в одну строку только показываем в древе (см. detector/minified.go)
+		if !opts.KeepMinified && detector.Minified(sample) {
+			file.skip = decisionMinified
+		}
+		// совпадение с --content-match ищем сразу, чтобы пометить файлы без него уже в древе
+		if opts.ContentMatch != nil && file.skip == "" {
+			matched, err := w.matchesContent(&file, opts.ContentMatch)
+			if err != nil {
+				n.logf("Error reading %s: %v\n", w.displayPath(n.relPath), err)
+				n.errs = append(n.errs, PathError{Path: n.relPath, Err: err})
+			}
+			if !matched {
+				file.skip = decisionNoMatch
+			}
+		}
+		if opts.GoDoc == GoDocOnly && file.skip == "" && isGoDocSource(n.relPath, file.lang) {
+			file.skip = decisionGoDoc
+		}
+	}
+	if !complete && opts.FuzzyHash && !file.isText {
+		data, complete, _ = w.readHead(&file, true)
+	}
+	if complete && len(data) == 0 {
+		file.skip = decisionEmpty
+	}
+	if complete {
+		file.size = int64(len(data))
+		file.hash = opts.hashSum(data)
+		if file.isText && file.skip == "" && w.keepContent(len(data)) {
+			file.data = data[:len(data):len(data)]
+		}
+		if opts.FuzzyHash && !file.isText {
+			file.fuzzy = fuzzyHash(data)
+		}
+	}
+}
+
+// render печатает древо (этап 1) по порядку и собирает файлы, директории и ошибки в порядке вывода
+// ID файлов тоже выдаются здесь: суффиксы одинаковых ID зависят от порядка, а он должен быть стабильным
+func (w *walker) render(n *treeNode, prefix string, files []fileInfo) []fileInfo {
+	for i, child := range n.children {
+		last := i == len(n.children)-1
+		connector, next := "├── ", "│   "
+		// с Canonical строка древа зависит только от глубины и имени, а не от того, последний ли это элемент
+		if last && !w.opts.Canonical {
+			connector, next = "└── ", "    "
+		}
+		w.flushNode(child)
+		// имена с переводами строк, управляющими символами или не в UTF-8 выводим в кавычках
+		shown := format.QuoteName(child.name)
+		switch {
+		case shown == child.name:
+		case unprintableName(child.name):
+			w.quotedNames++
+		default:
+			w.quotedLookalikes++
+		}
+
+		if child.isDir {
+			line := shown + "/"
+			if child.junction != "" {
+				line += " [-> " + strconv.Quote(child.junction) + "]"
+			}
+			if child.entries >= fanOutLarge {
+				// в такой директории обычно логи, кэши или данные, а не код; пометка объясняет, откуда длинное древо
+				line += fmt.Sprintf(" [%d entries]", child.entries)
+			}
+			fmt.Fprintln(w.tree, prefix+connector+line)
+			w.dirs = append(w.dirs, child.relPath)
+			if child.unvisited {
+				w.unvisited = append(w.unvisited, child.relPath)
+			}
+			files = w.render(child, prefix+next, files)
+			continue
+		}
+
+		if w.opts.FileIDs {
+			child.file.id = w.assignID(child.file)
+		}
+		line := shown
+		if child.file.target != "" {
+			line += " [-> " + strconv.Quote(child.file.target) + "]"
+		}
+		if tag := w.reasonTag(child.file); tag != "" {
+			line += " [" + tag + "]"
+		}
+		if child.file.suspect != "" {
+			line += " [" + format.EncodingSuspectTag + "]"
+		}
+		if child.file.id != "" {
+			line += " [" + child.file.id + "]"
+		}
+		fmt.Fprintln(w.tree, prefix+connector+line)
+		files = append(files, child.file)
+	}
+	return files
+}
+
+// unprintableName сообщает, что имя не в UTF-8 или содержит непечатаемые символы
+func unprintableName(name string) bool {
+	return !utf8.ValidString(name) || strings.ContainsFunc(name, func(r rune) bool { return !unicode.IsPrint(r) })
+}
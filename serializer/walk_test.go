@@ -0,0 +1,291 @@
+package serializer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+	"testing/fstest"
+
+	"github.com/asquebay/directory-serialization/format"
+)
+
+// wideTree создаёт в dir широкое древо: dirs директорий по files файлов, в каждой ещё поддиректория,
+// а среди файлов — пустые, бинарные и в другой кодировке, чтобы решения о них тоже сравнивались
+func wideTree(t testing.TB, dir string, dirs, files int) {
+	t.Helper()
+	for d := range dirs {
+		sub := filepath.Join(dir, fmt.Sprintf("dir%03d", d), "nested")
+		if err := os.MkdirAll(sub, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		for f := range files {
+			var data []byte
+			switch f % 4 {
+			case 0:
+				data = fmt.Appendf(nil, "package p%d\n\nfunc F%d() int { return %d }\n", d, f, f)
+			case 1:
+				data = []byte{0x7f, 'E', 'L', 'F', 0, 1, 2, byte(f)}
+			case 2:
+				data = fmt.Appendf(nil, "строка %d\nещё одна строка %d\n", f, d)
+			}
+			name := fmt.Sprintf("file%03d.txt", f)
+			if f%4 == 0 {
+				name = fmt.Sprintf("file%03d.go", f)
+			}
+			if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("dir%03d", d), name), data, 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if f%5 == 0 {
+				if err := os.WriteFile(filepath.Join(sub, name), data, 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+	}
+	// ошибки чтения (висячие ссылки) идут в лог, и тоже должны идти в порядке древа
+	for d := 0; d < dirs; d += 7 {
+		if err := os.Symlink("missing", filepath.Join(dir, fmt.Sprintf("dir%03d", d), "dangling")); err != nil {
+			t.Skipf("symlinks unavailable: %v", err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, ".gitignore"), []byte("dir00[13]/\n*.bak\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestOutputIndependentOfScheduling сериализует одно широкое древо при разных GOMAXPROCS и сравнивает
+// вывод, манифест и лог побайтно (запускать и с -race)
+func TestOutputIndependentOfScheduling(t *testing.T) {
+	root := t.TempDir()
+	wideTree(t, root, 64, 24)
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(0))
+
+	run := func(procs int) (out, manifest, log []byte) {
+		runtime.GOMAXPROCS(procs)
+		var outBuf, logBuf bytes.Buffer
+		manifestPath := filepath.Join(t.TempDir(), "manifest.json")
+		opts := Options{ManifestPath: manifestPath, Log: &logBuf, HeaderStats: true}
+		if _, err := Run(&outBuf, root, opts); err != nil {
+			t.Fatalf("GOMAXPROCS=%d: %v", procs, err)
+		}
+		data, err := os.ReadFile(manifestPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return outBuf.Bytes(), data, logBuf.Bytes()
+	}
+
+	wantOut, wantManifest, wantLog := run(1)
+	if len(wantLog) == 0 {
+		t.Fatal("no warnings in the log: the tree does not exercise log ordering")
+	}
+	for _, procs := range []int{2, 4, 8, 1} {
+		for attempt := range 3 {
+			out, manifest, log := run(procs)
+			if !bytes.Equal(out, wantOut) {
+				t.Errorf("GOMAXPROCS=%d, run %d: output differs from GOMAXPROCS=1", procs, attempt)
+			}
+			if !bytes.Equal(manifest, wantManifest) {
+				t.Errorf("GOMAXPROCS=%d, run %d: manifest differs from GOMAXPROCS=1", procs, attempt)
+			}
+			if !bytes.Equal(log, wantLog) {
+				t.Errorf("GOMAXPROCS=%d, run %d: log differs from GOMAXPROCS=1:\n%s\nwant:\n%s", procs, attempt, log, wantLog)
+			}
+		}
+	}
+}
+
+// TestManifestSymlink проверяет, что манифест хранит цель ссылки как есть, а права — файла за ней
+func TestManifestSymlink(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "a.txt"), []byte("a\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(filepath.Join(root, "a.txt"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("a.txt", filepath.Join(root, "link")); err != nil {
+		t.Skipf("symlinks unavailable: %v", err)
+	}
+	manifestPath := filepath.Join(t.TempDir(), "manifest.json")
+	if _, err := Run(io.Discard, root, Options{ManifestPath: manifestPath}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(manifestPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m struct {
+		Files []struct {
+			Path    string `json:"path"`
+			Mode    string `json:"mode"`
+			Symlink string `json:"symlink"`
+		} `json:"files"`
+	}
+	if err := json.Unmarshal(data, &m); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][2]string{"a.txt": {"0600", ""}, "link": {"0600", "a.txt"}}
+	for _, f := range m.Files {
+		if got := [2]string{f.Mode, f.Symlink}; got != want[f.Path] {
+			t.Errorf("%s: mode, symlink = %q, want %q", f.Path, got, want[f.Path])
+		}
+	}
+	if len(m.Files) != len(want) {
+		t.Errorf("%d files in the manifest, want %d", len(m.Files), len(want))
+	}
+}
+
+// TestSymlinkedFileContents проверяет, что файл за ссылкой читается целиком: размер берётся у файла,
+// а не у самой ссылки (длины пути цели), и ссылка не считается выросшим или разреженным файлом
+func TestSymlinkedFileContents(t *testing.T) {
+	root := t.TempDir()
+	long := bytes.Repeat([]byte("0123456789\n"), 1000)
+	if err := os.WriteFile(filepath.Join(root, "long.txt"), long, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("long.txt", filepath.Join(root, "link")); err != nil {
+		t.Skipf("symlinks unavailable: %v", err)
+	}
+	for _, sandbox := range []bool{false, true} {
+		var out, log bytes.Buffer
+		manifestPath := filepath.Join(t.TempDir(), "manifest.json")
+		opts := Options{ManifestPath: manifestPath, Log: &log, Sandbox: sandbox}
+		if _, err := Run(&out, root, opts); err != nil {
+			t.Fatal(err)
+		}
+		dump, err := format.Parse(&out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, f := range dump.Files {
+			if f.Path == "link" && !bytes.Equal(f.Content, long) {
+				t.Errorf("sandbox %v: link: %d bytes of contents, want %d", sandbox, len(f.Content), len(long))
+			}
+		}
+		if log.Len() > 0 {
+			t.Errorf("sandbox %v: unexpected log:\n%s", sandbox, log.Bytes())
+		}
+		data, err := os.ReadFile(manifestPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var m struct {
+			Files []struct {
+				Path      string `json:"path"`
+				Size      int64  `json:"size"`
+				Allocated *int64 `json:"allocated"`
+			} `json:"files"`
+		}
+		if err := json.Unmarshal(data, &m); err != nil {
+			t.Fatal(err)
+		}
+		for _, f := range m.Files {
+			if f.Size != int64(len(long)) || f.Allocated != nil {
+				t.Errorf("sandbox %v: %s: size %d, allocated %v, want %d and none", sandbox, f.Path, f.Size, f.Allocated, len(long))
+			}
+		}
+	}
+}
+
+// TestSymlinkToDirectory проверяет, что ссылка на директорию не выдаётся за файл, подменённый во время чтения
+func TestSymlinkToDirectory(t *testing.T) {
+	root := t.TempDir()
+	if err := os.Mkdir(filepath.Join(root, "dir"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("dir", filepath.Join(root, "link")); err != nil {
+		t.Skipf("symlinks unavailable: %v", err)
+	}
+	for _, sandbox := range []bool{false, true} {
+		var out, log bytes.Buffer
+		if _, err := Run(&out, root, Options{Log: &log, Sandbox: sandbox}); err != nil {
+			t.Fatal(err)
+		}
+		if bytes.Contains(log.Bytes(), []byte("replaced")) || !bytes.Contains(log.Bytes(), []byte("not a regular file")) {
+			t.Errorf("sandbox %v: log does not say the link is not a regular file:\n%s", sandbox, log.Bytes())
+		}
+	}
+}
+
+// TestSandboxKeepsOuterGitignore проверяет, что правила .gitignore над корнем действуют и с Sandbox:
+// ограничение чтения не должно расширять дамп
+func TestSandboxKeepsOuterGitignore(t *testing.T) {
+	repo := t.TempDir()
+	root := filepath.Join(repo, "proj")
+	for _, dir := range []string{filepath.Join(repo, ".git", "info"), root} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	files := map[string]string{
+		filepath.Join(repo, ".gitignore"):              "*.secret\n",
+		filepath.Join(repo, ".git", "info", "exclude"): "*.excl\n",
+		filepath.Join(root, "a.secret"):                "secret\n",
+		filepath.Join(root, "b.excl"):                  "excluded\n",
+		filepath.Join(root, "c.txt"):                   "kept\n",
+	}
+	for name, data := range files {
+		if err := os.WriteFile(name, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, sandbox := range []bool{false, true} {
+		var out bytes.Buffer
+		if _, err := Run(&out, root, Options{Sandbox: sandbox}); err != nil {
+			t.Fatal(err)
+		}
+		if bytes.Contains(out.Bytes(), []byte("a.secret")) || bytes.Contains(out.Bytes(), []byte("b.excl")) {
+			t.Errorf("sandbox %v: ignored files in the dump:\n%s", sandbox, out.Bytes())
+		}
+		if !bytes.Contains(out.Bytes(), []byte("c.txt")) {
+			t.Errorf("sandbox %v: c.txt missing from the dump:\n%s", sandbox, out.Bytes())
+		}
+	}
+}
+
+// countingFS считает, сколько раз открывали каждый файл
+type countingFS struct {
+	fs.FS
+	mu    sync.Mutex
+	opens map[string]int
+}
+
+func (c *countingFS) Open(name string) (fs.File, error) {
+	c.mu.Lock()
+	c.opens[name]++
+	c.mu.Unlock()
+	return c.FS.Open(name)
+}
+
+// TestSmallFilesOpenedOnce проверяет, что небольшой файл, прочитанный целиком при обходе, для вывода
+// содержимого не открывается снова, а большой — открывается
+func TestSmallFilesOpenedOnce(t *testing.T) {
+	big := bytes.Repeat([]byte("строка\n"), 10000)
+	fsys := &countingFS{FS: fstest.MapFS{
+		"small.go":   {Data: []byte("package main\n")},
+		"dir/b.txt":  {Data: []byte("b\n")},
+		"big.txt":    {Data: big},
+		"binary.bin": {Data: []byte{0, 1, 2, 3}},
+	}, opens: make(map[string]int)}
+	var out bytes.Buffer
+	if _, err := RunFS(&out, fsys, "root", Options{}); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]int{"small.go": 1, "dir/b.txt": 1, "binary.bin": 1, "big.txt": 2}
+	for name, n := range want {
+		if fsys.opens[name] != n {
+			t.Errorf("%s opened %d time(s), want %d", name, fsys.opens[name], n)
+		}
+	}
+	if !bytes.Contains(out.Bytes(), []byte("package main\n")) || !bytes.Contains(out.Bytes(), big) {
+		t.Errorf("contents missing from the dump:\n%s", out.Bytes())
+	}
+}
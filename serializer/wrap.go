@@ -0,0 +1,38 @@
+package serializer
+
+import (
+	"bytes"
+	"unicode/utf8"
+
+	"github.com/asquebay/directory-serialization/format"
+)
+
+// wrapLines переносит строки длиннее width символов (минифицированный код, JSON в одну строку):
+// каждый кусок, кроме последнего, — ровно width символов и format.WrapMarker, так что format.Unwrap восстановит исходник
+// возвращает данные и признак, что хоть одна строка перенесена
+func wrapLines(data []byte, width int) ([]byte, bool) {
+	var out bytes.Buffer
+	wrapped := false
+	for _, line := range bytes.SplitAfter(data, []byte("\n")) {
+		body, nl := bytes.CutSuffix(line, []byte("\n"))
+		for utf8.RuneCount(body) > width {
+			end := 0
+			for range width {
+				_, size := utf8.DecodeRune(body[end:])
+				end += size
+			}
+			out.Write(body[:end])
+			out.WriteString(format.WrapMarker + "\n")
+			body = body[end:]
+			wrapped = true
+		}
+		out.Write(body)
+		if nl {
+			out.WriteByte('\n')
+		}
+	}
+	if !wrapped {
+		return data, false
+	}
+	return out.Bytes(), true
+}
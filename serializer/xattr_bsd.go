@@ -0,0 +1,8 @@
+//go:build darwin || freebsd || netbsd
+
+package serializer
+
+import "golang.org/x/sys/unix"
+
+// errNoAttr — «такого атрибута нет»
+const errNoAttr = unix.ENOATTR
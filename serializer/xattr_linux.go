@@ -0,0 +1,8 @@
+//go:build linux
+
+package serializer
+
+import "golang.org/x/sys/unix"
+
+// errNoAttr — «такого атрибута нет»
+const errNoAttr = unix.ENODATA
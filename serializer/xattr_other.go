@@ -0,0 +1,15 @@
+//go:build !(linux || darwin || freebsd || netbsd)
+
+package serializer
+
+import "errors"
+
+// ReadXattrs на этой платформе расширенных атрибутов не видит
+func ReadXattrs(string) (map[string][]byte, error) {
+	return nil, nil
+}
+
+// WriteXattrs на этой платформе выставить атрибуты нельзя
+func WriteXattrs(string, map[string][]byte) error {
+	return errors.New("extended attributes are not supported on this platform")
+}
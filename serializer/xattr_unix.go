@@ -0,0 +1,65 @@
+//go:build linux || darwin || freebsd || netbsd
+
+package serializer
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"golang.org/x/sys/unix"
+)
+
+// ReadXattrs возвращает расширенные атрибуты файла (сам путь, по симлинкам не переходит)
+// на Linux сюда же попадают ACL (system.posix_acl_access); nil — атрибутов нет или ФС их не поддерживает
+func ReadXattrs(path string) (map[string][]byte, error) {
+	names, err := xattrCall(func(buf []byte) (int, error) { return unix.Llistxattr(path, buf) })
+	if err != nil || len(names) == 0 {
+		return nil, err
+	}
+	attrs := make(map[string][]byte)
+	for _, name := range bytes.Split(bytes.TrimSuffix(names, []byte{0}), []byte{0}) {
+		value, err := xattrCall(func(buf []byte) (int, error) { return unix.Lgetxattr(path, string(name), buf) })
+		if errors.Is(err, errNoAttr) {
+			continue // атрибут удалили между list и get
+		}
+		if err != nil {
+			return attrs, err
+		}
+		attrs[string(name)] = value
+	}
+	return attrs, nil
+}
+
+// WriteXattrs выставляет расширенные атрибуты файлу; атрибуты system.* и security.* обычно требуют прав root
+func WriteXattrs(path string, attrs map[string][]byte) error {
+	var errs []error
+	for name, value := range attrs {
+		if err := unix.Lsetxattr(path, name, value, 0); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// xattrCall вызывает list/get сначала за размером, потом за данными (размер может вырасти между вызовами)
+func xattrCall(call func([]byte) (int, error)) ([]byte, error) {
+	for {
+		size, err := call(nil)
+		if errors.Is(err, unix.ENOTSUP) {
+			return nil, nil
+		}
+		if err != nil || size == 0 {
+			return nil, err
+		}
+		buf := make([]byte, size)
+		n, err := call(buf)
+		if errors.Is(err, unix.ERANGE) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return buf[:n], nil
+	}
+}
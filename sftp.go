@@ -0,0 +1,505 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// --sftp user@host:/path: директория на удалённом сервере читается по SFTP, без rsync на локальный диск
+// соединение поднимает обычный ssh (ssh -s host sftp), так что работают ~/.ssh/config, ключи и агент
+// здесь только та часть протокола SFTP v3, что нужна для чтения: stat, листинг и чтение файлов
+// у каждого запроса свой номер, ответы разбираются отдельной горутиной, поэтому запросы из параллельного
+// обхода идут одновременно, а файл читается с упреждением: несколько READ подряд, не дожидаясь ответов
+
+// типы пакетов и флаги SFTP v3 (draft-ietf-secsh-filexfer-02)
+const (
+	fxpInit     = 1
+	fxpVersion  = 2
+	fxpOpen     = 3
+	fxpClose    = 4
+	fxpRead     = 5
+	fxpLstat    = 7
+	fxpOpendir  = 11
+	fxpReaddir  = 12
+	fxpStat     = 17
+	fxpStatus   = 101
+	fxpHandle   = 102
+	fxpData     = 103
+	fxpName     = 104
+	fxpAttrs    = 105
+	fxfRead     = 1
+	fxOK        = 0
+	fxEOF       = 1
+	fxNoSuch    = 2
+	fxDenied    = 3
+	attrSize    = 0x1
+	attrUIDGID  = 0x2
+	attrPerms   = 0x4
+	attrTimes   = 0x8
+	attrExtend  = 0x80000000
+	sftpChunk   = 32 << 10 // сколько байт просить одним READ (столько отдают все серверы)
+	sftpMaxPack = 1 << 20  // ответы больше этого — признак рассинхронизации потока
+)
+
+// sftpFS — fs.FS с содержимым удалённой директории
+type sftpFS struct {
+	root     string // удалённый путь к сериализуемой директории
+	requests int    // сколько READ одного файла держать в полёте
+
+	cmd   *exec.Cmd
+	wmu   sync.Mutex // пакеты пишутся целиком по одному
+	stdin io.WriteCloser
+
+	mu      sync.Mutex
+	nextID  uint32
+	pending map[uint32]chan sftpPacket
+	err     error // соединение оборвалось: все следующие запросы получат эту ошибку
+}
+
+// sftpPacket — ответ сервера: тип и данные после номера запроса
+type sftpPacket struct {
+	typ  byte
+	data []byte
+}
+
+// parseSFTPTarget разбирает "user@host:/path" на адрес для ssh и удалённый путь (пусто — домашняя директория)
+func parseSFTPTarget(target string) (string, string, error) {
+	host, dir, ok := strings.Cut(target, ":")
+	if !ok || host == "" {
+		return "", "", fmt.Errorf("expected user@host:/path, got %q", target)
+	}
+	if dir == "" {
+		dir = "."
+	}
+	return host, dir, nil
+}
+
+// openSFTP подключается к серверу командой sshCommand (например, "ssh -p 2222") и проверяет, что путь — директория
+func openSFTP(target, sshCommand string, requests int) (*sftpFS, string, error) {
+	host, dir, err := parseSFTPTarget(target)
+	if err != nil {
+		return nil, "", err
+	}
+	args := strings.Fields(sshCommand)
+	if len(args) == 0 {
+		return nil, "", errors.New("empty --ssh-command")
+	}
+	fsys := &sftpFS{root: dir, requests: max(requests, 1), pending: make(map[uint32]chan sftpPacket)}
+	fsys.cmd = exec.Command(args[0], append(args[1:], "-s", host, "sftp")...)
+	fsys.cmd.Stderr = os.Stderr // запрос пароля и ошибки ssh видны пользователю
+	if fsys.stdin, err = fsys.cmd.StdinPipe(); err != nil {
+		return nil, "", err
+	}
+	stdout, err := fsys.cmd.StdoutPipe()
+	if err != nil {
+		return nil, "", err
+	}
+	if err := fsys.cmd.Start(); err != nil {
+		return nil, "", err
+	}
+	out := bufio.NewReaderSize(stdout, 64<<10)
+
+	// рукопожатие: INIT без номера запроса, ответ VERSION
+	if err := fsys.send(fxpInit, binary.BigEndian.AppendUint32(nil, 3)); err != nil {
+		fsys.Close()
+		return nil, "", err
+	}
+	if typ, _, err := readPacket(out); err != nil || typ != fxpVersion {
+		fsys.Close()
+		if err == nil {
+			err = fmt.Errorf("unexpected packet %d", typ)
+		}
+		return nil, "", fmt.Errorf("sftp handshake with %s: %w", host, err)
+	}
+	go fsys.dispatch(out)
+
+	info, err := fs.Stat(fsys, ".")
+	if err != nil {
+		fsys.Close()
+		return nil, "", err
+	}
+	if !info.IsDir() {
+		fsys.Close()
+		return nil, "", fmt.Errorf("%s is not a directory", target)
+	}
+	name := path.Base(dir)
+	if name == "." || name == "/" {
+		name = host
+	}
+	return fsys, name, nil
+}
+
+// Close закрывает соединение
+func (fsys *sftpFS) Close() error {
+	fsys.stdin.Close()
+	return fsys.cmd.Wait()
+}
+
+// readPacket читает пакет: длина, тип, данные
+func readPacket(r io.Reader) (byte, []byte, error) {
+	var head [5]byte
+	if _, err := io.ReadFull(r, head[:]); err != nil {
+		return 0, nil, err
+	}
+	size := binary.BigEndian.Uint32(head[:4])
+	if size < 1 || size > sftpMaxPack {
+		return 0, nil, fmt.Errorf("bad sftp packet length %d", size)
+	}
+	data := make([]byte, size-1)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return 0, nil, err
+	}
+	return head[4], data, nil
+}
+
+// send пишет пакет
+func (fsys *sftpFS) send(typ byte, payload []byte) error {
+	packet := binary.BigEndian.AppendUint32(make([]byte, 0, 5+len(payload)), uint32(1+len(payload)))
+	packet = append(append(packet, typ), payload...)
+	fsys.wmu.Lock()
+	defer fsys.wmu.Unlock()
+	_, err := fsys.stdin.Write(packet)
+	return err
+}
+
+// dispatch раздаёт ответы ждущим их запросам, пока соединение живо
+func (fsys *sftpFS) dispatch(r io.Reader) {
+	for {
+		typ, data, err := readPacket(r)
+		if err == nil && len(data) < 4 {
+			err = errors.New("short sftp packet")
+		}
+		fsys.mu.Lock()
+		if err != nil {
+			if err == io.EOF {
+				err = errors.New("sftp connection closed")
+			}
+			fsys.err = err
+			for id, ch := range fsys.pending {
+				close(ch)
+				delete(fsys.pending, id)
+			}
+			fsys.mu.Unlock()
+			return
+		}
+		id := binary.BigEndian.Uint32(data)
+		ch := fsys.pending[id]
+		delete(fsys.pending, id)
+		fsys.mu.Unlock()
+		if ch != nil {
+			ch <- sftpPacket{typ: typ, data: data[4:]}
+		}
+	}
+}
+
+// start отправляет запрос и возвращает канал, в который придёт ответ (закрытый, если соединение оборвалось)
+func (fsys *sftpFS) start(typ byte, payload []byte) (<-chan sftpPacket, error) {
+	ch := make(chan sftpPacket, 1)
+	fsys.mu.Lock()
+	if fsys.err != nil {
+		fsys.mu.Unlock()
+		return nil, fsys.err
+	}
+	fsys.nextID++
+	id := fsys.nextID
+	fsys.pending[id] = ch
+	fsys.mu.Unlock()
+	if err := fsys.send(typ, append(binary.BigEndian.AppendUint32(nil, id), payload...)); err != nil {
+		return nil, err
+	}
+	return ch, nil
+}
+
+// wait ждёт ответа на запрос
+func (fsys *sftpFS) wait(ch <-chan sftpPacket) (sftpPacket, error) {
+	p, ok := <-ch
+	if !ok {
+		fsys.mu.Lock()
+		defer fsys.mu.Unlock()
+		return p, fsys.err
+	}
+	return p, nil
+}
+
+// call отправляет запрос и ждёт ответа
+func (fsys *sftpFS) call(typ byte, payload []byte) (sftpPacket, error) {
+	ch, err := fsys.start(typ, payload)
+	if err != nil {
+		return sftpPacket{}, err
+	}
+	return fsys.wait(ch)
+}
+
+// statusError переводит ответ STATUS в ошибку (nil для OK); коды 2 и 3 становятся fs.ErrNotExist и fs.ErrPermission
+func statusError(p sftpPacket) error {
+	if p.typ != fxpStatus {
+		return fmt.Errorf("unexpected sftp packet %d", p.typ)
+	}
+	b := sftpBuf(p.data)
+	code := b.uint32()
+	msg := b.string()
+	switch code {
+	case fxOK:
+		return nil
+	case fxEOF:
+		return io.EOF
+	case fxNoSuch:
+		return fs.ErrNotExist
+	case fxDenied:
+		return fs.ErrPermission
+	}
+	return fmt.Errorf("sftp: %s (code %d)", msg, code)
+}
+
+// sftpBuf разбирает данные пакета; на коротких данных возвращает нули, а не паникует
+type sftpBuf []byte
+
+func (b *sftpBuf) uint32() uint32 {
+	if len(*b) < 4 {
+		*b = nil
+		return 0
+	}
+	v := binary.BigEndian.Uint32(*b)
+	*b = (*b)[4:]
+	return v
+}
+
+func (b *sftpBuf) uint64() uint64 {
+	return uint64(b.uint32())<<32 | uint64(b.uint32())
+}
+
+func (b *sftpBuf) string() string {
+	n := b.uint32()
+	if uint32(len(*b)) < n {
+		*b = nil
+		return ""
+	}
+	s := string((*b)[:n])
+	*b = (*b)[n:]
+	return s
+}
+
+// attrs разбирает ATTRS в сведения о файле с именем name
+func (b *sftpBuf) attrs(name string) gitStat {
+	st := gitStat{name: name}
+	flags := b.uint32()
+	if flags&attrSize != 0 {
+		st.size = int64(b.uint64())
+	}
+	if flags&attrUIDGID != 0 {
+		b.uint32()
+		b.uint32()
+	}
+	if flags&attrPerms != 0 {
+		st.mode = posixMode(b.uint32())
+	}
+	if flags&attrTimes != 0 {
+		b.uint32() // atime
+		st.mtime = time.Unix(int64(b.uint32()), 0)
+	}
+	if flags&attrExtend != 0 {
+		for n := b.uint32(); n > 0 && len(*b) > 0; n-- {
+			b.string()
+			b.string()
+		}
+	}
+	return st
+}
+
+// posixMode переводит режим POSIX (st_mode) в fs.FileMode
+func posixMode(m uint32) fs.FileMode {
+	mode := fs.FileMode(m & 0o777)
+	switch m & 0o170000 {
+	case 0o040000:
+		mode |= fs.ModeDir
+	case 0o120000:
+		mode |= fs.ModeSymlink
+	case 0o010000:
+		mode |= fs.ModeNamedPipe
+	case 0o140000:
+		mode |= fs.ModeSocket
+	case 0o020000:
+		mode |= fs.ModeDevice | fs.ModeCharDevice
+	case 0o060000:
+		mode |= fs.ModeDevice
+	}
+	return mode
+}
+
+// sftpString кодирует строку протокола: длина и байты
+func sftpString(s string) []byte {
+	return append(binary.BigEndian.AppendUint32(nil, uint32(len(s))), s...)
+}
+
+// remote возвращает удалённый путь для имени из fs.FS
+func (fsys *sftpFS) remote(name string) string {
+	if name == "." {
+		return fsys.root
+	}
+	return path.Join(fsys.root, name)
+}
+
+func (fsys *sftpFS) Stat(name string) (fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrInvalid}
+	}
+	p, err := fsys.call(fxpStat, sftpString(fsys.remote(name)))
+	if err == nil && p.typ != fxpAttrs {
+		err = statusError(p)
+		if err == nil {
+			err = fmt.Errorf("unexpected sftp packet %d", p.typ)
+		}
+	}
+	if err != nil {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: err}
+	}
+	b := sftpBuf(p.data)
+	return b.attrs(path.Base(name)), nil
+}
+
+// handle открывает файл или директорию и возвращает handle сервера
+func (fsys *sftpFS) handle(typ byte, payload []byte) (string, error) {
+	p, err := fsys.call(typ, payload)
+	if err != nil {
+		return "", err
+	}
+	if p.typ != fxpHandle {
+		if err := statusError(p); err != nil {
+			return "", err
+		}
+		return "", fmt.Errorf("unexpected sftp packet %d", p.typ)
+	}
+	b := sftpBuf(p.data)
+	return b.string(), nil
+}
+
+// closeHandle закрывает handle, не дожидаясь ответа
+func (fsys *sftpFS) closeHandle(handle string) {
+	fsys.start(fxpClose, sftpString(handle))
+}
+
+func (fsys *sftpFS) Open(name string) (fs.File, error) {
+	info, err := fsys.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		entries, err := fsys.readDir(name)
+		if err != nil {
+			return nil, &fs.PathError{Op: "readdir", Path: name, Err: err}
+		}
+		return &gitDir{info: info.(gitStat), entries: entries}, nil
+	}
+	open := append(sftpString(fsys.remote(name)), 0, 0, 0, fxfRead, 0, 0, 0, 0) // флаги и пустые ATTRS
+	handle, err := fsys.handle(fxpOpen, open)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	return &sftpFile{fsys: fsys, handle: handle, info: info.(gitStat)}, nil
+}
+
+// readDir читает листинг директории; сведения о ссылках — о самих ссылках, как у os.ReadDir
+func (fsys *sftpFS) readDir(name string) ([]fs.DirEntry, error) {
+	handle, err := fsys.handle(fxpOpendir, sftpString(fsys.remote(name)))
+	if err != nil {
+		return nil, err
+	}
+	defer fsys.closeHandle(handle)
+	var entries []fs.DirEntry
+	for {
+		p, err := fsys.call(fxpReaddir, sftpString(handle))
+		if err != nil {
+			return entries, err
+		}
+		if p.typ != fxpName {
+			if err := statusError(p); err != nil && err != io.EOF {
+				return entries, err
+			}
+			return entries, nil
+		}
+		b := sftpBuf(p.data)
+		for n := b.uint32(); n > 0 && len(b) > 0; n-- {
+			child := b.string()
+			b.string() // longname, как у ls -l
+			st := b.attrs(child)
+			if child != "." && child != ".." {
+				entries = append(entries, fs.FileInfoToDirEntry(st))
+			}
+		}
+	}
+}
+
+// sftpFile — открытый удалённый файл; читается кусками по sftpChunk с упреждением:
+// окно запросов растёт вдвое с каждым прочитанным куском до fsys.requests,
+// чтобы на коротком чтении начала файла не тянуть лишнего, а большие файлы не ждали каждый кусок
+type sftpFile struct {
+	fsys   *sftpFS
+	handle string
+	info   gitStat
+	offset uint64              // с какого места просить следующий кусок
+	queue  []<-chan sftpPacket // запросы в полёте, по порядку
+	window int                 // сколько запросов держать в полёте
+	buf    []byte              // остаток полученного куска
+	err    error               // EOF или ошибка: дальше читать нечего
+}
+
+func (f *sftpFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+
+func (f *sftpFile) Read(p []byte) (int, error) {
+	for len(f.buf) == 0 {
+		if f.err != nil {
+			return 0, f.err
+		}
+		f.window = min(max(f.window*2, 1), f.fsys.requests)
+		for len(f.queue) < f.window {
+			req := append(sftpString(f.handle), binary.BigEndian.AppendUint64(nil, f.offset)...)
+			ch, err := f.fsys.start(fxpRead, binary.BigEndian.AppendUint32(req, sftpChunk))
+			if err != nil {
+				f.err = err
+				break
+			}
+			f.queue = append(f.queue, ch)
+			f.offset += sftpChunk
+		}
+		if len(f.queue) == 0 {
+			return 0, f.err
+		}
+		resp, err := f.fsys.wait(f.queue[0])
+		f.queue = f.queue[1:]
+		switch {
+		case err != nil:
+			f.err = err
+		case resp.typ == fxpData:
+			b := sftpBuf(resp.data)
+			f.buf = []byte(b.string())
+			// сервер может отдать меньше запрошенного не только в конце файла; тогда догоняем с нужного места
+			if len(f.buf) < sftpChunk {
+				f.offset -= uint64(len(f.queue)+1)*sftpChunk - uint64(len(f.buf))
+				f.queue = nil
+			}
+		default:
+			f.err = statusError(resp)
+			if f.err == nil {
+				f.err = fmt.Errorf("unexpected sftp packet %d", resp.typ)
+			}
+		}
+	}
+	n := copy(p, f.buf)
+	f.buf = f.buf[n:]
+	return n, nil
+}
+
+func (f *sftpFile) Close() error {
+	f.fsys.closeHandle(f.handle)
+	return nil
+}
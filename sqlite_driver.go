@@ -0,0 +1,6 @@
+//go:build (darwin && (amd64 || arm64)) || (freebsd && (386 || amd64 || arm || arm64)) || (linux && (386 || amd64 || arm || arm64 || loong64 || ppc64le || riscv64 || s390x)) || (openbsd && (amd64 || arm64)) || (windows && (386 || amd64 || arm64))
+
+package main
+
+// драйвер на чистом Go, cgo не нужен; собирается только на этих платформах, на остальных формата sqlite нет
+import _ "modernc.org/sqlite"
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/asquebay/directory-serialization/serializer"
+)
+
+// printStats печатает оценку размера вывода в токенах: всего и по ID языков, от больших к меньшим
+// древо и заголовки не считаются, только содержимое файлов
+func printStats(out io.Writer, r serializer.Report) {
+	fmt.Fprintf(out, "%d file(s) with contents, about %d tokens\n", r.Contents, r.Tokens)
+	langs := make([]string, 0, len(r.LangTokens))
+	for id := range r.LangTokens {
+		langs = append(langs, id)
+	}
+	sort.Slice(langs, func(i, j int) bool {
+		if r.LangTokens[langs[i]] != r.LangTokens[langs[j]] {
+			return r.LangTokens[langs[i]] > r.LangTokens[langs[j]]
+		}
+		return langs[i] < langs[j]
+	})
+	for _, id := range langs {
+		name := id
+		if name == "" {
+			name = "(unknown)"
+		}
+		fmt.Fprintf(out, "  %-16s %8d\n", name, r.LangTokens[id])
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+)
+
+// временные файлы (выгрузка docker save и распакованные слои образа) лежат в личной директории запуска:
+// имя у неё случайное, а права 0700, так что на общем CI-раннере соседние задачи не прочитают выгруженный код
+// и не подложат свой файл под ожидаемое имя; удаляется она целиком при любом выходе — обычном, по ошибке,
+// после паники в main и по Ctrl+C или SIGTERM; остаться она может только после SIGKILL или падения процесса
+
+// scratchDir — личная временная директория запуска
+type scratchDir struct {
+	mu   sync.Mutex
+	base string // в какой директории её создать (--temp-dir; пусто — os.TempDir(), то есть $TMPDIR)
+	path string // созданная директория; пусто — ещё не понадобилась
+}
+
+var scratch scratchDir
+
+// createTemp создаёт временный файл (права 0600) в личной директории, создавая её при первом вызове
+func (s *scratchDir) createTemp(pattern string) (*os.File, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.path == "" {
+		// MkdirTemp подбирает свободное случайное имя и создаёт директорию с правами 0700
+		dir, err := os.MkdirTemp(s.base, "directory-serialization-*")
+		if err != nil {
+			return nil, err
+		}
+		s.path = dir
+	}
+	return os.CreateTemp(s.path, pattern)
+}
+
+// remove удаляет личную директорию со всем содержимым
+func (s *scratchDir) remove() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.path != "" {
+		os.RemoveAll(s.path)
+		s.path = ""
+	}
+}
+
+// exit удаляет временные файлы и завершает программу; после того как они могли появиться, os.Exit напрямую не зовём
+func exit(code int) {
+	scratch.remove()
+	os.Exit(code)
+}
+
+// removeOnSignal удаляет временные файлы при Ctrl+C и SIGTERM и завершает программу с кодом 128+сигнал, как shell
+func removeOnSignal() {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, terminationSignals...)
+	go func() {
+		exit(signalExitCode(<-ch))
+	}()
+}
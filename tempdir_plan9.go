@@ -0,0 +1,12 @@
+package main
+
+import "os"
+
+// в Plan 9 вместо сигналов заметки (notes), SIGTERM нет: ловим только прерывание
+
+var terminationSignals = []os.Signal{os.Interrupt}
+
+// signalExitCode возвращает код выхода после прерывания
+func signalExitCode(os.Signal) int {
+	return 1
+}
@@ -0,0 +1,19 @@
+//go:build !plan9
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// terminationSignals — сигналы, по которым удаляются временные файлы
+var terminationSignals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+
+// signalExitCode возвращает код выхода 128+сигнал, как у shell
+func signalExitCode(sig os.Signal) int {
+	if s, ok := sig.(syscall.Signal); ok {
+		return 128 + int(s)
+	}
+	return 1
+}
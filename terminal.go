@@ -0,0 +1,24 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/asquebay/directory-serialization/serializer"
+)
+
+// isTerminal сообщает, выводится ли f в терминал
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// confirmRead спрашивает в терминале, читать ли содержимое, которое больше --confirm-over
+func confirmRead(size int64, files int) bool {
+	fmt.Fprintf(os.Stderr, "The dump would read about %s of contents from %d files. Continue? [y/N] ", serializer.FormatSize(size), files)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
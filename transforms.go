@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/asquebay/directory-serialization/serializer"
+)
+
+// правила --transform: по одному в строке, "шаблон преобразование [аргументы]"
+//
+//	*.ipynb  notebook
+//	*.go     strip-comments
+//	*        redact (?i)(api[_-]?key|password)\s*[:=]\s*\S+
+//	*.csv    head 20
+//
+// шаблон — как в path.Match, сверяется с путём от корня и с именем файла
+// правила применяются по порядку, до --summarize-docs, --head, --max-file-size и --wrap
+
+// parseTransforms разбирает правила --transform
+func parseTransforms(rules string) ([]serializer.Transformer, error) {
+	var steps []serializer.Transformer
+	sc := bufio.NewScanner(strings.NewReader(rules))
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("line %d: expected \"pattern transform [args]\", got %q", n, line)
+		}
+		pattern, name := fields[0], fields[1]
+		// аргумент — остаток строки после имени преобразования, чтобы в регулярках можно было писать пробелы
+		arg := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(strings.TrimPrefix(line, pattern)), name))
+		fn, err := transformFunc(name, arg)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+		steps = append(steps, serializer.ForFiles(pattern, fn))
+	}
+	return steps, sc.Err()
+}
+
+// transformFunc возвращает преобразование по имени из правила
+func transformFunc(name, arg string) (serializer.TransformFunc, error) {
+	switch name {
+	case "notebook":
+		return serializer.FlattenNotebook, nil
+	case "strip-comments":
+		return serializer.StripComments, nil
+	case "mask-env":
+		return serializer.MaskEnv, nil
+	case "redact":
+		re, err := regexp.Compile(arg)
+		if err != nil || arg == "" {
+			return nil, fmt.Errorf("redact needs a regular expression: %v", err)
+		}
+		return serializer.Redact(re), nil
+	case "replace":
+		// replace <regexp> <замена>: регулярка — первое слово, замена — остаток (может быть пустой)
+		expr, repl, _ := strings.Cut(arg, " ")
+		re, err := regexp.Compile(expr)
+		if err != nil || expr == "" {
+			return nil, fmt.Errorf("replace needs a regular expression: %v", err)
+		}
+		return serializer.Replace(re, strings.TrimSpace(repl), "replaced"), nil
+	case "head":
+		n, err := strconv.Atoi(arg)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("head needs a positive number of lines, got %q", arg)
+		}
+		return serializer.Head(n), nil
+	default:
+		return nil, fmt.Errorf("unknown transform %q (known: notebook, strip-comments, mask-env, redact, replace, head)", name)
+	}
+}
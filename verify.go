@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/asquebay/directory-serialization/format"
+	"github.com/asquebay/directory-serialization/serializer"
+)
+
+// runVerify реализует подкоманду verify: сверяет дамп с живой директорией
+// и сообщает о расхождениях (удалённые, изменённые файлы, смена типа)
+// содержимое есть в дампе только у текстовых файлов; бинарные и пропущенные без --manifest проверяются
+// только на существование, а с ним — по хешам из манифеста
+// возвращает код выхода: 0 — расхождений нет, 1 — есть расхождения, 2 — ошибка
+func runVerify(args []string) int {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	normalize := serializer.NormalizeKeep
+	fs.Func("normalize-names", "find files whose names on disk differ from the dump only in Unicode `form` (nfc or nfd)", func(s string) error {
+		normalize = s
+		return parseNormalizeForm(s)
+	})
+	manifestPath := fs.String("manifest", "", "also compare files by the hashes recorded in the manifest `file` written with the dump (--manifest), including binary and omitted ones")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: directory-serialization verify [flags] <dump> <directory>\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return 2
+	}
+	dumpPath, root := fs.Arg(0), fs.Arg(1)
+
+	f, err := os.Open(dumpPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error opening dump %s: %v\n", dumpPath, err)
+		return 2
+	}
+	dump, err := format.Parse(f)
+	f.Close()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error parsing dump %s: %v\n", dumpPath, err)
+		return 2
+	}
+
+	var hashes *verifyHashes
+	if *manifestPath != "" {
+		if hashes, err = loadVerifyHashes(*manifestPath); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 2
+		}
+	}
+
+	drift := 0
+	report := func(kind, path string) {
+		drift++
+		fmt.Printf("%-8s %s\n", kind, format.QuoteName(path))
+	}
+
+	// сначала структура: всё, что есть в древе, должно существовать и иметь тот же тип
+	for _, e := range dump.Entries {
+		info, err := os.Lstat(diskPath(root, e.Path, normalize))
+		switch {
+		case os.IsNotExist(err):
+			report("MISSING", e.Path)
+		case err != nil:
+			fmt.Fprintf(os.Stderr, "Error accessing %s: %v\n", e.Path, err)
+			report("ERROR", e.Path)
+		case info.IsDir() != e.IsDir:
+			report("TYPE", e.Path)
+		}
+	}
+
+	// затем содержимое текстовых файлов
+	compared := make(map[string]bool) // файлы, содержимое которых сверено целиком
+	for _, file := range dump.Files {
+		data, err := os.ReadFile(diskPath(root, file.Path, normalize))
+		if err != nil {
+			// отсутствие файла уже учтено при проверке древа
+			if !os.IsNotExist(err) {
+				fmt.Fprintf(os.Stderr, "Error reading %s: %v\n", file.Path, err)
+				report("ERROR", file.Path)
+			}
+			compared[file.Path] = true
+			continue
+		}
+		// у обрезанного файла в дампе только начало, его и сравниваем (а остальное — по хешу, если он есть)
+		if file.Truncated && !bytes.HasPrefix(data, file.Content) || !file.Truncated && !bytes.Equal(data, file.Content) {
+			report("CHANGED", file.Path)
+			compared[file.Path] = true
+		} else if !file.Truncated {
+			compared[file.Path] = true
+		}
+	}
+
+	// остальные файлы (бинарные, пропущенные, обрезанные) — по хешам манифеста; без них содержимое не сверено
+	byHash, unchecked := 0, 0
+	for _, e := range dump.Entries {
+		if e.IsDir || compared[e.Path] {
+			continue
+		}
+		want, ok := hashes.lookup(e.Path)
+		if !ok {
+			unchecked++
+			continue
+		}
+		got, err := hashes.hashFile(diskPath(root, e.Path, normalize))
+		switch {
+		case os.IsNotExist(err):
+			// уже MISSING
+		case err != nil:
+			fmt.Fprintf(os.Stderr, "Error reading %s: %v\n", e.Path, err)
+			report("ERROR", e.Path)
+		case got != want:
+			report("CHANGED", e.Path)
+		default:
+			byHash++
+		}
+	}
+
+	if drift > 0 {
+		fmt.Fprintf(os.Stderr, "%d difference(s) between %s and %s\n", drift, dumpPath, root)
+		return 1
+	}
+	fmt.Fprintf(os.Stderr, "OK: %d entries exist, %d file(s) match by contents", len(dump.Entries), len(compared))
+	if hashes != nil {
+		fmt.Fprintf(os.Stderr, ", %d by manifest hash", byHash)
+	}
+	fmt.Fprintln(os.Stderr)
+	if unchecked > 0 {
+		hint := " (use --manifest to compare them by hash)"
+		if hashes != nil {
+			hint = " (the manifest has no hash for them)"
+		}
+		fmt.Fprintf(os.Stderr, "%d file(s) without contents in the dump were only checked for existence%s\n", unchecked, hint)
+	}
+	return 0
+}
+
+// verifyHashes — хеши файлов из манифеста и алгоритм, которым они посчитаны
+type verifyHashes struct {
+	algo   string
+	hashes map[string]string // путь → хеш в hex
+}
+
+// loadVerifyHashes читает хеши из манифеста (поле sha256 или, с --hash-algo, hash)
+func loadVerifyHashes(path string) (*verifyHashes, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var m struct {
+		HashAlgo string `json:"hash_algorithm"`
+		Files    []struct {
+			Path    string `json:"path"`
+			RawPath []byte `json:"raw_path"`
+			SHA256  string `json:"sha256"`
+			Hash    string `json:"hash"`
+		} `json:"files"`
+	}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("parsing manifest %s: %v", path, err)
+	}
+	h := &verifyHashes{algo: m.HashAlgo, hashes: make(map[string]string, len(m.Files))}
+	for _, f := range m.Files {
+		if f.RawPath != nil {
+			f.Path = string(f.RawPath)
+		}
+		switch {
+		case f.Hash != "":
+			h.hashes[f.Path] = f.Hash
+		case f.SHA256 != "":
+			h.hashes[f.Path] = f.SHA256
+		}
+	}
+	return h, nil
+}
+
+// lookup возвращает хеш файла из манифеста; без манифеста хешей нет
+func (h *verifyHashes) lookup(path string) (string, bool) {
+	if h == nil {
+		return "", false
+	}
+	sum, ok := h.hashes[path]
+	return sum, ok
+}
+
+// hashFile хеширует файл на диске тем же алгоритмом, что в манифесте
+func (h *verifyHashes) hashFile(name string) (string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	return serializer.HashReader(h.algo, f)
+}
@@ -0,0 +1,598 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/asquebay/directory-serialization/format"
+	"github.com/asquebay/directory-serialization/serializer"
+)
+
+// runView реализует подкоманду view: открывает дамп в терминале — древо слева, содержимое файла справа,
+// с поиском по путям и содержимому и переходом к пути, так что большой дамп можно смотреть, не распаковывая
+// возвращает код выхода: 0 — просмотр закрыт, 2 — ошибка
+func runView(args []string) int {
+	fs := flag.NewFlagSet("view", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: directory-serialization view <dump>\n\n%s", viewHelp)
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+	dumpPath := fs.Arg(0)
+	if !isTerminal(os.Stdin) || !isTerminal(os.Stdout) {
+		fmt.Fprintln(os.Stderr, "Error: view needs an interactive terminal")
+		return 2
+	}
+
+	f, err := os.Open(dumpPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error opening dump %s: %v\n", dumpPath, err)
+		return 2
+	}
+	dump, err := format.Parse(f)
+	f.Close()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error parsing dump %s: %v\n", dumpPath, err)
+		return 2
+	}
+
+	restore, err := rawTerminal(os.Stdin)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 2
+	}
+	defer restore()
+	out := bufio.NewWriter(os.Stdout)
+	// альтернативный экран: после выхода терминал остаётся таким, каким был
+	fmt.Fprint(out, "\x1b[?1049h\x1b[?25l")
+	defer func() {
+		fmt.Fprint(out, "\x1b[?25h\x1b[?1049l")
+		out.Flush()
+	}()
+
+	v := newViewer(dump, dumpPath)
+	keys := make(chan []byte)
+	go func() {
+		buf := make([]byte, 256)
+		for {
+			n, err := os.Stdin.Read(buf)
+			if err != nil {
+				close(keys)
+				return
+			}
+			keys <- append([]byte(nil), buf[:n]...)
+		}
+	}()
+	resize := make(chan os.Signal, 1)
+	notifyResize(resize)
+
+	for {
+		v.width, v.height, err = terminalSize(os.Stdout)
+		if err != nil || v.width == 0 || v.height == 0 {
+			v.width, v.height = 80, 24
+		}
+		v.draw(out)
+		out.Flush()
+		select {
+		case data, ok := <-keys:
+			if !ok {
+				return 0
+			}
+			for _, key := range parseKeys(data) {
+				if !v.handle(key) {
+					return 0
+				}
+			}
+		case <-resize:
+		}
+	}
+}
+
+const viewHelp = `Keys:
+  Up/Down, j/k, PgUp/PgDn, Home/End   move in the tree or scroll the content
+  Enter, Right/l                      open a directory or show the file content
+  Left/h                              close a directory or go to its parent
+  Tab                                 switch between the tree and the content
+  /                                   search paths and content (lowercase query ignores case)
+  n                                   next match
+  g                                   go to a path
+  q, Ctrl-C                           quit
+`
+
+// viewRow — строка древа в просмотрщике
+type viewRow struct {
+	path  string
+	depth int
+	isDir bool
+}
+
+// viewer — состояние просмотрщика
+type viewer struct {
+	name      string // имя дампа для строки состояния
+	root      string
+	rows      []viewRow
+	files     map[string]format.File
+	collapsed map[string]bool // свёрнутые директории
+	visible   []int           // индексы rows, которые сейчас видны (внутри свёрнутых не видно)
+
+	sel, treeTop int      // выбранная строка visible и первая показанная
+	lines        []string // строки выбранного файла, уже в виде для экрана
+	linesOf      string   // чьи строки в lines
+	top, left    int      // первая показанная строка содержимого и сдвиг вправо
+	hit          int      // строка последнего совпадения поиска в выбранном файле, -1 — нет
+	focusContent bool
+
+	prompt string // приглашение ввода внизу ("/" или "Go to: "), пусто — ввода нет
+	input  []rune
+	query  string // последний поиск
+	status string // сообщение в строке состояния до следующей клавиши
+
+	width, height int
+}
+
+func newViewer(dump *format.Dump, name string) *viewer {
+	v := &viewer{name: name, root: dump.Root, files: make(map[string]format.File), collapsed: make(map[string]bool), hit: -1}
+	for _, e := range dump.Entries {
+		v.rows = append(v.rows, viewRow{path: e.Path, depth: strings.Count(e.Path, "/"), isDir: e.IsDir})
+	}
+	for _, file := range dump.Files {
+		v.files[file.Path] = file
+	}
+	v.layout()
+	v.loadFile()
+	return v
+}
+
+// layout пересчитывает видимые строки древа
+func (v *viewer) layout() {
+	v.visible = v.visible[:0]
+	hidden := "" // префикс свёрнутой директории, внутри которой мы сейчас
+	for i, row := range v.rows {
+		if hidden != "" && strings.HasPrefix(row.path, hidden) {
+			continue
+		}
+		hidden = ""
+		v.visible = append(v.visible, i)
+		if row.isDir && v.collapsed[row.path] {
+			hidden = row.path + "/"
+		}
+	}
+	v.sel = min(v.sel, max(len(v.visible)-1, 0))
+}
+
+// current возвращает выбранную строку древа
+func (v *viewer) current() (viewRow, bool) {
+	if len(v.visible) == 0 {
+		return viewRow{}, false
+	}
+	return v.rows[v.visible[v.sel]], true
+}
+
+// selectRow выбирает строку rows[i], раскрывая директории над ней
+func (v *viewer) selectRow(i int) {
+	p := v.rows[i].path
+	for dir := range v.collapsed {
+		if strings.HasPrefix(p, dir+"/") {
+			delete(v.collapsed, dir)
+		}
+	}
+	v.layout()
+	for j, idx := range v.visible {
+		if idx == i {
+			v.sel = j
+		}
+	}
+	v.loadFile()
+}
+
+// loadFile готовит строки выбранного файла для экрана, если выбран другой файл
+func (v *viewer) loadFile() {
+	row, ok := v.current()
+	if !ok || row.path == v.linesOf {
+		return
+	}
+	v.linesOf, v.lines, v.top, v.left, v.hit = row.path, nil, 0, 0, -1
+	file, ok := v.files[row.path]
+	if !ok {
+		return
+	}
+	for _, line := range strings.Split(strings.TrimSuffix(string(file.Content), "\n"), "\n") {
+		line = string(serializer.SanitizeControls([]byte(strings.TrimSuffix(line, "\r"))))
+		v.lines = append(v.lines, expandTabs(strings.ToValidUTF8(line, string(utf8.RuneError))))
+	}
+}
+
+func expandTabs(s string) string {
+	if !strings.Contains(s, "\t") {
+		return s
+	}
+	var b strings.Builder
+	col := 0
+	for _, r := range s {
+		if r == '\t' {
+			n := 4 - col%4
+			b.WriteString(strings.Repeat(" ", n))
+			col += n
+			continue
+		}
+		b.WriteRune(r)
+		col++
+	}
+	return b.String()
+}
+
+// panes возвращает ширину древа и высоту областей без строки состояния
+func (v *viewer) panes() (int, int) {
+	return min(40, max(v.width/3, 10)), max(v.height-1, 1)
+}
+
+// handle обрабатывает клавишу; false — выйти
+func (v *viewer) handle(key string) bool {
+	v.status = ""
+	if v.prompt != "" {
+		v.edit(key)
+		return true
+	}
+	_, h := v.panes()
+	switch key {
+	case "q", "ctrl-c":
+		return false
+	case "tab":
+		v.focusContent = !v.focusContent
+	case "/":
+		v.prompt, v.input = "/", nil
+	case "g":
+		v.prompt, v.input = "Go to: ", nil
+	case "n":
+		v.search()
+	case "enter", "right", "l":
+		row, ok := v.current()
+		switch {
+		case !ok:
+		case row.isDir:
+			delete(v.collapsed, row.path)
+			v.layout()
+		case key == "enter" || !v.focusContent:
+			v.focusContent = true
+		default:
+			v.left += 8
+		}
+	case "left", "h":
+		row, ok := v.current()
+		switch {
+		case v.focusContent:
+			v.left = max(v.left-8, 0)
+		case !ok:
+		case row.isDir && !v.collapsed[row.path]:
+			v.collapsed[row.path] = true
+			v.layout()
+		case row.depth > 0:
+			// к родительской директории
+			parent := row.path[:strings.LastIndex(row.path, "/")]
+			for i, r := range v.rows {
+				if r.path == parent {
+					v.selectRow(i)
+				}
+			}
+		}
+	case "up", "k":
+		v.move(-1)
+	case "down", "j":
+		v.move(1)
+	case "pgup":
+		v.move(-h + 1)
+	case "pgdn":
+		v.move(h - 1)
+	case "home":
+		v.move(-len(v.rows) - len(v.lines))
+	case "end":
+		v.move(len(v.rows) + len(v.lines))
+	}
+	return true
+}
+
+// move сдвигает выбор в древе или прокручивает содержимое
+func (v *viewer) move(delta int) {
+	_, h := v.panes()
+	if v.focusContent {
+		v.top = max(min(v.top+delta, len(v.lines)-h), 0)
+		return
+	}
+	v.sel = max(min(v.sel+delta, len(v.visible)-1), 0)
+	v.loadFile()
+}
+
+// edit обрабатывает клавишу в строке ввода
+func (v *viewer) edit(key string) {
+	switch key {
+	case "esc", "ctrl-c":
+		v.prompt = ""
+	case "backspace":
+		if len(v.input) > 0 {
+			v.input = v.input[:len(v.input)-1]
+		}
+	case "enter":
+		prompt, text := v.prompt, string(v.input)
+		v.prompt = ""
+		if text == "" {
+			return
+		}
+		if prompt == "/" {
+			v.query, v.hit = text, -1
+			v.search()
+		} else {
+			v.goTo(text)
+		}
+	default:
+		if r, size := utf8.DecodeRuneInString(key); size == len(key) && unicode.IsPrint(r) {
+			v.input = append(v.input, r)
+		}
+	}
+}
+
+// matches ищет запрос в строке: запрос из строчных букв — без учёта регистра (как smartcase в vim)
+func (v *viewer) matches(s string) bool {
+	if strings.ToLower(v.query) == v.query {
+		s = strings.ToLower(s)
+	}
+	return strings.Contains(s, v.query)
+}
+
+// search переходит к следующему совпадению запроса после текущего: к пути или строке содержимого
+func (v *viewer) search() {
+	if v.query == "" || len(v.visible) == 0 {
+		v.status = "No search yet: press /"
+		return
+	}
+	start := v.visible[v.sel]
+	startLine := v.top
+	if v.hit >= 0 {
+		startLine = v.hit + 1
+	}
+	for k := 0; k <= len(v.rows); k++ {
+		i := (start + k) % len(v.rows)
+		row := v.rows[i]
+		from, to := 0, -1
+		switch {
+		case k == 0:
+			from = startLine
+		case k == len(v.rows):
+			// круг замкнулся: остались строки выбранного файла до текущей
+			to = startLine
+		case v.matches(row.path):
+			v.selectRow(i)
+			v.focusContent = false
+			return
+		}
+		if file, ok := v.files[row.path]; ok && !row.isDir {
+			lines := strings.Split(string(file.Content), "\n")
+			if to < 0 || to > len(lines) {
+				to = len(lines)
+			}
+			for n := from; n < to; n++ {
+				if v.matches(lines[n]) {
+					v.selectRow(i)
+					_, h := v.panes()
+					v.hit, v.focusContent = n, true
+					v.top = max(min(n-h/3, len(v.lines)-h), 0)
+					return
+				}
+			}
+		}
+	}
+	v.status = "Not found: " + v.query
+}
+
+// goTo выбирает путь: точное совпадение, затем путь, оканчивающийся на введённый, затем содержащий его
+func (v *viewer) goTo(p string) {
+	p = strings.Trim(strings.TrimPrefix(p, "./"), "/")
+	p = strings.TrimPrefix(p, v.root+"/")
+	best, rank := -1, 3
+	for i, row := range v.rows {
+		r := 3
+		switch {
+		case row.path == p:
+			r = 0
+		case strings.HasSuffix(row.path, "/"+p):
+			r = 1
+		case strings.Contains(row.path, p):
+			r = 2
+		}
+		if r < rank {
+			best, rank = i, r
+		}
+	}
+	if best < 0 {
+		v.status = "No such path: " + p
+		return
+	}
+	v.selectRow(best)
+	v.focusContent = false
+}
+
+// draw рисует экран целиком
+func (v *viewer) draw(out *bufio.Writer) {
+	treeW, h := v.panes()
+	contentW := max(v.width-treeW-1, 1)
+	// выбранная строка всегда на экране
+	v.treeTop = min(max(v.treeTop, v.sel-h+1), v.sel)
+	row, _ := v.current()
+	file, hasFile := v.files[row.path]
+
+	gutter := len(fmt.Sprint(len(v.lines))) + 1
+	for y := 0; y < h; y++ {
+		fmt.Fprintf(out, "\x1b[%d;1H", y+1)
+		// древо
+		if i := v.treeTop + y; i < len(v.visible) {
+			r := v.rows[v.visible[i]]
+			name := format.QuoteName(r.path[strings.LastIndex(r.path, "/")+1:])
+			mark := "  "
+			if r.isDir {
+				name += "/"
+				mark = "▾ "
+				if v.collapsed[r.path] {
+					mark = "▸ "
+				}
+			}
+			cell := fit(strings.Repeat("  ", r.depth)+mark+name, treeW)
+			if i == v.sel {
+				// выбор ярче, когда в фокусе древо
+				style := "\x1b[7m"
+				if v.focusContent {
+					style = "\x1b[4m"
+				}
+				cell = style + cell + "\x1b[0m"
+			}
+			out.WriteString(cell)
+		} else {
+			out.WriteString(strings.Repeat(" ", treeW))
+		}
+		out.WriteString("\x1b[2m│\x1b[0m")
+		// содержимое
+		n := v.top + y
+		switch {
+		case !hasFile && y == 0 && !row.isDir && row.path != "":
+			out.WriteString(fit(" (content is not in the dump)", contentW))
+		case hasFile && n < len(v.lines):
+			num := fmt.Sprintf("%*d ", gutter, n+1)
+			if n == v.hit {
+				num = "\x1b[7m" + num + "\x1b[0m"
+			} else {
+				num = "\x1b[2m" + num + "\x1b[0m"
+			}
+			out.WriteString(num)
+			out.WriteString(v.highlight(fit(skipRunes(v.lines[n], v.left), max(contentW-gutter-1, 0))))
+		}
+		out.WriteString("\x1b[K")
+	}
+
+	// строка состояния или ввода
+	fmt.Fprintf(out, "\x1b[%d;1H\x1b[K", h+1)
+	if v.prompt != "" {
+		fmt.Fprintf(out, "%s%s\x1b[7m \x1b[0m", v.prompt, string(v.input))
+		return
+	}
+	left := v.name + ": " + format.QuoteName(v.root+"/"+row.path)
+	if row.isDir {
+		left += "/"
+	}
+	var right string
+	switch {
+	case v.status != "":
+		right = v.status
+	case hasFile && len(v.lines) > 0:
+		right = fmt.Sprintf("lines %d-%d of %d", v.top+1, min(v.top+h, len(v.lines)), len(v.lines))
+		if file.Truncated {
+			right += " (truncated in the dump)"
+		}
+	}
+	right += "  Tab / n g q"
+	out.WriteString("\x1b[7m" + fit(left, max(v.width-utf8.RuneCountInString(right), 0)) + right + "\x1b[0m")
+}
+
+// fit обрезает строку до width символов или дополняет пробелами
+func fit(s string, width int) string {
+	n := utf8.RuneCountInString(s)
+	if n > width {
+		r := []rune(s)
+		return string(r[:width])
+	}
+	return s + strings.Repeat(" ", width-n)
+}
+
+func skipRunes(s string, n int) string {
+	for ; n > 0 && s != ""; n-- {
+		_, size := utf8.DecodeRuneInString(s)
+		s = s[size:]
+	}
+	return s
+}
+
+// highlight выделяет совпадения запроса в видимой части строки
+func (v *viewer) highlight(s string) string {
+	if v.query == "" {
+		return s
+	}
+	hay, needle := []rune(s), []rune(v.query)
+	fold := strings.ToLower(v.query) == v.query
+	var b strings.Builder
+	for i := 0; i < len(hay); {
+		if runesAt(hay, i, needle, fold) {
+			b.WriteString("\x1b[7m" + string(hay[i:i+len(needle)]) + "\x1b[27m")
+			i += len(needle)
+			continue
+		}
+		b.WriteRune(hay[i])
+		i++
+	}
+	return b.String()
+}
+
+func runesAt(hay []rune, i int, needle []rune, fold bool) bool {
+	if i+len(needle) > len(hay) {
+		return false
+	}
+	for j, r := range needle {
+		h := hay[i+j]
+		if fold {
+			h = unicode.ToLower(h)
+		}
+		if h != r {
+			return false
+		}
+	}
+	return true
+}
+
+// parseKeys разбирает прочитанное из терминала на клавиши: печатные символы как есть,
+// остальные — именами ("up", "pgdn", "enter", "esc"...)
+func parseKeys(data []byte) []string {
+	var keys []string
+	for len(data) > 0 {
+		switch b := data[0]; {
+		case b == 0x1b && len(data) > 2 && (data[1] == '[' || data[1] == 'O'):
+			// CSI: параметры из цифр и ';', затем последний байт
+			end := 2
+			for end < len(data) && (data[end] >= '0' && data[end] <= '9' || data[end] == ';') {
+				end++
+			}
+			if end == len(data) {
+				return keys
+			}
+			keys = append(keys, csiKeys[string(data[2:end+1])])
+			data = data[end+1:]
+			continue
+		case b == 0x1b:
+			keys = append(keys, "esc")
+		case b == '\r' || b == '\n':
+			keys = append(keys, "enter")
+		case b == '\t':
+			keys = append(keys, "tab")
+		case b == 0x7f || b == 0x08:
+			keys = append(keys, "backspace")
+		case b == 0x03:
+			keys = append(keys, "ctrl-c")
+		case b >= 0x20:
+			r, size := utf8.DecodeRune(data)
+			keys = append(keys, string(r))
+			data = data[size:]
+			continue
+		}
+		data = data[1:]
+	}
+	return keys
+}
+
+var csiKeys = map[string]string{
+	"A": "up", "B": "down", "C": "right", "D": "left",
+	"H": "home", "F": "end", "1~": "home", "7~": "home", "4~": "end", "8~": "end",
+	"5~": "pgup", "6~": "pgdn",
+}
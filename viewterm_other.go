@@ -0,0 +1,19 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd)
+
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+// rawTerminal на этой платформе терминал в посимвольный режим не переводит
+func rawTerminal(*os.File) (func(), error) {
+	return nil, errors.New("view needs a Unix terminal")
+}
+
+func terminalSize(*os.File) (int, int, error) {
+	return 0, 0, errors.New("view needs a Unix terminal")
+}
+
+func notifyResize(chan<- os.Signal) {}
@@ -0,0 +1,146 @@
+//go:build js && wasm
+
+package main
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"syscall/js"
+	"time"
+)
+
+// jsFS — fs.FS поверх JS-объекта {"путь/к/файлу": {data: Uint8Array, mtime: миллисекунды}}, который собирает страница
+// браузер отдаёт содержимое файлов только асинхронно, поэтому страница читает их заранее,
+// а в память Go каждый файл копируется только при открытии
+type jsFS struct {
+	files map[string]jsFile
+	dirs  map[string][]string // имена детей каждой директории (корень — ".")
+}
+
+// jsFile — файл на стороне JS
+type jsFile struct {
+	data  js.Value
+	size  int64
+	mtime time.Time
+}
+
+// newJSFS строит индекс директорий по путям файлов
+func newJSFS(files js.Value) *jsFS {
+	fsys := &jsFS{files: make(map[string]jsFile), dirs: map[string][]string{".": nil}}
+	keys := js.Global().Get("Object").Call("keys", files)
+	for i := range keys.Length() {
+		name := keys.Index(i).String()
+		if !fs.ValidPath(name) || name == "." {
+			continue
+		}
+		entry := files.Get(name)
+		data := entry.Get("data")
+		file := jsFile{data: data, size: int64(data.Get("length").Int())}
+		if mtime := entry.Get("mtime"); mtime.Truthy() {
+			file.mtime = time.UnixMilli(int64(mtime.Float()))
+		}
+		fsys.files[name] = file
+		// добавляем файл и недостающие директории над ним
+		for child, dir := name, path.Dir(name); ; child, dir = dir, path.Dir(dir) {
+			_, known := fsys.dirs[dir]
+			fsys.dirs[dir] = append(fsys.dirs[dir], path.Base(child))
+			if known || dir == "." {
+				break
+			}
+		}
+	}
+	for _, names := range fsys.dirs {
+		sort.Strings(names)
+	}
+	return fsys
+}
+
+func (fsys *jsFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	if names, ok := fsys.dirs[name]; ok {
+		entries := make([]fs.DirEntry, len(names))
+		for i, child := range names {
+			entries[i] = fs.FileInfoToDirEntry(fsys.stat(path.Join(name, child)))
+		}
+		return &jsDir{info: fsys.stat(name), entries: entries}, nil
+	}
+	file, ok := fsys.files[name]
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	data := make([]byte, file.size)
+	js.CopyBytesToGo(data, file.data)
+	return &jsOpenFile{Reader: bytes.NewReader(data), info: fsys.stat(name)}, nil
+}
+
+// stat возвращает сведения о файле или директории, которые точно есть в индексе
+func (fsys *jsFS) stat(name string) jsStat {
+	if _, ok := fsys.dirs[name]; ok {
+		return jsStat{name: path.Base(name), dir: true}
+	}
+	file := fsys.files[name]
+	return jsStat{name: path.Base(name), size: file.size, mtime: file.mtime}
+}
+
+// jsStat — fs.FileInfo для jsFS
+type jsStat struct {
+	name  string
+	size  int64
+	mtime time.Time
+	dir   bool
+}
+
+func (s jsStat) Name() string       { return s.name }
+func (s jsStat) Size() int64        { return s.size }
+func (s jsStat) ModTime() time.Time { return s.mtime }
+func (s jsStat) IsDir() bool        { return s.dir }
+func (s jsStat) Sys() any           { return nil }
+
+func (s jsStat) Mode() fs.FileMode {
+	if s.dir {
+		return fs.ModeDir | 0o555
+	}
+	return 0o444
+}
+
+// jsOpenFile — открытый файл (содержимое уже скопировано из JS)
+type jsOpenFile struct {
+	*bytes.Reader
+	info jsStat
+}
+
+func (f *jsOpenFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *jsOpenFile) Close() error               { return nil }
+
+// jsDir — открытая директория
+type jsDir struct {
+	info    jsStat
+	entries []fs.DirEntry
+	offset  int
+}
+
+func (d *jsDir) Stat() (fs.FileInfo, error) { return d.info, nil }
+func (d *jsDir) Close() error               { return nil }
+
+func (d *jsDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.info.name, Err: fs.ErrInvalid}
+}
+
+func (d *jsDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	rest := d.entries[d.offset:]
+	if n <= 0 {
+		d.offset = len(d.entries)
+		return rest, nil
+	}
+	if len(rest) == 0 {
+		return nil, io.EOF
+	}
+	rest = rest[:min(n, len(rest))]
+	d.offset += len(rest)
+	return rest, nil
+}
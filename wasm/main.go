@@ -0,0 +1,75 @@
+//go:build js && wasm
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"syscall/js"
+
+	"github.com/asquebay/directory-serialization/serializer"
+)
+
+// сборка для браузера: страница (index.html) собирает перетащенную папку в JS-объект и вызывает
+//
+//	serializeDirectory(rootName, files, options) → {output, error, code, files, contents, errors}
+//
+// options — подмножество serializer.Options: format, head, maxFileSize, fileIDs, fenceLang, groupBy
+//
+// сборка: GOOS=js GOARCH=wasm go build -o wasm/serializer.wasm ./wasm
+// и cp "$(go env GOROOT)/lib/wasm/wasm_exec.js" wasm/
+
+func main() {
+	js.Global().Set("serializeDirectory", js.FuncOf(serializeDirectory))
+	// держим программу живой, пока открыта страница
+	select {}
+}
+
+func serializeDirectory(_ js.Value, args []js.Value) any {
+	if len(args) < 2 {
+		return js.ValueOf(map[string]any{"error": "usage: serializeDirectory(rootName, files, options)"})
+	}
+	var opts serializer.Options
+	if len(args) > 2 && args[2].Truthy() {
+		o := args[2]
+		if v := o.Get("format"); v.Truthy() {
+			opts.Format = v.String()
+		}
+		if v := o.Get("head"); v.Truthy() {
+			opts.HeadLines = v.Int()
+		}
+		if v := o.Get("maxFileSize"); v.Truthy() {
+			opts.MaxFileSize = int64(v.Float())
+		}
+		if v := o.Get("groupBy"); v.Truthy() {
+			opts.GroupBy = v.String()
+		}
+		opts.FileIDs = o.Get("fileIDs").Truthy()
+		opts.FenceLang = o.Get("fenceLang").Truthy()
+	}
+	if !serializer.Streams(opts.Format) {
+		return js.ValueOf(map[string]any{"error": "format " + opts.Format + " needs a file system to write to"})
+	}
+
+	var out bytes.Buffer
+	report, err := serializer.RunFS(&out, newJSFS(args[1]), args[0].String(), opts)
+	if err != nil {
+		// код ошибки — для обёрток, которым текст сообщения не годится
+		result := map[string]any{"error": err.Error()}
+		var e *serializer.Error
+		if errors.As(err, &e) {
+			result["code"] = string(e.Code)
+		}
+		return js.ValueOf(result)
+	}
+	errs := make([]any, len(report.Errors))
+	for i, e := range report.Errors {
+		errs[i] = e.Error()
+	}
+	return js.ValueOf(map[string]any{
+		"output":   out.String(),
+		"files":    report.Files,
+		"contents": report.Contents,
+		"errors":   errs,
+	})
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/asquebay/directory-serialization/format"
+	"github.com/asquebay/directory-serialization/serializer"
+)
+
+// runRestoreXattrs реализует подкоманду restore-xattrs: выставляет файлам директории расширенные атрибуты и ACL,
+// записанные в манифест с --xattrs (например, после распаковки бэкапа или клонирования)
+// возвращает код выхода: 0 — всё выставлено, 1 — часть атрибутов выставить не удалось, 2 — ошибка
+func runRestoreXattrs(args []string) int {
+	fs := flag.NewFlagSet("restore-xattrs", flag.ExitOnError)
+	normalize := serializer.NormalizeKeep
+	fs.Func("normalize-names", "find files whose names on disk differ from the manifest only in Unicode `form` (nfc or nfd)", func(s string) error {
+		normalize = s
+		return parseNormalizeForm(s)
+	})
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: directory-serialization restore-xattrs [flags] <manifest> <directory>\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return 2
+	}
+	manifestPath, root := fs.Arg(0), fs.Arg(1)
+
+	data, err := os.ReadFile(manifestPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading manifest %s: %v\n", manifestPath, err)
+		return 2
+	}
+	// из манифеста нужны только пути и атрибуты
+	var m struct {
+		Files []struct {
+			Path    string            `json:"path"`
+			RawPath []byte            `json:"raw_path"`
+			Xattrs  map[string][]byte `json:"xattrs"`
+		} `json:"files"`
+	}
+	if err := json.Unmarshal(data, &m); err != nil {
+		fmt.Fprintf(os.Stderr, "Error parsing manifest %s: %v\n", manifestPath, err)
+		return 2
+	}
+
+	restored, failed := 0, 0
+	for _, file := range m.Files {
+		if len(file.Xattrs) == 0 {
+			continue
+		}
+		path := file.Path
+		if file.RawPath != nil {
+			path = string(file.RawPath)
+		}
+		if err := serializer.WriteXattrs(diskPath(root, path, normalize), file.Xattrs); err != nil {
+			fmt.Fprintf(os.Stderr, "Error restoring attributes of %s: %v\n", format.QuoteName(path), err)
+			failed++
+			continue
+		}
+		restored++
+	}
+	fmt.Fprintf(os.Stderr, "Restored attributes of %d file(s)\n", restored)
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}